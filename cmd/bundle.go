@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/state"
+	"github.com/container-registry/harbor-satellite/internal/utils"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"golang.org/x/sync/errgroup"
+)
+
+const bundleUsage = `usage: satellite bundle export --output <file> [flags]
+       satellite bundle import --input <file> [flags]
+
+export pulls the satellite state, its group and config states and every image
+of the groups from Harbor into an OCI image layout tarball. import checks a
+bundle and syncs the satellite from it as if it had been fetched from Harbor.
+Run import while the satellite is stopped: it starts the embedded registry
+itself, or uses the registry configured with --byo-registry.`
+
+// registryStartTimeout bounds the wait for the local registry on import.
+const registryStartTimeout = 30 * time.Second
+
+// runBundle runs the "bundle" subcommand with the arguments that follow it.
+// Both directions use the config the satellite persisted in its config
+// directory, so the satellite must have registered with Ground Control once.
+func runBundle(args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return errors.New(bundleUsage)
+	}
+
+	fs := flag.NewFlagSet("bundle "+args[0], flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(fs.Output(), bundleUsage) }
+	configDir := fs.String("config-dir", os.Getenv("CONFIG_DIR"), "Configuration directory path (default: ~/.config/satellite)")
+	registryDataDir := fs.String("registry-data-dir", os.Getenv(config.RegistryDataDirEnvVar), "Registry data directory (overrides default storage path derived from config-dir)")
+	useUnsecure := fs.Bool("use-unsecure", os.Getenv("USE_UNSECURE") == "true", "Use insecure (HTTP) connections to registries")
+	jsonLogging := fs.Bool("json-logging", false, "Enable JSON logging")
+	harborRegistryURL := fs.String("harbor-registry-url", os.Getenv("HARBOR_REGISTRY_URL"), "Override Harbor registry URL from Ground Control (export only)")
+	output := fs.String("output", "", "Path of the bundle to write (export only)")
+	input := fs.String("input", "", "Path of the bundle to read (import only)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *configDir == "" {
+		dir, err := config.DefaultConfigDir()
+		if err != nil {
+			return fmt.Errorf("resolve default config directory: %w", err)
+		}
+		*configDir = dir
+	}
+	pathConfig, err := config.ResolvePathConfig(*configDir)
+	if err != nil {
+		return fmt.Errorf("resolve config paths: %w", err)
+	}
+	if *registryDataDir != "" {
+		pathConfig.ZotStorageDir = *registryDataDir
+	}
+
+	cm, warnings, err := config.InitConfigManager("", config.DefaultGroundControlURL, pathConfig.ConfigFile, pathConfig.PrevConfigFile, *jsonLogging, *useUnsecure)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if !cm.IsZTRDone() {
+		return errors.New("the satellite has not registered with Ground Control yet")
+	}
+
+	ctx, cancel := utils.SetupContext(context.Background())
+	defer cancel()
+	ctx, log := logger.InitLogger(ctx, cm.GetLogLevel(), *jsonLogging, warnings)
+
+	switch args[0] {
+	case "export":
+		if *output == "" {
+			return errors.New("missing required argument: --output")
+		}
+		if *harborRegistryURL != "" {
+			cm.With(config.SetHarborRegistryURL(*harborRegistryURL))
+			sc, err := config.ApplyHarborRegistryOverride(cm.GetStateConfig(), *harborRegistryURL)
+			if err != nil {
+				return fmt.Errorf("apply harbor registry URL override: %w", err)
+			}
+			cm.With(config.SetStateConfig(sc))
+		}
+		p := state.NewFetchAndReplicateStateProcess(cm, "", log)
+		return exportBundle(ctx, p, *output)
+
+	default:
+		if *input == "" {
+			return errors.New("missing required argument: --input")
+		}
+		applyZotConfig, _, err := setupZotConfig(ctx, cm, pathConfig)
+		if err != nil {
+			return err
+		}
+		zotConfigJSON, err := applyZotConfig(config.DefaultZotConfigJSON, cm.GetConfig())
+		if err != nil {
+			return fmt.Errorf("build Zot config: %w", err)
+		}
+		cm.With(config.SetZotConfigRaw(json.RawMessage(zotConfigJSON)))
+		endpoint, err := resolveLocalRegistryEndpoint(cm)
+		if err != nil {
+			return fmt.Errorf("resolving local registry endpoint: %w", err)
+		}
+
+		dir, err := os.MkdirTemp("", "satellite-bundle-*")
+		if err != nil {
+			return fmt.Errorf("create bundle directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		bundle, err := state.OpenBundle(*input, dir)
+		if err != nil {
+			return err
+		}
+		log.Info().Str("bundle", *input).Msg("Bundle checked, importing")
+
+		// The embedded registry only lives as long as the import
+		wg, ctx := errgroup.WithContext(ctx)
+		registryCtx, stopRegistry := context.WithCancel(ctx)
+		wg.Go(func() error { return handleRegistrySetup(registryCtx, log, cm, newZotManager(log, cm, pathConfig)) })
+		wg.Go(func() error {
+			defer stopRegistry()
+			if err := waitForRegistry(ctx, endpoint, registryStartTimeout); err != nil {
+				return err
+			}
+			p := state.NewFetchAndReplicateStateProcess(cm, pathConfig.StateFile, log)
+			return p.ImportBundle(ctx, bundle)
+		})
+		return wg.Wait()
+	}
+}
+
+func exportBundle(ctx context.Context, p *state.FetchAndReplicateStateProcess, output string) (err error) {
+	file, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("create bundle: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(output)
+		}
+	}()
+	return p.ExportBundle(ctx, file)
+}
+
+// waitForRegistry waits until the registry at endpoint accepts connections.
+func waitForRegistry(ctx context.Context, endpoint string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", utils.FormatRegistryURL(endpoint))
+		if err == nil {
+			return conn.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("local registry at %s is not reachable: %w", endpoint, err)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
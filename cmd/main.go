@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	runtime "github.com/container-registry/harbor-satellite/internal/container_runtime"
@@ -13,6 +14,7 @@ import (
 	"github.com/container-registry/harbor-satellite/internal/logger"
 	"github.com/container-registry/harbor-satellite/internal/registry"
 	"github.com/container-registry/harbor-satellite/internal/satellite"
+	"github.com/container-registry/harbor-satellite/internal/server"
 	"github.com/container-registry/harbor-satellite/internal/utils"
 	"github.com/container-registry/harbor-satellite/internal/watcher"
 	"github.com/container-registry/harbor-satellite/pkg/config"
@@ -57,6 +59,14 @@ type SatelliteOptions struct {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		if err := runBundle(os.Args[2:]); err != nil {
+			fmt.Printf("fatal: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var opts SatelliteOptions
 	var shutdownTimeout string
 
@@ -225,18 +235,27 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 		}
 	}
 
-	// Update Zot config with storage path
-	zotConfigJSON, err := config.BuildZotConfigWithStoragePath(pathConfig.ZotStorageDir)
+	// Set up the embedded registry. The same settings are added to every
+	// Zot config reloaded later, from Ground Control or a rollback
+	applyZotConfig, renewRegistryCert, err := setupZotConfig(ctx, cm, pathConfig)
+	if err != nil {
+		return err
+	}
+	zotConfigJSON, err := applyZotConfig(config.DefaultZotConfigJSON, cm.GetConfig())
 	if err != nil {
 		return fmt.Errorf("build Zot config: %w", err)
 	}
 	cm.With(config.SetZotConfigRaw(json.RawMessage(zotConfigJSON)))
+	cm.SetZotConfigOverlay(applyZotConfig)
 
 	// Resolve local registry endpoint for CRI mirror config
 	localRegistryEndpoint, err := resolveLocalRegistryEndpoint(cm)
 	if err != nil {
 		return fmt.Errorf("resolving local registry endpoint: %w", err)
 	}
+	if cm.IsLocalRegistryTLS() {
+		localRegistryEndpoint = "https://" + localRegistryEndpoint
+	}
 
 	// Resolve and apply CRI configs
 	criResults := resolveCRIAndApply(cm, opts.Mirrors, opts.NoRegistryFallback, localRegistryEndpoint)
@@ -271,8 +290,18 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 
 	eventChan := make(chan struct{})
 
+	// The embedded registry is restarted by its manager when its config
+	// changes, and rolled back if it fails to start with the new one
+	zm := newZotManager(log, cm, pathConfig)
+	if zm != nil {
+		hotReloadManager.SetRegistryReloader(zm)
+		if renewRegistryCert != nil {
+			wg.Go(func() error { return zm.RenewCertificates(ctx, registryCertRenewInterval, renewRegistryCert) })
+		}
+	}
+
 	// Handle registry setup
-	wg.Go(func() error { return handleRegistrySetup(ctx, log, cm, pathConfig) })
+	wg.Go(func() error { return handleRegistrySetup(ctx, log, cm, zm) })
 
 	// Watch for changes in the config file
 	wg.Go(func() error {
@@ -307,6 +336,9 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 	})
 
 	s := satellite.NewSatellite(cm, criResults, pathConfig.StateFile)
+	if zm != nil {
+		s.SetRegistryRestartReporter(zm)
+	}
 	err = s.Run(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to start satellite: %w", err)
@@ -318,6 +350,23 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 		}
 	}
 
+	if apiConfig := cm.GetLocalAPIConfig(); apiConfig.Enabled {
+		app := server.NewApp(server.NewDefaultRouter(""), ctx, log, &server.SyncRegistrar{
+			StateScheduler:  s.Scheduler(config.ReplicateStateJobName),
+			StatusScheduler: s.Scheduler(config.StatusReportJobName),
+			Groups:          s.StateProcess(),
+			Token:           apiConfig.Token,
+		}, &server.IntrospectionRegistrar{
+			State:           s.StateProcess(),
+			StateScheduler:  s.Scheduler(config.ReplicateStateJobName),
+			StatusScheduler: s.Scheduler(config.StatusReportJobName),
+			Token:           apiConfig.Token,
+		}, &server.MetricsRegistrar{Token: apiConfig.Token})
+		app.SetAddr(apiConfig.Address)
+		app.SetupRoutes()
+		app.SetupServer(wg)
+	}
+
 	return gracefulShutdown(ctx, log, s, wg, shutdownTimeout)
 }
 
@@ -380,7 +429,7 @@ func resolveCRIAndApply(cm *config.ConfigManager, mirrors mirrorFlags, noFallbac
 			fmt.Printf("warning: failed to resolve CRI configs: %v\n", err)
 			return nil
 		}
-		return runtime.ApplyCRIConfigs(configs, localRegistry)
+		return applyCRIConfigs(cm, configs, localRegistry)
 	}
 
 	// Explicit --mirrors flag
@@ -390,7 +439,7 @@ func resolveCRIAndApply(cm *config.ConfigManager, mirrors mirrorFlags, noFallbac
 			fmt.Printf("warning: failed to parse mirror flags: %v\n", err)
 			return nil
 		}
-		return runtime.ApplyCRIConfigs(configs, localRegistry)
+		return applyCRIConfigs(cm, configs, localRegistry)
 	}
 
 	// Disabled via flag or env var
@@ -402,6 +451,23 @@ func resolveCRIAndApply(cm *config.ConfigManager, mirrors mirrorFlags, noFallbac
 	return nil
 }
 
+// applyCRIConfigs points the container runtimes at the local registry. The
+// runtimes are given no credentials, so they are left untouched when pulls
+// from the embedded registry require them.
+func applyCRIConfigs(cm *config.ConfigManager, configs []runtime.CRIConfig, localRegistry string) []runtime.CRIConfigResult {
+	if !cm.GetOwnRegistry() && cm.GetRegistryTLSConfig().PullsRequireAuth() {
+		results := make([]runtime.CRIConfigResult, 0, len(configs))
+		for _, cfg := range configs {
+			results = append(results, runtime.CRIConfigResult{
+				CRI:   cfg.CRI,
+				Error: "the local registry requires authenticated pulls, container runtimes are given no credentials",
+			})
+		}
+		return results
+	}
+	return runtime.ApplyCRIConfigsWithCA(configs, localRegistry, cm.GetLocalRegistryCAFile())
+}
+
 func resolveLocalRegistryEndpoint(cm *config.ConfigManager) (string, error) {
 	if cm.GetOwnRegistry() {
 		return utils.FormatRegistryURL(cm.GetLocalRegistryURL()), nil
@@ -422,7 +488,82 @@ func resolveLocalRegistryEndpoint(cm *config.ConfigManager) (string, error) {
 	return addr + ":" + port, nil
 }
 
-func handleRegistrySetup(ctx context.Context, log *zerolog.Logger, cm *config.ConfigManager, pathConfig *config.PathConfig) error {
+// setupZotConfig prepares what the satellite manages for the embedded
+// registry: its storage directory, the log the storage quota and the
+// pull-through mode read pulls from, the sync extension serving the
+// pull-through mode, and its TLS and authentication. It
+// returns the overlay adding them to a Zot config and the function renewing
+// the registry certificate, if any.
+func setupZotConfig(ctx context.Context, cm *config.ConfigManager, pathConfig *config.PathConfig) (config.ZotConfigOverlay, func() (bool, error), error) {
+	zotConfigJSON, err := config.BuildZotConfigWithStoragePath(pathConfig.ZotStorageDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("build Zot config: %w", err)
+	}
+	applyTLS, renew, err := setupRegistryTLS(ctx, cm, pathConfig, zotConfigJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("set up registry TLS: %w", err)
+	}
+
+	apply := func(zotConfigJSON string, cfg *config.Config) (string, error) {
+		zotConfigJSON, err := config.SetZotStoragePath(zotConfigJSON, pathConfig.ZotStorageDir)
+		if err != nil {
+			return "", err
+		}
+		// The storage quota and the pull-through mode rank images by their
+		// last pull from Zot's access log. The log is always redirected, so
+		// that enabling either with a new config needs no registry restart;
+		// the satellite copies it to stdout.
+		if !cfg.AppConfig.BringOwnRegistry {
+			zotConfigJSON, err = config.SetZotLogOutput(zotConfigJSON, pathConfig.ZotAccessLog)
+			if err != nil {
+				return "", err
+			}
+			zotConfigJSON, err = setZotPullThrough(zotConfigJSON, cfg, pathConfig.ZotSyncCreds)
+			if err != nil {
+				return "", err
+			}
+		}
+		return applyTLS(zotConfigJSON)
+	}
+	return apply, renew, nil
+}
+
+// setZotPullThrough lets the embedded registry fetch an image of an allowed
+// repository from Harbor the moment a pull for it misses, with the
+// satellite's Harbor credentials. Until the satellite is registered it has
+// no credentials, and the sync extension is left out.
+func setZotPullThrough(zotConfigJSON string, cfg *config.Config, credentialsFile string) (string, error) {
+	pt := cfg.AppConfig.PullThrough
+	creds := cfg.StateConfig.RegistryCredentials
+	upstream := string(creds.URL)
+	if !pt.Enabled || upstream == "" {
+		return config.SetZotSync(zotConfigJSON, "", nil, false, "")
+	}
+
+	if override := cfg.AppConfig.HarborRegistryURL; override != "" {
+		if replaced, err := config.ReplaceURLHost(upstream, override); err == nil {
+			upstream = replaced
+		}
+	}
+	if !strings.Contains(upstream, "://") {
+		upstream = "https://" + upstream
+	}
+	if err := registry.WriteSyncCredentials(credentialsFile, upstream, creds.Username, creds.Password); err != nil {
+		return "", err
+	}
+	return config.SetZotSync(zotConfigJSON, upstream, pt.Allow, !cfg.AppConfig.UseUnsecure, credentialsFile)
+}
+
+// newZotManager returns the manager of the embedded registry, or nil if the
+// satellite uses its own registry.
+func newZotManager(log *zerolog.Logger, cm *config.ConfigManager, pathConfig *config.PathConfig) *registry.ZotManager {
+	if cm.GetOwnRegistry() {
+		return nil
+	}
+	return registry.NewZotManager(log.With().Str("component", "zot manager").Logger(), cm.GetRawZotConfig(), pathConfig.ZotTempConfig)
+}
+
+func handleRegistrySetup(ctx context.Context, log *zerolog.Logger, cm *config.ConfigManager, zm *registry.ZotManager) error {
 	log.Debug().Msg("Setting up local registry")
 
 	if cm.GetOwnRegistry() {
@@ -437,8 +578,6 @@ func handleRegistrySetup(ctx context.Context, log *zerolog.Logger, cm *config.Co
 
 	log.Info().Msg("Launching default registry")
 
-	zm := registry.NewZotManager(log.With().Str("component", "zot manager").Logger(), cm.GetRawZotConfig(), pathConfig.ZotTempConfig)
-
 	if err := zm.HandleRegistrySetup(ctx); err != nil {
 		return fmt.Errorf("default registry setup failed: %w", err)
 	}
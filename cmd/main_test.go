@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -176,6 +177,32 @@ func TestResolveCRIAndApply(t *testing.T) {
 		require.False(t, results[0].Success)
 	})
 
+	t.Run("runtimes not configured when pulls require credentials", func(t *testing.T) {
+		cfg := &config.Config{
+			AppConfig: config.AppConfig{
+				RegistryFallback: config.RegistryFallbackConfig{
+					Enabled:    true,
+					Registries: []string{"docker.io"},
+					Runtimes:   []string{"containerd"},
+				},
+				RegistryTLS: config.RegistryTLSConfig{
+					Mode:               config.RegistryTLSSelfSigned,
+					Auth:               config.RegistryAuthHtpasswd,
+					AuthenticatedPulls: true,
+				},
+			},
+			ZotConfigRaw: json.RawMessage(`{}`),
+		}
+		cm := newTestConfigManager(t, cfg)
+
+		results := resolveCRIAndApply(cm, nil, false, "localhost:8585")
+		require.Len(t, results, 1)
+		require.Equal(t, runtime.CRIContainerd, results[0].CRI)
+		require.False(t, results[0].Success)
+		require.Contains(t, results[0].Error, "authenticated pulls")
+		require.Empty(t, results[0].BackupPath)
+	})
+
 	t.Run("mirrors used when config disabled", func(t *testing.T) {
 		cfg := &config.Config{
 			ZotConfigRaw: json.RawMessage(`{}`),
@@ -208,3 +235,29 @@ func TestMirrorFlags(t *testing.T) {
 		require.Equal(t, "[]", m.String())
 	})
 }
+
+func TestSetZotPullThrough(t *testing.T) {
+	credsFile := filepath.Join(t.TempDir(), "zot-sync-credentials.json")
+	cfg := &config.Config{
+		StateConfig: config.StateConfig{RegistryCredentials: config.RegistryCredentials{
+			URL: "https://harbor.example.com", Username: "robot$edge", Password: "secret",
+		}},
+		AppConfig: config.AppConfig{PullThrough: config.PullThroughConfig{Enabled: true, Allow: []string{"library/*"}}},
+	}
+
+	zotConfigJSON, err := setZotPullThrough(config.DefaultZotConfigJSON, cfg, credsFile)
+	require.NoError(t, err)
+	require.Contains(t, zotConfigJSON, `"onDemand": true`)
+
+	data, err := os.ReadFile(credsFile)
+	require.NoError(t, err)
+	var creds map[string]map[string]string
+	require.NoError(t, json.Unmarshal(data, &creds))
+	require.Equal(t, map[string]string{"username": "robot$edge", "password": "secret"}, creds["harbor.example.com"], "Zot looks the credentials up by host")
+
+	// Before registration there are no credentials to fetch with
+	cfg.StateConfig.RegistryCredentials = config.RegistryCredentials{}
+	zotConfigJSON, err = setZotPullThrough(zotConfigJSON, cfg, credsFile)
+	require.NoError(t, err)
+	require.NotContains(t, zotConfigJSON, `"sync"`)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/registry"
+	"github.com/container-registry/harbor-satellite/internal/spiffe"
+	"github.com/container-registry/harbor-satellite/internal/utils"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+const (
+	// registryCertRenewInterval is how often the certificate of the embedded
+	// registry is checked for renewal.
+	registryCertRenewInterval = time.Minute
+	// registrySVIDTimeout bounds the wait for the SVID the registry serves.
+	registrySVIDTimeout = 30 * time.Second
+)
+
+// setupRegistryTLS provisions the certificate and the authentication of the
+// embedded registry, and points the local registry URL at the scheme the
+// registry is served with. It returns the function adding them to a Zot
+// config and the function that renews the certificate, which is nil for
+// provided certificates.
+func setupRegistryTLS(ctx context.Context, cm *config.ConfigManager, pathConfig *config.PathConfig, zotConfigJSON string) (func(string) (string, error), func() (bool, error), error) {
+	noTLS := func(zotConfigJSON string) (string, error) { return zotConfigJSON, nil }
+	if cm.GetOwnRegistry() {
+		return noTLS, nil, nil
+	}
+	scheme := "http://"
+	if cm.IsLocalRegistryTLS() {
+		scheme = "https://"
+	}
+	cm.With(config.SetLocalRegistryURL(scheme + utils.FormatRegistryURL(cm.GetLocalRegistryURL())))
+
+	rt := cm.GetRegistryTLSConfig()
+	if !rt.Enabled() {
+		return noTLS, nil, nil
+	}
+
+	dir := pathConfig.RegistryTLSDir
+	certFile := filepath.Join(dir, registry.TLSCertFile)
+	keyFile := filepath.Join(dir, registry.TLSKeyFile)
+	var renew func() (bool, error)
+	switch rt.Mode {
+	case config.RegistryTLSSelfSigned:
+		hosts, err := registryHosts(zotConfigJSON, rt.Hosts)
+		if err != nil {
+			return nil, nil, err
+		}
+		renew = func() (bool, error) {
+			return registry.IssueSelfSignedTLS(dir, hosts, time.Now())
+		}
+	case config.RegistryTLSSPIFFE:
+		client, err := connectRegistrySPIFFE(ctx, cm)
+		if err != nil {
+			return nil, nil, err
+		}
+		renew = func() (bool, error) {
+			return writeRegistrySVID(client, dir)
+		}
+	case config.RegistryTLSFiles:
+		certFile, keyFile = rt.CertFile, rt.KeyFile
+	}
+	if renew != nil {
+		if _, err := renew(); err != nil {
+			return nil, nil, fmt.Errorf("provision registry certificate: %w", err)
+		}
+	}
+
+	htpasswdFile := filepath.Join(dir, registry.HtpasswdFile)
+	if rt.Auth == config.RegistryAuthHtpasswd {
+		creds := cm.GetRemoteRegistryCredentials()
+		if err := registry.WriteHtpasswd(htpasswdFile, creds.Username, creds.Password); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	applyTLS := func(zotConfigJSON string) (string, error) {
+		zotConfigJSON, err := config.SetZotTLS(zotConfigJSON, certFile, keyFile)
+		if err != nil {
+			return "", err
+		}
+		return config.SetZotAuth(zotConfigJSON, rt, htpasswdFile)
+	}
+	return applyTLS, renew, nil
+}
+
+// registryHosts returns the names and addresses a self-signed registry
+// certificate covers: localhost, the host name, the address the registry
+// listens on and the configured extra hosts.
+func registryHosts(zotConfigJSON string, extra []string) ([]string, error) {
+	var zotConfig registry.ZotConfig
+	if err := json.Unmarshal([]byte(zotConfigJSON), &zotConfig); err != nil {
+		return nil, fmt.Errorf("unmarshalling zot config: %w", err)
+	}
+
+	hosts := []string{"localhost", "127.0.0.1"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hosts = append(hosts, hostname)
+	}
+	if zotConfig.HTTP.Address != "" {
+		hosts = append(hosts, utils.FormatRegistryURL(zotConfig.HTTP.Address))
+	}
+	hosts = append(hosts, extra...)
+
+	slices.Sort(hosts)
+	return slices.Compact(hosts), nil
+}
+
+// connectRegistrySPIFFE connects to the SPIFFE Workload API and waits for the
+// SVID the registry is served with. The connection is closed with ctx.
+func connectRegistrySPIFFE(ctx context.Context, cm *config.ConfigManager) (*spiffe.Client, error) {
+	if !cm.IsSPIFFEEnabled() {
+		return nil, fmt.Errorf("registry_tls.mode %s requires SPIFFE to be enabled", config.RegistryTLSSPIFFE)
+	}
+	spiffeCfg := cm.GetSPIFFEConfig()
+	client, err := spiffe.NewClient(spiffe.Config{
+		Enabled:          spiffeCfg.Enabled,
+		EndpointSocket:   spiffeCfg.EndpointSocket,
+		ExpectedServerID: spiffeCfg.ExpectedServerID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create SPIFFE client: %w", err)
+	}
+	if err := client.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("connect to SPIFFE Workload API: %w", err)
+	}
+	context.AfterFunc(ctx, func() { _ = client.Close() })
+	if err := client.WaitForSVID(ctx, registrySVIDTimeout); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// writeRegistrySVID writes the current SVID and the bundle of its trust
+// domain as the registry's certificate and CA. Nodes verify the registry by
+// host name, so the SVID needs DNS or IP SANs besides its SPIFFE ID.
+func writeRegistrySVID(client *spiffe.Client, dir string) (bool, error) {
+	svid, err := client.GetSVID()
+	if err != nil {
+		return false, err
+	}
+	if len(svid.Certificates) == 0 {
+		return false, errors.New("SVID has no certificate")
+	}
+	if leaf := svid.Certificates[0]; len(leaf.DNSNames) == 0 && len(leaf.IPAddresses) == 0 {
+		return false, fmt.Errorf("SVID %s has no DNS or IP SANs to serve the registry with", svid.ID)
+	}
+	certPEM, keyPEM, err := svid.Marshal()
+	if err != nil {
+		return false, fmt.Errorf("marshal SVID: %w", err)
+	}
+
+	authorities, err := client.GetX509Bundle()
+	if err != nil {
+		return false, err
+	}
+	var caPEM []byte
+	for _, ca := range authorities {
+		caPEM = append(caPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})...)
+	}
+
+	return registry.WriteTLSFiles(dir, certPEM, keyPEM, caPEM)
+}
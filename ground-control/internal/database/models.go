@@ -35,6 +35,12 @@ type Group struct {
 	UpdatedAt   time.Time
 }
 
+type GroupStateDigest struct {
+	GroupID  int32
+	Digest   string
+	PushedAt time.Time
+}
+
 type LoginAttempt struct {
 	ID          int32
 	Username    string
@@ -68,11 +74,81 @@ type SatelliteConfig struct {
 	ConfigID    int32
 }
 
+type SatelliteConfigRollback struct {
+	ID             int32
+	SatelliteID    int32
+	Digest         string
+	RestoredDigest string
+	Reason         string
+	RolledBackAt   time.Time
+	CreatedAt      time.Time
+}
+
+type SatelliteDeadLetter struct {
+	SatelliteID   int32
+	GroupName     string
+	Repository    string
+	Name          string
+	Tag           string
+	Digest        string
+	Error         string
+	Failures      int32
+	FirstFailedAt time.Time
+	LastFailedAt  time.Time
+	NextRetryAt   time.Time
+}
+
+type SatelliteEviction struct {
+	ID           int32
+	SatelliteID  int32
+	Reference    string
+	Digest       string
+	FreedBytes   int64
+	LastPulledAt sql.NullTime
+	EvictedAt    time.Time
+	CreatedAt    time.Time
+}
+
 type SatelliteGroup struct {
 	SatelliteID int32
 	GroupID     int32
 }
 
+type SatelliteGroupState struct {
+	SatelliteID int32
+	GroupName   string
+	StateDigest string
+	SyncedAt    time.Time
+}
+
+type SatellitePeer struct {
+	SatelliteID int32
+	Position    int32
+	Url         string
+}
+
+type SatelliteRegistryRestart struct {
+	ID          int32
+	SatelliteID int32
+	Outcome     string
+	Reason      string
+	RestartedAt time.Time
+	CreatedAt   time.Time
+}
+
+type SatelliteSignature struct {
+	SatelliteID int32
+	GroupName   string
+	Repository  string
+	Name        string
+	Tag         string
+	Digest      string
+	Verdict     string
+	Signer      string
+	Reason      string
+	CheckedAt   time.Time
+}
+
 type SatelliteStatus struct {
 	ID                 int32
 	SatelliteID        int32
@@ -89,6 +165,28 @@ type SatelliteStatus struct {
 	ArtifactIds        []int32
 }
 
+type SatelliteSync struct {
+	SatelliteID      int32
+	StateDigest      string
+	ConfigDigest     string
+	FinishedAt       time.Time
+	DurationMs       int64
+	Errors           int32
+	BytesTransferred int64
+}
+
+type SatelliteTombstone struct {
+	SatelliteID int32
+	GroupName   string
+	Repository  string
+	Name        string
+	Tag         string
+	Digest      string
+	RemovedAt   time.Time
+	PurgeAfter  time.Time
+	Hold        string
+}
+
 type SatelliteToken struct {
 	ID          int32
 	SatelliteID int32
@@ -0,0 +1,161 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	// GroupStateChanged is emitted when a group state artifact is rewritten.
+	GroupStateChanged = "group_state"
+	// ConfigChanged is emitted when the config assigned to a satellite changes.
+	ConfigChanged = "config"
+	// MembershipChanged is emitted when a satellite joins or leaves a group.
+	MembershipChanged = "membership"
+	// PeersChanged is emitted when the peers of a satellite change.
+	PeersChanged = "peers"
+)
+
+// DefaultCapacity is the number of events a broker retains for satellites
+// that reconnect with an older cursor.
+const DefaultCapacity = 256
+
+// Event tells satellites that their desired state changed.
+type Event struct {
+	Seq    uint64    `json:"seq"`
+	Type   string    `json:"type"`
+	Group  string    `json:"group,omitempty"`
+	Config string    `json:"config,omitempty"`
+	Time   time.Time `json:"time"`
+
+	satellites []int32
+}
+
+// Batch is the answer to a subscriber waiting for events.
+type Batch struct {
+	// Epoch identifies the broker instance. It changes when Ground Control
+	// restarts, which invalidates every cursor handed out before.
+	Epoch string `json:"epoch"`
+	// Cursor is the sequence number to resume from on the next request.
+	Cursor uint64  `json:"cursor"`
+	Events []Event `json:"events"`
+	// Resync is set when events may have been missed, either because the
+	// epoch changed or because the cursor fell out of the retained window.
+	// Subscribers should reconcile their full state.
+	Resync bool `json:"resync"`
+}
+
+// Broker keeps a bounded, in-memory log of events and wakes subscribers
+// waiting on it.
+type Broker struct {
+	mu       sync.Mutex
+	epoch    string
+	seq      uint64
+	log      []Event
+	capacity int
+	notify   chan struct{}
+}
+
+// NewBroker creates a broker retaining up to capacity events.
+func NewBroker(capacity int) *Broker {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return &Broker{
+		epoch:    hex.EncodeToString(b),
+		capacity: capacity,
+		notify:   make(chan struct{}),
+	}
+}
+
+// Epoch returns the identifier of this broker instance.
+func (b *Broker) Epoch() string {
+	return b.epoch
+}
+
+// Publish records an event for the given satellites and wakes waiting
+// subscribers. Events without satellites are dropped.
+func (b *Broker) Publish(ev Event, satellites ...int32) {
+	if len(satellites) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	ev.Seq = b.seq
+	ev.satellites = slices.Clone(satellites)
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	b.log = append(b.log, ev)
+	if len(b.log) > b.capacity {
+		b.log = slices.Delete(b.log, 0, len(b.log)-b.capacity)
+	}
+
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// Wait returns the events for a satellite published after cursor. If there
+// are none it blocks until one is published or ctx is done, in which case
+// an empty batch is returned. An empty epoch starts a new subscription at the
+// current position without replaying history.
+func (b *Broker) Wait(ctx context.Context, satellite int32, epoch string, cursor uint64) Batch {
+	for {
+		b.mu.Lock()
+		batch, ok := b.pending(satellite, epoch, cursor)
+		notify := b.notify
+		b.mu.Unlock()
+		if ok {
+			return batch
+		}
+
+		select {
+		case <-ctx.Done():
+			return batch
+		case <-notify:
+		}
+	}
+}
+
+// pending reports the events due for a satellite and whether the subscriber
+// should be answered right away. The caller must hold b.mu.
+func (b *Broker) pending(satellite int32, epoch string, cursor uint64) (Batch, bool) {
+	batch := Batch{Epoch: b.epoch, Cursor: b.seq, Events: []Event{}}
+
+	if epoch == "" {
+		return batch, true
+	}
+	if epoch != b.epoch || cursor > b.seq || cursor < b.oldest() {
+		batch.Resync = true
+		return batch, true
+	}
+
+	for _, ev := range b.log {
+		if ev.Seq > cursor && slices.Contains(ev.satellites, satellite) {
+			batch.Events = append(batch.Events, ev)
+		}
+	}
+	// Without matching events the cursor still advances past the ones that
+	// were scanned, so traffic for other satellites does not push this
+	// subscriber out of the retained window.
+	return batch, len(batch.Events) > 0
+}
+
+// oldest returns the lowest cursor that can still be served without gaps.
+// The caller must hold b.mu.
+func (b *Broker) oldest() uint64 {
+	if len(b.log) == 0 {
+		return b.seq
+	}
+	return b.log[0].Seq - 1
+}
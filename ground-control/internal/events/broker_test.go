@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_NewSubscriptionStartsAtCurrentPosition(t *testing.T) {
+	b := NewBroker(4)
+	b.Publish(Event{Type: GroupStateChanged, Group: "edge"}, 1)
+
+	batch := b.Wait(context.Background(), 1, "", 0)
+	require.Equal(t, b.Epoch(), batch.Epoch)
+	require.Equal(t, uint64(1), batch.Cursor)
+	require.Empty(t, batch.Events)
+	require.False(t, batch.Resync)
+}
+
+func TestBroker_ReturnsEventsForSatellite(t *testing.T) {
+	b := NewBroker(8)
+	b.Publish(Event{Type: GroupStateChanged, Group: "edge"}, 1, 2)
+	b.Publish(Event{Type: ConfigChanged, Config: "default"}, 2)
+	b.Publish(Event{Type: MembershipChanged, Group: "prod"}, 1)
+
+	batch := b.Wait(context.Background(), 1, b.Epoch(), 0)
+	require.False(t, batch.Resync)
+	require.Equal(t, uint64(3), batch.Cursor)
+	require.Len(t, batch.Events, 2)
+	require.Equal(t, "edge", batch.Events[0].Group)
+	require.Equal(t, uint64(1), batch.Events[0].Seq)
+	require.Equal(t, "prod", batch.Events[1].Group)
+	require.Equal(t, uint64(3), batch.Events[1].Seq)
+}
+
+func TestBroker_WaitBlocksUntilPublish(t *testing.T) {
+	b := NewBroker(8)
+
+	done := make(chan Batch, 1)
+	go func() {
+		done <- b.Wait(context.Background(), 7, b.Epoch(), 0)
+	}()
+
+	// An event for another satellite must not wake this subscriber.
+	b.Publish(Event{Type: ConfigChanged}, 8)
+	select {
+	case <-done:
+		t.Fatal("subscriber woke up for another satellite's event")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.Publish(Event{Type: GroupStateChanged, Group: "edge"}, 7)
+	select {
+	case batch := <-done:
+		require.Len(t, batch.Events, 1)
+		require.Equal(t, uint64(2), batch.Cursor)
+	case <-time.After(time.Second):
+		t.Fatal("subscriber was not woken up")
+	}
+}
+
+func TestBroker_WaitTimesOut(t *testing.T) {
+	b := NewBroker(8)
+	b.Publish(Event{Type: ConfigChanged}, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	batch := b.Wait(ctx, 1, b.Epoch(), 0)
+	require.Empty(t, batch.Events)
+	require.False(t, batch.Resync)
+	require.Equal(t, uint64(1), batch.Cursor)
+}
+
+func TestBroker_Resync(t *testing.T) {
+	b := NewBroker(2)
+	for range 4 {
+		b.Publish(Event{Type: GroupStateChanged}, 1)
+	}
+
+	tests := []struct {
+		name   string
+		epoch  string
+		cursor uint64
+		resync bool
+	}{
+		{name: "epoch changed", epoch: "stale", cursor: 4, resync: true},
+		{name: "cursor out of window", epoch: b.Epoch(), cursor: 1, resync: true},
+		{name: "cursor ahead of broker", epoch: b.Epoch(), cursor: 9, resync: true},
+		{name: "oldest retained cursor", epoch: b.Epoch(), cursor: 2, resync: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := b.Wait(context.Background(), 1, tt.epoch, tt.cursor)
+			require.Equal(t, tt.resync, batch.Resync)
+			require.Equal(t, uint64(4), batch.Cursor)
+		})
+	}
+}
+
+func TestBroker_PublishWithoutSatellites(t *testing.T) {
+	b := NewBroker(2)
+	b.Publish(Event{Type: GroupStateChanged})
+
+	batch := b.Wait(context.Background(), 1, "", 0)
+	require.Equal(t, uint64(0), batch.Cursor)
+}
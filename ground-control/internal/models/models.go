@@ -5,6 +5,7 @@ import "github.com/container-registry/harbor-satellite/pkg/config"
 type SatelliteStateArtifact struct {
 	States []string `json:"states,omitempty"`
 	Config string   `json:"config,omitempty"`
+	Peers  []string `json:"peers,omitempty"`
 }
 
 type StateArtifact struct {
@@ -46,6 +46,7 @@ func TestSyncHandler_WithCachedImages(t *testing.T) {
 		WithArgs("edge-01").
 		WillReturnRows(satRows)
 
+	mock.ExpectBegin()
 	// Mock BatchInsertArtifacts
 	mock.ExpectExec("INSERT INTO artifacts").
 		WithArgs(
@@ -79,9 +80,12 @@ func TestSyncHandler_WithCachedImages(t *testing.T) {
 		sql.NullInt32{Int32: 2, Valid: true}, now, now, pq.Array([]int32{10, 11}),
 	)
 	mock.ExpectQuery("INSERT INTO satellite_status").WillReturnRows(statusRows)
+	mock.ExpectExec("DELETE FROM satellite_dead_letters").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM satellite_tombstones").WillReturnResult(sqlmock.NewResult(0, 0))
 
 	// Mock UpdateSatelliteLastSeen
 	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
 	reqBody := SatelliteStatusParams{
 		Name:               "edge-01",
@@ -114,6 +118,7 @@ func TestSyncHandler_NoCachedImages(t *testing.T) {
 		WithArgs("edge-01").
 		WillReturnRows(satRows)
 
+	mock.ExpectBegin()
 	statusRows := sqlmock.NewRows([]string{
 		"id", "satellite_id", "activity", "latest_state_digest", "latest_config_digest",
 		"cpu_percent", "memory_used_bytes", "storage_used_bytes", "last_sync_duration_ms",
@@ -124,8 +129,11 @@ func TestSyncHandler_NoCachedImages(t *testing.T) {
 		sql.NullInt32{Int32: 0, Valid: true}, now, now, pq.Array([]int32(nil)),
 	)
 	mock.ExpectQuery("INSERT INTO satellite_status").WillReturnRows(statusRows)
+	mock.ExpectExec("DELETE FROM satellite_dead_letters").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM satellite_tombstones").WillReturnResult(sqlmock.NewResult(0, 0))
 
 	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
 	reqBody := SatelliteStatusParams{
 		Name:               "edge-01",
@@ -297,12 +305,14 @@ func TestSyncHandler_BatchInsertArtifactsFails(t *testing.T) {
 		WithArgs("edge-01").
 		WillReturnRows(satRows)
 
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO artifacts").
 		WithArgs(
 			pq.Array([]string{"localhost:8585/nginx:latest@sha256:abc"}),
 			pq.Array([]int64{50000}),
 		).
 		WillReturnError(fmt.Errorf("db connection lost"))
+	mock.ExpectRollback()
 
 	reqBody := SatelliteStatusParams{
 		Name:               "edge-01",
@@ -10,6 +10,7 @@ import (
 	"os"
 
 	"github.com/container-registry/harbor-satellite/ground-control/internal/database"
+	"github.com/container-registry/harbor-satellite/ground-control/internal/events"
 	"github.com/container-registry/harbor-satellite/ground-control/internal/models"
 	"github.com/container-registry/harbor-satellite/ground-control/internal/utils"
 	"github.com/container-registry/harbor-satellite/pkg/config"
@@ -228,6 +229,13 @@ func (s *Server) updateConfigHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	configSatellites, err := q.ConfigSatelliteList(r.Context(), result.ID)
+	if err != nil {
+		log.Println("Error listing satellites using config: ", err)
+		HandleAppError(w, err)
+		return
+	}
+
 	// Push config as OCI artifact
 	err = utils.CreateAndPushConfigStateArtifact(r.Context(), patchedJson, configName)
 	if err != nil {
@@ -246,6 +254,12 @@ func (s *Server) updateConfigHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	committed = true
 
+	satelliteIDs := make([]int32, 0, len(configSatellites))
+	for _, sc := range configSatellites {
+		satelliteIDs = append(satelliteIDs, sc.SatelliteID)
+	}
+	s.publishEvent(events.Event{Type: events.ConfigChanged, Config: configName}, satelliteIDs...)
+
 	WriteJSONResponse(w, http.StatusOK, result)
 }
 
@@ -345,7 +359,13 @@ func (s *Server) setSatelliteConfig(w http.ResponseWriter, r *http.Request) {
 		groupStates = append(groupStates, utils.AssembleGroupState(grp.GroupName))
 	}
 
-	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, req.ConfigName)
+	peers, err := fetchSatellitePeers(r.Context(), q, sat.ID)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, req.ConfigName, peers)
 	if err != nil {
 		log.Printf("Could not update satellite state artifact: %v", err)
 		HandleAppError(w, err)
@@ -362,6 +382,8 @@ func (s *Server) setSatelliteConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	committed = true
 
+	s.publishEvent(events.Event{Type: events.ConfigChanged, Config: req.ConfigName}, sat.ID)
+
 	WriteJSONResponse(w, http.StatusOK, map[string]string{})
 }
 
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/ground-control/internal/database"
+	"github.com/container-registry/harbor-satellite/ground-control/internal/events"
+	"github.com/container-registry/harbor-satellite/ground-control/internal/spiffe"
+	"github.com/container-registry/harbor-satellite/ground-control/pkg/crypto"
+)
+
+const (
+	defaultEventWait = 20 * time.Second
+	// maxEventWait keeps a long-poll below the server's WriteTimeout.
+	maxEventWait = 25 * time.Second
+)
+
+// eventsHandler long-polls for state change events addressed to the calling
+// satellite. Satellites pass back the epoch and cursor of the previous answer
+// and reconcile their full state when the response asks them to resync.
+func (s *Server) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.events == nil {
+		HandleAppError(w, &AppError{
+			Message: "event notifications are not enabled",
+			Code:    http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	sat, err := s.authenticateSatellite(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+
+	var cursor uint64
+	if v := query.Get("cursor"); v != "" {
+		cursor, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			HandleAppError(w, &AppError{Message: "invalid cursor", Code: http.StatusBadRequest})
+			return
+		}
+	}
+
+	wait := defaultEventWait
+	if v := query.Get("wait"); v != "" {
+		wait, err = time.ParseDuration(v)
+		if err != nil || wait < 0 {
+			HandleAppError(w, &AppError{Message: "invalid wait duration", Code: http.StatusBadRequest})
+			return
+		}
+	}
+	wait = min(wait, maxEventWait)
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	batch := s.events.Wait(ctx, sat.ID, query.Get("epoch"), cursor)
+	if r.Context().Err() != nil {
+		// The satellite went away while waiting.
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, batch)
+}
+
+// authenticateSatellite identifies the calling satellite by its SPIFFE ID or,
+// without one, by the robot account credentials Ground Control issued to the
+// satellite named in the "satellite" query parameter.
+func (s *Server) authenticateSatellite(r *http.Request) (database.Satellite, error) {
+	if name, ok := spiffe.GetSatelliteName(r.Context()); ok {
+		sat, err := s.dbQueries.GetSatelliteByName(r.Context(), name)
+		if err != nil {
+			log.Printf("Unknown satellite: %s", name)
+			return database.Satellite{}, &AppError{
+				Message: "unknown satellite entity",
+				Code:    http.StatusForbidden,
+			}
+		}
+		return sat, nil
+	}
+
+	unauthorized := &AppError{
+		Message: "invalid satellite credentials",
+		Code:    http.StatusUnauthorized,
+	}
+
+	robotName, secret, ok := r.BasicAuth()
+	if !ok {
+		return database.Satellite{}, unauthorized
+	}
+
+	satelliteName := r.URL.Query().Get("satellite")
+	sat, err := s.dbQueries.GetSatelliteByName(r.Context(), satelliteName)
+	if err != nil {
+		log.Printf("Event subscription for unknown satellite %q: %v", satelliteName, err)
+		return database.Satellite{}, unauthorized
+	}
+
+	robot, err := s.dbQueries.GetRobotAccBySatelliteID(r.Context(), sat.ID)
+	if err != nil {
+		log.Printf("No robot account for satellite %s: %v", satelliteName, err)
+		return database.Satellite{}, unauthorized
+	}
+	if robot.RobotExpiry.Valid && robot.RobotExpiry.Time.Before(time.Now()) {
+		log.Printf("Robot account for satellite %s has expired", satelliteName)
+		return database.Satellite{}, unauthorized
+	}
+	if robot.RobotName != robotName || !crypto.VerifySecret(secret, robot.RobotSecretHash) {
+		log.Printf("Invalid robot credentials for satellite %s", satelliteName)
+		return database.Satellite{}, unauthorized
+	}
+
+	return sat, nil
+}
+
+// publishEvent notifies satellites of a committed change. It is a no-op when
+// event notifications are disabled.
+func (s *Server) publishEvent(ev events.Event, satellites ...int32) {
+	if s.events == nil {
+		return
+	}
+	s.events.Publish(ev, satellites...)
+}
+
+func groupSatelliteIDs(members []database.SatelliteGroup) []int32 {
+	ids := make([]int32, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.SatelliteID)
+	}
+	return ids
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/container-registry/harbor-satellite/ground-control/internal/events"
+	"github.com/container-registry/harbor-satellite/ground-control/internal/utils"
+	"github.com/container-registry/harbor-satellite/ground-control/pkg/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func expectSatelliteRobot(t *testing.T, mock sqlmock.Sqlmock, secret string, expiry sql.NullTime) {
+	t.Helper()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	hash, err := crypto.HashSecret(secret)
+	require.NoError(t, err)
+
+	satRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+		AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{})
+	mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+		WithArgs("edge-01").
+		WillReturnRows(satRows)
+
+	robotRows := sqlmock.NewRows([]string{"id", "robot_name", "robot_secret_hash", "robot_id", "satellite_id", "robot_expiry", "created_at", "updated_at"}).
+		AddRow(1, "robot$edge-01", hash, "42", 1, expiry, now, now)
+	mock.ExpectQuery("SELECT .+ FROM robot_accounts").
+		WithArgs(int32(1)).
+		WillReturnRows(robotRows)
+}
+
+func TestEventsHandler(t *testing.T) {
+	t.Run("returns pending events", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		server.events = events.NewBroker(8)
+		server.events.Publish(events.Event{Type: events.GroupStateChanged, Group: "edge"}, 1)
+		server.events.Publish(events.Event{Type: events.ConfigChanged, Config: "other"}, 2)
+		expectSatelliteRobot(t, mock, "s3cret", sql.NullTime{})
+
+		req := httptest.NewRequest(http.MethodGet, "/satellites/events?satellite=edge-01&cursor=0&epoch="+server.events.Epoch(), nil)
+		req.SetBasicAuth("robot$edge-01", "s3cret")
+		rr := httptest.NewRecorder()
+		server.eventsHandler(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		var batch events.Batch
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &batch))
+		require.Equal(t, uint64(2), batch.Cursor)
+		require.Len(t, batch.Events, 1)
+		require.Equal(t, "edge", batch.Events[0].Group)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("times out without events", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		server.events = events.NewBroker(8)
+		expectSatelliteRobot(t, mock, "s3cret", sql.NullTime{})
+
+		req := httptest.NewRequest(http.MethodGet, "/satellites/events?satellite=edge-01&wait=10ms&epoch="+server.events.Epoch(), nil)
+		req.SetBasicAuth("robot$edge-01", "s3cret")
+		rr := httptest.NewRecorder()
+		server.eventsHandler(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		var batch events.Batch
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &batch))
+		require.Empty(t, batch.Events)
+		require.False(t, batch.Resync)
+	})
+
+	t.Run("wrong secret is rejected", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		server.events = events.NewBroker(8)
+		expectSatelliteRobot(t, mock, "s3cret", sql.NullTime{})
+
+		req := httptest.NewRequest(http.MethodGet, "/satellites/events?satellite=edge-01", nil)
+		req.SetBasicAuth("robot$edge-01", "wrong")
+		rr := httptest.NewRecorder()
+		server.eventsHandler(rr, req)
+
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("expired robot is rejected", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		server.events = events.NewBroker(8)
+		expectSatelliteRobot(t, mock, "s3cret", sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true})
+
+		req := httptest.NewRequest(http.MethodGet, "/satellites/events?satellite=edge-01", nil)
+		req.SetBasicAuth("robot$edge-01", "s3cret")
+		rr := httptest.NewRecorder()
+		server.eventsHandler(rr, req)
+
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("missing credentials are rejected", func(t *testing.T) {
+		server, _ := newMockServer(t)
+		server.events = events.NewBroker(8)
+
+		req := httptest.NewRequest(http.MethodGet, "/satellites/events?satellite=edge-01", nil)
+		rr := httptest.NewRecorder()
+		server.eventsHandler(rr, req)
+
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		server.events = events.NewBroker(8)
+		expectSatelliteRobot(t, mock, "s3cret", sql.NullTime{})
+
+		req := httptest.NewRequest(http.MethodGet, "/satellites/events?satellite=edge-01&cursor=abc", nil)
+		req.SetBasicAuth("robot$edge-01", "s3cret")
+		rr := httptest.NewRecorder()
+		server.eventsHandler(rr, req)
+
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		server, _ := newMockServer(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/satellites/events", nil)
+		rr := httptest.NewRecorder()
+		server.eventsHandler(rr, req)
+
+		require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	})
+}
+
+func TestStateKeyHandler(t *testing.T) {
+	t.Run("returns the key to a satellite", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		expectSatelliteRobot(t, mock, "s3cret", sql.NullTime{})
+
+		req := httptest.NewRequest(http.MethodGet, "/satellites/state-key?satellite=edge-01", nil)
+		req.SetBasicAuth("robot$edge-01", "s3cret")
+		rr := httptest.NewRecorder()
+		server.stateKeyHandler(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp StateKeyResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		require.Equal(t, utils.StatePublicKeyPEM(), resp.StatePublicKey)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("wrong secret is rejected", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		expectSatelliteRobot(t, mock, "s3cret", sql.NullTime{})
+
+		req := httptest.NewRequest(http.MethodGet, "/satellites/state-key?satellite=edge-01", nil)
+		req.SetBasicAuth("robot$edge-01", "wrong")
+		rr := httptest.NewRecorder()
+		server.stateKeyHandler(rr, req)
+
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+		require.NotContains(t, rr.Body.String(), "PUBLIC KEY")
+	})
+}
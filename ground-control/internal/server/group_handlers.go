@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/container-registry/harbor-satellite/ground-control/internal/database"
+	"github.com/container-registry/harbor-satellite/ground-control/internal/events"
 	"github.com/container-registry/harbor-satellite/ground-control/internal/models"
 	"github.com/container-registry/harbor-satellite/ground-control/internal/utils"
 	"github.com/container-registry/harbor-satellite/ground-control/reg/harbor"
@@ -106,13 +107,25 @@ func (s *Server) groupsSyncHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err = utils.CreateStateArtifact(r.Context(), &req)
+	digest, err := utils.CreateStateArtifact(r.Context(), &req)
 	if err != nil {
 		log.Println("Error creating state artifact:", err)
 		HandleAppError(w, err)
 		return
 	}
 
+	// Satellites report the digest of the group state they applied, which is
+	// compared with this one to tell whether they converged.
+	err = q.UpsertGroupStateDigest(r.Context(), database.UpsertGroupStateDigestParams{
+		GroupID: result.ID,
+		Digest:  digest,
+	})
+	if err != nil {
+		log.Println("Error storing group state digest:", err)
+		HandleAppError(w, err)
+		return
+	}
+
 	if err := tx.Commit(); err != nil {
 		log.Printf("Commit failed: %v", err)
 		HandleAppError(w, &AppError{
@@ -123,6 +136,8 @@ func (s *Server) groupsSyncHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	committed = true
 
+	s.publishEvent(events.Event{Type: events.GroupStateChanged, Group: req.Group}, groupSatelliteIDs(satellites)...)
+
 	WriteJSONResponse(w, http.StatusOK, result)
 }
 
@@ -281,8 +296,14 @@ func (s *Server) deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		peers, err := fetchSatellitePeers(r.Context(), q, sat.ID)
+		if err != nil {
+			HandleAppError(w, err)
+			return
+		}
+
 		// Update state artifact
-		err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, configObject.ConfigName)
+		err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, configObject.ConfigName, peers)
 		if err != nil {
 			log.Println(err)
 			err := &AppError{
@@ -315,6 +336,8 @@ func (s *Server) deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
 
 	committed = true
 
+	s.publishEvent(events.Event{Type: events.MembershipChanged, Group: groupName}, groupSatelliteIDs(satellites)...)
+
 	err = utils.DeleteArtifact(utils.ConstructHarborDeleteURL(groupName, "group"))
 	if err != nil {
 		log.Println(err)
@@ -340,6 +363,52 @@ func (s *Server) listGroupHandler(w http.ResponseWriter, r *http.Request) {
 	WriteJSONResponse(w, http.StatusOK, result)
 }
 
+// SatelliteConvergence tells whether a satellite of a group applied the group
+// state that was published last.
+type SatelliteConvergence struct {
+	Satellite       string `json:"satellite"`
+	AppliedDigest   string `json:"applied_digest"`
+	PublishedDigest string `json:"published_digest"`
+	Converged       bool   `json:"converged"`
+}
+
+// groupConvergenceHandler lists the satellites of a group with the group state
+// each of them applied last.
+func (s *Server) groupConvergenceHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupName := vars["group"]
+
+	exists, err := s.dbQueries.CheckGroupExists(r.Context(), groupName)
+	if err != nil {
+		log.Printf("error: failed to get group : %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to get group", Code: http.StatusInternalServerError})
+		return
+	}
+	if !exists {
+		HandleAppError(w, &AppError{Message: "error: group not found", Code: http.StatusNotFound})
+		return
+	}
+
+	rows, err := s.dbQueries.ListGroupConvergence(r.Context(), groupName)
+	if err != nil {
+		log.Printf("error: failed to get group convergence: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to get group convergence", Code: http.StatusInternalServerError})
+		return
+	}
+
+	result := make([]SatelliteConvergence, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, SatelliteConvergence{
+			Satellite:       row.SatelliteName,
+			AppliedDigest:   row.AppliedDigest,
+			PublishedDigest: row.PublishedDigest,
+			Converged:       isConverged(row.AppliedDigest, row.PublishedDigest),
+		})
+	}
+
+	WriteJSONResponse(w, http.StatusOK, result)
+}
+
 // groupSatelliteHandler lists all satellites attached to a specific group
 func (s *Server) groupSatelliteHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -278,6 +278,18 @@ func fetchSatelliteConfig(ctx context.Context, dbQueries *database.Queries, sate
 	return configObject, nil
 }
 
+func fetchSatellitePeers(ctx context.Context, dbQueries *database.Queries, satelliteID int32) ([]string, error) {
+	peers, err := dbQueries.ListSatellitePeers(ctx, satelliteID)
+	if err != nil {
+		log.Printf("Error: Failed to fetch satellite peers: %v", err)
+		return nil, &AppError{
+			Message: "Error: Failed to fetch satellite peers",
+			Code:    http.StatusInternalServerError,
+		}
+	}
+	return peers, nil
+}
+
 func toNullString(s string) sql.NullString {
 	return sql.NullString{String: s, Valid: s != ""}
 }
@@ -40,6 +40,7 @@ func (s *Server) RegisterRoutes() http.Handler {
 	api.HandleFunc("/groups/sync", s.groupsSyncHandler).Methods("POST")
 	api.HandleFunc("/groups/{group}", s.getGroupHandler).Methods("GET")
 	api.HandleFunc("/groups/{group}/satellites", s.groupSatelliteHandler).Methods("GET")
+	api.HandleFunc("/groups/{group}/convergence", s.groupConvergenceHandler).Methods("GET")
 	api.HandleFunc("/groups/satellite", s.addSatelliteToGroup).Methods("POST")
 	api.HandleFunc("/groups/satellite", s.removeSatelliteFromGroup).Methods("DELETE")
 	api.HandleFunc("/groups/{group}", s.RequireRole(roleSystemAdmin, s.deleteGroupHandler)).Methods("DELETE")
@@ -57,10 +58,18 @@ func (s *Server) RegisterRoutes() http.Handler {
 	api.HandleFunc("/satellites", s.registerSatelliteHandler).Methods("POST")
 	api.HandleFunc("/satellites/active", s.getActiveSatellitesHandler).Methods("GET")
 	api.HandleFunc("/satellites/stale", s.getStaleSatellitesHandler).Methods("GET")
+	api.HandleFunc("/satellites/signatures/rejected", s.getRejectedSignaturesHandler).Methods("GET")
 	api.HandleFunc("/satellites/{satellite}", s.GetSatelliteByName).Methods("GET")
 	api.HandleFunc("/satellites/{satellite}", s.DeleteSatelliteByName).Methods("DELETE")
 	api.HandleFunc("/satellites/{satellite}/status", s.getSatelliteStatusHandler).Methods("GET")
 	api.HandleFunc("/satellites/{satellite}/images", s.getCachedImagesHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/signatures", s.getSatelliteSignaturesHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/rollbacks", s.getSatelliteConfigRollbacksHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/evictions", s.getSatelliteEvictionsHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/restarts", s.getSatelliteRegistryRestartsHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/sync", s.getSatelliteSyncHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/peers", s.getSatellitePeersHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/peers", s.setSatellitePeersHandler).Methods("PUT")
 
 	// SPIRE management (admin only)
 	api.HandleFunc("/spire/status", s.RequireRole(roleSystemAdmin, s.spireStatusHandler)).Methods("GET")
@@ -84,5 +93,16 @@ func (s *Server) RegisterRoutes() http.Handler {
 	// Sync (dual auth: robot credentials or SPIFFE)
 	satellites.HandleFunc("/sync", s.syncHandler).Methods("POST")
 
+	// State change notifications (long-poll, robot credentials or SPIFFE)
+	satelliteEvents := satellites.PathPrefix("/events").Subrouter()
+	satelliteEvents.Use(spiffe.AuthMiddleware)
+	satelliteEvents.HandleFunc("", s.eventsHandler).Methods("GET")
+
+	// State public key for satellites registered before states were signed
+	// (robot credentials or SPIFFE)
+	stateKey := satellites.PathPrefix("/state-key").Subrouter()
+	stateKey.Use(spiffe.AuthMiddleware)
+	stateKey.HandleFunc("", s.stateKeyHandler).Methods("GET")
+
 	return r
 }
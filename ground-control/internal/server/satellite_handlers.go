@@ -1,15 +1,21 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/container-registry/harbor-satellite/ground-control/internal/database"
+	"github.com/container-registry/harbor-satellite/ground-control/internal/events"
 	"github.com/container-registry/harbor-satellite/ground-control/internal/spiffe"
 	"github.com/container-registry/harbor-satellite/ground-control/internal/utils"
 	"github.com/container-registry/harbor-satellite/ground-control/reg/harbor"
@@ -28,6 +34,18 @@ type RegisterSatelliteParams struct {
 	ConfigName string    `json:"config_name"`
 }
 
+// ZtrResponse is the state config a satellite receives when it registers,
+// along with the public key its state artifacts are signed with.
+type ZtrResponse struct {
+	config.StateConfig
+	StatePublicKey string `json:"state_public_key,omitempty"`
+}
+
+// StateKeyResponse is the public key state artifacts are signed with.
+type StateKeyResponse struct {
+	StatePublicKey string `json:"state_public_key"`
+}
+
 type RegisterSatelliteResponse struct {
 	Token string `json:"token"`
 }
@@ -37,19 +55,98 @@ type CachedImage struct {
 	SizeBytes int64  `json:"size_bytes"`
 }
 
+// ReportedEntity identifies the image a satellite report applies to.
+type ReportedEntity struct {
+	Name       string `json:"name"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+}
+
+// SignatureReport is the latest signature verdict a satellite reached for an
+// image of one of its groups.
+type SignatureReport struct {
+	Group     string         `json:"group"`
+	Entity    ReportedEntity `json:"entity"`
+	Verdict   string         `json:"verdict"`
+	Signer    string         `json:"signer,omitempty"`
+	Reason    string         `json:"reason,omitempty"`
+	CheckedAt time.Time      `json:"checked_at"`
+}
+
+// DeadLetterReport is an image a satellite gave up replicating after it
+// exhausted its retries, until NextRetryAt.
+type DeadLetterReport struct {
+	Group         string         `json:"group"`
+	Entity        ReportedEntity `json:"entity"`
+	Error         string         `json:"error"`
+	Failures      int            `json:"failures"`
+	FirstFailedAt time.Time      `json:"first_failed_at"`
+	LastFailedAt  time.Time      `json:"last_failed_at"`
+	NextRetryAt   time.Time      `json:"next_retry_at"`
+}
+
+// TombstoneReport is an image that left its group state and that a satellite
+// keeps until PurgeAfter. Hold says why a due image was not deleted yet, e.g.
+// because a container still uses it.
+type TombstoneReport struct {
+	Group      string         `json:"group"`
+	Entity     ReportedEntity `json:"entity"`
+	RemovedAt  time.Time      `json:"removed_at"`
+	PurgeAfter time.Time      `json:"purge_after"`
+	Hold       string         `json:"hold,omitempty"`
+}
+
+// ConfigRollbackReport describes a config a satellite rolled back because it
+// failed its health checks after being applied.
+type ConfigRollbackReport struct {
+	Digest         string    `json:"digest"`
+	RestoredDigest string    `json:"restored_digest,omitempty"`
+	Reason         string    `json:"reason"`
+	RolledBackAt   time.Time `json:"rolled_back_at"`
+}
+
+// EvictionReport describes an image a satellite removed from its local
+// registry to stay within its storage quota.
+type EvictionReport struct {
+	Reference    string    `json:"reference"`
+	Digest       string    `json:"digest"`
+	FreedBytes   int64     `json:"freed_bytes"`
+	LastPulledAt time.Time `json:"last_pulled_at,omitzero"`
+	EvictedAt    time.Time `json:"evicted_at"`
+}
+
+// RegistryRestartReport describes the outcome of a satellite applying a new
+// config to its embedded registry.
+type RegistryRestartReport struct {
+	At      time.Time `json:"at"`
+	Outcome string    `json:"outcome"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
 type SatelliteStatusParams struct {
-	Name                string        `json:"name"`
-	Activity            string        `json:"activity"`
-	StateReportInterval string        `json:"state_report_interval"`
-	LatestStateDigest   string        `json:"latest_state_digest"`
-	LatestConfigDigest  string        `json:"latest_config_digest"`
-	MemoryUsedBytes     uint64        `json:"memory_used_bytes"`
-	StorageUsedBytes    uint64        `json:"storage_used_bytes"`
-	CPUPercent          float64       `json:"cpu_percent"`
-	RequestCreatedTime  time.Time     `json:"request_created_time"`
-	LastSyncDurationMs  int64         `json:"last_sync_duration_ms"`
-	ImageCount          int           `json:"image_count"`
-	CachedImages        []CachedImage `json:"cached_images,omitempty"`
+	Name                string                 `json:"name"`
+	Activity            string                 `json:"activity"`
+	StateReportInterval string                 `json:"state_report_interval"`
+	LatestStateDigest   string                 `json:"latest_state_digest"`
+	LatestConfigDigest  string                 `json:"latest_config_digest"`
+	GroupStateDigests   map[string]string      `json:"group_state_digests,omitempty"`
+	MemoryUsedBytes     uint64                 `json:"memory_used_bytes"`
+	StorageUsedBytes    uint64                 `json:"storage_used_bytes"`
+	CPUPercent          float64                `json:"cpu_percent"`
+	RequestCreatedTime  time.Time              `json:"request_created_time"`
+	LastSyncAt          time.Time              `json:"last_sync_at,omitzero"`
+	LastSyncDurationMs  int64                  `json:"last_sync_duration_ms"`
+	LastSyncErrors      int                    `json:"last_sync_errors"`
+	LastSyncBytes       int64                  `json:"last_sync_bytes_transferred"`
+	ImageCount          int                    `json:"image_count"`
+	CachedImages        []CachedImage          `json:"cached_images,omitempty"`
+	DeadLetter          []DeadLetterReport     `json:"dead_letter,omitempty"`
+	Signatures          []SignatureReport      `json:"signatures,omitempty"`
+	Tombstones          []TombstoneReport      `json:"tombstones,omitempty"`
+	ConfigRollback      *ConfigRollbackReport  `json:"config_rollback,omitempty"`
+	Evictions           []EvictionReport       `json:"evictions,omitempty"`
+	RegistryRestart     *RegistryRestartReport `json:"registry_restart,omitempty"`
 }
 
 func (s *Server) registerSatelliteHandler(w http.ResponseWriter, r *http.Request) {
@@ -220,7 +317,7 @@ func (s *Server) registerSatelliteHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	// Create the satellite's state artifact
-	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), req.Name, groupStates, req.ConfigName)
+	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), req.Name, groupStates, req.ConfigName, nil)
 	if err != nil {
 		log.Println(err)
 		HandleAppError(w, err)
@@ -352,8 +449,14 @@ func (s *Server) ztrHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	peers, err := fetchSatellitePeers(r.Context(), s.dbQueries, satelliteID)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
 	// For sanity, create (update) the state artifact during the registration process as well.
-	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), satellite.Name, states, configObject.ConfigName)
+	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), satellite.Name, states, configObject.ConfigName, peers)
 	if err != nil {
 		log.Println(err)
 		HandleAppError(w, err)
@@ -383,7 +486,7 @@ func (s *Server) ztrHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, result)
+	WriteJSONResponse(w, http.StatusOK, ZtrResponse{StateConfig: result, StatePublicKey: utils.StatePublicKeyPEM()})
 }
 
 // spiffeZtrHandler handles Zero-Touch Registration using SPIFFE mTLS authentication.
@@ -513,7 +616,13 @@ func (s *Server) spiffeZtrHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = utils.CreateOrUpdateSatStateArtifact(r.Context(), satellite.Name, states, configObject.ConfigName)
+		peers, err := fetchSatellitePeers(r.Context(), s.dbQueries, satellite.ID)
+		if err != nil {
+			HandleAppError(w, err)
+			return
+		}
+
+		err = utils.CreateOrUpdateSatStateArtifact(r.Context(), satellite.Name, states, configObject.ConfigName, peers)
 		if err != nil {
 			log.Printf("SPIFFE ZTR: Failed to create state artifact: %v", err)
 			HandleAppError(w, err)
@@ -541,7 +650,18 @@ func (s *Server) spiffeZtrHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("SPIFFE ZTR: Successfully registered satellite %s", satelliteName)
-	WriteJSONResponse(w, http.StatusOK, result)
+	WriteJSONResponse(w, http.StatusOK, ZtrResponse{StateConfig: result, StatePublicKey: utils.StatePublicKeyPEM()})
+}
+
+// stateKeyHandler hands the state public key to satellites that registered
+// before Ground Control signed state artifacts. It is empty if they are not
+// signed.
+func (s *Server) stateKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.authenticateSatellite(r); err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	WriteJSONResponse(w, http.StatusOK, StateKeyResponse{StatePublicKey: utils.StatePublicKeyPEM()})
 }
 
 func (s *Server) listSatelliteHandler(w http.ResponseWriter, r *http.Request) {
@@ -592,6 +712,25 @@ func (s *Server) syncHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The status row and every report it carries are stored together, so a
+	// heartbeat the satellite resends after a failure is stored only once.
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to save status", Code: http.StatusInternalServerError})
+		return
+	}
+	q := s.dbQueries.WithTx(tx)
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Printf("Error: Failed to rollback heartbeat: %v", err)
+			}
+		}
+	}()
+
 	var artifactIDs []int32
 	if len(req.CachedImages) > 0 {
 		refs := make([]string, len(req.CachedImages))
@@ -601,7 +740,7 @@ func (s *Server) syncHandler(w http.ResponseWriter, r *http.Request) {
 			sizes[i] = img.SizeBytes
 		}
 
-		err := s.dbQueries.BatchInsertArtifacts(r.Context(), database.BatchInsertArtifactsParams{
+		err := q.BatchInsertArtifacts(r.Context(), database.BatchInsertArtifactsParams{
 			Refs:  refs,
 			Sizes: sizes,
 		})
@@ -611,7 +750,7 @@ func (s *Server) syncHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		artifacts, err := s.dbQueries.GetArtifactIDsByReferences(r.Context(), refs)
+		artifacts, err := q.GetArtifactIDsByReferences(r.Context(), refs)
 		if err != nil {
 			log.Printf("Failed to resolve artifact IDs: %v", err)
 			HandleAppError(w, &AppError{Message: "failed to resolve artifact IDs", Code: http.StatusInternalServerError})
@@ -625,7 +764,7 @@ func (s *Server) syncHandler(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Stored %d artifacts for satellite %s", len(artifactIDs), satelliteName)
 	}
 
-	_, err = s.dbQueries.InsertSatelliteStatus(r.Context(), database.InsertSatelliteStatusParams{
+	_, err = q.InsertSatelliteStatus(r.Context(), database.InsertSatelliteStatusParams{
 		SatelliteID:        sat.ID,
 		Activity:           req.Activity,
 		LatestStateDigest:  toNullString(req.LatestStateDigest),
@@ -644,7 +783,79 @@ func (s *Server) syncHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.dbQueries.UpdateSatelliteLastSeen(r.Context(), database.UpdateSatelliteLastSeenParams{
+	if err := storeSatelliteDeadLetters(r.Context(), q, sat.ID, req.DeadLetter); err != nil {
+		log.Printf("Failed to store dead-letter list: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to save dead-letter list", Code: http.StatusInternalServerError})
+		return
+	}
+
+	if err := storeSatelliteTombstones(r.Context(), q, sat.ID, req.Tombstones); err != nil {
+		log.Printf("Failed to store pending deletions: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to save pending deletions", Code: http.StatusInternalServerError})
+		return
+	}
+
+	if len(req.Signatures) > 0 {
+		if err := storeSatelliteSignatures(r.Context(), q, sat.ID, req.Signatures); err != nil {
+			log.Printf("Failed to store signature verdicts: %v", err)
+			HandleAppError(w, &AppError{Message: "failed to save signature verdicts", Code: http.StatusInternalServerError})
+			return
+		}
+	}
+
+	// A satellite resends a rollback until it gets a 200, so a rollback
+	// stored by a heartbeat whose response was lost is ignored.
+	if rb := req.ConfigRollback; rb != nil {
+		err := q.InsertSatelliteConfigRollback(r.Context(), database.InsertSatelliteConfigRollbackParams{
+			SatelliteID:    sat.ID,
+			Digest:         rb.Digest,
+			RestoredDigest: rb.RestoredDigest,
+			Reason:         rb.Reason,
+			RolledBackAt:   rb.RolledBackAt,
+		})
+		if err != nil {
+			log.Printf("Failed to store config rollback: %v", err)
+			HandleAppError(w, &AppError{Message: "failed to save config rollback", Code: http.StatusInternalServerError})
+			return
+		}
+		log.Printf("Satellite %s rolled back config %s: %s", satelliteName, rb.Digest, rb.Reason)
+	}
+
+	// Like rollbacks, a restart is resent until a heartbeat succeeds.
+	if rr := req.RegistryRestart; rr != nil {
+		err := q.InsertSatelliteRegistryRestart(r.Context(), database.InsertSatelliteRegistryRestartParams{
+			SatelliteID: sat.ID,
+			Outcome:     rr.Outcome,
+			Reason:      rr.Reason,
+			RestartedAt: rr.At,
+		})
+		if err != nil {
+			log.Printf("Failed to store registry restart: %v", err)
+			HandleAppError(w, &AppError{Message: "failed to save registry restart", Code: http.StatusInternalServerError})
+			return
+		}
+		log.Printf("Satellite %s applied a new registry config: %s", satelliteName, rr.Outcome)
+	}
+
+	if len(req.Evictions) > 0 {
+		if err := storeSatelliteEvictions(r.Context(), q, sat.ID, req.Evictions); err != nil {
+			log.Printf("Failed to store evictions: %v", err)
+			HandleAppError(w, &AppError{Message: "failed to save evictions", Code: http.StatusInternalServerError})
+			return
+		}
+		log.Printf("Satellite %s evicted %d images to stay within its storage quota", satelliteName, len(req.Evictions))
+	}
+
+	// Heartbeats repeat the last complete sync until the next one finishes.
+	if !req.LastSyncAt.IsZero() {
+		if err := storeSatelliteSync(r.Context(), q, sat.ID, &req); err != nil {
+			log.Printf("Failed to store last sync: %v", err)
+			HandleAppError(w, &AppError{Message: "failed to save last sync", Code: http.StatusInternalServerError})
+			return
+		}
+	}
+
+	err = q.UpdateSatelliteLastSeen(r.Context(), database.UpdateSatelliteLastSeenParams{
 		ID:                sat.ID,
 		HeartbeatInterval: toNullString(normalizedInterval),
 	})
@@ -654,9 +865,161 @@ func (s *Server) syncHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit heartbeat: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to save status", Code: http.StatusInternalServerError})
+		return
+	}
+	committed = true
+
 	w.WriteHeader(http.StatusOK)
 }
 
+// storeSatelliteSignatures replaces the signature verdicts stored for a
+// satellite with the ones it reported. A satellite reports every verdict it
+// holds, so the stored set always mirrors its latest heartbeat.
+func storeSatelliteSignatures(ctx context.Context, q *database.Queries, satelliteID int32, reports []SignatureReport) error {
+	params := database.BatchInsertSatelliteSignaturesParams{SatelliteID: satelliteID}
+	for _, r := range reports {
+		params.GroupNames = append(params.GroupNames, r.Group)
+		params.Repositories = append(params.Repositories, r.Entity.Repository)
+		params.Names = append(params.Names, r.Entity.Name)
+		params.Tags = append(params.Tags, r.Entity.Tag)
+		params.Digests = append(params.Digests, r.Entity.Digest)
+		params.Verdicts = append(params.Verdicts, r.Verdict)
+		params.Signers = append(params.Signers, r.Signer)
+		params.Reasons = append(params.Reasons, r.Reason)
+		params.CheckedAts = append(params.CheckedAts, r.CheckedAt)
+	}
+
+	if err := q.ClearSatelliteSignatures(ctx, satelliteID); err != nil {
+		return err
+	}
+	return q.BatchInsertSatelliteSignatures(ctx, params)
+}
+
+// storeSatelliteDeadLetters replaces the dead-letter list stored for a
+// satellite with the one it reported. Every heartbeat carries the whole list,
+// so one without entries clears it.
+func storeSatelliteDeadLetters(ctx context.Context, q *database.Queries, satelliteID int32, reports []DeadLetterReport) error {
+	if err := q.ClearSatelliteDeadLetters(ctx, satelliteID); err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		return nil
+	}
+
+	params := database.BatchInsertSatelliteDeadLettersParams{SatelliteID: satelliteID}
+	for _, r := range reports {
+		params.GroupNames = append(params.GroupNames, r.Group)
+		params.Repositories = append(params.Repositories, r.Entity.Repository)
+		params.Names = append(params.Names, r.Entity.Name)
+		params.Tags = append(params.Tags, r.Entity.Tag)
+		params.Digests = append(params.Digests, r.Entity.Digest)
+		params.Errors = append(params.Errors, r.Error)
+		params.Failures = append(params.Failures, int32(r.Failures))
+		params.FirstFailedAts = append(params.FirstFailedAts, r.FirstFailedAt)
+		params.LastFailedAts = append(params.LastFailedAts, r.LastFailedAt)
+		params.NextRetryAts = append(params.NextRetryAts, r.NextRetryAt)
+	}
+	return q.BatchInsertSatelliteDeadLetters(ctx, params)
+}
+
+// storeSatelliteTombstones replaces the pending deletions stored for a
+// satellite with the ones it reported. Like the dead-letter list, every
+// heartbeat carries all of them.
+func storeSatelliteTombstones(ctx context.Context, q *database.Queries, satelliteID int32, reports []TombstoneReport) error {
+	if err := q.ClearSatelliteTombstones(ctx, satelliteID); err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		return nil
+	}
+
+	params := database.BatchInsertSatelliteTombstonesParams{SatelliteID: satelliteID}
+	for _, r := range reports {
+		params.GroupNames = append(params.GroupNames, r.Group)
+		params.Repositories = append(params.Repositories, r.Entity.Repository)
+		params.Names = append(params.Names, r.Entity.Name)
+		params.Tags = append(params.Tags, r.Entity.Tag)
+		params.Digests = append(params.Digests, r.Entity.Digest)
+		params.RemovedAts = append(params.RemovedAts, r.RemovedAt)
+		params.PurgeAfters = append(params.PurgeAfters, r.PurgeAfter)
+		params.Holds = append(params.Holds, r.Hold)
+	}
+	return q.BatchInsertSatelliteTombstones(ctx, params)
+}
+
+// storeSatelliteEvictions records the images a satellite evicted. A satellite
+// resends its evictions until it gets a 200, so the ones already stored by a
+// heartbeat whose response was lost are ignored.
+func storeSatelliteEvictions(ctx context.Context, q *database.Queries, satelliteID int32, reports []EvictionReport) error {
+	for _, e := range reports {
+		err := q.InsertSatelliteEviction(ctx, database.InsertSatelliteEvictionParams{
+			SatelliteID:  satelliteID,
+			Reference:    e.Reference,
+			Digest:       e.Digest,
+			FreedBytes:   e.FreedBytes,
+			LastPulledAt: sql.NullTime{Time: e.LastPulledAt, Valid: !e.LastPulledAt.IsZero()},
+			EvictedAt:    e.EvictedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeSatelliteSync records the last complete sync of a satellite along with
+// the digest of the group state it applied for each of its groups.
+func storeSatelliteSync(ctx context.Context, q *database.Queries, satelliteID int32, req *SatelliteStatusParams) error {
+	groups := make([]string, 0, len(req.GroupStateDigests))
+	for group := range req.GroupStateDigests {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	digests := make([]string, len(groups))
+	for i, group := range groups {
+		digests[i] = req.GroupStateDigests[group]
+	}
+
+	err := q.UpsertSatelliteSync(ctx, database.UpsertSatelliteSyncParams{
+		SatelliteID:      satelliteID,
+		StateDigest:      req.LatestStateDigest,
+		ConfigDigest:     req.LatestConfigDigest,
+		FinishedAt:       req.LastSyncAt,
+		DurationMs:       req.LastSyncDurationMs,
+		Errors:           int32(req.LastSyncErrors),
+		BytesTransferred: req.LastSyncBytes,
+	})
+	if err != nil {
+		return err
+	}
+	if err := q.ClearSatelliteGroupStates(ctx, satelliteID); err != nil {
+		return err
+	}
+	if len(groups) > 0 {
+		err = q.BatchInsertSatelliteGroupStates(ctx, database.BatchInsertSatelliteGroupStatesParams{
+			SatelliteID:  satelliteID,
+			GroupNames:   groups,
+			StateDigests: digests,
+			SyncedAt:     req.LastSyncAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SatelliteStatusResponse is the latest status a satellite reported along
+// with the images it has dead-lettered and the deletions it has pending.
+type SatelliteStatusResponse struct {
+	database.SatelliteStatus
+	DeadLetter []database.SatelliteDeadLetter `json:"dead_letter"`
+	Tombstones []database.SatelliteTombstone  `json:"tombstones"`
+}
+
 func (s *Server) getSatelliteStatusHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	satelliteName := vars["satellite"]
@@ -673,7 +1036,25 @@ func (s *Server) getSatelliteStatusHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, status)
+	deadLetters, err := s.dbQueries.ListSatelliteDeadLetters(r.Context(), sat.ID)
+	if err != nil {
+		log.Printf("Failed to get dead-letter list: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to get dead-letter list", Code: http.StatusInternalServerError})
+		return
+	}
+
+	tombstones, err := s.dbQueries.ListSatelliteTombstones(r.Context(), sat.ID)
+	if err != nil {
+		log.Printf("Failed to get pending deletions: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to get pending deletions", Code: http.StatusInternalServerError})
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, SatelliteStatusResponse{
+		SatelliteStatus: status,
+		DeadLetter:      append([]database.SatelliteDeadLetter{}, deadLetters...),
+		Tombstones:      append([]database.SatelliteTombstone{}, tombstones...),
+	})
 }
 
 func (s *Server) getActiveSatellitesHandler(w http.ResponseWriter, r *http.Request) {
@@ -1173,6 +1554,12 @@ func (s *Server) addSatelliteToGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	peers, err := fetchSatellitePeers(r.Context(), s.dbQueries, sat.ID)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
 	// Get robot account permissions
 	robotAcc, err := s.dbQueries.GetRobotAccBySatelliteID(r.Context(), sat.ID)
 	if err != nil {
@@ -1198,7 +1585,7 @@ func (s *Server) addSatelliteToGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update the state artifact to also track the new group state artifact
-	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, configObject.ConfigName)
+	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, configObject.ConfigName, peers)
 	if err != nil {
 		log.Printf("Error: Failed to update satellite state artifact: %v", err)
 		HandleAppError(w, err)
@@ -1215,6 +1602,8 @@ func (s *Server) addSatelliteToGroup(w http.ResponseWriter, r *http.Request) {
 	}
 	committed = true
 
+	s.publishEvent(events.Event{Type: events.MembershipChanged, Group: grp.GroupName}, sat.ID)
+
 	WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Satellite successfully added to group"})
 }
 
@@ -1350,8 +1739,14 @@ func (s *Server) removeSatelliteFromGroup(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	peers, err := fetchSatellitePeers(r.Context(), q, sat.ID)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
 	// Update the state artifact to also track the new group state artifact
-	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, configObject.ConfigName)
+	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, configObject.ConfigName, peers)
 	if err != nil {
 		log.Println(err)
 		HandleAppError(w, err)
@@ -1368,6 +1763,8 @@ func (s *Server) removeSatelliteFromGroup(w http.ResponseWriter, r *http.Request
 	}
 	committed = true
 
+	s.publishEvent(events.Event{Type: events.MembershipChanged, Group: groupName}, sat.ID)
+
 	WriteJSONResponse(w, http.StatusOK, map[string]string{})
 }
 
@@ -1389,3 +1786,309 @@ func (s *Server) getCachedImagesHandler(w http.ResponseWriter, r *http.Request)
 
 	WriteJSONResponse(w, http.StatusOK, artifacts)
 }
+
+func (s *Server) getSatelliteSignaturesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	satelliteName := vars["satellite"]
+
+	sat, err := s.dbQueries.GetSatelliteByName(r.Context(), satelliteName)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "satellite not found", Code: http.StatusNotFound})
+		return
+	}
+
+	signatures, err := s.dbQueries.ListSatelliteSignatures(r.Context(), sat.ID)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "failed to get signature verdicts", Code: http.StatusInternalServerError})
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, signatures)
+}
+
+func (s *Server) getSatelliteConfigRollbacksHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	satelliteName := vars["satellite"]
+
+	sat, err := s.dbQueries.GetSatelliteByName(r.Context(), satelliteName)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "satellite not found", Code: http.StatusNotFound})
+		return
+	}
+
+	rollbacks, err := s.dbQueries.ListSatelliteConfigRollbacks(r.Context(), sat.ID)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "failed to get config rollbacks", Code: http.StatusInternalServerError})
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, rollbacks)
+}
+
+func (s *Server) getSatelliteEvictionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	satelliteName := vars["satellite"]
+
+	sat, err := s.dbQueries.GetSatelliteByName(r.Context(), satelliteName)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "satellite not found", Code: http.StatusNotFound})
+		return
+	}
+
+	evictions, err := s.dbQueries.ListSatelliteEvictions(r.Context(), sat.ID)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "failed to get evictions", Code: http.StatusInternalServerError})
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, evictions)
+}
+
+func (s *Server) getSatelliteRegistryRestartsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	satelliteName := vars["satellite"]
+
+	sat, err := s.dbQueries.GetSatelliteByName(r.Context(), satelliteName)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "satellite not found", Code: http.StatusNotFound})
+		return
+	}
+
+	restarts, err := s.dbQueries.ListSatelliteRegistryRestarts(r.Context(), sat.ID)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "failed to get registry restarts", Code: http.StatusInternalServerError})
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, restarts)
+}
+
+// GroupConvergence compares the group state a satellite last applied with the
+// one Ground Control last pushed for the group.
+type GroupConvergence struct {
+	Group           string `json:"group"`
+	AppliedDigest   string `json:"applied_digest"`
+	PublishedDigest string `json:"published_digest"`
+	Converged       bool   `json:"converged"`
+}
+
+// SatelliteSyncResponse is the last complete sync of a satellite and whether
+// it applied the latest state of every group it belongs to.
+type SatelliteSyncResponse struct {
+	LastSync  *database.SatelliteSync `json:"last_sync"`
+	Groups    []GroupConvergence      `json:"groups"`
+	Converged bool                    `json:"converged"`
+}
+
+// isConverged reports whether a satellite applied the group state that was
+// published last. A group Ground Control has not published a digest for, e.g.
+// one created before digests were recorded, never counts as converged.
+func isConverged(applied, published string) bool {
+	return published != "" && applied == published
+}
+
+func (s *Server) getSatelliteSyncHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	satelliteName := vars["satellite"]
+
+	sat, err := s.dbQueries.GetSatelliteByName(r.Context(), satelliteName)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "satellite not found", Code: http.StatusNotFound})
+		return
+	}
+
+	resp := SatelliteSyncResponse{Groups: []GroupConvergence{}, Converged: true}
+
+	lastSync, err := s.dbQueries.GetSatelliteSync(r.Context(), sat.ID)
+	switch {
+	case err == nil:
+		resp.LastSync = &lastSync
+	case !errors.Is(err, sql.ErrNoRows):
+		log.Printf("Failed to get last sync: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to get last sync", Code: http.StatusInternalServerError})
+		return
+	}
+
+	groups, err := s.dbQueries.ListSatelliteGroupConvergence(r.Context(), sat.ID)
+	if err != nil {
+		log.Printf("Failed to get group convergence: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to get group convergence", Code: http.StatusInternalServerError})
+		return
+	}
+	for _, g := range groups {
+		converged := isConverged(g.AppliedDigest, g.PublishedDigest)
+		resp.Groups = append(resp.Groups, GroupConvergence{
+			Group:           g.GroupName,
+			AppliedDigest:   g.AppliedDigest,
+			PublishedDigest: g.PublishedDigest,
+			Converged:       converged,
+		})
+		resp.Converged = resp.Converged && converged
+	}
+
+	WriteJSONResponse(w, http.StatusOK, resp)
+}
+
+// getRejectedSignaturesHandler lists, across the fleet, the images satellites
+// refused to replicate because their signatures did not verify.
+func (s *Server) getRejectedSignaturesHandler(w http.ResponseWriter, r *http.Request) {
+	rejected, err := s.dbQueries.ListRejectedSignatures(r.Context())
+	if err != nil {
+		log.Printf("Failed to get rejected signatures: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to get rejected signatures", Code: http.StatusInternalServerError})
+		return
+	}
+	WriteJSONResponse(w, http.StatusOK, rejected)
+}
+
+// maxSatellitePeers bounds the registries a satellite tries before Harbor.
+const maxSatellitePeers = 8
+
+// SatellitePeersParams lists the registries of the satellites a satellite
+// fetches blobs from before falling back to Harbor, in the order they are
+// tried. A parent satellite in a hierarchy is a single peer; satellites on
+// one LAN list each other.
+type SatellitePeersParams struct {
+	Peers []string `json:"peers"`
+}
+
+// validatePeerURLs normalizes peer registry URLs and rejects anything but
+// http(s) registry roots.
+func validatePeerURLs(peers []string) ([]string, error) {
+	if len(peers) > maxSatellitePeers {
+		return nil, &AppError{
+			Message: fmt.Sprintf("Error: at most %d peers are allowed", maxSatellitePeers),
+			Code:    http.StatusBadRequest,
+		}
+	}
+	seen := make(map[string]bool, len(peers))
+	valid := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		u, err := url.Parse(strings.TrimSpace(peer))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+			return nil, &AppError{
+				Message: fmt.Sprintf("Error: invalid peer registry URL %q", peer),
+				Code:    http.StatusBadRequest,
+			}
+		}
+		normalized := u.Scheme + "://" + u.Host
+		if !seen[normalized] {
+			seen[normalized] = true
+			valid = append(valid, normalized)
+		}
+	}
+	return valid, nil
+}
+
+func (s *Server) getSatellitePeersHandler(w http.ResponseWriter, r *http.Request) {
+	satelliteName := mux.Vars(r)["satellite"]
+
+	sat, err := s.dbQueries.GetSatelliteByName(r.Context(), satelliteName)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "satellite not found", Code: http.StatusNotFound})
+		return
+	}
+
+	peers, err := fetchSatellitePeers(r.Context(), s.dbQueries, sat.ID)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	if peers == nil {
+		peers = []string{}
+	}
+
+	WriteJSONResponse(w, http.StatusOK, SatellitePeersParams{Peers: peers})
+}
+
+// setSatellitePeersHandler replaces the peers of a satellite and republishes
+// its satellite state, which carries them.
+func (s *Server) setSatellitePeersHandler(w http.ResponseWriter, r *http.Request) {
+	satelliteName := mux.Vars(r)["satellite"]
+
+	var req SatellitePeersParams
+	if err := DecodeRequestBody(r, &req); err != nil {
+		log.Println("Error decoding request body: ", err)
+		HandleAppError(w, err)
+		return
+	}
+	peers, err := validatePeerURLs(req.Peers)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Println("Could not begin transaction:", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil {
+				log.Printf("Error: Failed to rollback transaction for failed process: %v", err)
+			}
+		}
+	}()
+
+	q := s.dbQueries.WithTx(tx)
+
+	sat, err := q.GetSatelliteByName(r.Context(), satelliteName)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "satellite not found", Code: http.StatusNotFound})
+		return
+	}
+
+	if err := q.ClearSatellitePeers(r.Context(), sat.ID); err != nil {
+		log.Printf("Error: Failed to clear satellite peers: %v", err)
+		HandleAppError(w, &AppError{Message: "Error: Failed to set satellite peers", Code: http.StatusInternalServerError})
+		return
+	}
+	for i, peer := range peers {
+		if err := q.AddSatellitePeer(r.Context(), database.AddSatellitePeerParams{SatelliteID: sat.ID, Position: int32(i), Url: peer}); err != nil {
+			log.Printf("Error: Failed to add satellite peer: %v", err)
+			HandleAppError(w, &AppError{Message: "Error: Failed to set satellite peers", Code: http.StatusInternalServerError})
+			return
+		}
+	}
+
+	groups, err := q.SatelliteGroupList(r.Context(), sat.ID)
+	if err != nil {
+		log.Printf("failed to list groups for satellite: %v, %v", sat.ID, err)
+		HandleAppError(w, &AppError{Message: "Error: Satellite Groups List Failed", Code: http.StatusInternalServerError})
+		return
+	}
+	states, err := getGroupStates(r.Context(), groups, q)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	configObject, err := fetchSatelliteConfig(r.Context(), q, sat.ID)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	if err := utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, states, configObject.ConfigName, peers); err != nil {
+		log.Printf("Error: Failed to update satellite state artifact: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		HandleAppError(w, &AppError{
+			Message: "Error: Failed to commit transaction",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	committed = true
+
+	s.publishEvent(events.Event{Type: events.PeersChanged}, sat.ID)
+
+	WriteJSONResponse(w, http.StatusOK, SatellitePeersParams{Peers: peers})
+}
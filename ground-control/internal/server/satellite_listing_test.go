@@ -2,6 +2,7 @@ package server
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -165,6 +166,21 @@ func TestGetSatelliteStatusHandler(t *testing.T) {
 			WithArgs(int32(1)).
 			WillReturnRows(statusRows)
 
+		deadLetterRows := sqlmock.NewRows([]string{
+			"satellite_id", "group_name", "repository", "name", "tag", "digest", "error", "failures",
+			"first_failed_at", "last_failed_at", "next_retry_at",
+		}).AddRow(1, "edge", "library", "nginx", "1.27", "sha256:abc", "manifest unknown", 5, now, now, now.Add(time.Hour))
+		mock.ExpectQuery("SELECT .+ FROM satellite_dead_letters").
+			WithArgs(int32(1)).
+			WillReturnRows(deadLetterRows)
+
+		tombstoneRows := sqlmock.NewRows([]string{
+			"satellite_id", "group_name", "repository", "name", "tag", "digest", "removed_at", "purge_after", "hold",
+		}).AddRow(1, "edge", "library", "redis", "7", "sha256:def", now, now, "in_use")
+		mock.ExpectQuery("SELECT .+ FROM satellite_tombstones").
+			WithArgs(int32(1)).
+			WillReturnRows(tombstoneRows)
+
 		req := httptest.NewRequest(http.MethodGet, "/api/satellites/edge-01/status", nil)
 		req = mux.SetURLVars(req, map[string]string{"satellite": "edge-01"})
 
@@ -173,6 +189,26 @@ func TestGetSatelliteStatusHandler(t *testing.T) {
 
 		require.Equal(t, http.StatusOK, rr.Code)
 		require.Contains(t, rr.Body.String(), "syncing")
+
+		var resp struct {
+			DeadLetter []struct {
+				Name     string
+				Error    string
+				Failures int32
+			} `json:"dead_letter"`
+			Tombstones []struct {
+				Name string
+				Hold string
+			} `json:"tombstones"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		require.Len(t, resp.DeadLetter, 1)
+		require.Equal(t, "nginx", resp.DeadLetter[0].Name)
+		require.Equal(t, "manifest unknown", resp.DeadLetter[0].Error)
+		require.Equal(t, int32(5), resp.DeadLetter[0].Failures)
+		require.Len(t, resp.Tombstones, 1)
+		require.Equal(t, "redis", resp.Tombstones[0].Name)
+		require.Equal(t, "in_use", resp.Tombstones[0].Hold)
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
 
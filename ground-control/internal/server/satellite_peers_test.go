@@ -0,0 +1,68 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePeerURLs(t *testing.T) {
+	peers, err := validatePeerURLs([]string{"http://10.0.0.5:8585/", " https://parent.site.local ", "http://10.0.0.5:8585"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://10.0.0.5:8585", "https://parent.site.local"}, peers)
+
+	for _, invalid := range []string{"10.0.0.5:8585", "ftp://10.0.0.5", "http://", "http://10.0.0.5:8585/v2/library", "http://host?x=1"} {
+		_, err := validatePeerURLs([]string{invalid})
+		require.Error(t, err, invalid)
+	}
+
+	_, err = validatePeerURLs(make([]string, maxSatellitePeers+1))
+	require.Error(t, err)
+}
+
+func TestGetSatellitePeersHandler(t *testing.T) {
+	server, mock := newMockServer(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	satRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+		AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{})
+	mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+		WithArgs("edge-01").
+		WillReturnRows(satRows)
+	mock.ExpectQuery("SELECT url FROM satellite_peers").
+		WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"url"}).AddRow("http://10.0.0.5:8585").AddRow("http://10.0.0.6:8585"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/satellites/edge-01/peers", nil)
+	req = mux.SetURLVars(req, map[string]string{"satellite": "edge-01"})
+	rr := httptest.NewRecorder()
+	server.getSatellitePeersHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp SatellitePeersParams
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Equal(t, []string{"http://10.0.0.5:8585", "http://10.0.0.6:8585"}, resp.Peers)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetSatellitePeersHandler_InvalidPeer(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	body, err := json.Marshal(SatellitePeersParams{Peers: []string{"not a url"}})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPut, "/api/satellites/edge-01/peers", bytes.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"satellite": "edge-01"})
+	rr := httptest.NewRecorder()
+	server.setSatellitePeersHandler(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,397 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// expectSatelliteHeartbeat mocks the lookup of satellite edge-01, the
+// transaction every heartbeat is stored in, the status row it inserts and the
+// dead-letter list and pending deletions it clears when it reports none.
+func expectSatelliteHeartbeat(mock sqlmock.Sqlmock, now time.Time) {
+	expectSatelliteStatus(mock, now)
+	mock.ExpectExec("DELETE FROM satellite_dead_letters").WithArgs(int32(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM satellite_tombstones").WithArgs(int32(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// expectSatelliteStatus mocks the lookup of satellite edge-01, the
+// transaction every heartbeat is stored in and the status row it inserts.
+func expectSatelliteStatus(mock sqlmock.Sqlmock, now time.Time) {
+	satRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+		AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{})
+	mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+		WithArgs("edge-01").
+		WillReturnRows(satRows)
+	mock.ExpectBegin()
+
+	statusRows := sqlmock.NewRows([]string{
+		"id", "satellite_id", "activity", "latest_state_digest", "latest_config_digest",
+		"cpu_percent", "memory_used_bytes", "storage_used_bytes", "last_sync_duration_ms",
+		"image_count", "reported_at", "created_at", "artifact_ids",
+	}).AddRow(
+		1, 1, "", sql.NullString{}, sql.NullString{},
+		sql.NullString{}, sql.NullInt64{}, sql.NullInt64{}, sql.NullInt64{},
+		sql.NullInt32{Int32: 0, Valid: true}, now, now, pq.Array([]int32(nil)),
+	)
+	mock.ExpectQuery("INSERT INTO satellite_status").WillReturnRows(statusRows)
+}
+
+func TestSyncHandler_StoresConfigRollback(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	expectSatelliteHeartbeat(mock, now)
+
+	mock.ExpectExec("INSERT INTO satellite_config_rollbacks").
+		WithArgs(int32(1), "sha256:bad", "sha256:good", "registry unhealthy after apply", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"config_rollback": {
+			"digest": "sha256:bad",
+			"restored_digest": "sha256:good",
+			"reason": "registry unhealthy after apply",
+			"rolled_back_at": "2026-05-04T12:00:00Z"
+		}
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHandler_ConfigRollbackFailsToStore(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	expectSatelliteHeartbeat(mock, now)
+
+	mock.ExpectExec("INSERT INTO satellite_config_rollbacks").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"config_rollback": {"digest": "sha256:bad", "reason": "registry unhealthy after apply", "rolled_back_at": "2026-05-04T12:00:00Z"}
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	// The satellite keeps the rollback pending until a heartbeat succeeds.
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHandler_StoresEvictions(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	pulled := time.Date(2026, 5, 1, 8, 30, 0, 0, time.UTC)
+	expectSatelliteHeartbeat(mock, now)
+
+	mock.ExpectExec("INSERT INTO satellite_evictions").
+		WithArgs(int32(1), "library/nginx:1.25", "sha256:aaa", int64(4096), sql.NullTime{Time: pulled, Valid: true}, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO satellite_evictions").
+		WithArgs(int32(1), "library/redis:7", "sha256:bbb", int64(0), sql.NullTime{}, now).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"evictions": [
+			{
+				"reference": "library/nginx:1.25",
+				"digest": "sha256:aaa",
+				"freed_bytes": 4096,
+				"last_pulled_at": "2026-05-01T08:30:00Z",
+				"evicted_at": "2026-05-04T12:00:00Z"
+			},
+			{
+				"reference": "library/redis:7",
+				"digest": "sha256:bbb",
+				"freed_bytes": 0,
+				"evicted_at": "2026-05-04T12:00:00Z"
+			}
+		]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHandler_EvictionsFailToStore(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	expectSatelliteHeartbeat(mock, now)
+
+	mock.ExpectExec("INSERT INTO satellite_evictions").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"evictions": [{"reference": "library/nginx:1.25", "digest": "sha256:aaa", "evicted_at": "2026-05-04T12:00:00Z"}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	// The satellite keeps the evictions pending until a heartbeat succeeds.
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHandler_StoresRegistryRestart(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	expectSatelliteHeartbeat(mock, now)
+
+	mock.ExpectExec("INSERT INTO satellite_registry_restarts").
+		WithArgs(int32(1), "rejected", "config does not validate: unknown storage driver", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"registry_restart": {
+			"at": "2026-05-04T12:00:00Z",
+			"outcome": "rejected",
+			"reason": "config does not validate: unknown storage driver"
+		}
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHandler_StoresDeadLetters(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	first := now.Add(-time.Hour)
+	retry := now.Add(30 * time.Minute)
+	expectSatelliteStatus(mock, now)
+
+	mock.ExpectExec("DELETE FROM satellite_dead_letters").WithArgs(int32(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO satellite_dead_letters").
+		WithArgs(
+			int32(1),
+			pq.Array([]string{"edge"}),
+			pq.Array([]string{"library"}),
+			pq.Array([]string{"nginx"}),
+			pq.Array([]string{"1.27"}),
+			pq.Array([]string{"sha256:abc"}),
+			pq.Array([]string{"manifest unknown"}),
+			pq.Array([]int32{5}),
+			pq.Array([]time.Time{first}),
+			pq.Array([]time.Time{now}),
+			pq.Array([]time.Time{retry}),
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM satellite_tombstones").WithArgs(int32(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// The body is written the way the satellite encodes it.
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"dead_letter": [
+			{
+				"group": "edge",
+				"entity": {"name": "nginx", "repository": "library", "tag": "1.27", "digest": "sha256:abc"},
+				"error": "manifest unknown",
+				"failures": 5,
+				"first_failed_at": "2026-05-04T11:00:00Z",
+				"last_failed_at": "2026-05-04T12:00:00Z",
+				"next_retry_at": "2026-05-04T12:30:00Z"
+			}
+		]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHandler_DeadLettersFailToStore(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	expectSatelliteStatus(mock, now)
+
+	mock.ExpectExec("DELETE FROM satellite_dead_letters").WithArgs(int32(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO satellite_dead_letters").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"dead_letter": [{"group": "edge", "entity": {"name": "nginx", "repository": "library", "tag": "1.27"}, "error": "manifest unknown", "failures": 5}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHandler_StoresTombstones(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	removed := now.Add(-48 * time.Hour)
+	purge := now.Add(-time.Hour)
+	expectSatelliteStatus(mock, now)
+
+	mock.ExpectExec("DELETE FROM satellite_dead_letters").WithArgs(int32(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM satellite_tombstones").WithArgs(int32(1)).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO satellite_tombstones").
+		WithArgs(
+			int32(1),
+			pq.Array([]string{"edge", "edge"}),
+			pq.Array([]string{"library", "library"}),
+			pq.Array([]string{"nginx", "redis"}),
+			pq.Array([]string{"1.25", "7"}),
+			pq.Array([]string{"sha256:aaa", "sha256:bbb"}),
+			pq.Array([]time.Time{removed, removed}),
+			pq.Array([]time.Time{purge, now.Add(time.Hour)}),
+			pq.Array([]string{"in_use", ""}),
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// The body is written the way the satellite encodes it.
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"tombstones": [
+			{
+				"group": "edge",
+				"entity": {"name": "nginx", "repository": "library", "tag": "1.25", "digest": "sha256:aaa"},
+				"removed_at": "2026-05-02T12:00:00Z",
+				"purge_after": "2026-05-04T11:00:00Z",
+				"hold": "in_use"
+			},
+			{
+				"group": "edge",
+				"entity": {"name": "redis", "repository": "library", "tag": "7", "digest": "sha256:bbb"},
+				"removed_at": "2026-05-02T12:00:00Z",
+				"purge_after": "2026-05-04T13:00:00Z"
+			}
+		]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHandler_TombstonesFailToStore(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	expectSatelliteStatus(mock, now)
+
+	mock.ExpectExec("DELETE FROM satellite_dead_letters").WithArgs(int32(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM satellite_tombstones").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"tombstones": [{"group": "edge", "entity": {"name": "nginx", "repository": "library", "tag": "1.25"}, "hold": "in_use"}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHandler_LateStoreFailureRollsBackHeartbeat(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	expectSatelliteHeartbeat(mock, now)
+
+	mock.ExpectExec("INSERT INTO satellite_config_rollbacks").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO satellite_syncs").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"config_rollback": {"digest": "sha256:bad", "reason": "registry unhealthy after apply", "rolled_back_at": "2026-05-04T12:00:00Z"},
+		"latest_state_digest": "sha256:combined",
+		"last_sync_at": "2026-05-04T11:59:00Z"
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	// The status row and the rollback are discarded with the failed sync, so
+	// the heartbeat the satellite resends stores them only once.
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
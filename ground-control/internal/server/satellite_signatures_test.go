@@ -0,0 +1,170 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/container-registry/harbor-satellite/ground-control/internal/database"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncHandler_StoresSignatures(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	expectSatelliteHeartbeat(mock, now)
+
+	mock.ExpectExec("DELETE FROM satellite_signatures").
+		WithArgs(int32(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO satellite_signatures").
+		WithArgs(
+			int32(1),
+			pq.Array([]string{"edge", "edge"}),
+			pq.Array([]string{"library", "library"}),
+			pq.Array([]string{"nginx", "alpine"}),
+			pq.Array([]string{"1.27", "3.20"}),
+			pq.Array([]string{"sha256:abc", "sha256:def"}),
+			pq.Array([]string{"verified", "rejected"}),
+			pq.Array([]string{"release@example.com", ""}),
+			pq.Array([]string{"", "no signature matches the trusted keys"}),
+			pq.Array([]time.Time{now, now}),
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// The body is written the way the satellite encodes it, so that a field
+	// Ground Control fails to decode shows up as a missing argument.
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"signatures": [
+			{
+				"group": "edge",
+				"entity": {"name": "nginx", "repository": "library", "tag": "1.27", "digest": "sha256:abc"},
+				"verdict": "verified",
+				"signer": "release@example.com",
+				"checked_at": "2026-05-04T12:00:00Z"
+			},
+			{
+				"group": "edge",
+				"entity": {"name": "alpine", "repository": "library", "tag": "3.20", "digest": "sha256:def"},
+				"verdict": "rejected",
+				"reason": "no signature matches the trusted keys",
+				"checked_at": "2026-05-04T12:00:00Z"
+			}
+		]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncHandler_SignaturesFailToStore(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	expectSatelliteHeartbeat(mock, now)
+
+	mock.ExpectExec("DELETE FROM satellite_signatures").
+		WithArgs(int32(1)).
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"signatures": [
+			{
+				"group": "edge",
+				"entity": {"name": "nginx", "repository": "library", "tag": "1.27", "digest": "sha256:abc"},
+				"verdict": "verified",
+				"checked_at": "2026-05-04T12:00:00Z"
+			}
+		]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSatelliteSignaturesHandler(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+
+	satRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+		AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{})
+	mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+		WithArgs("edge-01").
+		WillReturnRows(satRows)
+
+	sigRows := sqlmock.NewRows([]string{
+		"satellite_id", "group_name", "repository", "name", "tag", "digest",
+		"verdict", "signer", "reason", "checked_at",
+	}).AddRow(1, "edge", "library", "alpine", "3.20", "sha256:def", "rejected", "", "no signature matches the trusted keys", now)
+	mock.ExpectQuery("SELECT .+ FROM satellite_signatures").
+		WithArgs(int32(1)).
+		WillReturnRows(sigRows)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/satellites/edge-01/signatures", nil)
+	req = mux.SetURLVars(req, map[string]string{"satellite": "edge-01"})
+
+	rr := httptest.NewRecorder()
+	server.getSatelliteSignaturesHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var signatures []database.SatelliteSignature
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&signatures))
+	require.Len(t, signatures, 1)
+	require.Equal(t, "rejected", signatures[0].Verdict)
+	require.Equal(t, "alpine", signatures[0].Name)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRejectedSignaturesHandler(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+
+	rows := sqlmock.NewRows([]string{
+		"satellite_name", "group_name", "repository", "name", "tag", "digest", "reason", "checked_at",
+	}).
+		AddRow("edge-01", "edge", "library", "alpine", "3.20", "sha256:def", "no signature matches the trusted keys", now).
+		AddRow("edge-02", "edge", "library", "alpine", "3.20", "sha256:def", "no signature matches the trusted keys", now)
+	mock.ExpectQuery("SELECT .+ FROM satellite_signatures ss").WillReturnRows(rows)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/satellites/signatures/rejected", nil)
+	rr := httptest.NewRecorder()
+	server.getRejectedSignaturesHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var rejected []database.ListRejectedSignaturesRow
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&rejected))
+	require.Len(t, rejected, 2)
+	require.Equal(t, "edge-01", rejected[0].SatelliteName)
+	require.Equal(t, "edge-02", rejected[1].SatelliteName)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
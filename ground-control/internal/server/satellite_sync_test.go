@@ -0,0 +1,147 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncHandler_StoresLastSync(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	syncedAt := now.Add(-time.Minute)
+	expectSatelliteHeartbeat(mock, now)
+
+	mock.ExpectExec("INSERT INTO satellite_syncs").
+		WithArgs(int32(1), "sha256:combined", "sha256:config", syncedAt, int64(4200), int32(1), int64(1048576)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM satellite_group_states").
+		WithArgs(int32(1)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO satellite_group_states").
+		WithArgs(int32(1), pq.Array([]string{"edge", "retail"}), pq.Array([]string{"sha256:edge", "sha256:retail"}), syncedAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := `{
+		"name": "edge-01",
+		"request_created_time": "2026-05-04T12:00:00Z",
+		"latest_state_digest": "sha256:combined",
+		"latest_config_digest": "sha256:config",
+		"group_state_digests": {"retail": "sha256:retail", "edge": "sha256:edge"},
+		"last_sync_at": "2026-05-04T11:59:00Z",
+		"last_sync_duration_ms": 4200,
+		"last_sync_errors": 1,
+		"last_sync_bytes_transferred": 1048576
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.syncHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSatelliteSyncHandler(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+
+	expectSatellite := func(mock sqlmock.Sqlmock) {
+		satRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+			AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{})
+		mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+			WithArgs("edge-01").
+			WillReturnRows(satRows)
+	}
+	get := func(server *Server) SatelliteSyncResponse {
+		req := httptest.NewRequest(http.MethodGet, "/api/satellites/edge-01/sync", nil)
+		req = mux.SetURLVars(req, map[string]string{"satellite": "edge-01"})
+		rr := httptest.NewRecorder()
+		server.getSatelliteSyncHandler(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var resp SatelliteSyncResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp
+	}
+
+	t.Run("compares applied and published group states", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		expectSatellite(mock)
+
+		syncRows := sqlmock.NewRows([]string{
+			"satellite_id", "state_digest", "config_digest", "finished_at", "duration_ms", "errors", "bytes_transferred",
+		}).AddRow(1, "sha256:combined", "sha256:config", now, int64(4200), int32(0), int64(1048576))
+		mock.ExpectQuery("SELECT .+ FROM satellite_syncs").WithArgs(int32(1)).WillReturnRows(syncRows)
+
+		groupRows := sqlmock.NewRows([]string{"group_name", "applied_digest", "published_digest"}).
+			AddRow("edge", "sha256:edge", "sha256:edge").
+			AddRow("retail", "sha256:old", "sha256:retail")
+		mock.ExpectQuery("SELECT .+ FROM satellite_groups sg").WithArgs(int32(1)).WillReturnRows(groupRows)
+
+		resp := get(server)
+		require.NotNil(t, resp.LastSync)
+		require.Equal(t, "sha256:combined", resp.LastSync.StateDigest)
+		require.Len(t, resp.Groups, 2)
+		require.True(t, resp.Groups[0].Converged)
+		require.False(t, resp.Groups[1].Converged)
+		require.False(t, resp.Converged)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("satellite that never synced has not converged", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		expectSatellite(mock)
+
+		mock.ExpectQuery("SELECT .+ FROM satellite_syncs").WithArgs(int32(1)).WillReturnError(sql.ErrNoRows)
+
+		groupRows := sqlmock.NewRows([]string{"group_name", "applied_digest", "published_digest"}).
+			AddRow("edge", "", "sha256:edge")
+		mock.ExpectQuery("SELECT .+ FROM satellite_groups sg").WithArgs(int32(1)).WillReturnRows(groupRows)
+
+		resp := get(server)
+		require.Nil(t, resp.LastSync)
+		require.False(t, resp.Converged)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGroupConvergenceHandler(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("edge").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	rows := sqlmock.NewRows([]string{"satellite_name", "applied_digest", "published_digest"}).
+		AddRow("edge-01", "sha256:edge", "sha256:edge").
+		AddRow("edge-02", "sha256:old", "sha256:edge")
+	mock.ExpectQuery("SELECT .+ FROM satellite_groups sg").WithArgs("edge").WillReturnRows(rows)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/groups/edge/convergence", nil)
+	req = mux.SetURLVars(req, map[string]string{"group": "edge"})
+	rr := httptest.NewRecorder()
+	server.groupConvergenceHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var result []SatelliteConvergence
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&result))
+	require.Equal(t, []SatelliteConvergence{
+		{Satellite: "edge-01", AppliedDigest: "sha256:edge", PublishedDigest: "sha256:edge", Converged: true},
+		{Satellite: "edge-02", AppliedDigest: "sha256:old", PublishedDigest: "sha256:edge", Converged: false},
+	}, result)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -17,8 +17,10 @@ import (
 
 	"github.com/container-registry/harbor-satellite/ground-control/internal/auth"
 	"github.com/container-registry/harbor-satellite/ground-control/internal/database"
+	"github.com/container-registry/harbor-satellite/ground-control/internal/events"
 	"github.com/container-registry/harbor-satellite/ground-control/internal/middleware"
 	"github.com/container-registry/harbor-satellite/ground-control/internal/spiffe"
+	"github.com/container-registry/harbor-satellite/ground-control/internal/utils"
 )
 
 type Server struct {
@@ -42,6 +44,9 @@ type Server struct {
 
 	// Satellite status
 	staleThreshold time.Duration
+
+	// Push notifications to satellites, nil when disabled
+	events *events.Broker
 }
 
 // TLSConfig holds TLS settings for the server.
@@ -170,6 +175,20 @@ func NewServer() *ServerResult {
 		staleThreshold: parseDurationEnv("STALE_THRESHOLD", time.Hour),
 	}
 
+	if os.Getenv("SATELLITE_EVENTS_DISABLED") != "true" {
+		newServer.events = events.NewBroker(parseIntEnv("SATELLITE_EVENTS_BUFFER", events.DefaultCapacity))
+	}
+
+	// State artifacts are signed once a signing key is configured
+	if keyFile := os.Getenv("STATE_SIGNING_KEY_FILE"); keyFile != "" {
+		if err := utils.LoadStateSigningKey(keyFile, os.Getenv("STATE_SIGNING_KEY_GENERATE") == "true"); err != nil {
+			log.Fatalf("Failed to load state signing key: %v", err)
+		}
+		log.Printf("Signing state artifacts with the key at %s", keyFile)
+	} else {
+		log.Printf("Warning: STATE_SIGNING_KEY_FILE is not set, state artifacts are pushed unsigned")
+	}
+
 	// Bootstrap system admin user if not exists
 	if err := newServer.BootstrapSystemAdmin(context.Background()); err != nil {
 		log.Fatalf("Failed to bootstrap system admin: %v", err)
@@ -91,24 +91,24 @@ func AssembleGroupState(groupName string) string {
 	return state
 }
 
-// Create State Artifact for group
-func CreateStateArtifact(ctx context.Context, stateArtifact *m.StateArtifact) error {
+// Create State Artifact for group and return the digest satellites resolve it to
+func CreateStateArtifact(ctx context.Context, stateArtifact *m.StateArtifact) (string, error) {
 	// Set the registry URL from environment variable
 	stateArtifact.Registry = os.Getenv("HARBOR_URL")
 	if stateArtifact.Registry == "" {
-		return fmt.Errorf("HARBOR_URL environment variable is not set")
+		return "", fmt.Errorf("HARBOR_URL environment variable is not set")
 	}
 
 	// Marshal the state artifact to JSON format
 	data, err := json.Marshal(stateArtifact)
 	if err != nil {
-		return fmt.Errorf("failed to marshal state artifact to JSON: %v", err)
+		return "", fmt.Errorf("failed to marshal state artifact to JSON: %v", err)
 	}
 
 	// Create the image with the state artifact JSON
 	img, err := crane.Image(map[string][]byte{"artifacts.json": data})
 	if err != nil {
-		return fmt.Errorf("failed to create image: %v", err)
+		return "", fmt.Errorf("failed to create image: %v", err)
 	}
 
 	// Configure repository and credentials
@@ -116,7 +116,7 @@ func CreateStateArtifact(ctx context.Context, stateArtifact *m.StateArtifact) er
 	username := os.Getenv("HARBOR_USERNAME")
 	password := os.Getenv("HARBOR_PASSWORD")
 	if username == "" || password == "" {
-		return fmt.Errorf("HARBOR_USERNAME or HARBOR_PASSWORD environment variable is not set")
+		return "", fmt.Errorf("HARBOR_USERNAME or HARBOR_PASSWORD environment variable is not set")
 	}
 
 	auth := authn.FromConfig(authn.AuthConfig{
@@ -135,20 +135,30 @@ func CreateStateArtifact(ctx context.Context, stateArtifact *m.StateArtifact) er
 		destinationRepo = strings.SplitN(destinationRepo, "://", 2)[1]
 	}
 
+	img, err = signStateArtifact(img, destinationRepo, data)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to compute state artifact digest: %v", err)
+	}
+
 	// Push the image to the repository
 	if err := crane.Push(img, destinationRepo, options...); err != nil {
-		return fmt.Errorf("failed to push image: %v", err)
+		return "", fmt.Errorf("failed to push image: %v", err)
 	}
 
 	// Tag the image with timestamp and latest tags
 	tags := []string{fmt.Sprintf("%d", time.Now().Unix()), "latest"}
 	for _, tag := range tags {
 		if err := crane.Tag(destinationRepo, tag, options...); err != nil {
-			return fmt.Errorf("failed to tag image with %s: %v", tag, err)
+			return "", fmt.Errorf("failed to tag image with %s: %v", tag, err)
 		}
 	}
 
-	return nil
+	return digest.String(), nil
 }
 
 // Create and Push State Artifact for Config
@@ -183,6 +193,11 @@ func CreateAndPushConfigStateArtifact(ctx context.Context, configData []byte, co
 	destinationRepo := AssembleConfigState(configName)
 	destinationRepo = stripProtocol(destinationRepo)
 
+	img, err = signStateArtifact(img, destinationRepo, configData)
+	if err != nil {
+		return err
+	}
+
 	// Push the image to the repository
 	if err := crane.Push(img, destinationRepo, options...); err != nil {
 		return fmt.Errorf("failed to push image: %v", err)
@@ -199,7 +214,10 @@ func AssembleConfigState(configName string) string {
 	return fmt.Sprintf("%s/satellite/config-state/%s/state:latest", os.Getenv("HARBOR_URL"), configName)
 }
 
-func CreateOrUpdateSatStateArtifact(ctx context.Context, satelliteName string, states []string, config string) error {
+// CreateOrUpdateSatStateArtifact publishes the satellite state: the group
+// states and config the satellite follows, and the registries of the peer
+// satellites it fetches blobs from before falling back to Harbor.
+func CreateOrUpdateSatStateArtifact(ctx context.Context, satelliteName string, states []string, config string, peers []string) error {
 	if satelliteName == "" {
 		return fmt.Errorf("the satellite name must be atleast one character long")
 	}
@@ -212,7 +230,7 @@ func CreateOrUpdateSatStateArtifact(ctx context.Context, satelliteName string, s
 		return err
 	}
 
-	satelliteState := &m.SatelliteStateArtifact{States: states, Config: AssembleConfigState(config)}
+	satelliteState := &m.SatelliteStateArtifact{States: states, Config: AssembleConfigState(config), Peers: peers}
 	data, err := json.Marshal(satelliteState)
 	if err != nil {
 		return fmt.Errorf("failed to marshal satellite state artifact to JSON: %v", err)
@@ -232,6 +250,11 @@ func CreateOrUpdateSatStateArtifact(ctx context.Context, satelliteName string, s
 	destinationRepo := AssembleSatelliteState(satelliteName)
 	destinationRepo = stripProtocol(destinationRepo)
 
+	img, err = signStateArtifact(img, destinationRepo, data)
+	if err != nil {
+		return err
+	}
+
 	if err := pushImage(img, destinationRepo, options); err != nil {
 		return err
 	}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// State artifacts are signed with an ed25519 key held by Ground Control. The
+// signature covers the repository the artifact is pushed to, the digest of
+// its artifacts.json and the time it was issued, so that an artifact can be
+// neither moved to another repository nor replaced by an older one. Payload
+// and signature are stored as annotations of the artifact manifest, where
+// satellites that do not verify states ignore them. Satellites receive the
+// public key when they register; satellites registered before states were
+// signed fetch it from Ground Control.
+const (
+	StatePayloadAnnotation   = "io.goharbor.satellite.state.payload"
+	StateSignatureAnnotation = "io.goharbor.satellite.state.signature"
+)
+
+// StatePayload is what Ground Control signs for a state artifact.
+type StatePayload struct {
+	Subject  string    `json:"subject"`
+	Digest   string    `json:"digest"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// stateSigningKey signs state artifacts; nil pushes them unsigned.
+var stateSigningKey ed25519.PrivateKey
+
+// LoadStateSigningKey loads the PKCS#8 PEM encoded ed25519 key at path that
+// signs every state artifact pushed from then on. If there is no key yet, one
+// is generated and written to path when generate is set. Otherwise a missing
+// key is an error: satellites refuse states signed with any other key, so a
+// lost key must not be replaced silently.
+func LoadStateSigningKey(path string, generate bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && generate {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("generate state signing key: %w", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return fmt.Errorf("encode state signing key: %w", err)
+		}
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+			return fmt.Errorf("write state signing key: %w", err)
+		}
+		stateSigningKey = key
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read state signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("state signing key %s is not PEM encoded", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse state signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return fmt.Errorf("state signing key %s is a %T, not an ed25519 key", path, parsed)
+	}
+	stateSigningKey = key
+	return nil
+}
+
+// StatePublicKeyPEM returns the PEM encoded public key satellites verify
+// state artifacts with, or an empty string if states are not signed.
+func StatePublicKeyPEM() string {
+	if stateSigningKey == nil {
+		return ""
+	}
+	der, err := x509.MarshalPKIXPublicKey(stateSigningKey.Public())
+	if err != nil {
+		return ""
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// signStateArtifact annotates img, whose artifacts.json is data, with the
+// signed payload for the destination it is pushed to. Without a signing key
+// img is returned unchanged.
+func signStateArtifact(img v1.Image, destination string, data []byte) (v1.Image, error) {
+	if stateSigningKey == nil {
+		return img, nil
+	}
+	ref, err := name.ParseReference(destination)
+	if err != nil {
+		return nil, fmt.Errorf("parse state artifact destination: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	payload, err := json.Marshal(StatePayload{
+		Subject:  ref.Context().RepositoryStr(),
+		Digest:   "sha256:" + hex.EncodeToString(sum[:]),
+		IssuedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal state payload: %w", err)
+	}
+	signed, ok := mutate.Annotations(img, map[string]string{
+		StatePayloadAnnotation:   base64.StdEncoding.EncodeToString(payload),
+		StateSignatureAnnotation: base64.StdEncoding.EncodeToString(ed25519.Sign(stateSigningKey, payload)),
+	}).(v1.Image)
+	if !ok {
+		return nil, fmt.Errorf("annotate state artifact: unexpected type")
+	}
+	return signed, nil
+}
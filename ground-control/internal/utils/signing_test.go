@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadStateSigningKey_GeneratesOnce(t *testing.T) {
+	t.Cleanup(func() { stateSigningKey = nil })
+	path := filepath.Join(t.TempDir(), "state-signing.key")
+
+	require.NoError(t, LoadStateSigningKey(path, true))
+	first := StatePublicKeyPEM()
+	require.NotEmpty(t, first)
+
+	require.NoError(t, LoadStateSigningKey(path, true))
+	require.Equal(t, first, StatePublicKeyPEM(), "the stored key is reused")
+}
+
+func TestLoadStateSigningKey_MissingKey(t *testing.T) {
+	t.Cleanup(func() { stateSigningKey = nil })
+	path := filepath.Join(t.TempDir(), "state-signing.key")
+
+	require.Error(t, LoadStateSigningKey(path, false))
+	require.Empty(t, StatePublicKeyPEM())
+	require.NoFileExists(t, path)
+}
+
+func TestSignStateArtifact(t *testing.T) {
+	t.Cleanup(func() { stateSigningKey = nil })
+	data := []byte(`{"states":["harbor/satellite/group-state/edge/state:latest"]}`)
+	img, err := crane.Image(map[string][]byte{"artifacts.json": data})
+	require.NoError(t, err)
+
+	unsigned, err := signStateArtifact(img, "harbor:8080/satellite/satellite-state/sat/state:latest", data)
+	require.NoError(t, err)
+	require.Equal(t, img, unsigned, "states are pushed unsigned without a key")
+
+	require.NoError(t, LoadStateSigningKey(filepath.Join(t.TempDir(), "key"), true))
+	signed, err := signStateArtifact(img, "harbor:8080/satellite/satellite-state/sat/state:latest", data)
+	require.NoError(t, err)
+	manifest, err := signed.Manifest()
+	require.NoError(t, err)
+
+	payload, err := base64.StdEncoding.DecodeString(manifest.Annotations[StatePayloadAnnotation])
+	require.NoError(t, err)
+	signature, err := base64.StdEncoding.DecodeString(manifest.Annotations[StateSignatureAnnotation])
+	require.NoError(t, err)
+
+	block, _ := pem.Decode([]byte(StatePublicKeyPEM()))
+	require.NotNil(t, block)
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(key.(ed25519.PublicKey), payload, signature))
+
+	var decoded StatePayload
+	require.NoError(t, json.Unmarshal(payload, &decoded))
+	require.Equal(t, "satellite/satellite-state/sat/state", decoded.Subject)
+	require.Contains(t, decoded.Digest, "sha256:")
+	require.False(t, decoded.IssuedAt.IsZero())
+}
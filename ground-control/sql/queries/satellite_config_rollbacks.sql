@@ -0,0 +1,9 @@
+-- name: InsertSatelliteConfigRollback :exec
+INSERT INTO satellite_config_rollbacks (satellite_id, digest, restored_digest, reason, rolled_back_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (satellite_id, digest, rolled_back_at) DO NOTHING;
+
+-- name: ListSatelliteConfigRollbacks :many
+SELECT * FROM satellite_config_rollbacks
+WHERE satellite_id = $1
+ORDER BY rolled_back_at DESC;
@@ -0,0 +1,20 @@
+-- name: ListSatelliteDeadLetters :many
+SELECT * FROM satellite_dead_letters
+WHERE satellite_id = $1
+ORDER BY group_name, repository, name, tag;
+
+-- name: BatchInsertSatelliteDeadLetters :exec
+INSERT INTO satellite_dead_letters (
+  satellite_id, group_name, repository, name, tag, digest, error, failures,
+  first_failed_at, last_failed_at, next_retry_at
+)
+SELECT @satellite_id::INT, unnest(@group_names::TEXT[]), unnest(@repositories::TEXT[]),
+       unnest(@names::TEXT[]), unnest(@tags::TEXT[]), unnest(@digests::TEXT[]),
+       unnest(@errors::TEXT[]), unnest(@failures::INT[]),
+       unnest(@first_failed_ats::TIMESTAMP[]), unnest(@last_failed_ats::TIMESTAMP[]),
+       unnest(@next_retry_ats::TIMESTAMP[])
+ON CONFLICT DO NOTHING;
+
+-- name: ClearSatelliteDeadLetters :exec
+DELETE FROM satellite_dead_letters
+WHERE satellite_id = $1;
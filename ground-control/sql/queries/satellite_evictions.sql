@@ -0,0 +1,9 @@
+-- name: InsertSatelliteEviction :exec
+INSERT INTO satellite_evictions (satellite_id, reference, digest, freed_bytes, last_pulled_at, evicted_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (satellite_id, reference, evicted_at) DO NOTHING;
+
+-- name: ListSatelliteEvictions :many
+SELECT * FROM satellite_evictions
+WHERE satellite_id = $1
+ORDER BY evicted_at DESC;
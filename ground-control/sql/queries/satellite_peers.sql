@@ -0,0 +1,12 @@
+-- name: ListSatellitePeers :many
+SELECT url FROM satellite_peers
+WHERE satellite_id = $1
+ORDER BY position;
+
+-- name: AddSatellitePeer :exec
+INSERT INTO satellite_peers (satellite_id, position, url)
+VALUES ($1, $2, $3);
+
+-- name: ClearSatellitePeers :exec
+DELETE FROM satellite_peers
+WHERE satellite_id = $1;
@@ -0,0 +1,9 @@
+-- name: InsertSatelliteRegistryRestart :exec
+INSERT INTO satellite_registry_restarts (satellite_id, outcome, reason, restarted_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (satellite_id, restarted_at) DO NOTHING;
+
+-- name: ListSatelliteRegistryRestarts :many
+SELECT * FROM satellite_registry_restarts
+WHERE satellite_id = $1
+ORDER BY restarted_at DESC;
@@ -0,0 +1,26 @@
+-- name: ListSatelliteSignatures :many
+SELECT * FROM satellite_signatures
+WHERE satellite_id = $1
+ORDER BY group_name, repository, name, tag;
+
+-- name: ListRejectedSignatures :many
+SELECT s.name AS satellite_name, ss.group_name, ss.repository, ss.name, ss.tag,
+       ss.digest, ss.reason, ss.checked_at
+FROM satellite_signatures ss
+JOIN satellites s ON s.id = ss.satellite_id
+WHERE ss.verdict = 'rejected'
+ORDER BY s.name, ss.group_name, ss.repository, ss.name, ss.tag;
+
+-- name: BatchInsertSatelliteSignatures :exec
+INSERT INTO satellite_signatures (
+  satellite_id, group_name, repository, name, tag, digest, verdict, signer, reason, checked_at
+)
+SELECT @satellite_id::INT, unnest(@group_names::TEXT[]), unnest(@repositories::TEXT[]),
+       unnest(@names::TEXT[]), unnest(@tags::TEXT[]), unnest(@digests::TEXT[]),
+       unnest(@verdicts::TEXT[]), unnest(@signers::TEXT[]), unnest(@reasons::TEXT[]),
+       unnest(@checked_ats::TIMESTAMP[])
+ON CONFLICT DO NOTHING;
+
+-- name: ClearSatelliteSignatures :exec
+DELETE FROM satellite_signatures
+WHERE satellite_id = $1;
@@ -0,0 +1,58 @@
+-- name: UpsertGroupStateDigest :exec
+INSERT INTO group_state_digests (group_id, digest, pushed_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (group_id)
+DO UPDATE SET
+  digest = EXCLUDED.digest,
+  pushed_at = EXCLUDED.pushed_at;
+
+-- name: UpsertSatelliteSync :exec
+INSERT INTO satellite_syncs (
+  satellite_id, state_digest, config_digest, finished_at, duration_ms, errors, bytes_transferred
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (satellite_id)
+DO UPDATE SET
+  state_digest = EXCLUDED.state_digest,
+  config_digest = EXCLUDED.config_digest,
+  finished_at = EXCLUDED.finished_at,
+  duration_ms = EXCLUDED.duration_ms,
+  errors = EXCLUDED.errors,
+  bytes_transferred = EXCLUDED.bytes_transferred;
+
+-- name: GetSatelliteSync :one
+SELECT * FROM satellite_syncs
+WHERE satellite_id = $1;
+
+-- name: ClearSatelliteGroupStates :exec
+DELETE FROM satellite_group_states
+WHERE satellite_id = $1;
+
+-- name: BatchInsertSatelliteGroupStates :exec
+INSERT INTO satellite_group_states (satellite_id, group_name, state_digest, synced_at)
+SELECT @satellite_id::INT, unnest(@group_names::TEXT[]), unnest(@state_digests::TEXT[]), @synced_at::TIMESTAMP;
+
+-- name: ListSatelliteGroupConvergence :many
+SELECT g.group_name,
+       COALESCE(sgs.state_digest, '')::TEXT AS applied_digest,
+       COALESCE(gsd.digest, '')::TEXT AS published_digest
+FROM satellite_groups sg
+JOIN groups g ON g.id = sg.group_id
+LEFT JOIN group_state_digests gsd ON gsd.group_id = g.id
+LEFT JOIN satellite_group_states sgs
+  ON sgs.satellite_id = sg.satellite_id AND sgs.group_name = g.group_name
+WHERE sg.satellite_id = $1
+ORDER BY g.group_name;
+
+-- name: ListGroupConvergence :many
+SELECT s.name AS satellite_name,
+       COALESCE(sgs.state_digest, '')::TEXT AS applied_digest,
+       COALESCE(gsd.digest, '')::TEXT AS published_digest
+FROM satellite_groups sg
+JOIN satellites s ON s.id = sg.satellite_id
+JOIN groups g ON g.id = sg.group_id
+LEFT JOIN group_state_digests gsd ON gsd.group_id = g.id
+LEFT JOIN satellite_group_states sgs
+  ON sgs.satellite_id = s.id AND sgs.group_name = g.group_name
+WHERE g.group_name = $1
+ORDER BY s.name;
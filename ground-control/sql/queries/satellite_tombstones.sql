@@ -0,0 +1,18 @@
+-- name: ListSatelliteTombstones :many
+SELECT * FROM satellite_tombstones
+WHERE satellite_id = $1
+ORDER BY group_name, repository, name, tag;
+
+-- name: BatchInsertSatelliteTombstones :exec
+INSERT INTO satellite_tombstones (
+  satellite_id, group_name, repository, name, tag, digest, removed_at, purge_after, hold
+)
+SELECT @satellite_id::INT, unnest(@group_names::TEXT[]), unnest(@repositories::TEXT[]),
+       unnest(@names::TEXT[]), unnest(@tags::TEXT[]), unnest(@digests::TEXT[]),
+       unnest(@removed_ats::TIMESTAMP[]), unnest(@purge_afters::TIMESTAMP[]),
+       unnest(@holds::TEXT[])
+ON CONFLICT DO NOTHING;
+
+-- name: ClearSatelliteTombstones :exec
+DELETE FROM satellite_tombstones
+WHERE satellite_id = $1;
@@ -0,0 +1,11 @@
+-- +goose Up
+
+CREATE TABLE satellite_peers (
+  satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+  position INT NOT NULL,
+  url VARCHAR(255) NOT NULL,
+  PRIMARY KEY (satellite_id, position)
+);
+
+-- +goose Down
+DROP TABLE satellite_peers;
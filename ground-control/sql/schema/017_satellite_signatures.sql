@@ -0,0 +1,21 @@
+-- +goose Up
+
+CREATE TABLE satellite_signatures (
+  satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+  group_name VARCHAR(255) NOT NULL,
+  repository VARCHAR(255) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  tag VARCHAR(255) NOT NULL,
+  digest VARCHAR(255) NOT NULL,
+  verdict VARCHAR(32) NOT NULL,
+  signer TEXT NOT NULL DEFAULT '',
+  reason TEXT NOT NULL DEFAULT '',
+  checked_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (satellite_id, group_name, repository, name, tag)
+);
+
+CREATE INDEX idx_satellite_signatures_verdict ON satellite_signatures(verdict);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_satellite_signatures_verdict;
+DROP TABLE satellite_signatures;
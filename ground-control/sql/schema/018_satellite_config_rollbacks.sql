@@ -0,0 +1,15 @@
+-- +goose Up
+
+CREATE TABLE satellite_config_rollbacks (
+  id SERIAL PRIMARY KEY,
+  satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+  digest VARCHAR(255) NOT NULL,
+  restored_digest VARCHAR(255) NOT NULL DEFAULT '',
+  reason TEXT NOT NULL,
+  rolled_back_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (satellite_id, digest, rolled_back_at)
+);
+
+-- +goose Down
+DROP TABLE satellite_config_rollbacks;
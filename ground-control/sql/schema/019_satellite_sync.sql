@@ -0,0 +1,30 @@
+-- +goose Up
+
+CREATE TABLE group_state_digests (
+  group_id INT PRIMARY KEY REFERENCES groups(id) ON DELETE CASCADE,
+  digest VARCHAR(255) NOT NULL,
+  pushed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE satellite_syncs (
+  satellite_id INT PRIMARY KEY REFERENCES satellites(id) ON DELETE CASCADE,
+  state_digest VARCHAR(255) NOT NULL DEFAULT '',
+  config_digest VARCHAR(255) NOT NULL DEFAULT '',
+  finished_at TIMESTAMP NOT NULL,
+  duration_ms BIGINT NOT NULL,
+  errors INT NOT NULL,
+  bytes_transferred BIGINT NOT NULL
+);
+
+CREATE TABLE satellite_group_states (
+  satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+  group_name VARCHAR(255) NOT NULL,
+  state_digest VARCHAR(255) NOT NULL,
+  synced_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (satellite_id, group_name)
+);
+
+-- +goose Down
+DROP TABLE satellite_group_states;
+DROP TABLE satellite_syncs;
+DROP TABLE group_state_digests;
@@ -0,0 +1,16 @@
+-- +goose Up
+
+CREATE TABLE satellite_evictions (
+  id SERIAL PRIMARY KEY,
+  satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+  reference TEXT NOT NULL,
+  digest VARCHAR(255) NOT NULL,
+  freed_bytes BIGINT NOT NULL DEFAULT 0,
+  last_pulled_at TIMESTAMP,
+  evicted_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (satellite_id, reference, evicted_at)
+);
+
+-- +goose Down
+DROP TABLE satellite_evictions;
@@ -0,0 +1,14 @@
+-- +goose Up
+
+CREATE TABLE satellite_registry_restarts (
+  id SERIAL PRIMARY KEY,
+  satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+  outcome VARCHAR(32) NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  restarted_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (satellite_id, restarted_at)
+);
+
+-- +goose Down
+DROP TABLE satellite_registry_restarts;
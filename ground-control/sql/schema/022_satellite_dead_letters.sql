@@ -0,0 +1,19 @@
+-- +goose Up
+
+CREATE TABLE satellite_dead_letters (
+  satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+  group_name VARCHAR(255) NOT NULL,
+  repository VARCHAR(255) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  tag VARCHAR(255) NOT NULL,
+  digest VARCHAR(255) NOT NULL,
+  error TEXT NOT NULL DEFAULT '',
+  failures INT NOT NULL DEFAULT 0,
+  first_failed_at TIMESTAMP NOT NULL,
+  last_failed_at TIMESTAMP NOT NULL,
+  next_retry_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (satellite_id, group_name, repository, name, tag)
+);
+
+-- +goose Down
+DROP TABLE satellite_dead_letters;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+CREATE TABLE satellite_tombstones (
+  satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+  group_name VARCHAR(255) NOT NULL,
+  repository VARCHAR(255) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  tag VARCHAR(255) NOT NULL,
+  digest VARCHAR(255) NOT NULL,
+  removed_at TIMESTAMP NOT NULL,
+  purge_after TIMESTAMP NOT NULL,
+  hold VARCHAR(64) NOT NULL DEFAULT '',
+  PRIMARY KEY (satellite_id, group_name, repository, name, tag)
+);
+
+-- +goose Down
+DROP TABLE satellite_tombstones;
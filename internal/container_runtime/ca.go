@@ -0,0 +1,28 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	crioCertsDir   = "/etc/containers/certs.d"
+	dockerCertsDir = "/etc/docker/certs.d"
+)
+
+// installCA copies caFile to certsDir/<host:port>/ca.crt for registry, where
+// CRI-O, Podman and Docker look up the CA a registry's certificate is issued
+// by. Without caFile the system's trusted CAs apply and nothing is copied.
+func installCA(certsDir, registry, caFile string) error {
+	if caFile == "" {
+		return nil
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	dir := filepath.Join(certsDir, host)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	return copyFile(caFile, filepath.Join(dir, "ca.crt"))
+}
@@ -0,0 +1,24 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallCA(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, []byte("ca"), 0600))
+	certsDir := t.TempDir()
+
+	require.NoError(t, installCA(certsDir, "https://127.0.0.1:8585", caFile))
+	data, err := os.ReadFile(filepath.Join(certsDir, "127.0.0.1:8585", "ca.crt"))
+	require.NoError(t, err)
+	require.Equal(t, "ca", string(data))
+
+	// Without a CA the system's trusted CAs apply
+	require.NoError(t, installCA(certsDir, "https://registry.local:8585", ""))
+	require.NoDirExists(t, filepath.Join(certsDir, "registry.local:8585"))
+}
@@ -15,14 +15,14 @@ const (
 )
 
 // setContainerdConfig writes hosts.toml for multiple upstream registries and updates containerd registry plugin
-func setContainerdConfig(upstreamRegistries []string, localMirror string) (string, error) {
+func setContainerdConfig(upstreamRegistries []string, localMirror, caFile string) (string, error) {
 	backupPath, err := configureContainerd(containerdCertsDir)
 	if err != nil {
 		return backupPath, fmt.Errorf("failed to configure registry plugin: %w", err)
 	}
 
 	for _, registryURL := range upstreamRegistries {
-		if err := writeContainerdHostToml(registryURL, localMirror); err != nil {
+		if err := writeContainerdHostToml(registryURL, localMirror, caFile); err != nil {
 			return backupPath, fmt.Errorf("failed to configure containerd for %s: %w", registryURL, err)
 		}
 	}
@@ -30,8 +30,9 @@ func setContainerdConfig(upstreamRegistries []string, localMirror string) (strin
 	return backupPath, nil
 }
 
-// writeContainerdHostToml creates or updates hosts.toml for a registry
-func writeContainerdHostToml(registryURL, localMirror string) error {
+// writeContainerdHostToml creates or updates hosts.toml for a registry. The
+// mirror is verified with caFile if one is given.
+func writeContainerdHostToml(registryURL, localMirror, caFile string) error {
 	dir := filepath.Join(containerdCertsDir, registryURL)
 	if err := os.MkdirAll(dir, 0750); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", dir, err)
@@ -68,6 +69,7 @@ func writeContainerdHostToml(registryURL, localMirror string) error {
 
 	cfg.Host[localMirror] = Host{
 		Capabilities: []string{"pull", "resolve"},
+		CA:           caFile,
 	}
 
 	f, err := os.Create(filepath.Clean(path))
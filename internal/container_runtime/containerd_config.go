@@ -3,6 +3,7 @@ package runtime
 // Host represents a registry host entry in a hosts.toml file
 type Host struct {
 	Capabilities []string `toml:"capabilities"`
+	CA           string   `toml:"ca,omitempty"`
 }
 
 // ContainerdHosts represents the structure of a hosts.toml file
@@ -16,7 +16,10 @@ const (
 	registriesConfigPath     = "/etc/containers/registries.conf"
 )
 
-func setCrioConfig(upstreamRegistries []string, localMirror string) (string, error) {
+// setCrioConfig adds the local mirror to registries.conf for every upstream
+// registry. A mirror served over HTTPS is verified with caFile, installed
+// where CRI-O and Podman look up the CA of a registry.
+func setCrioConfig(upstreamRegistries []string, localMirror, caFile string) (string, error) {
 	if _, err := os.Stat(registriesConfigPath); os.IsNotExist(err) {
 		f, err := os.Create(registriesConfigPath)
 		if err != nil {
@@ -51,6 +54,13 @@ func setCrioConfig(upstreamRegistries []string, localMirror string) (string, err
 	}
 
 	insecure := !strings.HasPrefix(localMirror, "https://")
+	if !insecure {
+		if err := installCA(crioCertsDir, localMirror, caFile); err != nil {
+			return bkPath, fmt.Errorf("failed to install registry CA: %w", err)
+		}
+	}
+	// registries.conf locations carry no scheme
+	localMirror = strings.TrimPrefix(strings.TrimPrefix(localMirror, "https://"), "http://")
 
 	for _, upstream := range upstreamRegistries {
 		idx := slices.IndexFunc(cfg.Registries, func(r Registry) bool {
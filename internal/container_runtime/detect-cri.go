@@ -75,6 +75,13 @@ func parseMirrorFlags(mirrors []string) ([]CRIConfig, error) {
 // ApplyCRIConfigs applies the given CRI configs and returns results.
 // Errors are collected per-CRI rather than failing on the first error.
 func ApplyCRIConfigs(configs []CRIConfig, localRegistry string) []CRIConfigResult {
+	return ApplyCRIConfigsWithCA(configs, localRegistry, "")
+}
+
+// ApplyCRIConfigsWithCA is ApplyCRIConfigs for a local registry served over
+// HTTPS, whose certificate is verified with caFile. An empty caFile leaves
+// the verification to the system's trusted CAs.
+func ApplyCRIConfigsWithCA(configs []CRIConfig, localRegistry, caFile string) []CRIConfigResult {
 	var results []CRIConfigResult
 
 	for _, cfg := range configs {
@@ -85,11 +92,11 @@ func ApplyCRIConfigs(configs []CRIConfig, localRegistry string) []CRIConfigResul
 
 		switch cfg.CRI {
 		case CRIDocker:
-			backupPath, err = setDockerdConfig(cfg.Registries, localRegistry)
+			backupPath, err = setDockerdConfig(cfg.Registries, localRegistry, caFile)
 		case CRICrio, CRIPodman:
-			backupPath, err = setCrioConfig(cfg.Registries, localRegistry)
+			backupPath, err = setCrioConfig(cfg.Registries, localRegistry, caFile)
 		case CRIContainerd:
-			backupPath, err = setContainerdConfig(cfg.Registries, localRegistry)
+			backupPath, err = setContainerdConfig(cfg.Registries, localRegistry, caFile)
 		default:
 			err = fmt.Errorf("unsupported CRI: %s", cfg.CRI)
 		}
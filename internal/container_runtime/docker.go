@@ -13,7 +13,10 @@ import (
 
 const dockerConfigPath = "/etc/docker/daemon.json"
 
-func setDockerdConfig(mirrors []string, localRegistry string) (string, error) {
+// setDockerdConfig adds the local registry to Docker's registry mirrors. A
+// registry served over HTTPS is verified with caFile, installed in Docker's
+// certs.d directory.
+func setDockerdConfig(mirrors []string, localRegistry, caFile string) (string, error) {
 	if len(mirrors) == 0 {
 		return "", nil
 	}
@@ -29,6 +32,12 @@ func setDockerdConfig(mirrors []string, localRegistry string) (string, error) {
 		localRegistry = "http://" + localRegistry
 	}
 
+	if strings.HasPrefix(localRegistry, "https://") {
+		if err := installCA(dockerCertsDir, localRegistry, caFile); err != nil {
+			return "", fmt.Errorf("failed to install registry CA: %w", err)
+		}
+	}
+
 	backupPath, err := backupFile(dockerConfigPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to backup docker config: %w", err)
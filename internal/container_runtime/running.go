@@ -0,0 +1,217 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RunningImage is an image used by at least one running container, with the
+// references the runtime knows it by.
+type RunningImage struct {
+	ID          string   `json:"id"`
+	RepoTags    []string `json:"repo_tags,omitempty"`
+	RepoDigests []string `json:"repo_digests,omitempty"`
+}
+
+// runtimeQueryTimeout bounds each query to a container runtime.
+const runtimeQueryTimeout = 10 * time.Second
+
+// RunningImages lists the images of running containers for every runtime
+// whose socket is present: Docker through its API and containerd and CRI-O
+// through crictl. Runtimes that are not installed are skipped; a runtime that
+// is present but cannot be queried is an error, so callers can err on the
+// side of keeping images.
+func RunningImages(ctx context.Context) ([]RunningImage, error) {
+	return runningImages(ctx, os.Stat, exec.LookPath, runCrictl)
+}
+
+// crictlFunc runs crictl against a runtime endpoint and returns its stdout.
+type crictlFunc func(ctx context.Context, endpoint string, args ...string) ([]byte, error)
+
+func runningImages(ctx context.Context, statFn statFunc, lookPathFn lookPathFunc, crictl crictlFunc) ([]RunningImage, error) {
+	var images []RunningImage
+	var errs []error
+	for _, check := range criChecks {
+		if check.socket == "" {
+			continue
+		}
+		if _, err := statFn(check.socket); err != nil {
+			continue
+		}
+
+		var found []RunningImage
+		var err error
+		switch check.criType {
+		case CRIDocker:
+			found, err = dockerRunningImages(ctx, check.socket)
+		default:
+			if _, lookErr := lookPathFn("crictl"); lookErr != nil {
+				err = fmt.Errorf("crictl is required to list containers of %s: %w", check.criType, lookErr)
+				break
+			}
+			found, err = criRunningImages(ctx, "unix://"+check.socket, crictl)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", check.criType, err))
+			continue
+		}
+		images = append(images, found...)
+	}
+	return images, errors.Join(errs...)
+}
+
+type dockerContainer struct {
+	ImageID string `json:"ImageID"`
+}
+
+type dockerImage struct {
+	ID          string   `json:"Id"`
+	RepoTags    []string `json:"RepoTags"`
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+// dockerRunningImages queries the Docker Engine API on its unix socket.
+func dockerRunningImages(ctx context.Context, socket string) ([]RunningImage, error) {
+	client := &http.Client{
+		Timeout: runtimeQueryTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	var containers []dockerContainer
+	if err := getDockerJSON(ctx, client, "/containers/json", &containers); err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, nil
+	}
+	var images []dockerImage
+	if err := getDockerJSON(ctx, client, "/images/json", &images); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]dockerImage, len(images))
+	for _, img := range images {
+		byID[img.ID] = img
+	}
+	var running []RunningImage
+	seen := make(map[string]bool)
+	for _, c := range containers {
+		if seen[c.ImageID] {
+			continue
+		}
+		seen[c.ImageID] = true
+		img := byID[c.ImageID]
+		running = append(running, RunningImage{ID: c.ImageID, RepoTags: img.RepoTags, RepoDigests: img.RepoDigests})
+	}
+	return running, nil
+}
+
+func getDockerJSON(ctx context.Context, client *http.Client, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("query docker %s: %w", path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("query docker %s: unexpected status %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode docker %s: %w", path, err)
+	}
+	return nil
+}
+
+type crictlContainers struct {
+	Containers []struct {
+		ImageRef string `json:"imageRef"`
+	} `json:"containers"`
+}
+
+type crictlImages struct {
+	Images []struct {
+		ID          string   `json:"id"`
+		RepoTags    []string `json:"repoTags"`
+		RepoDigests []string `json:"repoDigests"`
+	} `json:"images"`
+}
+
+// criRunningImages lists running containers with crictl. Depending on the
+// runtime a container's imageRef is either the image ID or a repo digest.
+func criRunningImages(ctx context.Context, endpoint string, crictl crictlFunc) ([]RunningImage, error) {
+	out, err := crictl(ctx, endpoint, "ps", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var containers crictlContainers
+	if err := json.Unmarshal(out, &containers); err != nil {
+		return nil, fmt.Errorf("decode crictl ps: %w", err)
+	}
+	if len(containers.Containers) == 0 {
+		return nil, nil
+	}
+
+	out, err = crictl(ctx, endpoint, "images", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var images crictlImages
+	if err := json.Unmarshal(out, &images); err != nil {
+		return nil, fmt.Errorf("decode crictl images: %w", err)
+	}
+
+	var running []RunningImage
+	seen := make(map[string]bool)
+	for _, c := range containers.Containers {
+		if seen[c.ImageRef] {
+			continue
+		}
+		seen[c.ImageRef] = true
+		ri := RunningImage{ID: c.ImageRef}
+		if strings.Contains(c.ImageRef, "@") {
+			ri.RepoDigests = []string{c.ImageRef}
+		}
+		for _, img := range images.Images {
+			if img.ID == c.ImageRef || slices.Contains(img.RepoDigests, c.ImageRef) {
+				ri.ID = img.ID
+				ri.RepoTags = img.RepoTags
+				ri.RepoDigests = img.RepoDigests
+				break
+			}
+		}
+		running = append(running, ri)
+	}
+	return running, nil
+}
+
+func runCrictl(ctx context.Context, endpoint string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, runtimeQueryTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "crictl", append([]string{"--runtime-endpoint", endpoint}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("crictl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
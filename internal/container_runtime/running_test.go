@@ -0,0 +1,90 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func fakeStat(sockets ...string) statFunc {
+	return func(path string) (os.FileInfo, error) {
+		for _, s := range sockets {
+			if s == path {
+				return nil, nil
+			}
+		}
+		return nil, os.ErrNotExist
+	}
+}
+
+func foundCrictl(string) (string, error) { return "/usr/bin/crictl", nil }
+
+func TestRunningImages_NoRuntime(t *testing.T) {
+	images, err := runningImages(context.Background(), fakeStat(), foundCrictl, nil)
+	require.NoError(t, err)
+	require.Empty(t, images)
+}
+
+func TestRunningImages_Crictl(t *testing.T) {
+	crictl := func(_ context.Context, endpoint string, args ...string) ([]byte, error) {
+		require.Equal(t, "unix:///run/containerd/containerd.sock", endpoint)
+		switch args[0] {
+		case "ps":
+			return []byte(`{"containers":[{"imageRef":"sha256:aaa"},{"imageRef":"sha256:aaa"}]}`), nil
+		case "images":
+			return []byte(`{"images":[
+				{"id":"sha256:aaa","repoTags":["registry.example.com/library/app:v1"],"repoDigests":["registry.example.com/library/app@sha256:111"]},
+				{"id":"sha256:bbb","repoTags":["registry.example.com/library/other:v1"]}
+			]}`), nil
+		}
+		return nil, fmt.Errorf("unexpected args %v", args)
+	}
+
+	images, err := runningImages(context.Background(), fakeStat("/run/containerd/containerd.sock"), foundCrictl, crictl)
+	require.NoError(t, err)
+	require.Equal(t, []RunningImage{{
+		ID:          "sha256:aaa",
+		RepoTags:    []string{"registry.example.com/library/app:v1"},
+		RepoDigests: []string{"registry.example.com/library/app@sha256:111"},
+	}}, images)
+}
+
+func TestRunningImages_CrictlMissing(t *testing.T) {
+	notFound := func(string) (string, error) { return "", errors.New("not found") }
+	_, err := runningImages(context.Background(), fakeStat("/var/run/crio/crio.sock"), notFound, nil)
+	require.Error(t, err)
+}
+
+func TestDockerRunningImages(t *testing.T) {
+	// Unix socket paths are limited in length, so avoid the long t.TempDir().
+	dir, err := os.MkdirTemp("", "docker")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/json":
+			_, _ = w.Write([]byte(`[{"Id":"c1","ImageID":"sha256:aaa"}]`))
+		case "/images/json":
+			_, _ = w.Write([]byte(`[{"Id":"sha256:aaa","RepoTags":["app:v1"],"RepoDigests":["app@sha256:111"]}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	images, err := dockerRunningImages(context.Background(), socket)
+	require.NoError(t, err)
+	require.Equal(t, []RunningImage{{ID: "sha256:aaa", RepoTags: []string{"app:v1"}, RepoDigests: []string{"app@sha256:111"}}}, images)
+}
@@ -14,12 +14,19 @@ import (
 	"zotregistry.dev/zot/pkg/cli/server"
 )
 
+// RegistryReloader applies a new config to the embedded registry, restarting
+// it if needed.
+type RegistryReloader interface {
+	Reload(ctx context.Context, zotConfig json.RawMessage) error
+}
+
 type HotReloadManager struct {
 	cm                        *config.ConfigManager
 	log                       *zerolog.Logger
 	ctx                       context.Context
 	zotTempPath               string
 	stateReplicationScheduler *scheduler.Scheduler
+	registry                  RegistryReloader
 	changeCallbacks           map[config.ConfigChangeType][]config.ConfigChangeCallback
 	callbackMu                sync.RWMutex
 }
@@ -129,10 +136,6 @@ func (hrm *HotReloadManager) handleZotConfigChange(change config.ConfigChange) e
 		Str("type", string(change.Type)).
 		Msg("Handling Zot configuration change")
 
-	hrm.log.Warn().
-		Str("type", string(change.Type)).
-		Msg("Some Zot configuration may require to restart")
-
 	// verify the zot configuration before apply
 	var cfg cfg.Config
 	if err := json.Unmarshal(hrm.cm.GetRawZotConfig(), &cfg); err != nil {
@@ -145,6 +148,13 @@ func (hrm *HotReloadManager) handleZotConfigChange(change config.ConfigChange) e
 		return err
 	}
 
+	if hrm.registry != nil {
+		if err := hrm.registry.Reload(hrm.ctx, hrm.cm.GetRawZotConfig()); err != nil {
+			return fmt.Errorf("unable to apply zot configuration: %w", err)
+		}
+		return nil
+	}
+
 	err := os.WriteFile(hrm.zotTempPath, hrm.cm.GetRawZotConfig(), 0600)
 	if err != nil {
 		return fmt.Errorf("unable to change zot configuration: %w", err)
@@ -156,6 +166,12 @@ func (hrm *HotReloadManager) SetStateReplicationScheduler(stateReplicationSchedu
 	hrm.stateReplicationScheduler = stateReplicationScheduler
 }
 
+// SetRegistryReloader hands Zot config changes to the registry supervisor
+// instead of only rewriting the config Zot watches.
+func (hrm *HotReloadManager) SetRegistryReloader(registry RegistryReloader) {
+	hrm.registry = registry
+}
+
 func (hrm *HotReloadManager) ProcessConfigChanges(changes []config.ConfigChange) error {
 
 	hrm.log.Info().Int("change_count", len(changes)).Msg("Processing configuration changes")
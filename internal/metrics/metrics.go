@@ -0,0 +1,145 @@
+// Package metrics defines the Prometheus metrics of the satellite. They are
+// registered with the default registry, which the local API serves on
+// /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "satellite"
+
+// Outcomes of a config reconciliation.
+const (
+	ConfigUnchanged = "unchanged"
+	ConfigApplied   = "applied"
+	ConfigFailed    = "failed"
+	// ConfigRejected is a config skipped because it was rolled back before.
+	ConfigRejected   = "rejected"
+	ConfigPromoted   = "promoted"
+	ConfigRolledBack = "rolled_back"
+)
+
+// Results of a heartbeat.
+const (
+	HeartbeatSucceeded = "succeeded"
+	HeartbeatFailed    = "failed"
+)
+
+var (
+	// StateFetchDuration is how long fetching a group state artifact took.
+	StateFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "state_fetch_duration_seconds",
+		Help:      "Time taken to fetch a group state artifact.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"process", "group"})
+
+	// StateFetchErrors counts group state artifacts that could not be fetched.
+	StateFetchErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "state_fetch_errors_total",
+		Help:      "Group state artifacts that could not be fetched.",
+	}, []string{"process", "group"})
+
+	// ImagesReplicated counts images copied to the local registry.
+	ImagesReplicated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "images_replicated_total",
+		Help:      "Images copied to the local registry.",
+	}, []string{"process", "group"})
+
+	// ImagesDeleted counts images deleted or untagged in the local registry.
+	ImagesDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "images_deleted_total",
+		Help:      "Images deleted or untagged in the local registry.",
+	}, []string{"process", "group"})
+
+	// BytesReplicated counts bytes downloaded from the source registry.
+	BytesReplicated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "replicated_bytes_total",
+		Help:      "Bytes downloaded from the source registry while replicating.",
+	}, []string{"process", "group"})
+
+	// BytesDeleted counts the bytes referenced by the manifests deleted from
+	// the local registry. Layers shared with other images are counted too,
+	// so this is an upper bound of the space reclaimed by garbage collection.
+	BytesDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deleted_bytes_total",
+		Help:      "Bytes referenced by the manifests deleted from the local registry.",
+	}, []string{"process", "group"})
+
+	// ImagesEvicted counts images evicted from the local registry to keep it
+	// within its storage quota.
+	ImagesEvicted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "evicted_images_total",
+		Help:      "Images evicted from the local registry to stay within its storage quota.",
+	}, []string{"process"})
+
+	// PullThroughImages counts images the registry cached on demand for the
+	// pull-through mode and ad-hoc images deleted after their retention.
+	PullThroughImages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pull_through_images_total",
+		Help:      "Ad-hoc images cached on demand or expired, by event.",
+	}, []string{"process", "event"})
+
+	// LayerCacheHits counts layers already present in the local registry
+	// when an image was replicated.
+	LayerCacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "layer_cache_hits_total",
+		Help:      "Layers already present in the local registry when replicating an image.",
+	}, []string{"process", "group"})
+
+	// LayerCacheMisses counts layers that had to be pulled from the source.
+	LayerCacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "layer_cache_misses_total",
+		Help:      "Layers pulled from the source registry when replicating an image.",
+	}, []string{"process", "group"})
+
+	// PeerLayers counts layers fetched while peers are assigned, by whether
+	// a peer satellite or the upstream registry served them.
+	PeerLayers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "peer_layers_total",
+		Help:      "Layers fetched from peer satellites or, when no peer has them, from upstream.",
+	}, []string{"process", "group", "source"})
+
+	// RegistryRestarts counts configs applied to the embedded registry by
+	// restarting it, by outcome.
+	RegistryRestarts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registry_restarts_total",
+		Help:      "Restarts of the embedded registry to apply a new config, by outcome.",
+	}, []string{"outcome"})
+
+	// Heartbeats counts status reports sent to Ground Control by result.
+	Heartbeats = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "heartbeats_total",
+		Help:      "Status reports sent to Ground Control.",
+	}, []string{"process", "result"})
+
+	// RunDuration is how long a scheduled process run took.
+	RunDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Time taken by a run of a scheduled process.",
+		Buckets:   []float64{.1, .5, 1, 5, 15, 30, 60, 300, 900, 1800, 3600},
+	}, []string{"process", "trigger", "status"})
+
+	// ConfigReconciliations counts reconciliations of the remote config by
+	// outcome.
+	ConfigReconciliations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reconciliations_total",
+		Help:      "Reconciliations of the config published by Ground Control.",
+	}, []string{"process", "outcome"})
+)
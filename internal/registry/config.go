@@ -17,8 +17,15 @@ type ZotConfig struct {
 }
 
 type ZotHTTPConfig struct {
-	Address string `json:"address"`
-	Port    string `json:"port"`
+	Address string        `json:"address"`
+	Port    string        `json:"port"`
+	TLS     *ZotTLSConfig `json:"tls,omitempty"`
+}
+
+// ZotTLSConfig is the certificate Zot serves HTTPS with.
+type ZotTLSConfig struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
 }
 
 type ZotLogConfig struct {
@@ -29,10 +36,16 @@ type ZotStorageConfig struct {
 	RootDirectory string `json:"rootDirectory"`
 }
 
+// GetRegistryURL returns the URL the registry is served at, over HTTPS if
+// it has a certificate.
 func (c *ZotConfig) GetRegistryURL() string {
 	address := c.HTTP.Address
 	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
-		address = "http://" + address
+		scheme := "http://"
+		if c.HTTP.TLS != nil {
+			scheme = "https://"
+		}
+		address = scheme + address
 	}
 	return fmt.Sprintf("%s:%s", address, c.HTTP.Port)
 }
@@ -63,3 +76,23 @@ func ReadZotConfig(filePath string, zotConfig *ZotConfig) error {
 	}
 	return nil
 }
+
+// zotSyncCredentials is an entry of the credentials file of Zot's sync
+// extension, which is keyed by the upstream registry's host.
+type zotSyncCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// WriteSyncCredentials writes the credentials file Zot's sync extension
+// authenticates to upstream with.
+func WriteSyncCredentials(path, upstream, username, password string) error {
+	host := strings.TrimPrefix(strings.TrimPrefix(upstream, "https://"), "http://")
+	data, err := json.MarshalIndent(map[string]zotSyncCredentials{
+		strings.TrimSuffix(host, "/"): {Username: username, Password: password},
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal sync credentials: %w", err)
+	}
+	return writeFileAtomic(path, data, 0600)
+}
@@ -3,29 +3,45 @@ package registry
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"net/http"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
-	"zotregistry.dev/zot/pkg/api"
-	"zotregistry.dev/zot/pkg/api/config"
 	"zotregistry.dev/zot/pkg/cli/server"
 )
 
+// ZotManager runs the embedded Zot registry and restarts it when its config
+// changes.
 type ZotManager struct {
 	zotConfig    json.RawMessage
 	tempConfPath string
 	log          zerolog.Logger
+
+	launch        func(zotConfigPath string) (zotInstance, error)
+	drainTimeout  time.Duration
+	healthTimeout time.Duration
+
+	// restartMu serializes Reload; mu guards the fields below it.
+	restartMu sync.Mutex
+	mu        sync.Mutex
+	current   zotInstance
+	pending   *Restart
+	failed    chan error
 }
 
 func NewZotManager(log zerolog.Logger, zotConfig json.RawMessage, tempConfPath string) *ZotManager {
-	return &ZotManager{
-		zotConfig:    zotConfig,
-		tempConfPath: tempConfPath,
-		log:          log,
+	zm := &ZotManager{
+		zotConfig:     zotConfig,
+		tempConfPath:  tempConfPath,
+		log:           log,
+		drainTimeout:  zotDrainTimeout,
+		healthTimeout: zotHealthTimeout,
+		failed:        make(chan error, 1),
 	}
+	zm.launch = zm.launchZot
+	return zm
 }
 
 func (zm *ZotManager) HandleRegistrySetup(ctx context.Context) error {
@@ -47,11 +63,21 @@ func (zm *ZotManager) HandleRegistrySetup(ctx context.Context) error {
 		return fmt.Errorf("error verifying registry config: %w", err)
 	}
 
-	if err := zm.LaunchZotRegistry(ctx, zm.tempConfPath); err != nil {
+	if err := zm.start(); err != nil {
 		return fmt.Errorf("error launching default zot registry: %w", err)
 	}
 
-	return nil
+	select {
+	case <-ctx.Done():
+		zm.log.Warn().Msg("Context cancelled, shutting down zot registry")
+		zm.restartMu.Lock()
+		defer zm.restartMu.Unlock()
+		zm.drain()
+		return nil
+	case err := <-zm.failed:
+		zm.log.Error().Err(err).Msg("Zot registry stopped, exiting")
+		return fmt.Errorf("zot registry run failure: %w", err)
+	}
 }
 
 // WriteTempZotConfig creates a temp file and writes the zot config to it.
@@ -63,7 +89,11 @@ func (zm *ZotManager) WriteTempZotConfig() error {
 		return fmt.Errorf("failed to create temp zot config file: %w", err)
 	}
 
-	if _, err := tmpFile.Write(zm.zotConfig); err != nil {
+	zm.mu.Lock()
+	zotConfig := zm.zotConfig
+	zm.mu.Unlock()
+
+	if _, err := tmpFile.Write(zotConfig); err != nil {
 		return fmt.Errorf("failed to write to temp zot file present at %s: %w", tmpFile.Name(), err)
 	}
 
@@ -92,50 +122,6 @@ func (zm *ZotManager) RemoveTempZotConfig(tmpPath string) error {
 	return nil
 }
 
-func (zm *ZotManager) LaunchZotRegistry(ctx context.Context, zotConfigPath string) error {
-	zm.log.Info().Str("configPath", zotConfigPath).Msg("Launching zot registry")
-
-	conf := config.New()
-
-	if err := server.LoadConfiguration(conf, zotConfigPath); err != nil {
-		zm.log.Error().Err(err).Msg("Failed to load configuration")
-		return fmt.Errorf("failed to load zot configuration from %s: %w", zotConfigPath, err)
-	}
-
-	ctlr := api.NewController(conf)
-
-	var ldapCredentials string
-	if conf.HTTP.Auth != nil && conf.HTTP.Auth.LDAP != nil {
-		ldapCredentials = conf.HTTP.Auth.LDAP.CredentialsFile
-	}
-
-	hotReloader, err := server.NewHotReloader(ctlr, zotConfigPath, ldapCredentials)
-	if err != nil {
-		zm.log.Error().Err(err).Msg("Failed to create a new hot reloader")
-		return fmt.Errorf("failed to create hot reloader: %w", err)
-	}
-
-	hotReloader.Start()
-
-	if err := ctlr.Init(); err != nil {
-		zm.log.Error().Err(err).Msg("Failed to init controller")
-		return fmt.Errorf("failed to initialize controller: %w", err)
-	}
-
-	go func() {
-		<-ctx.Done()
-		zm.log.Warn().Msg("Context cancelled, shutting down zot registry")
-		ctlr.Shutdown() // nolint: contextcheck
-	}()
-
-	if err := ctlr.Run(); !errors.Is(err, http.ErrServerClosed) {
-		zm.log.Error().Err(err).Msg("Failed to start zot registry, exiting")
-		return fmt.Errorf("zot registry run failure: %w", err)
-	}
-
-	return nil
-}
-
 // ValidateRegistryConfig validates the zot registry configuration file.
 func (zm *ZotManager) VerifyRegistryConfig(zotConfigPath string) error {
 	zm.log.Info().Str("configPath", zotConfigPath).Msg("Validating zot config")
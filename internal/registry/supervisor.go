@@ -0,0 +1,468 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"zotregistry.dev/zot/pkg/api"
+	"zotregistry.dev/zot/pkg/api/config"
+	"zotregistry.dev/zot/pkg/cli/server"
+)
+
+// Outcomes of applying a new registry config.
+const (
+	// RestartApplied is a registry restarted with the new config.
+	RestartApplied = "restarted"
+	// RestartRolledBack is a registry that failed to start with the new
+	// config and runs with the previous one again.
+	RestartRolledBack = "rolled_back"
+	// RestartFailed is a registry that started with neither config.
+	RestartFailed = "failed"
+	// RestartRejected is an invalid config, the registry was not restarted.
+	RestartRejected = "rejected"
+)
+
+const (
+	// zotDrainTimeout bounds how long in-flight requests may take to
+	// complete before the registry is restarted anyway.
+	zotDrainTimeout = 30 * time.Second
+	// zotHealthTimeout is how long a restarted registry has to answer /v2/.
+	zotHealthTimeout = 30 * time.Second
+	// zotHealthInterval is the pause between two health checks.
+	zotHealthInterval = 250 * time.Millisecond
+)
+
+// Restart is the outcome of applying a new config to the embedded registry,
+// reported to Ground Control.
+type Restart struct {
+	At      time.Time `json:"at"`
+	Outcome string    `json:"outcome"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// zotInstance is a running registry.
+type zotInstance interface {
+	// url is the base URL of the registry, e.g. "http://127.0.0.1:8585".
+	url() string
+	// done yields the error the registry stopped with.
+	done() <-chan error
+	// shutdown stops accepting requests and returns once in-flight ones
+	// have completed.
+	shutdown()
+	// reload applies the settings Zot reloads while running, access
+	// control and extensions, from the config at zotConfigPath.
+	reload(zotConfigPath string) error
+}
+
+type zotController struct {
+	ctlr    *api.Controller
+	baseURL string
+	exited  chan error
+}
+
+func (z *zotController) url() string        { return z.baseURL }
+func (z *zotController) done() <-chan error { return z.exited }
+func (z *zotController) shutdown()          { z.ctlr.Shutdown() } // nolint: contextcheck
+
+func (z *zotController) reload(zotConfigPath string) error {
+	conf := config.New()
+	if err := server.LoadConfiguration(conf, zotConfigPath); err != nil {
+		return fmt.Errorf("failed to load zot configuration from %s: %w", zotConfigPath, err)
+	}
+	z.ctlr.LoadNewConfig(conf)
+	return nil
+}
+
+// launchZot starts Zot with the config at zotConfigPath without waiting for
+// it to serve requests. Zot's own hot reloader is not started: it would keep
+// watching the config for a controller that a restart shut down, so the
+// settings it reloads are applied by Reload instead.
+func (zm *ZotManager) launchZot(zotConfigPath string) (zotInstance, error) {
+	zm.log.Info().Str("configPath", zotConfigPath).Msg("Launching zot registry")
+
+	conf := config.New()
+	if err := server.LoadConfiguration(conf, zotConfigPath); err != nil {
+		zm.log.Error().Err(err).Msg("Failed to load configuration")
+		return nil, fmt.Errorf("failed to load zot configuration from %s: %w", zotConfigPath, err)
+	}
+
+	ctlr := api.NewController(conf)
+
+	if err := ctlr.Init(); err != nil {
+		zm.log.Error().Err(err).Msg("Failed to init controller")
+		return nil, fmt.Errorf("failed to initialize controller: %w", err)
+	}
+
+	scheme := "http://"
+	if conf.HTTP.TLS != nil {
+		scheme = "https://"
+	}
+	z := &zotController{ctlr: ctlr, baseURL: scheme + net.JoinHostPort(conf.HTTP.Address, conf.HTTP.Port), exited: make(chan error, 1)}
+	go func() {
+		z.exited <- ctlr.Run()
+	}()
+	return z, nil
+}
+
+// start launches the registry and supervises it, without waiting for it to
+// serve requests.
+func (zm *ZotManager) start() error {
+	inst, err := zm.launch(zm.tempConfPath)
+	if err != nil {
+		return err
+	}
+	zm.supervise(inst)
+	return nil
+}
+
+// startHealthy launches the registry and waits until it answers /v2/.
+func (zm *ZotManager) startHealthy(ctx context.Context) error {
+	inst, err := zm.launch(zm.tempConfPath)
+	if err != nil {
+		return err
+	}
+	if err := zm.waitHealthy(ctx, inst); err != nil {
+		inst.shutdown()
+		return err
+	}
+	zm.supervise(inst)
+	return nil
+}
+
+// supervise makes inst the running registry. If it stops on its own, the
+// failure is handed to HandleRegistrySetup.
+func (zm *ZotManager) supervise(inst zotInstance) {
+	zm.mu.Lock()
+	zm.current = inst
+	zm.mu.Unlock()
+
+	go func() {
+		err := <-inst.done()
+		zm.mu.Lock()
+		unexpected := zm.current == inst
+		if unexpected {
+			zm.current = nil
+		}
+		zm.mu.Unlock()
+		if !unexpected {
+			return
+		}
+		if err == nil {
+			err = errors.New("registry stopped")
+		}
+		select {
+		case zm.failed <- err:
+		default:
+		}
+	}()
+}
+
+// drain stops the running registry, letting in-flight requests complete
+// for up to drainTimeout.
+func (zm *ZotManager) drain() {
+	zm.mu.Lock()
+	inst := zm.current
+	zm.current = nil
+	zm.mu.Unlock()
+	if inst == nil {
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		inst.shutdown()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		zm.log.Info().Msg("Zot registry drained")
+	case <-time.After(zm.drainTimeout):
+		zm.log.Warn().Dur("timeout", zm.drainTimeout).Msg("In-flight registry requests did not complete in time, restarting anyway")
+	}
+}
+
+// waitHealthy polls /v2/ of inst until it answers or healthTimeout passes.
+// A 401 counts as healthy, the registry may require authentication. The
+// certificate is not verified: the check only asks whether the registry
+// the satellite just started serves its API.
+func (zm *ZotManager) waitHealthy(ctx context.Context, inst zotInstance) error {
+	ctx, cancel := context.WithTimeout(ctx, zm.healthTimeout)
+	defer cancel()
+
+	client := &http.Client{
+		Timeout:   2 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}, //nolint:gosec // see above
+	}
+	ticker := time.NewTicker(zotHealthInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		err := checkRegistryAPI(ctx, client, inst.url())
+		if err == nil {
+			return nil
+		}
+		// Keep why the registry was unhealthy over the deadline cutting
+		// the last check short
+		if lastErr == nil || ctx.Err() == nil {
+			lastErr = err
+		}
+		select {
+		case err := <-inst.done():
+			if err == nil || errors.Is(err, http.ErrServerClosed) {
+				err = errors.New("registry stopped")
+			}
+			return fmt.Errorf("registry exited: %w", err)
+		case <-ctx.Done():
+			return fmt.Errorf("registry not healthy after %s: %w", zm.healthTimeout, lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+func checkRegistryAPI(ctx context.Context, client *http.Client, baseURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/v2/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("unexpected status %d from /v2/", resp.StatusCode)
+	}
+	return nil
+}
+
+// Reload applies a new registry config. Settings Zot reloads while running,
+// access control and extensions, are loaded into the running registry. Any
+// other change drains the registry and restarts it; if it does not answer
+// /v2/ with the new config, it is restarted with the previous one. The
+// outcome is kept for PendingRestart.
+func (zm *ZotManager) Reload(ctx context.Context, zotConfig json.RawMessage) error {
+	zm.restartMu.Lock()
+	defer zm.restartMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	zm.mu.Lock()
+	previous := zm.zotConfig
+	running := zm.current != nil
+	zm.mu.Unlock()
+
+	if bytes.Equal(previous, zotConfig) {
+		return nil
+	}
+	if err := zm.verifyConfig(zotConfig); err != nil {
+		zm.record(RestartRejected, err)
+		return fmt.Errorf("invalid zot config: %w", err)
+	}
+
+	restart, err := needsRestart(previous, zotConfig)
+	if err != nil {
+		restart = true
+	}
+	if !running || !restart {
+		if err := zm.writeConfig(zotConfig); err != nil {
+			return err
+		}
+		zm.setConfig(zotConfig)
+		if running {
+			zm.mu.Lock()
+			inst := zm.current
+			zm.mu.Unlock()
+			if inst != nil {
+				if err := inst.reload(zm.tempConfPath); err != nil {
+					return fmt.Errorf("reload zot registry: %w", err)
+				}
+			}
+		}
+		return nil
+	}
+
+	zm.log.Info().Msg("Restarting zot registry to apply the new configuration")
+	zm.drain()
+
+	err = zm.writeConfig(zotConfig)
+	if err == nil {
+		err = zm.startHealthy(ctx)
+	}
+	if err == nil {
+		zm.setConfig(zotConfig)
+		zm.record(RestartApplied, nil)
+		zm.log.Info().Msg("Zot registry restarted with the new configuration")
+		return nil
+	}
+
+	zm.log.Error().Err(err).Msg("Zot registry failed to start with the new configuration, rolling back")
+	rollbackErr := zm.writeConfig(previous)
+	if rollbackErr == nil {
+		rollbackErr = zm.startHealthy(ctx)
+	}
+	if rollbackErr != nil {
+		zm.record(RestartFailed, fmt.Errorf("%w; previous config: %w", err, rollbackErr))
+		select {
+		case zm.failed <- rollbackErr:
+		default:
+		}
+		return fmt.Errorf("restart zot registry: %w; roll back: %w", err, rollbackErr)
+	}
+	zm.record(RestartRolledBack, err)
+	zm.log.Warn().Msg("Zot registry rolled back to the previous configuration")
+	return fmt.Errorf("restart zot registry, rolled back: %w", err)
+}
+
+// Restart drains the registry and starts it again with its current config,
+// to serve a renewed certificate. If it does not start again, the failure is
+// handed to HandleRegistrySetup.
+func (zm *ZotManager) Restart(ctx context.Context) error {
+	zm.restartMu.Lock()
+	defer zm.restartMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	zm.mu.Lock()
+	running := zm.current != nil
+	zm.mu.Unlock()
+	if !running {
+		return nil
+	}
+
+	zm.log.Info().Msg("Restarting zot registry")
+	zm.drain()
+	if err := zm.startHealthy(ctx); err != nil {
+		zm.record(RestartFailed, err)
+		select {
+		case zm.failed <- err:
+		default:
+		}
+		return fmt.Errorf("restart zot registry: %w", err)
+	}
+	return nil
+}
+
+// RenewCertificates calls renew every interval until ctx is done, and
+// restarts the registry whenever renew reports a new certificate, as the
+// registry loads its certificate when it starts.
+func (zm *ZotManager) RenewCertificates(ctx context.Context, interval time.Duration, renew func() (bool, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		renewed, err := renew()
+		if err != nil {
+			zm.log.Warn().Err(err).Msg("Failed to renew the registry certificate")
+			continue
+		}
+		if !renewed {
+			continue
+		}
+		zm.log.Info().Msg("Registry certificate renewed")
+		if err := zm.Restart(ctx); err != nil {
+			zm.log.Error().Err(err).Msg("Failed to restart the registry with its renewed certificate")
+		}
+	}
+}
+
+// verifyConfig loads zotConfig the way Zot does, from a file next to the
+// live one.
+func (zm *ZotManager) verifyConfig(zotConfig json.RawMessage) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(zm.tempConfPath), "zot-candidate-*.json")
+	if err != nil {
+		return fmt.Errorf("create candidate zot config: %w", err)
+	}
+	defer func() { _ = os.Remove(tmpFile.Name()) }()
+	if _, err := tmpFile.Write(zotConfig); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("write candidate zot config: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("close candidate zot config: %w", err)
+	}
+	return server.LoadConfiguration(config.New(), tmpFile.Name())
+}
+
+func (zm *ZotManager) writeConfig(zotConfig json.RawMessage) error {
+	zm.mu.Lock()
+	zm.zotConfig = zotConfig
+	zm.mu.Unlock()
+	return zm.WriteTempZotConfig()
+}
+
+func (zm *ZotManager) setConfig(zotConfig json.RawMessage) {
+	zm.mu.Lock()
+	defer zm.mu.Unlock()
+	zm.zotConfig = zotConfig
+}
+
+// needsRestart reports whether going from previous to next changes more than
+// the settings Zot reloads while running.
+func needsRestart(previous, next json.RawMessage) (bool, error) {
+	var a, b map[string]any
+	if err := json.Unmarshal(previous, &a); err != nil {
+		return true, err
+	}
+	if err := json.Unmarshal(next, &b); err != nil {
+		return true, err
+	}
+	for _, m := range []map[string]any{a, b} {
+		delete(m, "extensions")
+		if h, ok := m["http"].(map[string]any); ok {
+			delete(h, "accessControl")
+		}
+	}
+	return !reflect.DeepEqual(a, b), nil
+}
+
+func (zm *ZotManager) record(outcome string, reason error) {
+	restart := Restart{At: time.Now().UTC(), Outcome: outcome}
+	if reason != nil {
+		restart.Reason = reason.Error()
+	}
+	metrics.RegistryRestarts.WithLabelValues(outcome).Inc()
+
+	zm.mu.Lock()
+	defer zm.mu.Unlock()
+	zm.pending = &restart
+}
+
+// PendingRestart returns the outcome of the last config applied by a
+// restart, until RestartReported is called for it.
+func (zm *ZotManager) PendingRestart() (Restart, bool) {
+	zm.mu.Lock()
+	defer zm.mu.Unlock()
+	if zm.pending == nil {
+		return Restart{}, false
+	}
+	return *zm.pending, true
+}
+
+// RestartReported drops restart once Ground Control received it. A restart
+// recorded meanwhile stays pending.
+func (zm *ZotManager) RestartReported(restart Restart) {
+	zm.mu.Lock()
+	defer zm.mu.Unlock()
+	if zm.pending != nil && *zm.pending == restart {
+		zm.pending = nil
+	}
+}
@@ -0,0 +1,251 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeZot is a registry that answers /v2/ unless its config names a broken
+// storage, and holds /slow until release is closed.
+type fakeZot struct {
+	srv    *httptest.Server
+	exited chan error
+
+	mu     sync.Mutex
+	config string
+}
+
+func (z *fakeZot) url() string        { return z.srv.URL }
+func (z *fakeZot) done() <-chan error { return z.exited }
+func (z *fakeZot) shutdown() {
+	_ = z.srv.Config.Shutdown(context.Background())
+	z.srv.Close()
+	select {
+	case z.exited <- http.ErrServerClosed:
+	default:
+	}
+}
+
+func (z *fakeZot) reload(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.config = string(data)
+	return nil
+}
+
+func (z *fakeZot) loaded() string {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.config
+}
+
+type fakeLauncher struct {
+	mu       sync.Mutex
+	launched []*fakeZot
+	arrived  atomic.Int32
+	release  chan struct{}
+}
+
+func (l *fakeLauncher) launch(path string) (zotInstance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	broken := strings.Contains(string(data), "broken")
+	z := &fakeZot{exited: make(chan error, 1), config: string(data)}
+	z.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/slow":
+			l.arrived.Add(1)
+			<-l.release
+		case broken:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.launched = append(l.launched, z)
+	return z, nil
+}
+
+func (l *fakeLauncher) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.launched)
+}
+
+func zotConfig(t *testing.T, rootDirectory string) json.RawMessage {
+	t.Helper()
+	return json.RawMessage(fmt.Sprintf(`{
+    "distSpecVersion": "1.1.0",
+    "storage": { "rootDirectory": %q },
+    "http": { "address": "127.0.0.1", "port": "8585" },
+    "log": { "level": "info" }
+}`, rootDirectory))
+}
+
+func newSupervisedZot(t *testing.T, initial json.RawMessage) (*ZotManager, *fakeLauncher) {
+	t.Helper()
+	launcher := &fakeLauncher{release: make(chan struct{})}
+	zm := NewZotManager(zerolog.Nop(), initial, filepath.Join(t.TempDir(), "zot-hot.json"))
+	zm.launch = launcher.launch
+	zm.healthTimeout = time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- zm.HandleRegistrySetup(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-stopped)
+	})
+	require.Eventually(t, func() bool { return zm.running() != nil }, 5*time.Second, 10*time.Millisecond)
+	return zm, launcher
+}
+
+func (zm *ZotManager) running() *fakeZot {
+	zm.mu.Lock()
+	defer zm.mu.Unlock()
+	z, _ := zm.current.(*fakeZot)
+	return z
+}
+
+func TestReload_DrainsAndRestarts(t *testing.T) {
+	zm, launcher := newSupervisedZot(t, zotConfig(t, "/var/lib/zot"))
+	old := zm.running()
+
+	// A pull in flight when the restart begins completes before the old
+	// registry stops
+	pulled := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(old.url() + "/slow")
+		if err != nil {
+			pulled <- 0
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		pulled <- resp.StatusCode
+	}()
+	require.Eventually(t, func() bool { return launcher.arrived.Load() == 1 }, time.Second, 10*time.Millisecond)
+	time.AfterFunc(100*time.Millisecond, func() { close(launcher.release) })
+
+	next := zotConfig(t, "/data/zot")
+	require.NoError(t, zm.Reload(context.Background(), next))
+	require.Equal(t, http.StatusOK, <-pulled)
+
+	require.Equal(t, 2, launcher.count())
+	require.Contains(t, zm.running().loaded(), "/data/zot")
+	restart, ok := zm.PendingRestart()
+	require.True(t, ok)
+	require.Equal(t, RestartApplied, restart.Outcome)
+
+	zm.RestartReported(restart)
+	_, ok = zm.PendingRestart()
+	require.False(t, ok)
+}
+
+func TestReload_RollsBackConfigThatFailsToStart(t *testing.T) {
+	initial := zotConfig(t, "/var/lib/zot")
+	zm, launcher := newSupervisedZot(t, initial)
+	close(launcher.release)
+
+	err := zm.Reload(context.Background(), zotConfig(t, "/broken"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rolled back")
+
+	// Started with the new config, then with the previous one again
+	require.Equal(t, 3, launcher.count())
+	require.Contains(t, zm.running().loaded(), "/var/lib/zot")
+	data, err := os.ReadFile(zm.tempConfPath)
+	require.NoError(t, err)
+	require.JSONEq(t, string(initial), string(data))
+
+	restart, ok := zm.PendingRestart()
+	require.True(t, ok)
+	require.Equal(t, RestartRolledBack, restart.Outcome)
+	require.Contains(t, restart.Reason, "500")
+}
+
+func TestReload_WithoutRestart(t *testing.T) {
+	zm, launcher := newSupervisedZot(t, zotConfig(t, "/var/lib/zot"))
+	close(launcher.release)
+
+	// Access control is loaded into the running registry
+	var cfg map[string]any
+	require.NoError(t, json.Unmarshal(zotConfig(t, "/var/lib/zot"), &cfg))
+	cfg["http"].(map[string]any)["accessControl"] = map[string]any{"repositories": map[string]any{}}
+	next, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.NoError(t, zm.Reload(context.Background(), next))
+	require.Equal(t, 1, launcher.count())
+	data, err := os.ReadFile(zm.tempConfPath)
+	require.NoError(t, err)
+	require.JSONEq(t, string(next), string(data))
+	require.JSONEq(t, string(next), zm.running().loaded())
+
+	// An invalid config is rejected and the registry keeps running
+	err = zm.Reload(context.Background(), json.RawMessage(`{invalid`))
+	require.Error(t, err)
+	require.Equal(t, 1, launcher.count())
+	require.NotNil(t, zm.running())
+	restart, ok := zm.PendingRestart()
+	require.True(t, ok)
+	require.Equal(t, RestartRejected, restart.Outcome)
+}
+
+func TestHandleRegistrySetup_ReturnsWhenRegistryStops(t *testing.T) {
+	launcher := &fakeLauncher{release: make(chan struct{})}
+	close(launcher.release)
+	zm := NewZotManager(zerolog.Nop(), zotConfig(t, "/var/lib/zot"), filepath.Join(t.TempDir(), "zot-hot.json"))
+	zm.launch = launcher.launch
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- zm.HandleRegistrySetup(context.Background()) }()
+	require.Eventually(t, func() bool { return zm.running() != nil }, 5*time.Second, 10*time.Millisecond)
+
+	zm.running().exited <- errors.New("listener closed")
+	require.ErrorContains(t, <-stopped, "listener closed")
+}
+
+func TestRenewCertificates_RestartsOnRenewal(t *testing.T) {
+	zm, launcher := newSupervisedZot(t, zotConfig(t, "/var/lib/zot"))
+	close(launcher.release)
+
+	var renewals atomic.Int32
+	renew := func() (bool, error) {
+		// Only the second check finds a new certificate
+		return renewals.Add(1) == 2, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- zm.RenewCertificates(ctx, 10*time.Millisecond, renew) }()
+
+	require.Eventually(t, func() bool { return renewals.Load() >= 4 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-stopped)
+
+	require.Equal(t, 2, launcher.count())
+	require.NotNil(t, zm.running())
+	_, ok := zm.PendingRestart()
+	require.False(t, ok, "a renewal is not reported as a config change")
+}
@@ -0,0 +1,256 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Files in the registry TLS directory.
+const (
+	TLSCAFile    = "ca.crt"
+	TLSCAKeyFile = "ca.key"
+	TLSCertFile  = "tls.crt"
+	TLSKeyFile   = "tls.key"
+	HtpasswdFile = "htpasswd"
+)
+
+const (
+	selfSignedCAValidity   = 10 * 365 * 24 * time.Hour
+	selfSignedCertValidity = 365 * 24 * time.Hour
+	// selfSignedRenewBefore is how long before it expires a self-signed
+	// certificate is issued again.
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// IssueSelfSignedTLS makes sure dir holds a CA and a server certificate for
+// hosts issued by it. The CA is kept for as long as it is valid, so runtimes
+// that trust it keep trusting the registry; the server certificate is issued
+// again when it covers other hosts or is about to expire. It reports whether
+// a new server certificate was written.
+func IssueSelfSignedTLS(dir string, hosts []string, now time.Time) (bool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return false, fmt.Errorf("create registry TLS directory: %w", err)
+	}
+
+	ca, caKey, err := loadCertAndKey(filepath.Join(dir, TLSCAFile), filepath.Join(dir, TLSCAKeyFile))
+	if err != nil || !validAt(ca, now) {
+		if ca, caKey, err = issueCA(dir, now); err != nil {
+			return false, err
+		}
+	}
+
+	cert, _, err := loadCertAndKey(filepath.Join(dir, TLSCertFile), filepath.Join(dir, TLSKeyFile))
+	if err == nil && validAt(cert, now) && cert.CheckSignatureFrom(ca) == nil && coversExactly(cert, hosts) {
+		return false, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, fmt.Errorf("generate registry key: %w", err)
+	}
+	template, err := certificateTemplate("harbor-satellite registry", now, selfSignedCertValidity)
+	if err != nil {
+		return false, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return false, fmt.Errorf("issue registry certificate: %w", err)
+	}
+	if err := writeKeyPair(dir, TLSCertFile, TLSKeyFile, der, key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// issueCA writes a new CA to dir.
+func issueCA(dir string, now time.Time) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate registry CA key: %w", err)
+	}
+	template, err := certificateTemplate("harbor-satellite registry CA", now, selfSignedCAValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("issue registry CA: %w", err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse registry CA: %w", err)
+	}
+	if err := writeKeyPair(dir, TLSCAFile, TLSCAKeyFile, der, key); err != nil {
+		return nil, nil, err
+	}
+	return ca, key, nil
+}
+
+func certificateTemplate(commonName string, now time.Time, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number: %w", err)
+	}
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+// validAt reports whether cert is valid at now and not due for renewal.
+func validAt(cert *x509.Certificate, now time.Time) bool {
+	return !now.Before(cert.NotBefore) && now.Add(selfSignedRenewBefore).Before(cert.NotAfter)
+}
+
+// coversExactly reports whether the SANs of cert are hosts.
+func coversExactly(cert *x509.Certificate, hosts []string) bool {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	want := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			host = ip.String()
+		}
+		want = append(want, host)
+	}
+	slices.Sort(sans)
+	slices.Sort(want)
+	return slices.Equal(sans, slices.Compact(want))
+}
+
+func loadCertAndKey(certFile, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(filepath.Clean(certFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Clean(keyFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, errors.New("invalid PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func writeKeyPair(dir, certName, keyName string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("marshal key: %w", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := writeFileAtomic(filepath.Join(dir, keyName), keyPEM, 0600); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, certName), certPEM, 0644)
+}
+
+// WriteTLSFiles writes a certificate chain, its key and the CA bundle that
+// issued it to dir, for certificates issued elsewhere such as SPIFFE SVIDs.
+// It reports whether any of them changed.
+func WriteTLSFiles(dir string, certPEM, keyPEM, caPEM []byte) (bool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return false, fmt.Errorf("create registry TLS directory: %w", err)
+	}
+
+	changed := false
+	for _, f := range []struct {
+		name string
+		data []byte
+		perm os.FileMode
+	}{
+		{TLSKeyFile, keyPEM, 0600},
+		{TLSCertFile, certPEM, 0644},
+		{TLSCAFile, caPEM, 0644},
+	} {
+		path := filepath.Join(dir, f.name)
+		if current, err := os.ReadFile(filepath.Clean(path)); err == nil && bytes.Equal(current, f.data) {
+			continue
+		}
+		if err := writeFileAtomic(path, f.data, f.perm); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// WriteHtpasswd writes an htpasswd file with a single bcrypt entry for
+// username.
+func WriteHtpasswd(path, username, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash registry password: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create htpasswd directory: %w", err)
+	}
+	return writeFileAtomic(path, []byte(username+":"+string(hash)+"\n"), 0600)
+}
+
+// writeFileAtomic replaces path with data, so that the registry never reads
+// a partly written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("chmod %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func readCert(t *testing.T, path string) *x509.Certificate {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestIssueSelfSignedTLS(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	hosts := []string{"localhost", "127.0.0.1", "0.0.0.0"}
+
+	issued, err := IssueSelfSignedTLS(dir, hosts, now)
+	require.NoError(t, err)
+	require.True(t, issued)
+
+	ca := readCert(t, filepath.Join(dir, TLSCAFile))
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	cert := readCert(t, filepath.Join(dir, TLSCertFile))
+	for _, host := range hosts {
+		_, err := cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, CurrentTime: now})
+		require.NoError(t, err, host)
+	}
+	info, err := os.Stat(filepath.Join(dir, TLSKeyFile))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// A valid certificate for the same hosts is kept
+	issued, err = IssueSelfSignedTLS(dir, []string{"0.0.0.0", "localhost", "127.0.0.1"}, now)
+	require.NoError(t, err)
+	require.False(t, issued)
+
+	// Other hosts or an upcoming expiry get a new certificate from the same
+	// CA, so that runtimes keep trusting the registry
+	for _, tc := range []struct {
+		hosts []string
+		at    time.Time
+	}{
+		{hosts: append(hosts, "edge-1.example.com"), at: now},
+		{hosts: hosts, at: now.Add(selfSignedCertValidity - selfSignedRenewBefore/2)},
+	} {
+		issued, err = IssueSelfSignedTLS(dir, tc.hosts, tc.at)
+		require.NoError(t, err)
+		require.True(t, issued)
+		require.True(t, ca.Equal(readCert(t, filepath.Join(dir, TLSCAFile))))
+		renewed := readCert(t, filepath.Join(dir, TLSCertFile))
+		require.NotEqual(t, cert.SerialNumber, renewed.SerialNumber)
+		cert = renewed
+	}
+}
+
+func TestWriteTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	changed, err := WriteTLSFiles(dir, []byte("cert"), []byte("key"), []byte("ca"))
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	changed, err = WriteTLSFiles(dir, []byte("cert"), []byte("key"), []byte("ca"))
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	changed, err = WriteTLSFiles(dir, []byte("renewed cert"), []byte("renewed key"), []byte("ca"))
+	require.NoError(t, err)
+	require.True(t, changed)
+	data, err := os.ReadFile(filepath.Join(dir, TLSCertFile))
+	require.NoError(t, err)
+	require.Equal(t, "renewed cert", string(data))
+}
+
+func TestWriteHtpasswd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tls", HtpasswdFile)
+	require.NoError(t, WriteHtpasswd(path, "admin", "secret"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	user, hash, ok := strings.Cut(strings.TrimSpace(string(data)), ":")
+	require.True(t, ok)
+	require.Equal(t, "admin", user)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret")))
+}
@@ -15,6 +15,8 @@ type Satellite struct {
 	criResults    []runtime.CRIConfigResult
 	schedulers    []*scheduler.Scheduler
 	stateFilePath string
+	stateProcess  *state.FetchAndReplicateStateProcess
+	restarts      state.RegistryRestartReporter
 }
 
 func NewSatellite(cm *config.ConfigManager, criResults []runtime.CRIConfigResult, stateFilePath string) *Satellite {
@@ -26,11 +28,23 @@ func NewSatellite(cm *config.ConfigManager, criResults []runtime.CRIConfigResult
 	}
 }
 
+// SetRegistryRestartReporter reports the restarts of the embedded registry
+// with the heartbeat. It must be called before Run.
+func (s *Satellite) SetRegistryRestartReporter(reporter state.RegistryRestartReporter) {
+	s.restarts = reporter
+}
+
 func (s *Satellite) Run(ctx context.Context) error {
 	log := logger.FromContext(ctx)
 	log.Info().Msg("Starting Satellite")
 
 	fetchAndReplicateStateProcess := state.NewFetchAndReplicateStateProcess(s.cm, s.stateFilePath, log)
+	s.stateProcess = fetchAndReplicateStateProcess
+
+	// The embedded registry logs to a file the satellite reads pulls from
+	if !s.cm.GetOwnRegistry() {
+		go fetchAndReplicateStateProcess.FollowRegistryLog(ctx)
+	}
 
 	// Create ZTR scheduler if not already done
 	if !s.cm.IsZTRDone() {
@@ -48,6 +62,7 @@ func (s *Satellite) Run(ctx context.Context) error {
 				s.cm.GetRegistrationInterval(),
 				spiffeZtrProcess,
 				log,
+				scheduler.WithJitter(s.cm.GetSchedulerJitter(spiffeZtrProcess.Name())),
 			)
 		} else {
 			log.Info().Msg("Using token-based ZTR")
@@ -56,6 +71,7 @@ func (s *Satellite) Run(ctx context.Context) error {
 				s.cm.GetRegistrationInterval(),
 				ztrProcess,
 				log,
+				scheduler.WithJitter(s.cm.GetSchedulerJitter(ztrProcess.Name())),
 			)
 		}
 
@@ -72,6 +88,7 @@ func (s *Satellite) Run(ctx context.Context) error {
 		s.cm.GetStateReplicationInterval(),
 		fetchAndReplicateStateProcess,
 		log,
+		scheduler.WithJitter(s.cm.GetSchedulerJitter(fetchAndReplicateStateProcess.Name())),
 	)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create state replication scheduler")
@@ -80,15 +97,35 @@ func (s *Satellite) Run(ctx context.Context) error {
 	s.schedulers = append(s.schedulers, stateScheduler)
 	stateScheduler.Start(ctx)
 
+	// Sync as soon as Ground Control reports a change; the schedule above
+	// remains the fallback while the subscription is down.
+	if s.cm.IsGroundControlEventsEnabled() {
+		watcher := state.NewEventWatcher(s.cm, func(context.Context) error {
+			_, err := stateScheduler.Trigger()
+			return err
+		})
+		go watcher.Run(ctx)
+	}
+
 	// Create status report scheduler with pending CRI results
 	statusReportProcess := state.NewStatusReportingProcess(s.cm)
 	if len(s.criResults) > 0 {
 		statusReportProcess.SetPendingCRIResults(s.criResults)
 	}
+	statusReportProcess.SetDeadLetterStore(fetchAndReplicateStateProcess.DeadLetters())
+	statusReportProcess.SetSignatureStore(fetchAndReplicateStateProcess.Signatures())
+	statusReportProcess.SetTombstoneStore(fetchAndReplicateStateProcess.Tombstones())
+	statusReportProcess.SetEvictionLog(fetchAndReplicateStateProcess.Evictions())
+	statusReportProcess.SetSyncReporter(fetchAndReplicateStateProcess)
+	statusReportProcess.SetConfigRollbackReporter(fetchAndReplicateStateProcess)
+	if s.restarts != nil {
+		statusReportProcess.SetRegistryRestartReporter(s.restarts)
+	}
 	statusScheduler, err := scheduler.NewSchedulerWithInterval(
 		s.cm.GetHeartbeatInterval(),
 		statusReportProcess,
 		log,
+		scheduler.WithJitter(s.cm.GetSchedulerJitter(statusReportProcess.Name())),
 	)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create status report scheduler")
@@ -104,6 +141,22 @@ func (s *Satellite) GetSchedulers() []*scheduler.Scheduler {
 	return s.schedulers
 }
 
+// Scheduler returns the scheduler of the named process, or nil if the
+// process is not scheduled.
+func (s *Satellite) Scheduler(name string) *scheduler.Scheduler {
+	for _, sched := range s.schedulers {
+		if sched.Name() == name {
+			return sched
+		}
+	}
+	return nil
+}
+
+// StateProcess returns the state replication process once Run has started it.
+func (s *Satellite) StateProcess() *state.FetchAndReplicateStateProcess {
+	return s.stateProcess
+}
+
 // Stop gracefully stops all schedulers and logs the shutdown process
 func (s *Satellite) Stop(ctx context.Context) {
 	log := logger.FromContext(ctx)
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"time"
 )
 
 // Process represents a process that can be scheduled
@@ -18,3 +19,11 @@ type Process interface {
 	// ShouldStop returns true if the process scheduling should be stopped
 	IsComplete() bool
 }
+
+// WindowedProcess is implemented by processes that may only run at certain
+// times. The scheduler defers runs while the window is closed and starts them
+// as soon as it opens.
+type WindowedProcess interface {
+	// InWindow reports whether the process may run at now and, if not, why
+	InWindow(now time.Time) (bool, string)
+}
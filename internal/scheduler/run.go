@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+)
+
+// Run statuses
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// What started a run
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// maxRunHistory is the number of finished runs a scheduler remembers.
+const maxRunHistory = 20
+
+var (
+	// ErrAlreadyRunning is returned by Trigger while a run of the process is
+	// in progress.
+	ErrAlreadyRunning = errors.New("process is already running")
+	// ErrNotStarted is returned by Trigger before Start or after shutdown.
+	ErrNotStarted = errors.New("scheduler is not running")
+)
+
+// Run is one execution of a scheduled process.
+type Run struct {
+	ID         string    `json:"id"`
+	Process    string    `json:"process"`
+	Trigger    string    `json:"trigger"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// TriggerOption adjusts the context a manually triggered run executes with,
+// for processes that accept per-run parameters through it.
+type TriggerOption func(ctx context.Context) context.Context
+
+type runIDKey struct{}
+
+// RunID returns the ID of the run executing with ctx, if any.
+func RunID(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Trigger starts a run of the process right away, outside of its schedule and
+// sync windows. While a run is in progress it returns that run together with
+// ErrAlreadyRunning.
+func (s *Scheduler) Trigger(opts ...TriggerOption) (Run, error) {
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+	if ctx == nil || ctx.Err() != nil {
+		return Run{}, ErrNotStarted
+	}
+
+	for _, opt := range opts {
+		ctx = opt(ctx)
+	}
+	return s.launchProcess(ctx, TriggerManual)
+}
+
+// Run returns the run with the given ID, if the scheduler still remembers it.
+func (s *Scheduler) Run(id string) (Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.runs {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return Run{}, false
+}
+
+// Runs returns the remembered runs, most recent first.
+func (s *Scheduler) Runs() []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := make([]Run, len(s.runs))
+	for i, r := range s.runs {
+		runs[len(s.runs)-1-i] = r
+	}
+	return runs
+}
+
+// startRun records a new run. The caller must hold s.mu.
+func (s *Scheduler) startRun(trigger string) Run {
+	run := Run{
+		ID:        newRunID(),
+		Process:   s.name,
+		Trigger:   trigger,
+		Status:    RunRunning,
+		StartedAt: time.Now(),
+	}
+	s.runs = append(s.runs, run)
+	if len(s.runs) > maxRunHistory {
+		s.runs = s.runs[len(s.runs)-maxRunHistory:]
+	}
+	s.current = run.ID
+	return run
+}
+
+// finishRun records the outcome of a run.
+func (s *Scheduler) finishRun(id string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == id {
+		s.current = ""
+	}
+	for i := range s.runs {
+		if s.runs[i].ID != id {
+			continue
+		}
+		s.runs[i].FinishedAt = time.Now()
+		s.runs[i].DurationMs = s.runs[i].FinishedAt.Sub(s.runs[i].StartedAt).Milliseconds()
+		s.runs[i].Status = RunSucceeded
+		if err != nil {
+			s.runs[i].Status = RunFailed
+			s.runs[i].Error = err.Error()
+		}
+		metrics.RunDuration.WithLabelValues(s.name, s.runs[i].Trigger, s.runs[i].Status).
+			Observe(s.runs[i].FinishedAt.Sub(s.runs[i].StartedAt).Seconds())
+	}
+}
+
+// currentRun returns the run in progress. The caller must hold s.mu.
+func (s *Scheduler) currentRun() Run {
+	for _, r := range s.runs {
+		if r.ID == s.current {
+			return r
+		}
+	}
+	return Run{}
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const everyPrefix = "@every "
+
+// cronParser accepts standard 5-field expressions, 6-field expressions with a
+// leading seconds field, descriptors such as @hourly, and a CRON_TZ= or TZ=
+// prefix selecting the time zone.
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// Schedule returns the next activation time after the given time.
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+// everySchedule runs at a fixed interval. Unlike cron's @every it keeps
+// sub-second precision.
+type everySchedule time.Duration
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// ParseSchedule parses an "@every <duration>" interval or a cron expression,
+// for example "0 3 * * *", "*/30 * * * * *" or "CRON_TZ=Europe/Berlin 0 2 * * 1-5".
+func ParseSchedule(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("empty expression provided")
+	}
+	if strings.HasPrefix(expr, everyPrefix) {
+		d, err := parseEveryExpr(expr)
+		if err != nil {
+			return nil, err
+		}
+		return everySchedule(d), nil
+	}
+	sched, err := cronParser.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return sched, nil
+}
+
+func parseEveryExpr(expr string) (time.Duration, error) {
+	if !strings.HasPrefix(expr, everyPrefix) {
+		return 0, fmt.Errorf("unsupported format: must start with %q", everyPrefix)
+	}
+	d, err := time.ParseDuration(strings.TrimPrefix(expr, everyPrefix))
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("interval must be positive, got %s", d)
+	}
+	return d, nil
+}
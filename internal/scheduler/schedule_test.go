@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	from := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)
+	fromBerlin := time.Date(2025, 3, 10, 12, 0, 0, 0, berlin)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		next time.Time
+	}{
+		{"every keeps sub-second precision", "@every 250ms", from, from.Add(250 * time.Millisecond)},
+		{"five fields", "30 14 * * *", from, time.Date(2025, 3, 10, 14, 30, 0, 0, time.Local)},
+		{"six fields with seconds", "15 */5 * * * *", from, from.Add(15 * time.Second)},
+		{"descriptor", "@daily", from, time.Date(2025, 3, 11, 0, 0, 0, 0, time.Local)},
+		{"time zone", "CRON_TZ=Europe/Berlin 0 2 * * *", fromBerlin, time.Date(2025, 3, 11, 2, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := ParseSchedule(tt.expr)
+			require.NoError(t, err)
+			next := sched.Next(tt.from)
+			require.True(t, tt.next.Equal(next), "next run %s, want %s", next, tt.next)
+		})
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "@every", "@every -1s", "@every 0s", "* * *", "61 * * * *", "CRON_TZ=Nowhere/City * * * * *"} {
+		_, err := ParseSchedule(expr)
+		require.Error(t, err, expr)
+	}
+}
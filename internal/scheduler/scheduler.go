@@ -3,38 +3,71 @@ package scheduler
 import (
 	"context"
 	"fmt"
-	"strings"
+	"math"
+	"math/rand/v2"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
 )
 
-// Scheduler manages the execution of processes with configurable intervals
+// windowRecheckInterval is how often a deferred run checks whether the
+// process's window has opened, so that a long interval cannot step over a
+// short window.
+const windowRecheckInterval = time.Minute
+
+// Scheduler manages the execution of processes on an interval or cron schedule
 type Scheduler struct {
 	name     string
-	ticker   *time.Ticker
 	process  Process
 	log      *zerolog.Logger
+	expr     string
+	schedule Schedule
 	interval time.Duration
+	jitter   time.Duration
+	recheck  time.Duration
+	reset    chan struct{}
+	ctx      context.Context
+	runs     []Run
+	current  string
 	mu       sync.Mutex
 	wg       sync.WaitGroup
 }
 
-// NewSchedulerWithInterval creates a new scheduler with a parsed interval string
-func NewSchedulerWithInterval(intervalExpr string, process Process, log *zerolog.Logger) (*Scheduler, error) {
-	duration, err := parseEveryExpr(intervalExpr)
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithJitter delays every run, including the first one, by a random duration
+// in [0, jitter), so that a fleet started at the same moment does not hit its
+// upstreams in the same second.
+func WithJitter(jitter time.Duration) Option {
+	return func(s *Scheduler) {
+		if jitter > 0 {
+			s.jitter = jitter
+		}
+	}
+}
+
+// NewSchedulerWithInterval creates a new scheduler from an "@every <duration>"
+// interval or a cron expression, see ParseSchedule.
+func NewSchedulerWithInterval(intervalExpr string, process Process, log *zerolog.Logger, opts ...Option) (*Scheduler, error) {
+	schedule, err := ParseSchedule(intervalExpr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse interval: %w", err)
 	}
 
-	ticker := time.NewTicker(duration)
 	scheduler := &Scheduler{
 		name:     process.Name(),
-		ticker:   ticker,
 		process:  process,
 		log:      log,
-		interval: duration,
+		expr:     intervalExpr,
+		schedule: schedule,
+		interval: scheduleInterval(schedule),
+		recheck:  windowRecheckInterval,
+		reset:    make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(scheduler)
 	}
 
 	return scheduler, nil
@@ -43,6 +76,10 @@ func NewSchedulerWithInterval(intervalExpr string, process Process, log *zerolog
 // Start launches Run in a goroutine with proper WaitGroup tracking.
 // wg.Add must happen before the goroutine to avoid a race with Stop.
 func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
 	s.wg.Add(1)
 	go s.run(ctx)
 }
@@ -50,17 +87,36 @@ func (s *Scheduler) Start(ctx context.Context) {
 // run starts the scheduler and blocks until context is cancelled
 func (s *Scheduler) run(ctx context.Context) {
 	defer s.wg.Done()
-	defer s.ticker.Stop()
 
 	s.log.Info().
 		Str("Process", s.process.Name()).
-		Dur("interval", s.interval).
+		Str("schedule", s.getExpr()).
+		Dur("jitter", s.jitter).
 		Msg("Starting scheduler")
 
-	// Run once immediately
-	s.launchProcess(ctx)
+	// Run once immediately, after the splay
+	if splay := s.splay(); splay > 0 {
+		s.log.Debug().
+			Str("Process", s.process.Name()).
+			Dur("splay", splay).
+			Msg("Delaying first run")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(splay):
+		}
+	}
+	deferred := s.launchInWindow(ctx, false)
+
+	timer := time.NewTimer(s.untilNext())
+	defer timer.Stop()
 
 	for {
+		var recheck <-chan time.Time
+		if deferred {
+			recheck = time.After(s.recheck)
+		}
+
 		select {
 		case <-ctx.Done():
 			s.log.Info().
@@ -68,49 +124,145 @@ func (s *Scheduler) run(ctx context.Context) {
 				Msg("Scheduler received cancellation signal. Exiting...")
 			return
 
-		case <-s.ticker.C:
+		case <-timer.C:
 			if s.process.IsComplete() {
 				s.log.Info().
 					Str("Process", s.process.Name()).
 					Msg("Process marked as complete. Stopping scheduling.")
 				return
 			}
-			s.launchProcess(ctx)
+			deferred = s.launchInWindow(ctx, deferred)
+			timer.Reset(s.untilNext())
+
+		case <-s.reset:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(s.untilNext())
+
+		case <-recheck:
+			deferred = s.launchInWindow(ctx, deferred)
 		}
 	}
 }
 
-// ResetInterval changes the ticker interval dynamically
-func (s *Scheduler) ResetInterval(newInterval time.Duration) {
+// untilNext returns how long to wait for the next run of the schedule, jitter
+// included. A cron schedule that never fires again waits indefinitely, until
+// the schedule is reset.
+func (s *Scheduler) untilNext() time.Duration {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	schedule := s.schedule
+	s.mu.Unlock()
 
-	s.ticker.Reset(newInterval)
-	s.interval = newInterval
-	s.log.Info().
-		Str("Process", s.process.Name()).
-		Dur("newInterval", newInterval).
-		Msg("Scheduler interval reset")
+	now := time.Now()
+	next := schedule.Next(now)
+	if next.IsZero() {
+		s.log.Warn().
+			Str("Process", s.process.Name()).
+			Str("schedule", s.getExpr()).
+			Msg("Schedule has no future runs")
+		return math.MaxInt64
+	}
+	return next.Sub(now) + s.splay()
+}
+
+// splay returns a random delay below the configured jitter.
+func (s *Scheduler) splay() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return rand.N(s.jitter)
+}
+
+// launchInWindow launches the process unless it is a WindowedProcess whose
+// window is closed, and reports whether the run was deferred.
+func (s *Scheduler) launchInWindow(ctx context.Context, wasDeferred bool) bool {
+	if w, ok := s.process.(WindowedProcess); ok {
+		if open, reason := w.InWindow(time.Now()); !open {
+			event := s.log.Debug()
+			if !wasDeferred {
+				event = s.log.Info()
+			}
+			event.Str("Process", s.process.Name()).
+				Str("reason", reason).
+				Msg("Outside of sync window, deferring run")
+			return true
+		}
+		if wasDeferred {
+			s.log.Info().
+				Str("Process", s.process.Name()).
+				Msg("Sync window opened, running deferred task")
+		}
+	}
+	_, _ = s.launchProcess(ctx, TriggerSchedule)
+	return false
+}
+
+// ResetInterval changes the scheduler to run at a fixed interval
+func (s *Scheduler) ResetInterval(newInterval time.Duration) {
+	if newInterval <= 0 {
+		s.log.Warn().
+			Str("Process", s.process.Name()).
+			Dur("newInterval", newInterval).
+			Msg("Ignoring non-positive scheduler interval")
+		return
+	}
+	s.resetSchedule(fmt.Sprintf("%s%s", everyPrefix, newInterval), everySchedule(newInterval))
 }
 
-// ResetIntervalFromExpr changes the ticker interval using an expression string
+// ResetIntervalFromExpr changes the schedule using an "@every <duration>" or
+// cron expression
 func (s *Scheduler) ResetIntervalFromExpr(intervalExpr string) error {
-	duration, err := parseEveryExpr(intervalExpr)
+	schedule, err := ParseSchedule(intervalExpr)
 	if err != nil {
 		return fmt.Errorf("failed to parse interval: %w", err)
 	}
 
-	s.ResetInterval(duration)
+	s.resetSchedule(intervalExpr, schedule)
 	return nil
 }
 
-// GetInterval returns the current interval
+func (s *Scheduler) resetSchedule(expr string, schedule Schedule) {
+	s.mu.Lock()
+	s.expr = expr
+	s.schedule = schedule
+	s.interval = scheduleInterval(schedule)
+	s.mu.Unlock()
+
+	select {
+	case s.reset <- struct{}{}:
+	default:
+	}
+	s.log.Info().
+		Str("Process", s.process.Name()).
+		Str("schedule", expr).
+		Msg("Scheduler interval reset")
+}
+
+// GetInterval returns the current interval, or zero for cron schedules
 func (s *Scheduler) GetInterval() time.Duration {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	return s.interval
 }
 
+func (s *Scheduler) getExpr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expr
+}
+
+// scheduleInterval returns the fixed interval of an @every schedule.
+func scheduleInterval(schedule Schedule) time.Duration {
+	if every, ok := schedule.(everySchedule); ok {
+		return time.Duration(every)
+	}
+	return 0
+}
+
 // Name returns the name of the scheduler
 func (s *Scheduler) Name() string {
 	return s.name
@@ -132,39 +284,39 @@ func (s *Scheduler) Stop(ctx context.Context) error {
 	}
 }
 
-func (s *Scheduler) launchProcess(ctx context.Context) {
+// launchProcess starts a run unless one is already in progress, in which
+// case that run is returned with ErrAlreadyRunning.
+func (s *Scheduler) launchProcess(ctx context.Context, trigger string) (Run, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.process.IsRunning() {
-		s.log.Info().
-			Str("Process", s.process.Name()).
-			Msg("Scheduler triggering task execution")
-
-		s.wg.Add(1)
-		go func() {
-			defer s.wg.Done()
-			if err := s.process.Execute(ctx); err != nil {
-				s.log.Warn().
-					Str("Process", s.process.Name()).
-					Err(err).
-					Msg("Error occurred while executing process.")
-			}
-		}()
-	} else {
+	if s.current != "" || s.process.IsRunning() {
 		s.log.Debug().
 			Str("Process", s.process.Name()).
+			Str("trigger", trigger).
 			Msg("Process already executing")
+		return s.currentRun(), ErrAlreadyRunning
 	}
-}
 
-func parseEveryExpr(expr string) (time.Duration, error) {
-	const prefix = "@every "
-	if expr == "" {
-		return 0, fmt.Errorf("empty expression provided")
-	}
-	if !strings.HasPrefix(expr, prefix) {
-		return 0, fmt.Errorf("unsupported format: must start with %q", prefix)
-	}
-	return time.ParseDuration(strings.TrimPrefix(expr, prefix))
+	run := s.startRun(trigger)
+	s.log.Info().
+		Str("Process", s.process.Name()).
+		Str("run_id", run.ID).
+		Str("trigger", trigger).
+		Msg("Scheduler triggering task execution")
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		err := s.process.Execute(context.WithValue(ctx, runIDKey{}, run.ID))
+		s.finishRun(run.ID, err)
+		if err != nil {
+			s.log.Warn().
+				Str("Process", s.process.Name()).
+				Str("run_id", run.ID).
+				Err(err).
+				Msg("Error occurred while executing process.")
+		}
+	}()
+	return run, nil
 }
@@ -2,11 +2,15 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
@@ -225,3 +229,213 @@ func TestMultipleSchedulers_GracefulShutdown(t *testing.T) {
 		t.Fatal("timed out waiting for all schedulers to stop")
 	}
 }
+
+// windowedProcess is a mockProcess that may only run while open is set.
+type windowedProcess struct {
+	mockProcess
+	open atomic.Bool
+}
+
+func (w *windowedProcess) InWindow(time.Time) (bool, string) {
+	if w.open.Load() {
+		return true, ""
+	}
+	return false, "window closed"
+}
+
+func TestWindowedProcess_DeferredUntilWindowOpens(t *testing.T) {
+	proc := &windowedProcess{mockProcess: mockProcess{name: "windowed"}}
+
+	sched, err := NewSchedulerWithInterval("@every 1h", proc, nopLogger())
+	require.NoError(t, err)
+	sched.recheck = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched.Start(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(0), proc.execCount.Load(), "process ran outside its window")
+
+	// The hourly tick is far away; the deferred run starts on a recheck.
+	proc.open.Store(true)
+	require.Eventually(t, func() bool {
+		return proc.execCount.Load() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(1), proc.execCount.Load(), "rechecks continued after the deferred run")
+
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+}
+
+func TestCronSchedule_Runs(t *testing.T) {
+	proc := &mockProcess{name: "cron"}
+
+	sched, err := NewSchedulerWithInterval("* * * * * *", proc, nopLogger())
+	require.NoError(t, err)
+	require.Zero(t, sched.GetInterval())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched.Start(ctx)
+
+	// The immediate run, then the next full second.
+	require.Eventually(t, func() bool {
+		return proc.execCount.Load() >= 2
+	}, 3*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+}
+
+func TestJitter_DelaysFirstRun(t *testing.T) {
+	proc := &mockProcess{name: "jittered"}
+
+	sched, err := NewSchedulerWithInterval("@every 1h", proc, nopLogger(), WithJitter(time.Hour))
+	require.NoError(t, err)
+
+	for range 100 {
+		d := sched.splay()
+		require.GreaterOrEqual(t, d, time.Duration(0))
+		require.Less(t, d, time.Hour)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sched.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+
+	// With an hour of splay the first run is all but certain to be pending.
+	require.Equal(t, int32(0), proc.execCount.Load())
+}
+
+func TestResetIntervalFromExpr_RearmsTimer(t *testing.T) {
+	proc := &mockProcess{name: "reset"}
+
+	sched, err := NewSchedulerWithInterval("@every 1h", proc, nopLogger())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched.Start(ctx)
+	require.Eventually(t, func() bool {
+		return proc.execCount.Load() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	require.Error(t, sched.ResetIntervalFromExpr("not a schedule"))
+	require.NoError(t, sched.ResetIntervalFromExpr("@every 20ms"))
+	require.Equal(t, 20*time.Millisecond, sched.GetInterval())
+
+	// The hourly timer is replaced rather than waited out.
+	require.Eventually(t, func() bool {
+		return proc.execCount.Load() >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+}
+
+func TestTrigger_RespectsRunningGuard(t *testing.T) {
+	release := make(chan struct{})
+	proc := &mockProcess{
+		name: "triggered",
+		execFn: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+	}
+
+	sched, err := NewSchedulerWithInterval("@every 1h", proc, nopLogger())
+	require.NoError(t, err)
+
+	_, err = sched.Trigger()
+	require.ErrorIs(t, err, ErrNotStarted)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched.Start(ctx)
+
+	// The immediate scheduled run is in progress.
+	require.Eventually(t, func() bool { return proc.execCount.Load() == 1 }, time.Second, 5*time.Millisecond)
+	running, err := sched.Trigger()
+	require.ErrorIs(t, err, ErrAlreadyRunning)
+	require.Equal(t, TriggerSchedule, running.Trigger)
+	require.Equal(t, RunRunning, running.Status)
+
+	release <- struct{}{}
+	require.Eventually(t, func() bool {
+		run, ok := sched.Run(running.ID)
+		return ok && run.Status == RunSucceeded
+	}, time.Second, 5*time.Millisecond)
+
+	run, err := sched.Trigger()
+	require.NoError(t, err)
+	require.Equal(t, TriggerManual, run.Trigger)
+	require.NotEqual(t, running.ID, run.ID)
+	close(release)
+	require.Eventually(t, func() bool {
+		run, _ := sched.Run(run.ID)
+		return run.Status == RunSucceeded && !run.FinishedAt.IsZero()
+	}, time.Second, 5*time.Millisecond)
+
+	runs := sched.Runs()
+	require.Len(t, runs, 2)
+	require.Equal(t, run.ID, runs[0].ID, "most recent run first")
+
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+}
+
+func TestTrigger_PassesOptionsAndRecordsFailure(t *testing.T) {
+	type key struct{}
+	seen := make(chan string, 2)
+	proc := &mockProcess{
+		name: "failing",
+		execFn: func(ctx context.Context) error {
+			v, _ := ctx.Value(key{}).(string)
+			seen <- v
+			return errors.New("boom")
+		},
+	}
+
+	sched, err := NewSchedulerWithInterval("@every 1h", proc, nopLogger())
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched.Start(ctx)
+	require.Equal(t, "", <-seen)
+	require.Eventually(t, func() bool { return len(sched.Runs()) == 1 && sched.Runs()[0].Status == RunFailed }, time.Second, 5*time.Millisecond)
+
+	run, err := sched.Trigger(func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, key{}, "group-a")
+	})
+	require.NoError(t, err)
+	require.Equal(t, "group-a", <-seen)
+	require.Eventually(t, func() bool {
+		got, _ := sched.Run(run.ID)
+		return got.Status == RunFailed && got.Error == "boom"
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+}
+
+func TestFinishRun_ObservesDuration(t *testing.T) {
+	proc := &mockProcess{name: "observed", execErr: errors.New("boom")}
+	sched, err := NewSchedulerWithInterval("@every 1h", proc, nopLogger())
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched.Start(ctx)
+	require.Eventually(t, func() bool { return len(sched.Runs()) == 1 && sched.Runs()[0].Status == RunFailed }, time.Second, 5*time.Millisecond)
+
+	m := &dto.Metric{}
+	require.NoError(t, metrics.RunDuration.WithLabelValues("observed", TriggerSchedule, RunFailed).(prometheus.Metric).Write(m))
+	require.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+}
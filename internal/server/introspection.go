@@ -0,0 +1,175 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/container-registry/harbor-satellite/internal/scheduler"
+	"github.com/container-registry/harbor-satellite/internal/state"
+)
+
+// defaultRunLimit is the number of runs listed when no limit is given.
+const defaultRunLimit = 10
+
+// StateInspector exposes what the satellite replicated.
+type StateInspector interface {
+	Inventory() []state.GroupInventory
+	ConfigDigest() string
+	RunGroups(runID string) ([]state.GroupSyncResult, bool)
+}
+
+// IntrospectionRegistrar serves read-only endpoints describing the
+// replication state of the satellite:
+//
+//	GET /api/v1/groups                        groups and their entities
+//	GET /api/v1/groups/{group}                one group
+//	GET /api/v1/entities?group=...&status=... entities and their status
+//	GET /api/v1/runs?limit=N                  the last N runs, newest first
+//	GET /api/v1/config                        digest of the applied config
+//
+// Every endpoint requires Token as a bearer token.
+type IntrospectionRegistrar struct {
+	State           StateInspector
+	StateScheduler  *scheduler.Scheduler
+	StatusScheduler *scheduler.Scheduler
+	Token           string
+}
+
+type groupResponse struct {
+	state.GroupInventory
+	Counts map[string]int `json:"counts"`
+}
+
+type entityResponse struct {
+	Group string `json:"group"`
+	state.EntityInventory
+}
+
+type runDetail struct {
+	scheduler.Run
+	Groups []state.GroupSyncResult `json:"groups,omitempty"`
+}
+
+type configResponse struct {
+	ConfigDigest string `json:"config_digest"`
+}
+
+func (ir *IntrospectionRegistrar) RegisterRoutes(router Router) {
+	api := router.Group("/api/v1")
+	api.Use(BearerAuth(ir.Token))
+	api.HandleFunc("/groups", ir.listGroups)
+	api.HandleFunc("/groups/{group}", ir.getGroup)
+	api.HandleFunc("/entities", ir.listEntities)
+	api.HandleFunc("/runs", ir.listRuns)
+	api.HandleFunc("/config", ir.getConfig)
+}
+
+func (ir *IntrospectionRegistrar) listGroups(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	groups := []groupResponse{}
+	for _, g := range ir.inventory() {
+		groups = append(groups, newGroupResponse(g))
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+func (ir *IntrospectionRegistrar) getGroup(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	name := r.PathValue("group")
+	for _, g := range ir.inventory() {
+		if g.Name == name {
+			writeJSON(w, http.StatusOK, newGroupResponse(g))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "unknown group "+name)
+}
+
+func (ir *IntrospectionRegistrar) listEntities(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	group := r.URL.Query().Get("group")
+	status := r.URL.Query().Get("status")
+
+	entities := []entityResponse{}
+	for _, g := range ir.inventory() {
+		if group != "" && g.Name != group {
+			continue
+		}
+		for _, e := range g.Entities {
+			if status != "" && e.Status != status {
+				continue
+			}
+			entities = append(entities, entityResponse{Group: g.Name, EntityInventory: e})
+		}
+	}
+	writeJSON(w, http.StatusOK, entities)
+}
+
+func (ir *IntrospectionRegistrar) listRuns(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	limit := defaultRunLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	runs := []runDetail{}
+	for _, s := range []*scheduler.Scheduler{ir.StateScheduler, ir.StatusScheduler} {
+		if s == nil {
+			continue
+		}
+		for _, run := range s.Runs() {
+			detail := runDetail{Run: run}
+			if ir.State != nil && s == ir.StateScheduler {
+				detail.Groups, _ = ir.State.RunGroups(run.ID)
+			}
+			runs = append(runs, detail)
+		}
+	}
+	slices.SortStableFunc(runs, func(a, b runDetail) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (ir *IntrospectionRegistrar) getConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	var resp configResponse
+	if ir.State != nil {
+		resp.ConfigDigest = ir.State.ConfigDigest()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (ir *IntrospectionRegistrar) inventory() []state.GroupInventory {
+	if ir.State == nil {
+		return nil
+	}
+	return ir.State.Inventory()
+}
+
+func newGroupResponse(g state.GroupInventory) groupResponse {
+	counts := map[string]int{}
+	for _, e := range g.Entities {
+		counts[e.Status]++
+	}
+	return groupResponse{GroupInventory: g, Counts: counts}
+}
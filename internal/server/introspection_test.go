@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/scheduler"
+	"github.com/container-registry/harbor-satellite/internal/state"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeInspector struct {
+	groups []state.GroupInventory
+	digest string
+	runs   map[string][]state.GroupSyncResult
+}
+
+func (f *fakeInspector) Inventory() []state.GroupInventory { return f.groups }
+func (f *fakeInspector) ConfigDigest() string              { return f.digest }
+func (f *fakeInspector) RunGroups(id string) ([]state.GroupSyncResult, bool) {
+	r, ok := f.runs[id]
+	return r, ok
+}
+
+// quickProcess finishes right away.
+type quickProcess struct{}
+
+func (quickProcess) Name() string                  { return "replicate_state" }
+func (quickProcess) IsRunning() bool               { return false }
+func (quickProcess) IsComplete() bool              { return false }
+func (quickProcess) Execute(context.Context) error { return nil }
+
+func newIntrospectionTestServer(t *testing.T, inspector *fakeInspector) (*httptest.Server, *scheduler.Scheduler) {
+	t.Helper()
+	log := zerolog.Nop()
+	sched, err := scheduler.NewSchedulerWithInterval("@every 1h", quickProcess{}, &log)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sched.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, sched.Stop(context.Background()))
+	})
+
+	app := NewApp(NewDefaultRouter(""), ctx, &log, &IntrospectionRegistrar{
+		State:          inspector,
+		StateScheduler: sched,
+		Token:          testToken,
+	})
+	app.SetupRoutes()
+	srv := httptest.NewServer(app)
+	t.Cleanup(srv.Close)
+	return srv, sched
+}
+
+func getJSON(t *testing.T, srv *httptest.Server, path string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func testInventory() []state.GroupInventory {
+	return []state.GroupInventory{
+		{
+			Name: "edge",
+			URL:  "registry/satellite/group-state/edge/state:latest",
+			Entities: []state.EntityInventory{
+				{Entity: state.Entity{Name: "app", Repository: "library", Tag: "v1"}, Status: state.InventoryReplicated},
+				{Entity: state.Entity{Name: "db", Repository: "library", Tag: "v1"}, Status: state.InventoryFailed, Error: "manifest unknown"},
+			},
+		},
+		{
+			Name: "prod",
+			URL:  "registry/satellite/group-state/prod/state:latest",
+			Entities: []state.EntityInventory{
+				{Entity: state.Entity{Name: "api", Repository: "library", Tag: "v2"}, Status: state.InventoryPending},
+			},
+		},
+	}
+}
+
+func TestIntrospectionRegistrar_Groups(t *testing.T) {
+	srv, _ := newIntrospectionTestServer(t, &fakeInspector{groups: testInventory()})
+
+	var groups []groupResponse
+	require.Equal(t, http.StatusOK, getJSON(t, srv, "/api/v1/groups", &groups))
+	require.Len(t, groups, 2)
+	require.Equal(t, "edge", groups[0].Name)
+	require.Equal(t, map[string]int{state.InventoryReplicated: 1, state.InventoryFailed: 1}, groups[0].Counts)
+
+	var group groupResponse
+	require.Equal(t, http.StatusOK, getJSON(t, srv, "/api/v1/groups/prod", &group))
+	require.Len(t, group.Entities, 1)
+	require.Equal(t, "api", group.Entities[0].Name)
+
+	require.Equal(t, http.StatusNotFound, getJSON(t, srv, "/api/v1/groups/unknown", nil))
+}
+
+func TestIntrospectionRegistrar_Entities(t *testing.T) {
+	srv, _ := newIntrospectionTestServer(t, &fakeInspector{groups: testInventory()})
+
+	var entities []entityResponse
+	require.Equal(t, http.StatusOK, getJSON(t, srv, "/api/v1/entities", &entities))
+	require.Len(t, entities, 3)
+
+	require.Equal(t, http.StatusOK, getJSON(t, srv, "/api/v1/entities?status=failed", &entities))
+	require.Len(t, entities, 1)
+	require.Equal(t, "edge", entities[0].Group)
+	require.Equal(t, "db", entities[0].Name)
+	require.Equal(t, "manifest unknown", entities[0].Error)
+
+	require.Equal(t, http.StatusOK, getJSON(t, srv, "/api/v1/entities?group=prod", &entities))
+	require.Len(t, entities, 1)
+	require.Equal(t, state.InventoryPending, entities[0].Status)
+}
+
+func TestIntrospectionRegistrar_Runs(t *testing.T) {
+	inspector := &fakeInspector{runs: map[string][]state.GroupSyncResult{}}
+	srv, sched := newIntrospectionTestServer(t, inspector)
+
+	// Wait for the scheduled first run, then add a manual one.
+	require.Eventually(t, func() bool {
+		runs := sched.Runs()
+		return len(runs) == 1 && runs[0].Status == scheduler.RunSucceeded
+	}, time.Second, 5*time.Millisecond)
+	first := sched.Runs()[0]
+	inspector.runs[first.ID] = []state.GroupSyncResult{{Group: "edge", Replicated: 2}}
+
+	time.Sleep(2 * time.Millisecond)
+	manual, err := sched.Trigger()
+	require.NoError(t, err)
+
+	var runs []runDetail
+	require.Equal(t, http.StatusOK, getJSON(t, srv, "/api/v1/runs", &runs))
+	require.Len(t, runs, 2)
+	require.Equal(t, manual.ID, runs[0].ID)
+	require.Equal(t, first.ID, runs[1].ID)
+	require.Equal(t, []state.GroupSyncResult{{Group: "edge", Replicated: 2}}, runs[1].Groups)
+
+	require.Equal(t, http.StatusOK, getJSON(t, srv, "/api/v1/runs?limit=1", &runs))
+	require.Len(t, runs, 1)
+	require.Equal(t, manual.ID, runs[0].ID)
+
+	require.Equal(t, http.StatusBadRequest, getJSON(t, srv, "/api/v1/runs?limit=0", nil))
+}
+
+func TestIntrospectionRegistrar_Config(t *testing.T) {
+	srv, _ := newIntrospectionTestServer(t, &fakeInspector{digest: "sha256:cfg"})
+
+	var resp configResponse
+	require.Equal(t, http.StatusOK, getJSON(t, srv, "/api/v1/config", &resp))
+	require.Equal(t, "sha256:cfg", resp.ConfigDigest)
+}
+
+func TestIntrospectionRegistrar_RequiresToken(t *testing.T) {
+	srv, _ := newIntrospectionTestServer(t, &fakeInspector{})
+
+	resp, err := http.Get(srv.URL + "/api/v1/groups")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
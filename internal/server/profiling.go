@@ -7,12 +7,19 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-type MetricsRegistrar struct{}
+// MetricsRegistrar serves the default Prometheus registry on /metrics. If
+// Token is set, scrapes must carry it as a bearer token.
+type MetricsRegistrar struct {
+	Token string
+}
 
 type DebugRegistrar struct{}
 
 func (m *MetricsRegistrar) RegisterRoutes(router Router) {
 	metricsGroup := router.Group("/metrics")
+	if m.Token != "" {
+		metricsGroup.Use(BearerAuth(m.Token))
+	}
 	metricsGroup.Handle("", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}))
 }
 
@@ -34,6 +34,11 @@ func NewApp(router Router, ctx context.Context, logger *zerolog.Logger, registra
 	}
 }
 
+// SetAddr changes the address the server listens on.
+func (a *App) SetAddr(addr string) {
+	a.server.Addr = addr
+}
+
 func (a *App) SetupRoutes() {
 	for _, registrar := range a.registrars {
 		registrar.RegisterRoutes(a.router)
@@ -53,7 +58,7 @@ func (a *App) Shutdown(ctx context.Context) error {
 
 func (a *App) SetupServer(g *errgroup.Group) {
 	g.Go(func() error {
-		a.Logger.Info().Msg("Starting server on :9090")
+		a.Logger.Info().Msgf("Starting server on %s", a.server.Addr)
 		if err := a.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			return err
 		}
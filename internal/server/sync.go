@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/container-registry/harbor-satellite/internal/scheduler"
+	"github.com/container-registry/harbor-satellite/internal/state"
+)
+
+// GroupChecker reports whether the satellite belongs to a group.
+type GroupChecker interface {
+	HasGroup(group string) bool
+}
+
+// SyncRegistrar serves the endpoints that trigger runs on demand and report
+// their progress:
+//
+//	POST /api/v1/sync/state            replicate the state of every group
+//	POST /api/v1/sync/groups/{group}   replicate the state of one group
+//	POST /api/v1/sync/status           send a status report
+//	GET  /api/v1/runs/{id}             poll a run
+//
+// Triggers return 202 with the new run, or 409 with the run in progress.
+// Every endpoint requires Token as a bearer token.
+type SyncRegistrar struct {
+	StateScheduler  *scheduler.Scheduler
+	StatusScheduler *scheduler.Scheduler
+	Groups          GroupChecker
+	Token           string
+}
+
+type runResponse struct {
+	scheduler.Run
+	Message string `json:"message,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (sr *SyncRegistrar) RegisterRoutes(router Router) {
+	api := router.Group("/api/v1")
+	api.Use(BearerAuth(sr.Token))
+	api.HandleFunc("/sync/state", sr.triggerState)
+	api.HandleFunc("/sync/groups/{group}", sr.triggerGroup)
+	api.HandleFunc("/sync/status", sr.triggerStatus)
+	api.HandleFunc("/runs/{id}", sr.getRun)
+}
+
+func (sr *SyncRegistrar) triggerState(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	trigger(w, sr.StateScheduler)
+}
+
+func (sr *SyncRegistrar) triggerGroup(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	group := r.PathValue("group")
+	if sr.Groups == nil || !sr.Groups.HasGroup(group) {
+		writeError(w, http.StatusNotFound, "unknown group "+group)
+		return
+	}
+	trigger(w, sr.StateScheduler, func(ctx context.Context) context.Context {
+		return state.WithGroup(ctx, group)
+	})
+}
+
+func (sr *SyncRegistrar) triggerStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	trigger(w, sr.StatusScheduler)
+}
+
+func (sr *SyncRegistrar) getRun(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	id := r.PathValue("id")
+	for _, s := range []*scheduler.Scheduler{sr.StateScheduler, sr.StatusScheduler} {
+		if s == nil {
+			continue
+		}
+		if run, ok := s.Run(id); ok {
+			writeJSON(w, http.StatusOK, runResponse{Run: run})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "unknown run "+id)
+}
+
+func trigger(w http.ResponseWriter, s *scheduler.Scheduler, opts ...scheduler.TriggerOption) {
+	if s == nil {
+		writeError(w, http.StatusServiceUnavailable, "process is not scheduled on this satellite")
+		return
+	}
+
+	run, err := s.Trigger(opts...)
+	switch {
+	case errors.Is(err, scheduler.ErrAlreadyRunning):
+		if run.ID != "" {
+			w.Header().Set("Location", "/api/v1/runs/"+run.ID)
+		}
+		writeJSON(w, http.StatusConflict, runResponse{Run: run, Message: err.Error()})
+	case err != nil:
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		w.Header().Set("Location", "/api/v1/runs/"+run.ID)
+		writeJSON(w, http.StatusAccepted, runResponse{Run: run})
+	}
+}
+
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/scheduler"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "0123456789abcdef"
+
+// blockingProcess runs until release is closed.
+type blockingProcess struct {
+	running atomic.Bool
+	release chan struct{}
+}
+
+func (p *blockingProcess) Name() string     { return "replicate_state" }
+func (p *blockingProcess) IsRunning() bool  { return p.running.Load() }
+func (p *blockingProcess) IsComplete() bool { return false }
+func (p *blockingProcess) Execute(ctx context.Context) error {
+	p.running.Store(true)
+	defer p.running.Store(false)
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+type staticGroups []string
+
+func (g staticGroups) HasGroup(group string) bool {
+	for _, name := range g {
+		if name == group {
+			return true
+		}
+	}
+	return false
+}
+
+func newSyncTestServer(t *testing.T) (*httptest.Server, *blockingProcess) {
+	t.Helper()
+	log := zerolog.Nop()
+	proc := &blockingProcess{release: make(chan struct{})}
+	sched, err := scheduler.NewSchedulerWithInterval("@every 1h", proc, &log)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sched.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, sched.Stop(context.Background()))
+	})
+
+	router := NewDefaultRouter("")
+	app := NewApp(router, ctx, &log, &SyncRegistrar{
+		StateScheduler: sched,
+		Groups:         staticGroups{"edge"},
+		Token:          testToken,
+	})
+	app.SetupRoutes()
+	srv := httptest.NewServer(app)
+	t.Cleanup(srv.Close)
+	return srv, proc
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path, token string) (*http.Response, runResponse) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var body runResponse
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	return resp, body
+}
+
+func TestSyncRegistrar_RequiresToken(t *testing.T) {
+	srv, _ := newSyncTestServer(t)
+
+	resp, _ := do(t, srv, http.MethodPost, "/api/v1/sync/state", "")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, _ = do(t, srv, http.MethodPost, "/api/v1/sync/state", "wrong-token-wrong")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSyncRegistrar_TriggerAndPoll(t *testing.T) {
+	srv, proc := newSyncTestServer(t)
+
+	// The scheduled first run holds the guard.
+	var running runResponse
+	require.Eventually(t, func() bool {
+		var resp *http.Response
+		resp, running = do(t, srv, http.MethodPost, "/api/v1/sync/state", testToken)
+		return resp.StatusCode == http.StatusConflict
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, scheduler.TriggerSchedule, running.Trigger)
+
+	resp, polled := do(t, srv, http.MethodGet, "/api/v1/runs/"+running.ID, testToken)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, scheduler.RunRunning, polled.Status)
+
+	close(proc.release)
+	require.Eventually(t, func() bool {
+		_, polled = do(t, srv, http.MethodGet, "/api/v1/runs/"+running.ID, testToken)
+		return polled.Status == scheduler.RunSucceeded
+	}, time.Second, 5*time.Millisecond)
+
+	resp, run := do(t, srv, http.MethodPost, "/api/v1/sync/groups/edge", testToken)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, "/api/v1/runs/"+run.ID, resp.Header.Get("Location"))
+	require.Equal(t, scheduler.TriggerManual, run.Trigger)
+}
+
+func TestSyncRegistrar_Errors(t *testing.T) {
+	srv, _ := newSyncTestServer(t)
+
+	resp, _ := do(t, srv, http.MethodPost, "/api/v1/sync/groups/unknown", testToken)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(t, srv, http.MethodGet, "/api/v1/sync/state", testToken)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, _ = do(t, srv, http.MethodGet, "/api/v1/runs/nope", testToken)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// No status scheduler is configured.
+	resp, _ = do(t, srv, http.MethodPost, "/api/v1/sync/status", testToken)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestMetricsRegistrar_RequiresToken(t *testing.T) {
+	log := zerolog.Nop()
+	app := NewApp(NewDefaultRouter(""), context.Background(), &log, &MetricsRegistrar{Token: testToken})
+	app.SetupRoutes()
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	resp, _ := do(t, srv, http.MethodGet, "/metrics", "")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, _ = do(t, srv, http.MethodGet, "/metrics", testToken)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,239 @@
+package signature
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Annotations cosign puts on the layers of a signature manifest.
+const (
+	cosignSignatureAnnotation   = "dev.cosignproject.cosign/signature"
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+	cosignChainAnnotation       = "dev.sigstore.cosign/chain"
+	cosignBundleAnnotation      = "dev.sigstore.cosign/bundle"
+)
+
+// cosignArtifactType is the artifact type of cosign signatures stored as OCI
+// 1.1 referrers.
+const cosignArtifactType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+
+// Fulcio certificate extensions carrying the OIDC issuer of the signer.
+var (
+	fulcioIssuerV1OID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	fulcioIssuerV2OID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// simpleSigning is the part of cosign's signed payload that binds the
+// signature to an image digest.
+type simpleSigning struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// rekorBundle is the transparency log entry cosign attaches to keyless
+// signatures.
+type rekorBundle struct {
+	SignedEntryTimestamp []byte       `json:"SignedEntryTimestamp"`
+	Payload              rekorPayload `json:"Payload"`
+}
+
+// rekorPayload is signed by the log in its canonical JSON form, which for
+// these fields is their alphabetical order.
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// verifyCosignImage checks every signature layer of a cosign signature
+// manifest and returns the signer of the first one that satisfies the policy.
+func (v *Verifier) verifyCosignImage(img v1.Image, digest v1.Hash) (string, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return "", fmt.Errorf("read cosign signature manifest: %w", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return "", fmt.Errorf("read cosign signature layers: %w", err)
+	}
+
+	var errs []string
+	for i, desc := range manifest.Layers {
+		if _, ok := desc.Annotations[cosignSignatureAnnotation]; !ok || i >= len(layers) {
+			continue
+		}
+		payload, err := readLayer(layers[i])
+		if err != nil {
+			return "", fmt.Errorf("read cosign payload: %w", err)
+		}
+		signer, err := v.verifyCosignLayer(payload, desc.Annotations, digest)
+		if err == nil {
+			return signer, nil
+		}
+		errs = append(errs, "cosign: "+err.Error())
+	}
+	if len(errs) == 0 {
+		return "", errors.New("cosign: signature manifest holds no signatures")
+	}
+	return "", errors.New(strings.Join(errs, "; "))
+}
+
+func (v *Verifier) verifyCosignLayer(payload []byte, annotations map[string]string, digest v1.Hash) (string, error) {
+	var p simpleSigning
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return "", fmt.Errorf("parse payload: %w", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != digest.String() {
+		return "", fmt.Errorf("payload signs %s, not %s", p.Critical.Image.DockerManifestDigest, digest)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(annotations[cosignSignatureAnnotation])
+	if err != nil {
+		return "", fmt.Errorf("decode signature: %w", err)
+	}
+
+	if certPEM := annotations[cosignCertificateAnnotation]; certPEM != "" {
+		return v.verifyCosignCertificate(payload, sig, certPEM, annotations[cosignChainAnnotation], annotations[cosignBundleAnnotation])
+	}
+
+	if len(v.keys) == 0 {
+		return "", errors.New("key-based signature but no public keys are trusted")
+	}
+	for _, k := range v.keys {
+		if verifySignature(k.key, payload, sig) == nil {
+			return k.id, nil
+		}
+	}
+	return "", errors.New("signature does not match any trusted public key")
+}
+
+// verifyCosignCertificate checks a keyless signature. Fulcio certificates
+// are short-lived, so the chain is verified at the time recorded in the Rekor
+// bundle when a Rekor key is trusted, and at the current time otherwise.
+func (v *Verifier) verifyCosignCertificate(payload, sig []byte, certPEM, chainPEM, bundle string) (string, error) {
+	certs, err := parsePEMCertificates([]byte(certPEM))
+	if err != nil {
+		return "", fmt.Errorf("parse certificate: %w", err)
+	}
+	var intermediates []*x509.Certificate
+	if chainPEM != "" {
+		if intermediates, err = parsePEMCertificates([]byte(chainPEM)); err != nil {
+			return "", fmt.Errorf("parse certificate chain: %w", err)
+		}
+	}
+	leaf := certs[0]
+
+	at := v.now()
+	if bundle != "" && v.rekorKey != nil {
+		if at, err = v.verifyRekorBundle(bundle, sig); err != nil {
+			return "", err
+		}
+	}
+
+	candidates := append([]string{}, leaf.EmailAddresses...)
+	for _, u := range leaf.URIs {
+		candidates = append(candidates, u.String())
+	}
+	signer, err := v.verifyCertificate(leaf, intermediates, at, candidates, fulcioIssuer(leaf))
+	if err != nil {
+		return "", err
+	}
+	if err := verifySignature(leaf.PublicKey, payload, sig); err != nil {
+		return "", err
+	}
+	return signer, nil
+}
+
+// verifyRekorBundle checks the log's signed entry timestamp and that the
+// entry records sig, and returns the time the entry was logged.
+func (v *Verifier) verifyRekorBundle(bundle string, sig []byte) (time.Time, error) {
+	var b rekorBundle
+	if err := json.Unmarshal([]byte(bundle), &b); err != nil {
+		return time.Time{}, fmt.Errorf("parse rekor bundle: %w", err)
+	}
+	canonical, err := json.Marshal(b.Payload)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("encode rekor payload: %w", err)
+	}
+	if err := verifySignature(v.rekorKey, canonical, b.SignedEntryTimestamp); err != nil {
+		return time.Time{}, fmt.Errorf("verify rekor bundle: %w", err)
+	}
+
+	body, err := base64.StdEncoding.DecodeString(b.Payload.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decode rekor entry: %w", err)
+	}
+	var entry struct {
+		Spec struct {
+			Signature struct {
+				Content string `json:"content"`
+			} `json:"signature"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return time.Time{}, fmt.Errorf("parse rekor entry: %w", err)
+	}
+	if entry.Spec.Signature.Content != base64.StdEncoding.EncodeToString(sig) {
+		return time.Time{}, errors.New("rekor entry does not record this signature")
+	}
+	return time.Unix(b.Payload.IntegratedTime, 0), nil
+}
+
+// fulcioIssuer returns the OIDC issuer recorded in a Fulcio certificate.
+func fulcioIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(fulcioIssuerV2OID):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(fulcioIssuerV1OID):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return certs, nil
+}
+
+func readLayer(layer v1.Layer) ([]byte, error) {
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+	return io.ReadAll(io.LimitReader(rc, maxPayloadSize))
+}
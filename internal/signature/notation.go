@@ -0,0 +1,207 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// notationArtifactType is the artifact type of notation signatures.
+const notationArtifactType = "application/vnd.cncf.notary.signature"
+
+// Media types of notation signature envelopes.
+const (
+	notationJWSMediaType  = "application/jose+json"
+	notationCOSEMediaType = "application/cose"
+	notationPayloadType   = "application/vnd.cncf.notary.payload.v1+json"
+)
+
+// jwsEnvelope is the flattened JSON serialization notation uses for JWS.
+type jwsEnvelope struct {
+	Payload   string `json:"payload"`
+	Protected string `json:"protected"`
+	Header    struct {
+		CertChain [][]byte `json:"x5c"`
+	} `json:"header"`
+	Signature string `json:"signature"`
+}
+
+type jwsProtectedHeader struct {
+	Algorithm   string     `json:"alg"`
+	ContentType string     `json:"cty"`
+	Expiry      *time.Time `json:"io.cncf.notary.expiry,omitempty"`
+}
+
+type notationPayload struct {
+	TargetArtifact v1.Descriptor `json:"targetArtifact"`
+}
+
+// verifyNotationImage checks the envelopes of a notation signature manifest
+// and returns the signer of the first one that satisfies the policy.
+func (v *Verifier) verifyNotationImage(img v1.Image, digest v1.Hash) (string, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return "", fmt.Errorf("read notation signature manifest: %w", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return "", fmt.Errorf("read notation signature layers: %w", err)
+	}
+
+	var errs []string
+	for i, desc := range manifest.Layers {
+		if i >= len(layers) {
+			break
+		}
+		switch string(desc.MediaType) {
+		case notationJWSMediaType:
+			envelope, err := readLayer(layers[i])
+			if err != nil {
+				return "", fmt.Errorf("read notation envelope: %w", err)
+			}
+			signer, err := v.verifyNotationJWS(envelope, digest)
+			if err == nil {
+				return signer, nil
+			}
+			errs = append(errs, "notation: "+err.Error())
+		case notationCOSEMediaType:
+			errs = append(errs, "notation: COSE signature envelopes are not supported")
+		}
+	}
+	if len(errs) == 0 {
+		return "", errors.New("notation: signature manifest holds no envelopes")
+	}
+	return "", errors.New(strings.Join(errs, "; "))
+}
+
+func (v *Verifier) verifyNotationJWS(data []byte, digest v1.Hash) (string, error) {
+	var env jwsEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return "", fmt.Errorf("parse envelope: %w", err)
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	if err != nil {
+		return "", fmt.Errorf("decode protected header: %w", err)
+	}
+	var header jwsProtectedHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return "", fmt.Errorf("parse protected header: %w", err)
+	}
+	if header.ContentType != notationPayloadType {
+		return "", fmt.Errorf("unexpected payload type %q", header.ContentType)
+	}
+	if header.Expiry != nil && v.now().After(*header.Expiry) {
+		return "", fmt.Errorf("signature expired at %s", header.Expiry.Format(time.RFC3339))
+	}
+
+	if len(env.Header.CertChain) == 0 {
+		return "", errors.New("envelope has no certificate chain")
+	}
+	certs := make([]*x509.Certificate, 0, len(env.Header.CertChain))
+	for _, der := range env.Header.CertChain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return "", fmt.Errorf("parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	leaf := certs[0]
+
+	sig, err := base64.RawURLEncoding.DecodeString(env.Signature)
+	if err != nil {
+		return "", fmt.Errorf("decode signature: %w", err)
+	}
+	if err := verifyJWS(header.Algorithm, leaf.PublicKey, []byte(env.Protected+"."+env.Payload), sig); err != nil {
+		return "", err
+	}
+
+	rawPayload, err := base64.RawURLEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return "", fmt.Errorf("decode payload: %w", err)
+	}
+	var payload notationPayload
+	if err := json.Unmarshal(rawPayload, &payload); err != nil {
+		return "", fmt.Errorf("parse payload: %w", err)
+	}
+	if payload.TargetArtifact.Digest != digest {
+		return "", fmt.Errorf("payload signs %s, not %s", payload.TargetArtifact.Digest, digest)
+	}
+
+	// Notation identities are X.509 subject DNs, compared attribute by
+	// attribute so that their order does not matter.
+	signer, err := v.verifyCertificate(leaf, certs[1:], v.now(), []string{normalizeDN(leaf.Subject.String())}, "")
+	if err != nil {
+		return "", err
+	}
+	return signer, nil
+}
+
+// verifyJWS checks a JWS signature for the algorithms notation signs with.
+func verifyJWS(alg string, key crypto.PublicKey, signingInput, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "PS256", "ES256":
+		hash = crypto.SHA256
+	case "PS384", "ES384":
+		hash = crypto.SHA384
+	case "PS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signingInput)
+	sum := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "PS") {
+			return fmt.Errorf("algorithm %s does not match RSA key", alg)
+		}
+		if err := rsa.VerifyPSS(k, hash, sum, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+			return errInvalidSignature
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("algorithm %s does not match ECDSA key", alg)
+		}
+		// JWS encodes ECDSA signatures as the fixed-size concatenation r || s.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, sum, r, s) {
+			return errInvalidSignature
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// normalizeDN puts the attributes of a distinguished name in a stable order,
+// e.g. "C=US, O=acme, CN=signer" and "CN=signer,O=acme,C=US" normalize to
+// the same string.
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
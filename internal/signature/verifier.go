@@ -0,0 +1,343 @@
+// Package signature verifies cosign and notation signatures of images against
+// the trust policy in the satellite config before they are replicated.
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Verdict is the outcome of checking an image against the trust policy.
+type Verdict string
+
+const (
+	// Verified means at least one signature satisfied the trust policy.
+	Verified Verdict = "verified"
+	// Unsigned means no cosign or notation signature was found.
+	Unsigned Verdict = "unsigned"
+	// Rejected means signatures were found but none satisfied the policy.
+	Rejected Verdict = "rejected"
+)
+
+// Result is the verdict for one image together with the identity of the
+// signer that satisfied the policy, or the reason it was rejected.
+type Result struct {
+	Verdict Verdict
+	Signer  string
+	Reason  string
+}
+
+// maxPayloadSize bounds the signature payloads and envelopes read from the
+// registry.
+const maxPayloadSize = 1 << 20
+
+var errInvalidSignature = errors.New("invalid signature")
+
+type trustedKey struct {
+	key crypto.PublicKey
+	id  string
+}
+
+type identity struct {
+	subject string
+	pattern *regexp.Regexp
+	issuer  string
+}
+
+// Verifier checks image signatures against a trust policy. It is safe for
+// concurrent use.
+type Verifier struct {
+	keys          []trustedKey
+	roots         *x509.CertPool
+	identities    []identity
+	rekorKey      crypto.PublicKey
+	enforce       bool
+	allowUnsigned bool
+	initErr       error
+	now           func() time.Time
+}
+
+// NewVerifier builds a verifier from the trust policy. It always returns a
+// usable verifier: if the policy cannot be loaded the error is returned and
+// the verifier rejects every image, so a broken policy fails closed.
+func NewVerifier(cfg config.SignatureVerificationConfig) (*Verifier, error) {
+	v := &Verifier{
+		enforce:       cfg.Mode != config.SignatureModeAudit,
+		allowUnsigned: cfg.AllowUnsigned,
+		now:           time.Now,
+	}
+	if err := v.load(cfg); err != nil {
+		v.initErr = err
+		return v, err
+	}
+	return v, nil
+}
+
+func (v *Verifier) load(cfg config.SignatureVerificationConfig) error {
+	for _, value := range cfg.PublicKeys {
+		key, err := config.ParsePublicKeyPEM(value)
+		if err != nil {
+			return fmt.Errorf("load public key: %w", err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return fmt.Errorf("marshal public key: %w", err)
+		}
+		sum := sha256.Sum256(der)
+		v.keys = append(v.keys, trustedKey{key: key, id: "sha256:" + hex.EncodeToString(sum[:])})
+	}
+
+	if len(cfg.RootCertificates) > 0 {
+		v.roots = x509.NewCertPool()
+		for _, value := range cfg.RootCertificates {
+			certs, err := config.ParseCertificatesPEM(value)
+			if err != nil {
+				return fmt.Errorf("load root certificates: %w", err)
+			}
+			for _, cert := range certs {
+				v.roots.AddCert(cert)
+			}
+		}
+	}
+
+	for _, id := range cfg.Identities {
+		parsed := identity{subject: id.Subject, issuer: id.Issuer}
+		if id.SubjectRegExp != "" {
+			re, err := regexp.Compile(id.SubjectRegExp)
+			if err != nil {
+				return fmt.Errorf("compile subject_regexp %q: %w", id.SubjectRegExp, err)
+			}
+			parsed.pattern = re
+		}
+		v.identities = append(v.identities, parsed)
+	}
+
+	if cfg.RekorPublicKey != "" {
+		key, err := config.ParsePublicKeyPEM(cfg.RekorPublicKey)
+		if err != nil {
+			return fmt.Errorf("load rekor public key: %w", err)
+		}
+		v.rekorKey = key
+	}
+	return nil
+}
+
+// Admits reports whether an image with the given result may be replicated.
+func (v *Verifier) Admits(r Result) bool {
+	if !v.enforce {
+		return true
+	}
+	switch r.Verdict {
+	case Verified:
+		return true
+	case Unsigned:
+		return v.allowUnsigned
+	default:
+		return false
+	}
+}
+
+// Verify looks up the cosign and notation signatures of subject and checks
+// them against the trust policy. Signatures are discovered through the
+// cosign tag scheme and the OCI referrers API. The error is non-nil only if
+// the signatures could not be fetched; a failed verification is reported as
+// a Rejected result.
+func (v *Verifier) Verify(ctx context.Context, subject name.Digest, opts ...remote.Option) (Result, error) {
+	digest, err := v1.NewHash(subject.DigestStr())
+	if err != nil {
+		return Result{}, fmt.Errorf("parse subject digest: %w", err)
+	}
+	opts = append(append([]remote.Option{}, opts...), remote.WithContext(ctx))
+	return v.VerifySignatures(digest, registrySignatures{subject: subject, digest: digest, opts: opts})
+}
+
+// Signatures gives access to the signatures of an image.
+type Signatures interface {
+	// CosignSignature returns the image cosign tags sha256-<hex>.sig for the
+	// image, or nil if there is none.
+	CosignSignature() (v1.Image, error)
+	// Referrers lists the artifacts referring to the image, with their
+	// artifact types.
+	Referrers() ([]v1.Descriptor, error)
+	// Referrer returns the referrer with the given digest.
+	Referrer(digest v1.Hash) (v1.Image, error)
+}
+
+// VerifySignatures is Verify for the signatures of the image with the given
+// digest read from sigs, e.g. from a bundle rather than a registry.
+func (v *Verifier) VerifySignatures(digest v1.Hash, sigs Signatures) (Result, error) {
+	if v.initErr != nil {
+		return Result{Verdict: Rejected, Reason: fmt.Sprintf("trust policy could not be loaded: %v", v.initErr)}, nil
+	}
+
+	found := false
+	var reasons []string
+	check := func(signer string, err error) (Result, bool) {
+		found = true
+		if err != nil {
+			reasons = append(reasons, err.Error())
+			return Result{}, false
+		}
+		return Result{Verdict: Verified, Signer: signer}, true
+	}
+
+	sigImage, err := sigs.CosignSignature()
+	if err != nil {
+		return Result{}, err
+	}
+	if sigImage != nil {
+		if r, ok := check(v.verifyCosignImage(sigImage, digest)); ok {
+			return r, nil
+		}
+	}
+
+	referrers, err := sigs.Referrers()
+	if err != nil {
+		return Result{}, err
+	}
+	for _, m := range referrers {
+		var verify func(v1.Image, v1.Hash) (string, error)
+		switch m.ArtifactType {
+		case cosignArtifactType:
+			verify = v.verifyCosignImage
+		case notationArtifactType:
+			verify = v.verifyNotationImage
+		default:
+			continue
+		}
+		img, err := sigs.Referrer(m.Digest)
+		if err != nil {
+			return Result{}, fmt.Errorf("fetch signature %s: %w", m.Digest, err)
+		}
+		if r, ok := check(verify(img, digest)); ok {
+			return r, nil
+		}
+	}
+
+	if !found {
+		return Result{Verdict: Unsigned}, nil
+	}
+	return Result{Verdict: Rejected, Reason: strings.Join(reasons, "; ")}, nil
+}
+
+// registrySignatures reads the signatures of an image from the registry it
+// is in, using the cosign tag scheme and the OCI referrers API.
+type registrySignatures struct {
+	subject name.Digest
+	digest  v1.Hash
+	opts    []remote.Option
+}
+
+func (s registrySignatures) CosignSignature() (v1.Image, error) {
+	sigTag := s.subject.Context().Tag(strings.Replace(s.digest.String(), ":", "-", 1) + ".sig")
+	img, err := remote.Image(sigTag, s.opts...)
+	switch {
+	case isNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("fetch cosign signature: %w", err)
+	}
+	return img, nil
+}
+
+func (s registrySignatures) Referrers() ([]v1.Descriptor, error) {
+	referrers, err := remote.Referrers(s.subject, s.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("list referrers: %w", err)
+	}
+	manifest, err := referrers.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("read referrers: %w", err)
+	}
+	return manifest.Manifests, nil
+}
+
+func (s registrySignatures) Referrer(digest v1.Hash) (v1.Image, error) {
+	return remote.Image(s.subject.Context().Digest(digest.String()), s.opts...)
+}
+
+// verifyCertificate checks that cert chains to the trusted roots at the given
+// time and returns the trusted identity it carries.
+func (v *Verifier) verifyCertificate(cert *x509.Certificate, intermediates []*x509.Certificate, at time.Time, candidates []string, issuer string) (string, error) {
+	if v.roots == nil {
+		return "", errors.New("certificate signature but no root certificates are trusted")
+	}
+	pool := x509.NewCertPool()
+	for _, c := range intermediates {
+		pool.AddCert(c)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: pool,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return "", fmt.Errorf("verify certificate chain: %w", err)
+	}
+
+	for _, id := range v.identities {
+		if id.issuer != "" && id.issuer != issuer {
+			continue
+		}
+		for _, c := range candidates {
+			if id.matches(c) {
+				return c, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("certificate identity %s is not trusted", strings.Join(candidates, ", "))
+}
+
+func (id identity) matches(subject string) bool {
+	if id.pattern != nil {
+		return id.pattern.MatchString(subject)
+	}
+	return id.subject == subject || normalizeDN(id.subject) == normalizeDN(subject)
+}
+
+// verifySignature checks sig over payload with SHA-256, as used by cosign.
+func verifySignature(key crypto.PublicKey, payload, sig []byte) error {
+	sum := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, sum[:], sig) {
+			return errInvalidSignature
+		}
+		return nil
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig); err != nil {
+			return errInvalidSignature
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, sig) {
+			return errInvalidSignature
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
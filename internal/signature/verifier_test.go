@@ -0,0 +1,380 @@
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+)
+
+// testRepo starts an in-memory registry with the referrers API and pushes a
+// random image to it, returning the repository and the image digest.
+func testRepo(t *testing.T) (name.Repository, name.Digest) {
+	t.Helper()
+	srv := httptest.NewServer(registry.New(registry.WithReferrersSupport(true)))
+	t.Cleanup(srv.Close)
+
+	repo, err := name.NewRepository(strings.TrimPrefix(srv.URL, "http://")+"/library/app", name.Insecure)
+	require.NoError(t, err)
+
+	img, err := random.Image(256, 1)
+	require.NoError(t, err)
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	require.NoError(t, remote.Write(repo.Tag("v1"), img))
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	return repo, repo.Digest(digest.String())
+}
+
+func publicKeyPEM(t *testing.T, key crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func certPEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+func newECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+// testCA is a self-signed root used to issue signing certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key := newECDSAKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test root"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) issue(t *testing.T, tmpl *x509.Certificate, pub crypto.PublicKey) *x509.Certificate {
+	t.Helper()
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, pub, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func cosignPayload(digest string) []byte {
+	return []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"library/app"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, digest))
+}
+
+func signECDSA(t *testing.T, key *ecdsa.PrivateKey, payload []byte) []byte {
+	t.Helper()
+	sum := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	require.NoError(t, err)
+	return sig
+}
+
+// pushCosignSignature stores a cosign signature layer under the
+// sha256-<hex>.sig tag of subject.
+func pushCosignSignature(t *testing.T, subject name.Digest, payload []byte, annotations map[string]string) {
+	t.Helper()
+	layer := static.NewLayer(payload, "application/vnd.dev.cosign.simplesigning.v1+json")
+	img, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{
+		Layer:       layer,
+		Annotations: annotations,
+	})
+	require.NoError(t, err)
+	tag := strings.Replace(subject.DigestStr(), ":", "-", 1) + ".sig"
+	require.NoError(t, remote.Write(subject.Context().Tag(tag), img))
+}
+
+// pushReferrer stores envelope as a single-layer artifact referring to
+// subject.
+func pushReferrer(t *testing.T, subject name.Digest, artifactType string, layerType types.MediaType, envelope []byte) {
+	t.Helper()
+	desc, err := remote.Head(subject)
+	require.NoError(t, err)
+
+	img, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{
+		Layer: static.NewLayer(envelope, layerType),
+	})
+	require.NoError(t, err)
+	img = mutate.ConfigMediaType(img, types.MediaType(artifactType))
+	artifact, ok := mutate.Subject(img, *desc).(v1.Image)
+	require.True(t, ok)
+
+	digest, err := artifact.Digest()
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(subject.Context().Digest(digest.String()), artifact))
+}
+
+func verify(t *testing.T, cfg config.SignatureVerificationConfig, subject name.Digest) Result {
+	t.Helper()
+	v, err := NewVerifier(cfg)
+	require.NoError(t, err)
+	result, err := v.Verify(context.Background(), subject)
+	require.NoError(t, err)
+	return result
+}
+
+func TestVerify_Unsigned(t *testing.T) {
+	_, subject := testRepo(t)
+	key := newECDSAKey(t)
+
+	result := verify(t, config.SignatureVerificationConfig{PublicKeys: []string{publicKeyPEM(t, &key.PublicKey)}}, subject)
+	require.Equal(t, Unsigned, result.Verdict)
+}
+
+func TestVerify_CosignPublicKey(t *testing.T) {
+	_, subject := testRepo(t)
+	key := newECDSAKey(t)
+	payload := cosignPayload(subject.DigestStr())
+	pushCosignSignature(t, subject, payload, map[string]string{
+		cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signECDSA(t, key, payload)),
+	})
+
+	result := verify(t, config.SignatureVerificationConfig{PublicKeys: []string{publicKeyPEM(t, &key.PublicKey)}}, subject)
+	require.Equal(t, Verified, result.Verdict)
+	require.True(t, strings.HasPrefix(result.Signer, "sha256:"))
+
+	other := newECDSAKey(t)
+	result = verify(t, config.SignatureVerificationConfig{PublicKeys: []string{publicKeyPEM(t, &other.PublicKey)}}, subject)
+	require.Equal(t, Rejected, result.Verdict)
+	require.Contains(t, result.Reason, "does not match any trusted public key")
+}
+
+func TestVerify_CosignPayloadForOtherDigest(t *testing.T) {
+	_, subject := testRepo(t)
+	key := newECDSAKey(t)
+	payload := cosignPayload("sha256:" + strings.Repeat("0", 64))
+	pushCosignSignature(t, subject, payload, map[string]string{
+		cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signECDSA(t, key, payload)),
+	})
+
+	result := verify(t, config.SignatureVerificationConfig{PublicKeys: []string{publicKeyPEM(t, &key.PublicKey)}}, subject)
+	require.Equal(t, Rejected, result.Verdict)
+	require.Contains(t, result.Reason, "payload signs")
+}
+
+func fulcioIssuerExtension(t *testing.T, issuer string) pkix.Extension {
+	t.Helper()
+	value, err := asn1.MarshalWithParams(issuer, "utf8")
+	require.NoError(t, err)
+	return pkix.Extension{Id: fulcioIssuerV2OID, Value: value}
+}
+
+func TestVerify_CosignKeyless(t *testing.T) {
+	_, subject := testRepo(t)
+	ca := newTestCA(t)
+	key := newECDSAKey(t)
+	leaf := ca.issue(t, &x509.Certificate{
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		EmailAddresses:  []string{"release@example.com"},
+		ExtraExtensions: []pkix.Extension{fulcioIssuerExtension(t, "https://accounts.example.com")},
+	}, &key.PublicKey)
+
+	payload := cosignPayload(subject.DigestStr())
+	pushCosignSignature(t, subject, payload, map[string]string{
+		cosignSignatureAnnotation:   base64.StdEncoding.EncodeToString(signECDSA(t, key, payload)),
+		cosignCertificateAnnotation: certPEM(leaf),
+	})
+
+	cfg := config.SignatureVerificationConfig{
+		RootCertificates: []string{certPEM(ca.cert)},
+		Identities:       []config.CertificateIdentity{{Subject: "release@example.com", Issuer: "https://accounts.example.com"}},
+	}
+	result := verify(t, cfg, subject)
+	require.Equal(t, Verified, result.Verdict)
+	require.Equal(t, "release@example.com", result.Signer)
+
+	cfg.Identities = []config.CertificateIdentity{{SubjectRegExp: `@example\.com$`, Issuer: "https://other.example.com"}}
+	result = verify(t, cfg, subject)
+	require.Equal(t, Rejected, result.Verdict)
+	require.Contains(t, result.Reason, "not trusted")
+}
+
+func TestVerify_CosignKeylessUsesRekorTime(t *testing.T) {
+	_, subject := testRepo(t)
+	ca := newTestCA(t)
+	key := newECDSAKey(t)
+	signedAt := time.Now().Add(-2 * time.Hour)
+	// Short-lived certificate that expired long before the sync runs
+	leaf := ca.issue(t, &x509.Certificate{
+		NotBefore:      signedAt.Add(-time.Minute),
+		NotAfter:       signedAt.Add(10 * time.Minute),
+		EmailAddresses: []string{"release@example.com"},
+	}, &key.PublicKey)
+
+	payload := cosignPayload(subject.DigestStr())
+	sig := signECDSA(t, key, payload)
+
+	rekorKey := newECDSAKey(t)
+	body := fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"signature":{"content":%q}}}`, base64.StdEncoding.EncodeToString(sig))
+	entry := rekorPayload{
+		Body:           base64.StdEncoding.EncodeToString([]byte(body)),
+		IntegratedTime: signedAt.Unix(),
+		LogID:          "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d",
+		LogIndex:       42,
+	}
+	canonical, err := json.Marshal(entry)
+	require.NoError(t, err)
+	bundle, err := json.Marshal(rekorBundle{SignedEntryTimestamp: signECDSA(t, rekorKey, canonical), Payload: entry})
+	require.NoError(t, err)
+
+	pushCosignSignature(t, subject, payload, map[string]string{
+		cosignSignatureAnnotation:   base64.StdEncoding.EncodeToString(sig),
+		cosignCertificateAnnotation: certPEM(leaf),
+		cosignBundleAnnotation:      string(bundle),
+	})
+
+	cfg := config.SignatureVerificationConfig{
+		RootCertificates: []string{certPEM(ca.cert)},
+		Identities:       []config.CertificateIdentity{{Subject: "release@example.com"}},
+	}
+	require.Equal(t, Rejected, verify(t, cfg, subject).Verdict, "expired certificate without a trusted log time")
+
+	cfg.RekorPublicKey = publicKeyPEM(t, &rekorKey.PublicKey)
+	require.Equal(t, Verified, verify(t, cfg, subject).Verdict)
+}
+
+// notationEnvelope builds a JWS envelope signed with PS256 over subject.
+func notationEnvelope(t *testing.T, key *rsa.PrivateKey, chain []*x509.Certificate, digest string) []byte {
+	t.Helper()
+	protected, err := json.Marshal(map[string]any{
+		"alg":                          "PS256",
+		"cty":                          notationPayloadType,
+		"crit":                         []string{"io.cncf.notary.signingScheme"},
+		"io.cncf.notary.signingScheme": "notary.x509",
+		"io.cncf.notary.signingTime":   time.Now().Format(time.RFC3339),
+	})
+	require.NoError(t, err)
+	payload, err := json.Marshal(map[string]any{
+		"targetArtifact": map[string]any{
+			"mediaType": string(types.OCIManifestSchema1),
+			"digest":    digest,
+			"size":      512,
+		},
+	})
+	require.NoError(t, err)
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(protected) + "." + enc.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, sum[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	require.NoError(t, err)
+
+	var x5c [][]byte
+	for _, c := range chain {
+		x5c = append(x5c, c.Raw)
+	}
+	envelope, err := json.Marshal(map[string]any{
+		"payload":   enc.EncodeToString(payload),
+		"protected": enc.EncodeToString(protected),
+		"header":    map[string]any{"x5c": x5c},
+		"signature": enc.EncodeToString(sig),
+	})
+	require.NoError(t, err)
+	return envelope
+}
+
+func TestVerify_NotationJWS(t *testing.T) {
+	_, subject := testRepo(t)
+	ca := newTestCA(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	leaf := ca.issue(t, &x509.Certificate{
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(time.Hour),
+		Subject:   pkix.Name{Country: []string{"US"}, Organization: []string{"acme"}, CommonName: "release"},
+	}, &key.PublicKey)
+
+	pushReferrer(t, subject, notationArtifactType, notationJWSMediaType, notationEnvelope(t, key, []*x509.Certificate{leaf}, subject.DigestStr()))
+
+	cfg := config.SignatureVerificationConfig{
+		RootCertificates: []string{certPEM(ca.cert)},
+		Identities:       []config.CertificateIdentity{{Subject: "C=US, O=acme, CN=release"}},
+	}
+	result := verify(t, cfg, subject)
+	require.Equal(t, Verified, result.Verdict)
+
+	cfg.Identities = []config.CertificateIdentity{{Subject: "C=US, O=acme, CN=someone-else"}}
+	require.Equal(t, Rejected, verify(t, cfg, subject).Verdict)
+}
+
+func TestNewVerifier_BrokenPolicyFailsClosed(t *testing.T) {
+	_, subject := testRepo(t)
+
+	v, err := NewVerifier(config.SignatureVerificationConfig{PublicKeys: []string{"-----BEGIN PUBLIC KEY-----\nbm90IGEga2V5\n-----END PUBLIC KEY-----\n"}})
+	require.Error(t, err)
+	require.NotNil(t, v)
+
+	result, err := v.Verify(context.Background(), subject)
+	require.NoError(t, err)
+	require.Equal(t, Rejected, result.Verdict)
+	require.False(t, v.Admits(result))
+}
+
+func TestVerifier_Admits(t *testing.T) {
+	enforce, err := NewVerifier(config.SignatureVerificationConfig{Mode: config.SignatureModeEnforce})
+	require.NoError(t, err)
+	require.True(t, enforce.Admits(Result{Verdict: Verified}))
+	require.False(t, enforce.Admits(Result{Verdict: Unsigned}))
+	require.False(t, enforce.Admits(Result{Verdict: Rejected}))
+
+	allowUnsigned, err := NewVerifier(config.SignatureVerificationConfig{AllowUnsigned: true})
+	require.NoError(t, err)
+	require.True(t, allowUnsigned.Admits(Result{Verdict: Unsigned}))
+	require.False(t, allowUnsigned.Admits(Result{Verdict: Rejected}))
+
+	audit, err := NewVerifier(config.SignatureVerificationConfig{Mode: config.SignatureModeAudit})
+	require.NoError(t, err)
+	require.True(t, audit.Admits(Result{Verdict: Rejected}))
+}
@@ -104,6 +104,28 @@ func (c *Client) GetSVID() (*x509svid.SVID, error) {
 	return c.x509Source.GetX509SVID()
 }
 
+// GetX509Bundle returns the X.509 authorities of this workload's trust
+// domain.
+func (c *Client) GetX509Bundle() ([]*x509.Certificate, error) {
+	svid, err := c.GetSVID()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed || c.x509Source == nil {
+		return nil, fmt.Errorf("client is closed")
+	}
+
+	bundle, err := c.x509Source.GetX509BundleForTrustDomain(svid.ID.TrustDomain())
+	if err != nil {
+		return nil, fmt.Errorf("get bundle for %s: %w", svid.ID.TrustDomain(), err)
+	}
+	return bundle.X509Authorities(), nil
+}
+
 // GetSPIFFEID returns the SPIFFE ID of this workload.
 func (c *Client) GetSPIFFEID() (spiffeid.ID, error) {
 	svid, err := c.GetSVID()
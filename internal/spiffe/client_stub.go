@@ -40,6 +40,10 @@ func (c *Client) GetSVID() (*x509svid.SVID, error) {
 	return nil, ErrSPIFFENotAvailable
 }
 
+func (c *Client) GetX509Bundle() ([]*x509.Certificate, error) {
+	return nil, ErrSPIFFENotAvailable
+}
+
 func (c *Client) GetSPIFFEID() (spiffeid.ID, error) {
 	return spiffeid.ID{}, ErrSPIFFENotAvailable
 }
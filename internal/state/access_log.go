@@ -0,0 +1,285 @@
+package state
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/logger"
+)
+
+// AccessFileName is the last pull of each local image, persisted next to
+// state.json.
+const AccessFileName = "access.json"
+
+// zotAccessMessage is the message of the lines Zot logs for every request.
+const zotAccessMessage = "HTTP API"
+
+const (
+	// registryLogMaxSize is the size past which the registry's log is
+	// truncated, once every line of it was read.
+	registryLogMaxSize = 10 << 20
+	// registryLogInterval is how often Follow reads the registry's log.
+	registryLogInterval = time.Second
+)
+
+// zotAccessLogEntry is the part of a Zot request log line the tracker reads.
+type zotAccessLogEntry struct {
+	Message    string              `json:"message"`
+	Time       time.Time           `json:"time"`
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	StatusCode int                 `json:"statusCode"`
+	Headers    map[string][]string `json:"headers"`
+}
+
+// AccessTracker follows the access log of the local registry and records
+// when each image was last pulled, by "repository:tag" and by
+// "repository@digest". Requests
+// of the satellite itself, made with go-containerregistry or Go's HTTP
+// client, are not pulls. The log is read from where the previous scan
+// stopped and from the start again once it is rotated or truncated.
+//
+// The registry writes its whole log to that file instead of stdout, so a
+// tracker that follows the log copies every line it reads to out and keeps
+// the file below maxSize.
+type AccessTracker struct {
+	mu       sync.Mutex
+	path     string
+	logPath  string
+	offset   int64
+	lastPull map[string]time.Time
+	out      io.Writer
+	maxSize  int64
+}
+
+type persistedAccess struct {
+	LogPath  string               `json:"log_path,omitempty"`
+	Offset   int64                `json:"offset,omitempty"`
+	LastPull map[string]time.Time `json:"last_pull,omitempty"`
+}
+
+// NewAccessTracker loads the recorded pulls from path. A missing file yields
+// an empty tracker; an empty path keeps the tracker in memory only.
+func NewAccessTracker(path string) (*AccessTracker, error) {
+	a := &AccessTracker{path: path, lastPull: make(map[string]time.Time)}
+	if path == "" {
+		return a, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return a, nil
+		}
+		return a, fmt.Errorf("read access file: %w", err)
+	}
+
+	var persisted persistedAccess
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return a, fmt.Errorf("unmarshal access file: %w", err)
+	}
+	a.logPath, a.offset = persisted.LogPath, persisted.Offset
+	for k, v := range persisted.LastPull {
+		a.lastPull[k] = v
+	}
+	return a, nil
+}
+
+// accessPath returns the access file that belongs to stateFilePath.
+func accessPath(stateFilePath string) string {
+	if stateFilePath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(stateFilePath), AccessFileName)
+}
+
+// Scan reads the lines appended to the access log at logPath since the last
+// scan. A missing log is not an error, the registry may not have written it
+// yet.
+func (a *AccessTracker) Scan(logPath string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if logPath != a.logPath {
+		a.logPath, a.offset = logPath, 0
+	}
+	file, err := os.Open(filepath.Clean(logPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open access log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat access log: %w", err)
+	}
+	if info.Size() < a.offset {
+		a.offset = 0
+	}
+	if _, err := file.Seek(a.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek access log: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A partial line is read again once it is complete
+			return a.truncate(file)
+		}
+		if err != nil {
+			return fmt.Errorf("read access log: %w", err)
+		}
+		a.offset += int64(len(line))
+		if a.out != nil {
+			_, _ = a.out.Write(line)
+		}
+		a.record(line)
+	}
+}
+
+// truncate empties the log once it was read up to its end and exceeds
+// maxSize. The registry appends to it, so it writes from the start again.
+// Lines appended between the size check and the truncation are lost, which
+// costs at most the pulls logged in that instant.
+func (a *AccessTracker) truncate(file *os.File) error {
+	if a.maxSize <= 0 || a.offset < a.maxSize {
+		return nil
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat access log: %w", err)
+	}
+	if info.Size() != a.offset {
+		return nil
+	}
+	if err := os.Truncate(file.Name(), 0); err != nil {
+		return fmt.Errorf("truncate access log: %w", err)
+	}
+	a.offset = 0
+	return nil
+}
+
+// Follow scans the registry's log, found by logPath, until ctx is done. The
+// lines read are copied to out and the log is truncated once it grows past
+// registryLogMaxSize. Scans made for a sync share its position, so no line
+// is copied twice.
+func (a *AccessTracker) Follow(ctx context.Context, logPath func() string, out io.Writer) {
+	a.mu.Lock()
+	a.out, a.maxSize = out, registryLogMaxSize
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.out, a.maxSize = nil, 0
+		a.mu.Unlock()
+	}()
+
+	log := logger.FromContext(ctx)
+	ticker := time.NewTicker(registryLogInterval)
+	defer ticker.Stop()
+	var lastErr string
+	for {
+		if path := logPath(); path != "" {
+			err := a.Scan(path)
+			// A failure is reported once, not every second
+			if err != nil && err.Error() != lastErr {
+				log.Warn().Err(err).Str("path", path).Msg("Failed to read the registry log")
+			}
+			lastErr = ""
+			if err != nil {
+				lastErr = err.Error()
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record notes the pull logged on line, if it is one.
+func (a *AccessTracker) record(line []byte) {
+	var entry zotAccessLogEntry
+	if err := json.Unmarshal(line, &entry); err != nil || entry.Message != zotAccessMessage {
+		return
+	}
+	if entry.Method != http.MethodGet && entry.Method != http.MethodHead {
+		return
+	}
+	if entry.StatusCode != http.StatusOK || isSatelliteUserAgent(entry.Headers["User-Agent"]) {
+		return
+	}
+	repo, ref, ok := strings.Cut(strings.TrimPrefix(entry.Path, "/v2/"), "/manifests/")
+	if !ok || repo == "" || ref == "" || !strings.HasPrefix(entry.Path, "/v2/") {
+		return
+	}
+	ref, _, _ = strings.Cut(ref, "?")
+
+	key := repo + ":" + ref
+	if strings.Contains(ref, ":") {
+		key = repo + "@" + ref
+	}
+	if entry.Time.After(a.lastPull[key]) {
+		a.lastPull[key] = entry.Time
+	}
+}
+
+func isSatelliteUserAgent(agents []string) bool {
+	for _, agent := range agents {
+		if strings.HasPrefix(agent, "go-containerregistry") || strings.HasPrefix(agent, "Go-http-client") {
+			return true
+		}
+	}
+	return false
+}
+
+// LastPull returns when the image was last pulled by tag or by digest, or
+// the zero time if it never was.
+func (a *AccessTracker) LastPull(repo, tag, digest string) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	last := a.lastPull[repo+":"+tag]
+	if byDigest := a.lastPull[repo+"@"+digest]; digest != "" && byDigest.After(last) {
+		last = byDigest
+	}
+	return last
+}
+
+// Forget drops the pulls of an evicted image.
+func (a *AccessTracker) Forget(repo, tag, digest string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.lastPull, repo+":"+tag)
+	if digest != "" {
+		delete(a.lastPull, repo+"@"+digest)
+	}
+}
+
+// Save persists the recorded pulls. It is a no-op for in-memory trackers.
+func (a *AccessTracker) Save() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(persistedAccess{LogPath: a.logPath, Offset: a.offset, LastPull: a.lastPull}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal access file: %w", err)
+	}
+	return writeFileAtomic(a.path, data, "access-*.json.tmp")
+}
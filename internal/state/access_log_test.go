@@ -0,0 +1,130 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func zotAccessLine(at time.Time, method, path string, status int, agent string) string {
+	return fmt.Sprintf(`{"level":"info","module":"http","message":"HTTP API","time":%q,"method":%q,"path":%q,"statusCode":%d,"headers":{"User-Agent":[%q]}}`+"\n",
+		at.Format(time.RFC3339Nano), method, path, status, agent)
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func TestAccessTracker_Scan(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "zot-access.log")
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	appendFile(t, logPath,
+		zotAccessLine(at, "GET", "/v2/library/app/manifests/v1", 200, "containerd/v1.7.0")+
+			zotAccessLine(at.Add(time.Minute), "HEAD", "/v2/library/app/manifests/"+digest, 200, "containerd/v1.7.0")+
+			zotAccessLine(at.Add(2*time.Minute), "GET", "/v2/library/sync/manifests/v1", 200, "go-containerregistry/v0.20.3")+
+			zotAccessLine(at.Add(3*time.Minute), "GET", "/v2/library/missing/manifests/v1", 404, "containerd/v1.7.0")+
+			zotAccessLine(at.Add(4*time.Minute), "PUT", "/v2/library/push/manifests/v1", 201, "docker/27.0")+
+			"not json\n")
+
+	a, err := NewAccessTracker(filepath.Join(dir, AccessFileName))
+	require.NoError(t, err)
+	require.NoError(t, a.Scan(logPath))
+	require.Equal(t, at.Add(time.Minute), a.LastPull("library/app", "v1", digest), "a pull by digest is newer")
+	require.Equal(t, at, a.LastPull("library/app", "v1", ""))
+	require.True(t, a.LastPull("library/sync", "v1", "").IsZero(), "replication is not a pull")
+	require.True(t, a.LastPull("library/missing", "v1", "").IsZero())
+	require.True(t, a.LastPull("library/push", "v1", "").IsZero())
+
+	// A partial line is read once it is complete
+	line := zotAccessLine(at.Add(time.Hour), "GET", "/v2/library/other/manifests/v2", 200, "cri-o")
+	appendFile(t, logPath, line[:20])
+	require.NoError(t, a.Scan(logPath))
+	require.True(t, a.LastPull("library/other", "v2", "").IsZero())
+	appendFile(t, logPath, line[20:])
+	require.NoError(t, a.Scan(logPath))
+	require.Equal(t, at.Add(time.Hour), a.LastPull("library/other", "v2", ""))
+
+	require.NoError(t, a.Save())
+	loaded, err := NewAccessTracker(filepath.Join(dir, AccessFileName))
+	require.NoError(t, err)
+	require.Equal(t, at.Add(time.Hour), loaded.LastPull("library/other", "v2", ""))
+
+	// A rotated log is read from the start
+	require.NoError(t, os.WriteFile(logPath, []byte(zotAccessLine(at.Add(2*time.Hour), "GET", "/v2/library/app/manifests/v1", 200, "containerd")), 0o600))
+	require.NoError(t, loaded.Scan(logPath))
+	require.Equal(t, at.Add(2*time.Hour), loaded.LastPull("library/app", "v1", ""))
+
+	loaded.Forget("library/app", "v1", digest)
+	require.True(t, loaded.LastPull("library/app", "v1", digest).IsZero())
+}
+
+func TestAccessTracker_Follow(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "zot-access.log")
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	line := zotAccessLine(at, "GET", "/v2/library/app/manifests/v1", 200, "containerd")
+	appendFile(t, logPath, line)
+
+	a, err := NewAccessTracker("")
+	require.NoError(t, err)
+	var out syncBuffer
+	ctx, cancel := context.WithCancel(testContext())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Follow(ctx, func() string { return logPath }, &out)
+	}()
+	require.Eventually(t, func() bool { return out.String() == line }, 5*time.Second, 10*time.Millisecond, "the registry's log reaches stdout")
+	require.Equal(t, at, a.LastPull("library/app", "v1", ""))
+
+	// A sync scanning the log does not copy the lines again
+	require.NoError(t, a.Scan(logPath))
+	require.Equal(t, line, out.String())
+	cancel()
+	<-done
+
+	// Past its maximum size the log is emptied once read
+	a.maxSize = int64(len(line))
+	appendFile(t, logPath, line)
+	require.NoError(t, a.Scan(logPath))
+	info, err := os.Stat(logPath)
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	later := zotAccessLine(at.Add(time.Hour), "GET", "/v2/library/app/manifests/v1", 200, "containerd")
+	appendFile(t, logPath, later)
+	require.NoError(t, a.Scan(logPath))
+	require.Equal(t, at.Add(time.Hour), a.LastPull("library/app", "v1", ""))
+}
+
+// syncBuffer is a bytes.Buffer safe for the follower and the test to use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,132 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AdHocFileName is the list of images cached by the pull-through mode,
+// persisted next to state.json.
+const AdHocFileName = "adhoc.json"
+
+// AdHocImage is an image no group state lists, cached by the registry
+// because a pull for it missed.
+type AdHocImage struct {
+	Entity   Entity    `json:"entity"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+// AdHocStore tracks the ad-hoc images of the local registry and persists them
+// to disk. A store with an empty path is kept in memory only.
+type AdHocStore struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*AdHocImage
+}
+
+// NewAdHocStore loads the ad-hoc images from path. A missing file yields an
+// empty store.
+func NewAdHocStore(path string) (*AdHocStore, error) {
+	s := &AdHocStore{
+		path:    path,
+		entries: make(map[string]*AdHocImage),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return s, fmt.Errorf("read ad-hoc file: %w", err)
+	}
+
+	var entries []AdHocImage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return s, fmt.Errorf("unmarshal ad-hoc file: %w", err)
+	}
+	for i := range entries {
+		s.entries[entries[i].Entity.reference()] = &entries[i]
+	}
+	return s, nil
+}
+
+// adHocPath returns the ad-hoc file that belongs to stateFilePath.
+func adHocPath(stateFilePath string) string {
+	if stateFilePath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(stateFilePath), AdHocFileName)
+}
+
+// Add records a cached image and reports whether it is new. An image
+// recorded again keeps its original CachedAt and takes the new digest.
+func (s *AdHocStore) Add(e Entity, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if img, ok := s.entries[e.reference()]; ok {
+		img.Entity.Digest = e.Digest
+		return false
+	}
+	s.entries[e.reference()] = &AdHocImage{Entity: e, CachedAt: now}
+	return true
+}
+
+// Remove drops a deleted image, or one a group state lists now.
+func (s *AdHocStore) Remove(e Entity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, e.reference())
+}
+
+// Entries returns a copy of the ad-hoc images ordered by reference.
+func (s *AdHocStore) Entries() []AdHocImage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entriesUnlocked()
+}
+
+// Entities returns the entities of the ad-hoc images.
+func (s *AdHocStore) Entities() []Entity {
+	entries := s.Entries()
+	entities := make([]Entity, 0, len(entries))
+	for _, img := range entries {
+		entities = append(entities, img.Entity)
+	}
+	return entities
+}
+
+func (s *AdHocStore) entriesUnlocked() []AdHocImage {
+	entries := make([]AdHocImage, 0, len(s.entries))
+	for _, img := range s.entries {
+		entries = append(entries, *img)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Entity.reference() < entries[j].Entity.reference()
+	})
+	return entries
+}
+
+// Save persists the ad-hoc images. It is a no-op for in-memory stores.
+func (s *AdHocStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.entriesUnlocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal ad-hoc list: %w", err)
+	}
+	return writeFileAtomic(s.path, data, "adhoc-*.json.tmp")
+}
@@ -0,0 +1,123 @@
+package state
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttleChunkSize bounds how much a throttled body reads at once, so that
+// a large read does not turn into one long stall followed by a burst.
+const throttleChunkSize = 32 << 10
+
+// BandwidthLimiter is a token bucket capping transfers at a number of bytes
+// per second, with a burst of one second worth of bytes. A nil limiter does
+// not limit. It is safe for concurrent use; concurrent readers share the rate.
+type BandwidthLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewBandwidthLimiter returns a limiter for the given rate, or nil if the
+// rate is not positive.
+func NewBandwidthLimiter(bytesPerSecond int64) *BandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &BandwidthLimiter{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		now:    time.Now,
+	}
+}
+
+// WaitN blocks until n bytes may be transferred or ctx is done. The bytes are
+// reserved up front, so waiters are served in the order they arrived.
+func (l *BandwidthLimiter) WaitN(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.rate {
+			l.tokens = l.rate
+		}
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(deficit / l.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens += float64(n)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// throttleTransport returns a transport that paces blob response bodies
+// through every non-nil limiter. If there are none, base is returned as is.
+func throttleTransport(base http.RoundTripper, limiters ...*BandwidthLimiter) http.RoundTripper {
+	var active []*BandwidthLimiter
+	for _, l := range limiters {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return base
+	}
+	return &throttledTransport{base: base, limiters: active}
+}
+
+type throttledTransport struct {
+	base     http.RoundTripper
+	limiters []*BandwidthLimiter
+}
+
+func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.Body == nil || !strings.Contains(req.URL.Path, "/blobs/") {
+		return resp, err
+	}
+	resp.Body = &throttledBody{ReadCloser: resp.Body, ctx: req.Context(), limiters: t.limiters}
+	return resp, nil
+}
+
+// throttledBody waits on its limiters for every chunk read from the network.
+type throttledBody struct {
+	io.ReadCloser
+	ctx      context.Context
+	limiters []*BandwidthLimiter
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > throttleChunkSize {
+		p = p[:throttleChunkSize]
+	}
+	n, err := b.ReadCloser.Read(p)
+	for _, l := range b.limiters {
+		if werr := l.WaitN(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,64 @@
+package state
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBandwidthLimiter_Unlimited(t *testing.T) {
+	require.Nil(t, NewBandwidthLimiter(0))
+	require.Nil(t, NewBandwidthLimiter(-1))
+
+	var l *BandwidthLimiter
+	require.NoError(t, l.WaitN(context.Background(), 1<<30))
+}
+
+func TestBandwidthLimiter_PacesTransfers(t *testing.T) {
+	l := NewBandwidthLimiter(100 << 10)
+	ctx := context.Background()
+
+	// The first second worth of bytes is available as burst.
+	start := time.Now()
+	require.NoError(t, l.WaitN(ctx, 100<<10))
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// The next 20KiB have to wait for roughly 200ms of refill.
+	start = time.Now()
+	require.NoError(t, l.WaitN(ctx, 20<<10))
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestBandwidthLimiter_CancelledWaitReturnsBytes(t *testing.T) {
+	l := NewBandwidthLimiter(1 << 10)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, l.WaitN(ctx, 1<<10))
+	require.ErrorIs(t, l.WaitN(ctx, 1<<10), context.Canceled)
+	require.InDelta(t, 0, l.tokens, 0.001)
+}
+
+func TestReplicate_ThrottlesBlobDownloads(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	img := pushImage(t, srcAddr, "library", "app", "v1", 1)
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	size, err := layers[0].Size()
+	require.NoError(t, err)
+
+	// Allow one second of burst plus half the image, so the copy has to wait
+	// for about half a second of refill.
+	limit := size * 2 / 3
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithBandwidthLimit(limit))
+
+	start := time.Now()
+	_, err = r.Replicate(testContext(), []Entity{{Name: "app", Repository: "library", Tag: "v1"}})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+}
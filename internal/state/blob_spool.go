@@ -0,0 +1,446 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// SpoolDirName is the blob spool directory kept next to state.json.
+const SpoolDirName = "blob-spool"
+
+// spoolMaxAge is how long an untouched spooled blob is kept. Partial
+// downloads of images that left the group state are dropped after it.
+const spoolMaxAge = 7 * 24 * time.Hour
+
+// partialSuffix marks blobs whose download has not completed yet.
+const partialSuffix = ".partial"
+
+// BlobSpool stages large blobs on disk while they are downloaded from the
+// source registry. Downloads append to a partial file and resume from its
+// size with an HTTP Range request, so an interrupted transfer, even across a
+// satellite restart, continues where it stopped. A blob is only handed on
+// after its digest has been verified.
+type BlobSpool struct {
+	dir string
+
+	mu    sync.Mutex
+	locks map[v1.Hash]*sync.Mutex
+}
+
+// NewBlobSpool creates the spool directory if needed.
+func NewBlobSpool(dir string) (*BlobSpool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create blob spool: %w", err)
+	}
+	return &BlobSpool{dir: dir, locks: make(map[v1.Hash]*sync.Mutex)}, nil
+}
+
+// spoolDir returns the spool directory that belongs to stateFilePath.
+func spoolDir(stateFilePath string) string {
+	if stateFilePath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(stateFilePath), SpoolDirName)
+}
+
+func (s *BlobSpool) path(digest v1.Hash) string {
+	return filepath.Join(s.dir, digest.Algorithm+"-"+digest.Hex)
+}
+
+// lock serialises work on one blob, which several entities may share.
+func (s *BlobSpool) lock(digest v1.Hash) func() {
+	s.mu.Lock()
+	l, ok := s.locks[digest]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[digest] = l
+	}
+	s.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// Fetch downloads the blob described by desc from repo into the spool, or
+// resumes a previous partial download, and returns the path of the verified
+// blob. client must be authorized to pull from repo.
+func (s *BlobSpool) Fetch(ctx context.Context, client *http.Client, repo name.Repository, desc v1.Descriptor) (string, error) {
+	unlock := s.lock(desc.Digest)
+	defer unlock()
+
+	final := s.path(desc.Digest)
+	if fi, err := os.Stat(final); err == nil && fi.Size() == desc.Size {
+		return final, nil
+	}
+
+	partial := final + partialSuffix
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("open spooled blob: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", fmt.Errorf("seek spooled blob: %w", err)
+	}
+	if offset > desc.Size {
+		if offset, err = truncate(f); err != nil {
+			return "", err
+		}
+	}
+
+	if offset < desc.Size {
+		if err := s.download(ctx, client, repo, desc, f, offset); err != nil {
+			return "", err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("close spooled blob: %w", err)
+	}
+
+	if err := verifyBlob(partial, desc); err != nil {
+		_ = os.Remove(partial)
+		return "", err
+	}
+	if err := os.Rename(partial, final); err != nil {
+		return "", fmt.Errorf("commit spooled blob: %w", err)
+	}
+	return final, nil
+}
+
+// download writes the blob to f starting at offset. If the registry ignores
+// the Range request the file is rewritten from the start.
+func (s *BlobSpool) download(ctx context.Context, client *http.Client, repo name.Repository, desc v1.Descriptor, f *os.File, offset int64) error {
+	u := fmt.Sprintf("%s://%s/v2/%s/blobs/%s", repo.Scheme(), repo.RegistryStr(), repo.RepositoryStr(), desc.Digest)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("build blob request: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, desc.Size-1))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch blob %s: %w", desc.Digest, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			if offset == 0 {
+				return fmt.Errorf("fetch blob %s: unexpected partial response %q", desc.Digest, resp.Header.Get("Content-Range"))
+			}
+			if _, err := truncate(f); err != nil {
+				return err
+			}
+			return s.download(ctx, client, repo, desc, f, 0)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Nothing left to fetch; verification decides whether the file is good.
+		return nil
+	default:
+		if err := transport.CheckError(resp, http.StatusOK); err != nil {
+			return fmt.Errorf("fetch blob %s: %w", desc.Digest, err)
+		}
+		if offset > 0 {
+			if _, err := truncate(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := io.Copy(f, io.LimitReader(resp.Body, desc.Size)); err != nil {
+		return fmt.Errorf("download blob %s: %w", desc.Digest, err)
+	}
+	return nil
+}
+
+// Release removes a committed blob once it has been written to the local
+// registry.
+func (s *BlobSpool) Release(digest v1.Hash) {
+	unlock := s.lock(digest)
+	defer unlock()
+	_ = os.Remove(s.path(digest))
+}
+
+// Prune removes blobs and partial downloads not touched for maxAge, e.g.
+// those of images that were dropped from the group state mid-transfer.
+func (s *BlobSpool) Prune(maxAge time.Duration, now time.Time) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("read blob spool: %w", err)
+	}
+	var errs []error
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() || now.Sub(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, e.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func truncate(f *os.File) (int64, error) {
+	if err := f.Truncate(0); err != nil {
+		return 0, fmt.Errorf("truncate spooled blob: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seek spooled blob: %w", err)
+	}
+	return 0, nil
+}
+
+// verifyBlob checks the size and digest of a downloaded blob.
+func verifyBlob(path string, desc v1.Descriptor) error {
+	if desc.Digest.Algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest algorithm %q", desc.Digest.Algorithm)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open spooled blob: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return fmt.Errorf("hash spooled blob: %w", err)
+	}
+	if n != desc.Size {
+		return fmt.Errorf("%w: blob %s has %d bytes, expected %d", ErrDigestMismatch, desc.Digest, n, desc.Size)
+	}
+	if got := fmt.Sprintf("%x", h.Sum(nil)); got != desc.Digest.Hex {
+		return fmt.Errorf("%w: blob %s hashes to sha256:%s", ErrDigestMismatch, desc.Digest, got)
+	}
+	return nil
+}
+
+// contentRangeStart parses the first byte position of a
+// "bytes start-end/size" Content-Range header.
+func contentRangeStart(header string) (int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	return n, err == nil
+}
+
+// spoolClient returns an HTTP client authorized to pull from repo.
+func spoolClient(ctx context.Context, repo name.Repository, auth authn.Authenticator, base http.RoundTripper) (*http.Client, error) {
+	rt, err := transport.NewWithContext(ctx, repo.Registry, auth, base, []string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, fmt.Errorf("authorize blob download: %w", err)
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// layerSpooler routes the large layers of the images of one entity through
+// the blob spool. A nil spooler leaves images untouched.
+type layerSpooler struct {
+	ctx     context.Context
+	spool   *BlobSpool
+	minSize int64
+	repo    name.Repository
+	opts    *replicationOptions
+
+	once      sync.Once
+	client    *http.Client
+	clientErr error
+
+	mu      sync.Mutex
+	spooled []v1.Hash
+}
+
+func (r *BasicReplicator) newLayerSpooler(ctx context.Context, repo name.Repository, opts *replicationOptions) *layerSpooler {
+	if r.spool == nil {
+		return nil
+	}
+	return &layerSpooler{ctx: ctx, spool: r.spool, minSize: r.spoolMinSize, repo: repo, opts: opts}
+}
+
+// image swaps the layers of img that are at least minSize bytes for layers
+// downloaded through the spool. Manifest and config are untouched, so the
+// image keeps its digest.
+func (s *layerSpooler) image(img v1.Image) v1.Image {
+	if s == nil {
+		return img
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return img
+	}
+	wrapped := make([]v1.Layer, len(layers))
+	spooling := false
+	for i, l := range layers {
+		wrapped[i] = l
+		size, err := l.Size()
+		if err != nil || size < s.minSize {
+			continue
+		}
+		digest, err := l.Digest()
+		if err != nil {
+			continue
+		}
+		desc := v1.Descriptor{Digest: digest, Size: size}
+		wrapped[i] = &spooledLayer{Layer: l, fetch: func() (string, error) { return s.fetch(desc) }}
+		spooling = true
+	}
+	if !spooling {
+		return img
+	}
+	return &wrappedImage{Image: img, layers: wrapped}
+}
+
+// index applies image to every image of idx, recursing into nested indexes.
+func (s *layerSpooler) index(idx v1.ImageIndex) v1.ImageIndex {
+	if s == nil {
+		return idx
+	}
+	return &wrappedIndex{idx: idx, wrapper: s}
+}
+
+func (s *layerSpooler) fetch(desc v1.Descriptor) (string, error) {
+	s.once.Do(func() {
+		s.client, s.clientErr = spoolClient(s.ctx, s.repo, s.opts.pullAuth, s.opts.pullTransport)
+	})
+	if s.clientErr != nil {
+		return "", s.clientErr
+	}
+	path, err := s.spool.Fetch(s.ctx, s.client, s.repo, desc)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.spooled = append(s.spooled, desc.Digest)
+	s.mu.Unlock()
+	return path, nil
+}
+
+// release drops the blobs spooled for a write that succeeded. Blobs of a
+// failed write are kept so that the next attempt does not download them
+// again.
+func (s *layerSpooler) release() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.spooled {
+		s.spool.Release(d)
+	}
+	s.spooled = nil
+}
+
+// layerWrapper swaps the layers of the images handed to remote.Write and
+// remote.WriteIndex, e.g. to fetch them through the spool or from a peer.
+type layerWrapper interface {
+	image(img v1.Image) v1.Image
+	index(idx v1.ImageIndex) v1.ImageIndex
+}
+
+// wrappedImage is an image whose layers were swapped by a layerWrapper.
+type wrappedImage struct {
+	v1.Image
+	layers []v1.Layer
+}
+
+func (i *wrappedImage) Layers() ([]v1.Layer, error) {
+	return i.layers, nil
+}
+
+func (i *wrappedImage) LayerByDigest(h v1.Hash) (v1.Layer, error) {
+	for _, l := range i.layers {
+		if d, err := l.Digest(); err == nil && d == h {
+			return l, nil
+		}
+	}
+	return i.Image.LayerByDigest(h)
+}
+
+// wrappedIndex hands its children to remote.WriteIndex with their layers
+// swapped by wrapper. The index itself is passed through unchanged.
+type wrappedIndex struct {
+	idx     v1.ImageIndex
+	wrapper layerWrapper
+}
+
+func (i *wrappedIndex) MediaType() (types.MediaType, error)       { return i.idx.MediaType() }
+func (i *wrappedIndex) Digest() (v1.Hash, error)                  { return i.idx.Digest() }
+func (i *wrappedIndex) Size() (int64, error)                      { return i.idx.Size() }
+func (i *wrappedIndex) IndexManifest() (*v1.IndexManifest, error) { return i.idx.IndexManifest() }
+func (i *wrappedIndex) RawManifest() ([]byte, error)              { return i.idx.RawManifest() }
+
+func (i *wrappedIndex) Image(h v1.Hash) (v1.Image, error) {
+	img, err := i.idx.Image(h)
+	if err != nil {
+		return nil, err
+	}
+	return i.wrapper.image(img), nil
+}
+
+func (i *wrappedIndex) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
+	idx, err := i.idx.ImageIndex(h)
+	if err != nil {
+		return nil, err
+	}
+	return i.wrapper.index(idx), nil
+}
+
+func (i *wrappedIndex) Manifests() ([]partial.Describable, error) {
+	children, err := partial.Manifests(i.idx)
+	if err != nil {
+		return nil, err
+	}
+	for n, child := range children {
+		switch c := child.(type) {
+		case v1.ImageIndex:
+			children[n] = i.wrapper.index(c)
+		case v1.Image:
+			children[n] = i.wrapper.image(c)
+		}
+	}
+	return children, nil
+}
+
+// spooledLayer fetches its compressed content through the spool. The
+// download only starts when remote.Write asks for the content, i.e. after it
+// found the blob missing at the destination.
+type spooledLayer struct {
+	v1.Layer
+	fetch func() (string, error)
+}
+
+func (l *spooledLayer) Compressed() (io.ReadCloser, error) {
+	path, err := l.fetch()
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
@@ -0,0 +1,218 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeRecorder serves a registry and records the Range header of every blob
+// request. If cutAfter is set, the first blob response at least that large is
+// cut off after cutAfter bytes.
+type rangeRecorder struct {
+	mu       sync.Mutex
+	ranges   []string
+	cutAfter int64
+	cut      atomic.Bool
+}
+
+func (rr *rangeRecorder) serve(t *testing.T) string {
+	t.Helper()
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet && strings.Contains(req.URL.Path, "/blobs/") {
+			rr.mu.Lock()
+			rr.ranges = append(rr.ranges, req.Header.Get("Range"))
+			rr.mu.Unlock()
+			if rr.cutAfter > 0 && !rr.cut.Load() {
+				cw := &cuttingWriter{ResponseWriter: w, remaining: rr.cutAfter}
+				reg.ServeHTTP(cw, req)
+				if cw.cut {
+					rr.cut.Store(true)
+					panic(http.ErrAbortHandler)
+				}
+				return
+			}
+		}
+		reg.ServeHTTP(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func (rr *rangeRecorder) recorded() []string {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	return append([]string(nil), rr.ranges...)
+}
+
+// cuttingWriter stops writing after remaining bytes so that the client sees
+// a truncated body.
+type cuttingWriter struct {
+	http.ResponseWriter
+	remaining int64
+	cut       bool
+}
+
+func (w *cuttingWriter) Write(p []byte) (int, error) {
+	if w.cut {
+		return 0, io.ErrClosedPipe
+	}
+	if int64(len(p)) > w.remaining {
+		p = p[:w.remaining]
+		w.cut = true
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.remaining -= int64(n)
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+	if w.cut {
+		return n, io.ErrClosedPipe
+	}
+	return n, err
+}
+
+func spoolFixture(t *testing.T, addr string) (name.Repository, v1.Layer, *http.Client) {
+	t.Helper()
+	img := pushImage(t, addr, "library", "app", "v1", 1)
+	layers, err := img.Layers()
+	require.NoError(t, err)
+
+	repo, err := name.NewRepository(addr+"/library/app", name.Insecure)
+	require.NoError(t, err)
+	client, err := spoolClient(context.Background(), repo, authn.Anonymous, http.DefaultTransport)
+	require.NoError(t, err)
+	return repo, layers[0], client
+}
+
+func layerDescriptor(t *testing.T, l v1.Layer) (v1.Descriptor, []byte) {
+	t.Helper()
+	digest, err := l.Digest()
+	require.NoError(t, err)
+	size, err := l.Size()
+	require.NoError(t, err)
+	rc, err := l.Compressed()
+	require.NoError(t, err)
+	defer func() { _ = rc.Close() }()
+	content, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return v1.Descriptor{Digest: digest, Size: size}, content
+}
+
+func TestBlobSpool_ResumesPartialDownload(t *testing.T) {
+	rr := &rangeRecorder{}
+	addr := rr.serve(t)
+	repo, layer, client := spoolFixture(t, addr)
+	desc, content := layerDescriptor(t, layer)
+
+	spool, err := NewBlobSpool(t.TempDir())
+	require.NoError(t, err)
+	half := int64(len(content) / 2)
+	require.NoError(t, os.WriteFile(spool.path(desc.Digest)+partialSuffix, content[:half], 0o600))
+
+	path, err := spool.Fetch(context.Background(), client, repo, desc)
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+	require.NoFileExists(t, path+partialSuffix)
+	require.Contains(t, rr.recorded(), fmt.Sprintf("bytes=%d-%d", half, desc.Size-1))
+}
+
+func TestBlobSpool_CorruptPartialIsDiscarded(t *testing.T) {
+	addr := (&rangeRecorder{}).serve(t)
+	repo, layer, client := spoolFixture(t, addr)
+	desc, content := layerDescriptor(t, layer)
+
+	spool, err := NewBlobSpool(t.TempDir())
+	require.NoError(t, err)
+	partial := spool.path(desc.Digest) + partialSuffix
+	require.NoError(t, os.WriteFile(partial, make([]byte, len(content)/2), 0o600))
+
+	_, err = spool.Fetch(context.Background(), client, repo, desc)
+	require.ErrorIs(t, err, ErrDigestMismatch)
+	require.NoFileExists(t, partial)
+
+	// The next attempt starts over and succeeds.
+	path, err := spool.Fetch(context.Background(), client, repo, desc)
+	require.NoError(t, err)
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+}
+
+func TestBlobSpool_Prune(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := NewBlobSpool(dir)
+	require.NoError(t, err)
+
+	stale := filepath.Join(dir, "sha256-stale"+partialSuffix)
+	fresh := filepath.Join(dir, "sha256-fresh"+partialSuffix)
+	require.NoError(t, os.WriteFile(stale, []byte("x"), 0o600))
+	require.NoError(t, os.WriteFile(fresh, []byte("x"), 0o600))
+	old := time.Now().Add(-2 * spoolMaxAge)
+	require.NoError(t, os.Chtimes(stale, old, old))
+
+	require.NoError(t, spool.Prune(spoolMaxAge, time.Now()))
+	require.NoFileExists(t, stale)
+	require.FileExists(t, fresh)
+}
+
+func TestReplicate_ResumesInterruptedLayer(t *testing.T) {
+	rr := &rangeRecorder{}
+	srcAddr := rr.serve(t)
+	_, dstAddr := newTestRegistry(t)
+	img := pushImage(t, srcAddr, "library", "app", "v1", 1)
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	desc, content := layerDescriptor(t, layers[0])
+
+	// Arm the cut only now, so that setup pushes are not affected.
+	rr.cutAfter = int64(len(content) / 2)
+	spool, err := NewBlobSpool(t.TempDir())
+	require.NoError(t, err)
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true,
+		WithBlobSpool(spool, 1),
+		WithRetryPolicy(fastRetryPolicy(3)),
+	)
+	summary, err := r.Replicate(testContext(), []Entity{{Name: "app", Repository: "library", Tag: "v1"}})
+	require.NoError(t, err)
+	require.Equal(t, EntityReplicated, summary.Results[0].Status)
+	require.True(t, rr.cut.Load(), "transfer was never interrupted")
+	resumed := false
+	for _, r := range rr.recorded() {
+		resumed = resumed || strings.HasPrefix(r, "bytes=") && !strings.HasPrefix(r, "bytes=0-")
+	}
+	require.True(t, resumed, "interrupted layer was downloaded again from the start")
+
+	dstRef, err := name.ParseReference(dstAddr+"/library/app:v1", name.Insecure)
+	require.NoError(t, err)
+	dstImg, err := remote.Image(dstRef)
+	require.NoError(t, err)
+	dstLayers, err := dstImg.Layers()
+	require.NoError(t, err)
+	_, dstContent := layerDescriptor(t, dstLayers[0])
+	require.Equal(t, content, dstContent)
+
+	// The committed blob is released once the image is written.
+	require.NoFileExists(t, spool.path(desc.Digest))
+}
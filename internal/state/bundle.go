@@ -0,0 +1,913 @@
+package state
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/signature"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rs/zerolog"
+)
+
+// A bundle carries everything a satellite syncs from Harbor in a tarball of
+// an OCI image layout, for sites without a link to Harbor. Every manifest
+// listed in the layout's index.json is annotated with its kind and with the
+// reference it was exported from, minus the registry host, so that host
+// overrides on either side do not matter. Signatures and other referrers of
+// the images are carried along, annotated with the digest they refer to.
+const (
+	// BundleKindAnnotation records what a manifest of a bundle is.
+	BundleKindAnnotation = "io.goharbor.satellite.bundle.kind"
+	// BundleRefAnnotation records the reference a manifest of a bundle was
+	// exported from.
+	BundleRefAnnotation = "org.opencontainers.image.ref.name"
+	// BundleSubjectAnnotation records the digest of the image a referrer of
+	// a bundle refers to.
+	BundleSubjectAnnotation = "io.goharbor.satellite.bundle.subject"
+)
+
+// Kinds of the manifests of a bundle.
+const (
+	BundleKindSatelliteState = "satellite-state"
+	BundleKindGroupState     = "group-state"
+	BundleKindConfig         = "config"
+	BundleKindImage          = "image"
+	BundleKindReferrer       = "referrer"
+)
+
+// ErrInvalidBundle is returned for bundles that are corrupt or lack an
+// artifact their states refer to.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// bundleKey is the reference of a state artifact or image within a bundle:
+// its repository path and tag or digest, without scheme or registry host.
+func bundleKey(ref string) (string, error) {
+	ref = strings.TrimPrefix(strings.TrimPrefix(ref, "https://"), "http://")
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return "", fmt.Errorf("parse reference %s: %w", ref, err)
+	}
+	sep := ":"
+	if _, ok := parsed.(name.Digest); ok {
+		sep = "@"
+	}
+	return parsed.Context().RepositoryStr() + sep + parsed.Identifier(), nil
+}
+
+// entityBundleKey is the reference of an entity within a bundle.
+func entityBundleKey(entity Entity) (string, error) {
+	return bundleKey(fmt.Sprintf("%s/%s:%s", entity.GetRepository(), entity.GetName(), entity.GetTag()))
+}
+
+// entityBundleRepo is the repository of an entity within a bundle.
+func entityBundleRepo(entity Entity) (string, error) {
+	key, err := entityBundleKey(entity)
+	if err != nil {
+		return "", err
+	}
+	return key[:strings.LastIndex(key, ":")], nil
+}
+
+// findInLayout returns the descriptor of the manifest of the layout whose
+// reference annotation is ref, as written by other tools, or the bundle key of
+// ref. An empty ref selects the only manifest of the layout.
+func findInLayout(path layout.Path, ref string) (v1.Descriptor, error) {
+	index, err := path.ImageIndex()
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("read layout index: %w", err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("read layout index: %w", err)
+	}
+	if ref == "" {
+		if len(manifest.Manifests) != 1 {
+			return v1.Descriptor{}, fmt.Errorf("%s holds %d manifests, a reference is needed to pick one", path, len(manifest.Manifests))
+		}
+		return manifest.Manifests[0], nil
+	}
+
+	key, err := bundleKey(ref)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	for _, desc := range manifest.Manifests {
+		if name := desc.Annotations[BundleRefAnnotation]; name == ref || name == key {
+			return desc, nil
+		}
+	}
+	return v1.Descriptor{}, fmt.Errorf("%s not found in %s", key, path)
+}
+
+// LayoutStateFetcher reads a state artifact from an OCI image layout, where
+// it is found by its reference annotation.
+type LayoutStateFetcher struct {
+	path     layout.Path
+	ref      string
+	verifier *StateVerifier
+}
+
+func NewLayoutStateFetcher(path layout.Path, ref string) StateFetcher {
+	return &LayoutStateFetcher{path: path, ref: ref}
+}
+
+func (f *LayoutStateFetcher) FetchStateArtifact(ctx context.Context, state any, log *zerolog.Logger) error {
+	switch state.(type) {
+	case *SatelliteState, *State, *config.Config:
+	default:
+		return fmt.Errorf("unexpected state type: %T", state)
+	}
+	log.Info().Msgf("Reading state artifact %s from %s", f.ref, f.path)
+	img, err := f.pullImage(ctx, log)
+	if err != nil {
+		return err
+	}
+	return decodeStateArtifact(f.ref, img, state, f.verifier, log)
+}
+
+func (f *LayoutStateFetcher) FetchDigest(ctx context.Context, log *zerolog.Logger) (string, error) {
+	desc, err := findInLayout(f.path, f.ref)
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest.String(), nil
+}
+
+func (f *LayoutStateFetcher) pullImage(ctx context.Context, log *zerolog.Logger) (v1.Image, error) {
+	desc, err := findInLayout(f.path, f.ref)
+	if err != nil {
+		return nil, err
+	}
+	return f.path.Image(desc.Digest)
+}
+
+// artifactPuller is implemented by the state fetchers that can return the
+// state artifact itself, which is what a bundle is made of.
+type artifactPuller interface {
+	pullImage(ctx context.Context, log *zerolog.Logger) (v1.Image, error)
+}
+
+// LayoutReplicator replicates images from an OCI image layout to the local
+// registry. Deletions and untagging are left to the embedded replicator, which
+// already works on the local registry only.
+//
+// Signatures are verified against the ones exported with the images, and
+// referrers are pushed along with them, the way BasicReplicator does from
+// Harbor.
+type LayoutReplicator struct {
+	*BasicReplicator
+	path layout.Path
+}
+
+func NewLayoutReplicator(path layout.Path, replicator *BasicReplicator) *LayoutReplicator {
+	return &LayoutReplicator{BasicReplicator: replicator, path: path}
+}
+
+// Replicate pushes the entities from the layout to the local registry. Images
+// whose tag already points at the bundled digest are skipped.
+func (r *LayoutReplicator) Replicate(ctx context.Context, replicationEntities []Entity) (ReplicationSummary, error) {
+	summary := ReplicationSummary{}
+	if len(replicationEntities) == 0 {
+		return summary, nil
+	}
+
+	opts, err := r.buildReplicationOptions(ctx)
+	if err != nil {
+		return summary, err
+	}
+
+	for _, entity := range replicationEntities {
+		if ctx.Err() != nil {
+			summary.Results = append(summary.Results, EntityResult{Entity: entity, Status: EntityFailed, Err: ctx.Err()})
+			continue
+		}
+		status, verification, err := r.pushEntity(entity, opts)
+		result := EntityResult{Entity: entity, Status: status, Signature: verification, Attempts: 1}
+		if err != nil {
+			logger.FromContext(ctx).Error().Err(err).Str("entity", entity.reference()).Msg("Failed to import image")
+			result.Status = EntityFailed
+			result.Err = err
+		}
+		summary.Results = append(summary.Results, result)
+	}
+
+	if ctx.Err() != nil {
+		return summary, ctx.Err()
+	}
+	return summary, summary.Err()
+}
+
+func (r *LayoutReplicator) pushEntity(entity Entity, opts *replicationOptions) (EntityStatus, *signature.Result, error) {
+	key, err := entityBundleKey(entity)
+	if err != nil {
+		return EntityFailed, nil, err
+	}
+	desc, err := findInLayout(r.path, key)
+	if err != nil {
+		return EntityFailed, nil, err
+	}
+	if entity.Digest != "" && desc.Digest.String() != entity.Digest {
+		return EntityFailed, nil, fmt.Errorf("%w: state declares %s, bundle holds %s", ErrDigestMismatch, entity.Digest, desc.Digest)
+	}
+	referrers, err := newLayoutReferrers(r.path, entity)
+	if err != nil {
+		return EntityFailed, nil, err
+	}
+
+	var verification *signature.Result
+	if r.verifier != nil {
+		result, err := r.verifier.VerifySignatures(desc.Digest, referrers.signaturesOf(desc.Digest))
+		if err != nil {
+			return EntityFailed, nil, fmt.Errorf("verify signature: %w", err)
+		}
+		verification = &result
+		if !r.verifier.Admits(result) {
+			return EntityFailed, verification, fmt.Errorf("%w: %s %s", ErrSignatureRejected, result.Verdict, result.Reason)
+		}
+	}
+
+	dstRef := fmt.Sprintf("%s/%s/%s:%s", r.remoteRegistryURL, entity.GetRepository(), entity.GetName(), entity.GetTag())
+	dst, err := name.ParseReference(dstRef, opts.nameOpts...)
+	if err != nil {
+		return EntityFailed, verification, fmt.Errorf("parse dest ref %s: %w", dstRef, err)
+	}
+	pushed, err := r.pushManifest(desc, dst, opts)
+	if err != nil {
+		return EntityFailed, verification, fmt.Errorf("push image: %w", err)
+	}
+	status := EntitySkipped
+	if pushed {
+		status = EntityReplicated
+	}
+
+	// Referrers are pushed on every import, skipped images included, like
+	// copyReferrers does from Harbor.
+	if err := r.pushReferrers(referrers, dst.Context(), desc.Digest, opts, maxReferrerDepth); err != nil {
+		return EntityFailed, verification, fmt.Errorf("replicate referrers: %w", err)
+	}
+	return status, verification, nil
+}
+
+// pushReferrers pushes the referrers of digest exported with the bundle to
+// dstRepo: OCI referrers, followed to depth, and cosign's tag-based
+// signatures, attestations and SBOMs.
+func (r *LayoutReplicator) pushReferrers(referrers *layoutReferrers, dstRepo name.Repository, digest v1.Hash, opts *replicationOptions, depth int) error {
+	for _, m := range referrers.of(digest) {
+		if _, err := r.pushManifest(m, dstRepo.Digest(m.Digest.String()), opts); err != nil {
+			return fmt.Errorf("push referrer %s: %w", m.Digest, err)
+		}
+		if depth > 1 {
+			if err := r.pushReferrers(referrers, dstRepo, m.Digest, opts, depth-1); err != nil {
+				return err
+			}
+		}
+	}
+	for _, suffix := range cosignTagSuffixes {
+		tag := cosignTag(digest, suffix)
+		m, ok := referrers.tagged(tag)
+		if !ok {
+			continue
+		}
+		if _, err := r.pushManifest(m, dstRepo.Tag(tag), opts); err != nil {
+			return fmt.Errorf("push %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// pushManifest pushes the image or index of the layout described by desc to
+// dst unchanged. It reports false without pushing if dst already has the same
+// digest.
+func (r *LayoutReplicator) pushManifest(desc v1.Descriptor, dst name.Reference, opts *replicationOptions) (bool, error) {
+	if existing, err := remote.Head(dst, opts.pushOpts...); err == nil && existing.Digest == desc.Digest {
+		return false, nil
+	}
+	if desc.MediaType.IsIndex() {
+		idx, err := r.path.ImageIndex()
+		if err == nil {
+			idx, err = idx.ImageIndex(desc.Digest)
+		}
+		if err != nil {
+			return false, fmt.Errorf("read index %s: %w", desc.Digest, err)
+		}
+		return true, remote.WriteIndex(dst, idx, opts.pushOpts...)
+	}
+	img, err := r.path.Image(desc.Digest)
+	if err != nil {
+		return false, fmt.Errorf("read image %s: %w", desc.Digest, err)
+	}
+	return true, remote.Write(dst, img, opts.pushOpts...)
+}
+
+// layoutReferrers looks up the referrers exported with the images of one
+// repository of a bundle.
+type layoutReferrers struct {
+	path      layout.Path
+	repo      string
+	manifests []v1.Descriptor
+}
+
+func newLayoutReferrers(path layout.Path, entity Entity) (*layoutReferrers, error) {
+	repo, err := entityBundleRepo(entity)
+	if err != nil {
+		return nil, err
+	}
+	index, err := path.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("read layout index: %w", err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("read layout index: %w", err)
+	}
+	referrers := &layoutReferrers{path: path, repo: repo}
+	for _, desc := range manifest.Manifests {
+		if desc.Annotations[BundleKindAnnotation] == BundleKindReferrer {
+			referrers.manifests = append(referrers.manifests, desc)
+		}
+	}
+	return referrers, nil
+}
+
+// of returns the OCI referrers of digest.
+func (l *layoutReferrers) of(digest v1.Hash) []v1.Descriptor {
+	var referrers []v1.Descriptor
+	for _, desc := range l.manifests {
+		if desc.Annotations[BundleSubjectAnnotation] == digest.String() &&
+			desc.Annotations[BundleRefAnnotation] == l.repo+"@"+desc.Digest.String() {
+			referrers = append(referrers, desc)
+		}
+	}
+	return referrers
+}
+
+// tagged returns the artifact exported under the given cosign tag.
+func (l *layoutReferrers) tagged(tag string) (v1.Descriptor, bool) {
+	for _, desc := range l.manifests {
+		if desc.Annotations[BundleRefAnnotation] == l.repo+":"+tag {
+			return desc, true
+		}
+	}
+	return v1.Descriptor{}, false
+}
+
+// signaturesOf returns the signatures of digest for the verifier.
+func (l *layoutReferrers) signaturesOf(digest v1.Hash) signature.Signatures {
+	return layoutSignatures{referrers: l, subject: digest}
+}
+
+// layoutSignatures reads the signatures of an image from a bundle.
+type layoutSignatures struct {
+	referrers *layoutReferrers
+	subject   v1.Hash
+}
+
+func (s layoutSignatures) CosignSignature() (v1.Image, error) {
+	desc, ok := s.referrers.tagged(cosignTag(s.subject, ".sig"))
+	if !ok {
+		return nil, nil
+	}
+	return s.referrers.path.Image(desc.Digest)
+}
+
+func (s layoutSignatures) Referrers() ([]v1.Descriptor, error) {
+	return s.referrers.of(s.subject), nil
+}
+
+func (s layoutSignatures) Referrer(digest v1.Hash) (v1.Image, error) {
+	return s.referrers.path.Image(digest)
+}
+
+// Bundle is a bundle unpacked on disk and checked by OpenBundle.
+type Bundle struct {
+	path     layout.Path
+	stateRef string
+}
+
+type bundleContextKey struct{}
+
+// withBundle makes a run of the state replication process sync from the
+// bundle instead of Harbor.
+func withBundle(ctx context.Context, b *Bundle) context.Context {
+	return context.WithValue(ctx, bundleContextKey{}, b)
+}
+
+func bundleFromContext(ctx context.Context) *Bundle {
+	b, _ := ctx.Value(bundleContextKey{}).(*Bundle)
+	return b
+}
+
+// stateFetcher returns the fetcher of a state artifact: the bundle during an
+// import, the input otherwise. State artifacts from Harbor or from a bundle
+// are verified if Ground Control signs them.
+func (f *FetchAndReplicateStateProcess) stateFetcher(ctx context.Context, input, username, password string, useInsecure bool, log *zerolog.Logger) (StateFetcher, error) {
+	if b := bundleFromContext(ctx); b != nil {
+		if input == "" {
+			return nil, errors.New("empty state reference")
+		}
+		return &LayoutStateFetcher{path: b.path, ref: input, verifier: f.stateVerifier(log)}, nil
+	}
+	fetcher, err := getStateFetcherForInput(input, username, password, useInsecure, log)
+	if err != nil {
+		return nil, err
+	}
+	if urlFetcher, ok := fetcher.(*URLStateFetcher); ok {
+		urlFetcher.SetVerifier(f.stateVerifier(log))
+	}
+	return fetcher, nil
+}
+
+// OpenBundle unpacks the bundle tarball into dir and checks it: every blob
+// must match its digest, every manifest must have its blobs, and the group
+// states, config state and images the satellite state refers to must all be
+// present.
+func OpenBundle(archive, dir string) (*Bundle, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, fmt.Errorf("open bundle: %w", err)
+	}
+	defer file.Close()
+	if err := untar(file, dir); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	path, err := layout.FromPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if err := verifyBlobs(dir); err != nil {
+		return nil, err
+	}
+
+	index, err := path.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("%w: read index: %v", ErrInvalidBundle, err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("%w: read index: %v", ErrInvalidBundle, err)
+	}
+	b := &Bundle{path: path}
+	for _, desc := range manifest.Manifests {
+		if err := checkBlobsPresent(path, desc); err != nil {
+			return nil, err
+		}
+		if desc.Annotations[BundleKindAnnotation] == BundleKindSatelliteState {
+			if b.stateRef != "" {
+				return nil, fmt.Errorf("%w: more than one satellite state", ErrInvalidBundle)
+			}
+			b.stateRef = desc.Annotations[BundleRefAnnotation]
+		}
+	}
+	if b.stateRef == "" {
+		return nil, fmt.Errorf("%w: no satellite state", ErrInvalidBundle)
+	}
+	if err := b.checkComplete(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// checkComplete checks that everything the satellite state refers to is in
+// the bundle.
+func (b *Bundle) checkComplete() error {
+	log := zerolog.Nop()
+	ctx := context.Background()
+
+	satelliteState := &SatelliteState{}
+	if err := NewLayoutStateFetcher(b.path, b.stateRef).FetchStateArtifact(ctx, satelliteState, &log); err != nil {
+		return fmt.Errorf("%w: satellite state: %v", ErrInvalidBundle, err)
+	}
+	if satelliteState.Config != "" {
+		if _, err := findInLayout(b.path, satelliteState.Config); err != nil {
+			return fmt.Errorf("%w: config state: %v", ErrInvalidBundle, err)
+		}
+	}
+	for _, groupURL := range satelliteState.States {
+		state := NewState()
+		if err := NewLayoutStateFetcher(b.path, groupURL).FetchStateArtifact(ctx, state, &log); err != nil {
+			return fmt.Errorf("%w: group state: %v", ErrInvalidBundle, err)
+		}
+		if _, err := ProcessState(&state); err != nil {
+			return fmt.Errorf("%w: group state %s: %v", ErrInvalidBundle, groupURL, err)
+		}
+		for _, entity := range FetchEntitiesFromState(state) {
+			key, err := entityBundleKey(entity)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+			}
+			desc, err := findInLayout(b.path, key)
+			if err != nil {
+				return fmt.Errorf("%w: image of group %s: %v", ErrInvalidBundle, groupURL, err)
+			}
+			if entity.Digest != "" && desc.Digest.String() != entity.Digest {
+				return fmt.Errorf("%w: %s is %s in the bundle, the group state declares %s", ErrInvalidBundle, key, desc.Digest, entity.Digest)
+			}
+		}
+	}
+	return nil
+}
+
+// verifyBlobs checks that every blob of the layout at dir matches its digest.
+func verifyBlobs(dir string) error {
+	return filepath.WalkDir(filepath.Join(dir, "blobs"), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if algorithm := filepath.Base(filepath.Dir(p)); algorithm != "sha256" {
+			return fmt.Errorf("%w: unsupported digest algorithm %s", ErrInvalidBundle, algorithm)
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		hash, _, err := v1.SHA256(file)
+		if err != nil {
+			return fmt.Errorf("hash blob %s: %w", d.Name(), err)
+		}
+		if hash.Hex != d.Name() {
+			return fmt.Errorf("%w: blob sha256:%s is corrupt", ErrInvalidBundle, d.Name())
+		}
+		return nil
+	})
+}
+
+// checkBlobsPresent checks that the manifest of desc and every blob it refers
+// to, recursively for indexes, are in the layout. Non-distributable layers are
+// not expected to be.
+func checkBlobsPresent(path layout.Path, desc v1.Descriptor) error {
+	raw, err := path.Bytes(desc.Digest)
+	if err != nil {
+		return fmt.Errorf("%w: missing manifest %s", ErrInvalidBundle, desc.Digest)
+	}
+	switch {
+	case desc.MediaType.IsIndex():
+		manifest, err := v1.ParseIndexManifest(bytes.NewReader(raw))
+		if err != nil {
+			return fmt.Errorf("%w: parse index %s: %v", ErrInvalidBundle, desc.Digest, err)
+		}
+		for _, child := range manifest.Manifests {
+			if err := checkBlobsPresent(path, child); err != nil {
+				return err
+			}
+		}
+	case desc.MediaType.IsImage():
+		manifest, err := v1.ParseManifest(bytes.NewReader(raw))
+		if err != nil {
+			return fmt.Errorf("%w: parse manifest %s: %v", ErrInvalidBundle, desc.Digest, err)
+		}
+		for _, blob := range append([]v1.Descriptor{manifest.Config}, manifest.Layers...) {
+			if len(blob.URLs) > 0 {
+				continue
+			}
+			rc, err := path.Blob(blob.Digest)
+			if err != nil {
+				return fmt.Errorf("%w: missing blob %s of %s", ErrInvalidBundle, blob.Digest, desc.Digest)
+			}
+			rc.Close()
+		}
+	}
+	return nil
+}
+
+// ImportBundle syncs the satellite from the bundle the way a scheduled run
+// syncs it from Harbor: the config is reconciled, images are pushed to the
+// local registry, those no longer wanted are retired and state.json is
+// updated. Only the local registry has to be reachable.
+func (f *FetchAndReplicateStateProcess) ImportBundle(ctx context.Context, b *Bundle) error {
+	return f.Execute(withBundle(ctx, b))
+}
+
+// ExportBundle writes a bundle of the satellite state, the group and config
+// states it refers to and every image of the groups, pulled from Harbor with
+// the satellite's credentials, to out.
+func (f *FetchAndReplicateStateProcess) ExportBundle(ctx context.Context, out io.Writer) error {
+	log := logger.FromContext(ctx).With().Str("process", f.name).Logger()
+
+	replicator, sourceURL, srcUsername, srcPassword, _, useUnsecure, satelliteStateURL := f.setupReplication(&log)
+	if satelliteStateURL == "" || sourceURL == "" {
+		return errors.New("satellite state URL or source registry URL is empty, is the satellite registered?")
+	}
+	basic, ok := replicator.(*BasicReplicator)
+	if !ok {
+		return fmt.Errorf("unexpected replicator %T", replicator)
+	}
+
+	dir, err := os.MkdirTemp("", "satellite-bundle-*")
+	if err != nil {
+		return fmt.Errorf("create bundle directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	path, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		return fmt.Errorf("create bundle layout: %w", err)
+	}
+	w := &bundleWriter{path: path, written: map[string]bool{}}
+
+	satelliteState := &SatelliteState{}
+	if err := w.addState(ctx, BundleKindSatelliteState, satelliteStateURL, srcUsername, srcPassword, useUnsecure, satelliteState, &log); err != nil {
+		return err
+	}
+	if override := f.cm.GetHarborRegistryURL(); override != "" {
+		if satelliteState, err = applyHarborOverrideToSatelliteState(satelliteState, override); err != nil {
+			return err
+		}
+	}
+	if satelliteState.Config != "" {
+		if err := w.addState(ctx, BundleKindConfig, satelliteState.Config, srcUsername, srcPassword, useUnsecure, nil, &log); err != nil {
+			return err
+		}
+	}
+
+	opts, err := basic.buildReplicationOptions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, groupURL := range satelliteState.States {
+		state := NewState()
+		if err := w.addState(ctx, BundleKindGroupState, groupURL, srcUsername, srcPassword, useUnsecure, state, &log); err != nil {
+			return err
+		}
+		if _, err := ProcessState(&state); err != nil {
+			return fmt.Errorf("process group state %s: %w", groupURL, err)
+		}
+		for _, entity := range FetchEntitiesFromState(state) {
+			if err := w.addEntity(sourceURL, entity, opts); err != nil {
+				return err
+			}
+		}
+		log.Info().Msgf("Exported group state %s", groupURL)
+	}
+
+	return writeTar(out, dir)
+}
+
+// bundleWriter appends state artifacts and images to the layout of a bundle,
+// each once.
+type bundleWriter struct {
+	path    layout.Path
+	written map[string]bool
+}
+
+func (w *bundleWriter) annotations(kind, ref string) (map[string]string, bool, error) {
+	key, err := bundleKey(ref)
+	if err != nil {
+		return nil, false, err
+	}
+	if w.written[key] {
+		return nil, false, nil
+	}
+	w.written[key] = true
+	return map[string]string{BundleKindAnnotation: kind, BundleRefAnnotation: key}, true, nil
+}
+
+// addState appends the state artifact at url and, unless out is nil, decodes
+// it into out.
+func (w *bundleWriter) addState(ctx context.Context, kind, url, username, password string, useUnsecure bool, out any, log *zerolog.Logger) error {
+	fetcher, err := getStateFetcherForInput(url, username, password, useUnsecure, log)
+	if err != nil {
+		return err
+	}
+	puller, ok := fetcher.(artifactPuller)
+	if !ok {
+		return fmt.Errorf("state fetcher %T cannot export artifacts", fetcher)
+	}
+	img, err := puller.pullImage(ctx, log)
+	if err != nil {
+		return fmt.Errorf("pull state artifact %s: %w", url, err)
+	}
+	if out != nil {
+		if err := extractArtifactJSON(url, img, out, log); err != nil {
+			return err
+		}
+	}
+	annotations, add, err := w.annotations(kind, url)
+	if err != nil || !add {
+		return err
+	}
+	return w.path.AppendImage(img, layout.WithAnnotations(annotations))
+}
+
+// addEntity appends the image of the entity, pulled from the source registry
+// by the digest the group state declares, and its referrers. Indexes are
+// exported with every platform.
+func (w *bundleWriter) addEntity(sourceURL string, entity Entity, opts *replicationOptions) error {
+	key, err := entityBundleKey(entity)
+	if err != nil {
+		return err
+	}
+	annotations, add, err := w.annotations(BundleKindImage, key)
+	if err != nil || !add {
+		return err
+	}
+
+	srcRef := fmt.Sprintf("%s/%s/%s:%s", sourceURL, entity.GetRepository(), entity.GetName(), entity.GetTag())
+	if entity.Digest != "" {
+		srcRef = fmt.Sprintf("%s/%s/%s@%s", sourceURL, entity.GetRepository(), entity.GetName(), entity.Digest)
+	}
+	src, err := name.ParseReference(srcRef, opts.nameOpts...)
+	if err != nil {
+		return fmt.Errorf("parse source ref %s: %w", srcRef, err)
+	}
+	desc, err := remote.Get(src, opts.pullOpts...)
+	if err != nil {
+		return fmt.Errorf("fetch image descriptor %s: %w", srcRef, err)
+	}
+	if entity.Digest != "" && desc.Digest.String() != entity.Digest {
+		return fmt.Errorf("%w: state declares %s, source served %s", ErrDigestMismatch, entity.Digest, desc.Digest)
+	}
+
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return fmt.Errorf("read index %s: %w", srcRef, err)
+		}
+		err = w.path.AppendIndex(idx, layout.WithAnnotations(annotations))
+		if err != nil {
+			return err
+		}
+	} else {
+		img, err := desc.Image()
+		if err != nil {
+			return fmt.Errorf("read image %s: %w", srcRef, err)
+		}
+		if err := w.path.AppendImage(img, layout.WithAnnotations(annotations)); err != nil {
+			return err
+		}
+	}
+	return w.addReferrers(src.Context(), desc.Digest, opts, maxReferrerDepth)
+}
+
+// addReferrers appends the artifacts referring to digest in srcRepo, found
+// the way copyReferrers finds them, so that an import can verify and push
+// signatures without Harbor.
+func (w *bundleWriter) addReferrers(srcRepo name.Repository, digest v1.Hash, opts *replicationOptions, depth int) error {
+	idx, err := remote.Referrers(srcRepo.Digest(digest.String()), opts.pullOpts...)
+	if err != nil {
+		return fmt.Errorf("list referrers of %s: %w", digest, err)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("read referrers of %s: %w", digest, err)
+	}
+	for _, m := range manifest.Manifests {
+		if err := w.addReferrer(srcRepo.Digest(m.Digest.String()), digest, m.ArtifactType, opts); err != nil {
+			return fmt.Errorf("export referrer %s: %w", m.Digest, err)
+		}
+		if depth > 1 {
+			if err := w.addReferrers(srcRepo, m.Digest, opts, depth-1); err != nil {
+				return err
+			}
+		}
+	}
+	for _, suffix := range cosignTagSuffixes {
+		tag := cosignTag(digest, suffix)
+		err := w.addReferrer(srcRepo.Tag(tag), digest, "", opts)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("export %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// addReferrer appends the artifact at ref, which refers to subject. The
+// artifact type the referrers API reported is kept in the layout's index.
+func (w *bundleWriter) addReferrer(ref name.Reference, subject v1.Hash, artifactType string, opts *replicationOptions) error {
+	desc, err := remote.Get(ref, opts.pullOpts...)
+	if err != nil {
+		return err
+	}
+	annotations, add, err := w.annotations(BundleKindReferrer, ref.String())
+	if err != nil || !add {
+		return err
+	}
+	annotations[BundleSubjectAnnotation] = subject.String()
+
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err == nil {
+			err = w.path.WriteIndex(idx)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		img, err := desc.Image()
+		if err == nil {
+			err = w.path.WriteImage(img)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return w.path.AppendDescriptor(v1.Descriptor{
+		MediaType:    desc.MediaType,
+		Size:         desc.Size,
+		Digest:       desc.Digest,
+		ArtifactType: artifactType,
+		Annotations:  annotations,
+	})
+}
+
+// writeTar writes the files under dir to out as a tar archive.
+func writeTar(out io.Writer, dir string) error {
+	tw := tar.NewWriter(out)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	return tw.Close()
+}
+
+// untar extracts a tar archive into dir. Only directories and regular files
+// are extracted, and only within dir.
+func untar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !filepath.IsLocal(filepath.FromSlash(hdr.Name)) {
+			return fmt.Errorf("archive entry %q is outside the bundle", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, tr)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("archive entry %q has unsupported type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
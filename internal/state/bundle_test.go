@@ -0,0 +1,243 @@
+package state
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-registry/harbor-satellite/internal/signature"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// pushStateArtifact pushes v as the artifacts.json of a state artifact and
+// returns its URL.
+func pushStateArtifact(t *testing.T, addr, repo string, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	img, err := crane.Image(map[string][]byte{"artifacts.json": data})
+	require.NoError(t, err)
+	ref := addr + "/" + repo + ":latest"
+	require.NoError(t, crane.Push(img, ref, crane.Insecure))
+	return "http://" + ref
+}
+
+func newBundleTestProcess(t *testing.T, srcAddr, dstAddr string) *FetchAndReplicateStateProcess {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		StateConfig: config.StateConfig{
+			StateURL:            "http://" + srcAddr + "/satellite/satellite-state/test-sat/state:latest",
+			RegistryCredentials: config.RegistryCredentials{URL: config.URL("http://" + srcAddr)},
+		},
+		AppConfig: config.AppConfig{
+			GroundControlURL:         "http://127.0.0.1:8080",
+			UseUnsecure:              true,
+			LocalRegistryCredentials: config.RegistryCredentials{URL: config.URL("http://" + dstAddr)},
+		},
+		ZotConfigRaw: json.RawMessage(`{}`),
+	}
+	cm, err := config.NewConfigManager(filepath.Join(dir, "config.json"), filepath.Join(dir, "prev.json"), "token", "http://127.0.0.1:8080", false, cfg)
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	return NewFetchAndReplicateStateProcess(cm, filepath.Join(dir, "state.json"), &log)
+}
+
+// exportTestBundle publishes a satellite state with one group holding one
+// image on a source registry, exports it and returns the bundle path, the
+// group state URL and the image reference.
+func exportTestBundle(t *testing.T, dstAddr string) (string, string, Entity) {
+	t.Helper()
+	src, srcAddr := newTestRegistry(t)
+	img := pushImage(t, srcAddr, "library", "app", "v1", 2)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	entity := Entity{Repository: "library", Name: "app", Tag: "v1", Digest: digest.String()}
+
+	exporter := newBundleTestProcess(t, srcAddr, dstAddr)
+	groupURL := pushStateArtifact(t, srcAddr, "satellite/group-state/edge/state", State{
+		Registry:  srcAddr,
+		Artifacts: []Artifact{{Repository: "library/app", Tags: []string{"v1"}, Digest: digest.String()}},
+	})
+	configURL := pushStateArtifact(t, srcAddr, "satellite/config-state/test-sat/state", exporter.cm.GetConfig())
+	pushStateArtifact(t, srcAddr, "satellite/satellite-state/test-sat/state", SatelliteState{
+		States: []string{groupURL},
+		Config: configURL,
+	})
+
+	archive := filepath.Join(t.TempDir(), "bundle.tar")
+	file, err := os.Create(archive)
+	require.NoError(t, err)
+	require.NoError(t, exporter.ExportBundle(testContext(), file))
+	require.NoError(t, file.Close())
+
+	// Imports must not need the source
+	src.Close()
+	return archive, groupURL, entity
+}
+
+func TestBundleKey(t *testing.T) {
+	tests := map[string]string{
+		"http://registry:5000/satellite/group-state/edge/state:latest": "satellite/group-state/edge/state:latest",
+		"registry.example.com/library/app:v1":                          "library/app:v1",
+		"library/app@sha256:" + string(bytes.Repeat([]byte("a"), 64)):  "library/app@sha256:" + string(bytes.Repeat([]byte("a"), 64)),
+	}
+	for ref, want := range tests {
+		got, err := bundleKey(ref)
+		require.NoError(t, err)
+		require.Equal(t, want, got, ref)
+	}
+}
+
+func TestBundle_ExportImport(t *testing.T) {
+	_, dstAddr := newTestRegistry(t)
+	archive, groupURL, entity := exportTestBundle(t, dstAddr)
+
+	b, err := OpenBundle(archive, t.TempDir())
+	require.NoError(t, err)
+
+	importer := newBundleTestProcess(t, "harbor.invalid", dstAddr)
+	require.NoError(t, importer.ImportBundle(testContext(), b))
+
+	ref, err := name.ParseReference(dstAddr+"/library/app:v1", name.Insecure)
+	require.NoError(t, err)
+	desc, err := remote.Head(ref)
+	require.NoError(t, err)
+	require.Equal(t, entity.Digest, desc.Digest.String())
+
+	persisted, err := LoadState(importer.stateFilePath)
+	require.NoError(t, err)
+	require.Len(t, persisted.Groups, 1)
+	require.Equal(t, groupURL, persisted.Groups[0].URL)
+	require.Equal(t, []Entity{entity}, persisted.Groups[0].Entities)
+	require.NotEmpty(t, persisted.ConfigDigest)
+
+	report, ok := importer.LastSync()
+	require.True(t, ok)
+	require.Zero(t, report.Errors)
+
+	// A second import finds everything in place
+	require.NoError(t, importer.ImportBundle(testContext(), b))
+}
+
+func TestOpenBundle_RejectsCorruptBlob(t *testing.T) {
+	archive, _, entity := exportTestBundle(t, "127.0.0.1:1")
+
+	dir := t.TempDir()
+	file, err := os.Open(archive)
+	require.NoError(t, err)
+	require.NoError(t, untar(file, dir))
+	require.NoError(t, file.Close())
+
+	blob := filepath.Join(dir, "blobs", "sha256", entity.Digest[len("sha256:"):])
+	data, err := os.ReadFile(blob)
+	require.NoError(t, err)
+	data[len(data)-2] ^= 0xff
+	require.NoError(t, os.WriteFile(blob, data, 0o644))
+
+	tampered := filepath.Join(t.TempDir(), "tampered.tar")
+	out, err := os.Create(tampered)
+	require.NoError(t, err)
+	require.NoError(t, writeTar(out, dir))
+	require.NoError(t, out.Close())
+
+	_, err = OpenBundle(tampered, t.TempDir())
+	require.ErrorIs(t, err, ErrInvalidBundle)
+}
+
+func TestOpenBundle_RejectsMissingImage(t *testing.T) {
+	archive, _, entity := exportTestBundle(t, "127.0.0.1:1")
+
+	dir := t.TempDir()
+	file, err := os.Open(archive)
+	require.NoError(t, err)
+	require.NoError(t, untar(file, dir))
+	require.NoError(t, file.Close())
+
+	path, err := layout.FromPath(dir)
+	require.NoError(t, err)
+	key, err := entityBundleKey(entity)
+	require.NoError(t, err)
+	require.NoError(t, path.RemoveDescriptors(func(d v1.Descriptor) bool {
+		return d.Annotations[BundleRefAnnotation] == key
+	}))
+
+	incomplete := filepath.Join(t.TempDir(), "incomplete.tar")
+	out, err := os.Create(incomplete)
+	require.NoError(t, err)
+	require.NoError(t, writeTar(out, dir))
+	require.NoError(t, out.Close())
+
+	_, err = OpenBundle(incomplete, t.TempDir())
+	require.ErrorIs(t, err, ErrInvalidBundle)
+	require.ErrorContains(t, err, key)
+}
+
+func TestUntar_RejectsEscapingEntries(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../escape", Typeflag: tar.TypeReg, Size: 1, Mode: 0o644}))
+	_, err := tw.Write([]byte("x"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	dir := t.TempDir()
+	require.Error(t, untar(&buf, filepath.Join(dir, "bundle")))
+	require.NoFileExists(t, filepath.Join(dir, "escape"))
+}
+
+func TestLayoutReplicator_VerifiesAndPushesExportedSignatures(t *testing.T) {
+	srcAddr := newReferrersRegistry(t)
+	dstAddr := newReferrersRegistry(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signed := pushOCIImage(t, srcAddr+"/library/signed:v1")
+	signWithCosign(t, key, srcAddr+"/library/signed", signed.String())
+	sbom := pushReferrer(t, srcAddr+"/library/signed", signed)
+	pushOCIImage(t, srcAddr+"/library/unsigned:v1")
+
+	entities := []Entity{
+		{Name: "signed", Repository: "library", Tag: "v1", Digest: signed.String()},
+		{Name: "unsigned", Repository: "library", Tag: "v1"},
+	}
+	path, err := layout.Write(t.TempDir(), empty.Index)
+	require.NoError(t, err)
+	w := &bundleWriter{path: path, written: map[string]bool{}}
+	exporter := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true).(*BasicReplicator)
+	opts, err := exporter.buildReplicationOptions(testContext())
+	require.NoError(t, err)
+	for _, entity := range entities {
+		require.NoError(t, w.addEntity(srcAddr, entity, opts))
+	}
+
+	importer := NewBasicReplicator("", "", "harbor.invalid", dstAddr, "", "", true,
+		WithSignatureVerifier(newTestVerifier(t, key, config.SignatureVerificationConfig{})))
+	r := NewLayoutReplicator(path, importer.(*BasicReplicator))
+	summary, err := r.Replicate(testContext(), entities)
+	require.Error(t, err)
+
+	require.Equal(t, EntityReplicated, summary.Results[0].Status)
+	require.Equal(t, signature.Verified, summary.Results[0].Signature.Verdict)
+	requireManifest(t, dstAddr+"/library/signed:v1", true)
+	requireManifest(t, dstAddr+"/library/signed:"+cosignTag(signed, ".sig"), true)
+	requireManifest(t, dstAddr+"/library/signed@"+sbom.String(), true)
+
+	require.ErrorIs(t, summary.Results[1].Err, ErrSignatureRejected)
+	require.Equal(t, signature.Unsigned, summary.Results[1].Signature.Verdict)
+	requireManifest(t, dstAddr+"/library/unsigned:v1", false)
+}
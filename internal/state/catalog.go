@@ -9,8 +9,11 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
 )
@@ -20,6 +23,9 @@ type CachedImage struct {
 	SizeBytes int64  `json:"size_bytes"`
 }
 
+// catalogScope is the token scope of the catalog endpoint.
+const catalogScope = "registry:catalog:*"
+
 type catalogResponse struct {
 	Repositories []string `json:"repositories"`
 }
@@ -28,9 +34,19 @@ type tagsResponse struct {
 	Tags []string `json:"tags"`
 }
 
-func collectCachedImages(ctx context.Context, registryHost string, insecure bool) ([]CachedImage, error) {
+// collectCachedImages lists the images in the registry at registryHost with
+// auth. A registry served over HTTPS is verified with caFile, if given.
+func collectCachedImages(ctx context.Context, registryHost string, insecure bool, caFile string, auth authn.Authenticator) ([]CachedImage, error) {
 	log := logger.FromContext(ctx)
-	client := &http.Client{Timeout: 30 * time.Second}
+	transport, err := localRegistryTransport(caFile)
+	if err != nil {
+		return nil, err
+	}
+	authTransport, err := authenticatedTransport(ctx, registryHost, insecure, auth, transport, catalogScope)
+	if err != nil {
+		return nil, fmt.Errorf("fetch catalog: %w", err)
+	}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: authTransport}
 
 	repos, err := fetchCatalog(ctx, client, registryHost, insecure)
 	if err != nil {
@@ -46,7 +62,7 @@ func collectCachedImages(ctx context.Context, registryHost string, insecure bool
 		}
 		for _, tag := range tags {
 			ref := fmt.Sprintf("%s/%s:%s", registryHost, repo, tag)
-			img, err := collectImageInfo(ref, crane.WithContext(ctx), insecure)
+			img, err := collectImageInfo(ref, crane.WithContext(ctx), insecure, crane.WithTransport(transport), crane.WithAuth(auth))
 			if err != nil {
 				log.Warn().Err(err).Str("ref", ref).Msg("Skipping image: failed to collect info")
 				continue
@@ -61,8 +77,8 @@ func collectCachedImages(ctx context.Context, registryHost string, insecure bool
 	return images, nil
 }
 
-func collectImageInfo(ref string, ctxOpt crane.Option, insecure bool) (CachedImage, error) {
-	opts := []crane.Option{ctxOpt}
+func collectImageInfo(ref string, ctxOpt crane.Option, insecure bool, extra ...crane.Option) (CachedImage, error) {
+	opts := append([]crane.Option{ctxOpt}, extra...)
 	if insecure {
 		opts = append(opts, crane.Insecure)
 	}
@@ -112,6 +128,25 @@ func computeManifestSize(raw []byte) (int64, error) {
 	return total, nil
 }
 
+// authenticatedTransport authenticates the requests of base to the registry
+// at registryHost with auth, answering basic and token challenges alike.
+// Tokens are requested for scopes, and for the scopes the registry asks for
+// later. Anonymous requests are sent as they are.
+func authenticatedTransport(ctx context.Context, registryHost string, insecure bool, auth authn.Authenticator, base http.RoundTripper, scopes ...string) (http.RoundTripper, error) {
+	if auth == authn.Anonymous {
+		return base, nil
+	}
+	var nameOpts []name.Option
+	if insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	registry, err := name.NewRegistry(registryHost, nameOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return transport.NewWithContext(ctx, registry, auth, base, scopes)
+}
+
 func registryScheme(insecure bool) string {
 	if insecure {
 		return "http"
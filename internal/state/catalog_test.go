@@ -11,7 +11,12 @@ import (
 	"testing"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
@@ -200,7 +205,7 @@ func TestCollectCachedImages(t *testing.T) {
 
 		addr := strings.TrimPrefix(srv.URL, "http://")
 		ctx := testContext()
-		images, err := collectCachedImages(ctx, addr, true)
+		images, err := collectCachedImages(ctx, addr, true, "", authn.Anonymous)
 		require.NoError(t, err)
 		require.Empty(t, images)
 		require.NotNil(t, images)
@@ -218,16 +223,52 @@ func TestCollectCachedImages(t *testing.T) {
 
 		addr := strings.TrimPrefix(srv.URL, "http://")
 		ctx := testContext()
-		images, err := collectCachedImages(ctx, addr, true)
+		images, err := collectCachedImages(ctx, addr, true, "", authn.Anonymous)
 		require.NoError(t, err)
 		require.Empty(t, images)
 	})
 
 	t.Run("unreachable registry returns error", func(t *testing.T) {
 		ctx := testContext()
-		_, err := collectCachedImages(ctx, "127.0.0.1:1", true)
+		_, err := collectCachedImages(ctx, "127.0.0.1:1", true, "", authn.Anonymous)
 		require.Error(t, err)
 	})
+
+	t.Run("authenticates to the registry", func(t *testing.T) {
+		addr := newAuthTestRegistry(t, "admin", "secret")
+		auth := &authn.Basic{Username: "admin", Password: "secret"}
+		ref, err := name.ParseReference(addr+"/library/app:v1", name.Insecure)
+		require.NoError(t, err)
+		img, err := random.Image(1024, 1)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img, remote.WithAuth(auth)))
+
+		images, err := collectCachedImages(testContext(), addr, true, "", auth)
+		require.NoError(t, err)
+		require.Len(t, images, 1)
+		require.True(t, strings.HasPrefix(images[0].Reference, addr+"/library/app:v1@sha256:"))
+
+		_, err = collectCachedImages(testContext(), addr, true, "", authn.Anonymous)
+		require.Error(t, err, "the catalog is not served without credentials")
+	})
+}
+
+// newAuthTestRegistry starts a registry that requires basic authentication
+// with username and password, like Zot with htpasswd and authenticated
+// pulls.
+func newAuthTestRegistry(t *testing.T, username, password string) string {
+	t.Helper()
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != username || p != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
 }
 
 func TestCollectImageInfo_MissingManifest(t *testing.T) {
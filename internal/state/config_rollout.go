@@ -0,0 +1,405 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"github.com/container-registry/harbor-satellite/internal/utils"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/rs/zerolog"
+)
+
+// ConfigRolloutFileName is the config rollout record persisted next to
+// state.json.
+const ConfigRolloutFileName = "config_rollout.json"
+
+// healthCheckTimeout bounds each health check of a config on probation.
+const healthCheckTimeout = 10 * time.Second
+
+// ConfigProbation is a config applied from Ground Control that has not been
+// promoted yet. PreviousDigest is the last promoted config, the one restored
+// on rollback; it is kept when a newer config replaces one still on
+// probation.
+type ConfigProbation struct {
+	Digest         string    `json:"digest"`
+	PreviousDigest string    `json:"previous_digest,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	Deadline       time.Time `json:"deadline"`
+	Threshold      int       `json:"failure_threshold"`
+	Failures       int       `json:"failures,omitempty"`
+}
+
+// ConfigRollback is a config that failed its probation and was replaced by
+// the previous one. The config is not applied again until Ground Control
+// publishes another.
+type ConfigRollback struct {
+	Digest         string    `json:"digest"`
+	RestoredDigest string    `json:"restored_digest,omitempty"`
+	Reason         string    `json:"reason"`
+	RolledBackAt   time.Time `json:"rolled_back_at"`
+	Reported       bool      `json:"reported,omitempty"`
+}
+
+// Verdicts of a health check of a config on probation.
+type probationVerdict int
+
+const (
+	probationPassed probationVerdict = iota
+	probationFailed
+	probationPromoted
+	probationExhausted
+)
+
+// ConfigRolloutStore tracks the config on probation and the last rollback,
+// and persists them to disk so that a probation survives restarts. A store
+// with an empty path is kept in memory only.
+type ConfigRolloutStore struct {
+	mu        sync.Mutex
+	path      string
+	probation *ConfigProbation
+	rollback  *ConfigRollback
+}
+
+type persistedConfigRollout struct {
+	Probation *ConfigProbation `json:"probation,omitempty"`
+	Rollback  *ConfigRollback  `json:"rollback,omitempty"`
+}
+
+// NewConfigRolloutStore loads the rollout record from path. A missing file
+// yields an empty store.
+func NewConfigRolloutStore(path string) (*ConfigRolloutStore, error) {
+	s := &ConfigRolloutStore{path: path}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return s, fmt.Errorf("read config rollout file: %w", err)
+	}
+
+	var persisted persistedConfigRollout
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return s, fmt.Errorf("unmarshal config rollout file: %w", err)
+	}
+	s.probation, s.rollback = persisted.Probation, persisted.Rollback
+	return s, nil
+}
+
+// configRolloutPath returns the rollout file that belongs to stateFilePath.
+func configRolloutPath(stateFilePath string) string {
+	if stateFilePath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(stateFilePath), ConfigRolloutFileName)
+}
+
+// Begin puts the config with the given digest on probation until period has
+// passed. A config replacing one still on probation keeps its PreviousDigest.
+func (s *ConfigRolloutStore) Begin(digest, previousDigest string, period time.Duration, threshold int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.probation != nil {
+		previousDigest = s.probation.PreviousDigest
+	}
+	s.probation = &ConfigProbation{
+		Digest:         digest,
+		PreviousDigest: previousDigest,
+		StartedAt:      now,
+		Deadline:       now.Add(period),
+		Threshold:      threshold,
+	}
+}
+
+// Clear ends the probation without a verdict.
+func (s *ConfigRolloutStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.probation = nil
+}
+
+// Probation returns the config on probation, if any.
+func (s *ConfigRolloutStore) Probation() (ConfigProbation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.probation == nil {
+		return ConfigProbation{}, false
+	}
+	return *s.probation, true
+}
+
+// OnProbation reports whether a config is on probation.
+func (s *ConfigRolloutStore) OnProbation() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.probation != nil
+}
+
+// Rejected reports whether the config with the given digest was rolled back.
+func (s *ConfigRolloutStore) Rejected(digest string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rollback != nil && s.rollback.Digest == digest
+}
+
+// Check records the outcome of a health check of the config on probation. A
+// passing check after the deadline promotes the config; failures count until
+// the threshold of consecutive failures is reached.
+func (s *ConfigRolloutStore) Check(err error, now time.Time) probationVerdict {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.probation == nil {
+		return probationPassed
+	}
+	if err != nil {
+		s.probation.Failures++
+		if s.probation.Failures >= s.probation.Threshold {
+			return probationExhausted
+		}
+		return probationFailed
+	}
+	s.probation.Failures = 0
+	if !now.Before(s.probation.Deadline) {
+		s.probation = nil
+		return probationPromoted
+	}
+	return probationPassed
+}
+
+// RollBack ends the probation and records its config as rolled back.
+func (s *ConfigRolloutStore) RollBack(reason string, now time.Time) ConfigRollback {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rollback ConfigRollback
+	if s.probation != nil {
+		rollback = ConfigRollback{
+			Digest:         s.probation.Digest,
+			RestoredDigest: s.probation.PreviousDigest,
+			Reason:         reason,
+			RolledBackAt:   now,
+		}
+	}
+	s.probation = nil
+	s.rollback = &rollback
+	return rollback
+}
+
+// PendingRollback returns the last rollback if Ground Control has not been
+// told about it yet.
+func (s *ConfigRolloutStore) PendingRollback() (ConfigRollback, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rollback == nil || s.rollback.Reported {
+		return ConfigRollback{}, false
+	}
+	return *s.rollback, true
+}
+
+// MarkReported records that the rollback was reported. A rollback that
+// happened since is left pending.
+func (s *ConfigRolloutStore) MarkReported(rollback ConfigRollback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rollback != nil && s.rollback.Digest == rollback.Digest && s.rollback.RolledBackAt.Equal(rollback.RolledBackAt) {
+		s.rollback.Reported = true
+	}
+}
+
+// Save persists the rollout record. It is a no-op for in-memory stores.
+func (s *ConfigRolloutStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(persistedConfigRollout{Probation: s.probation, Rollback: s.rollback}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal config rollout: %w", err)
+	}
+	return writeFileAtomic(s.path, data, "config-rollout-*.json.tmp")
+}
+
+// healthCheck is one of the checks a config on probation must pass.
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// configHealthChecks returns the checks run against a config on probation.
+func (f *FetchAndReplicateStateProcess) configHealthChecks() []healthCheck {
+	if f.healthChecks != nil {
+		return f.healthChecks
+	}
+	return []healthCheck{
+		{name: "ground control reachable", check: func(ctx context.Context) error {
+			return checkReachable(ctx, f.cm.ResolveGroundControlURL())
+		}},
+		{name: "local registry healthy", check: func(ctx context.Context) error {
+			insecure := f.cm.UseUnsecure() && !f.cm.IsLocalRegistryTLS()
+			return checkRegistryHealthy(ctx, utils.FormatRegistryURL(f.cm.GetLocalRegistryURL()), insecure, f.cm.GetLocalRegistryCAFile(), localRegistryAuth(f.cm))
+		}},
+	}
+}
+
+// checkConfigProbation runs the health checks of the config on probation, if
+// any, along with the outcome of the satellite state fetch of this run, and
+// promotes or rolls back the config accordingly.
+func (f *FetchAndReplicateStateProcess) checkConfigProbation(ctx context.Context, fetchErr error, log *zerolog.Logger) {
+	probation, ok := f.rollout.Probation()
+	if !ok {
+		return
+	}
+
+	var errs []error
+	if fetchErr != nil {
+		errs = append(errs, fmt.Errorf("state fetch: %w", fetchErr))
+	}
+	for _, hc := range f.configHealthChecks() {
+		checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		err := hc.check(checkCtx)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hc.name, err))
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	checkErr := errors.Join(errs...)
+
+	switch f.rollout.Check(checkErr, time.Now()) {
+	case probationPassed:
+		log.Debug().Str("digest", probation.Digest).Time("deadline", probation.Deadline).Msg("Config on probation passed its health checks")
+	case probationPromoted:
+		metrics.ConfigReconciliations.WithLabelValues(f.name, metrics.ConfigPromoted).Inc()
+		log.Info().Str("digest", probation.Digest).Msg("Config passed its probation and is promoted")
+	case probationFailed:
+		log.Warn().Err(checkErr).Str("digest", probation.Digest).Int("failures", probation.Failures+1).Int("threshold", probation.Threshold).
+			Msg("Config on probation failed its health checks")
+	case probationExhausted:
+		f.rollbackConfig(probation, checkErr, log)
+	}
+
+	if err := f.rollout.Save(); err != nil {
+		log.Warn().Err(err).Msg("Failed to persist the config rollout")
+	}
+}
+
+// rollbackConfig restores the previous config. The state config and the
+// Harbor registry URL override are not part of a remote config and are kept.
+func (f *FetchAndReplicateStateProcess) rollbackConfig(probation ConfigProbation, reason error, log *zerolog.Logger) {
+	log.Error().Err(reason).Str("digest", probation.Digest).Str("previous_digest", probation.PreviousDigest).
+		Msg("Config failed its probation, rolling back to the previous config")
+
+	prev, err := f.cm.ReadPrevConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read the previous config, keeping the config on probation")
+		return
+	}
+	prev.StateConfig = f.cm.GetStateConfig()
+	prev.AppConfig.HarborRegistryURL = f.cm.GetHarborRegistryURL()
+	validated, _, err := config.ValidateAndEnforceDefaults(prev, f.cm.DefaultGroundControlURL)
+	if err != nil {
+		log.Error().Err(err).Msg("The previous config is invalid, keeping the config on probation")
+		return
+	}
+	if err := f.cm.WriteConfigToDisk(validated); err != nil {
+		log.Error().Err(err).Msg("Failed to write the previous config to disk, keeping the config on probation")
+		return
+	}
+
+	f.rollout.RollBack(reason.Error(), time.Now())
+	metrics.ConfigReconciliations.WithLabelValues(f.name, metrics.ConfigRolledBack).Inc()
+
+	f.currentConfigDigest = probation.PreviousDigest
+	f.recordConfigDigest(probation.PreviousDigest)
+	if f.stateFilePath != "" {
+		if err := SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest); err != nil {
+			log.Warn().Err(err).Msg("Failed to persist state after the config rollback")
+		}
+	}
+}
+
+// PendingConfigRollback returns the last config rollback if Ground Control
+// has not been told about it yet.
+func (f *FetchAndReplicateStateProcess) PendingConfigRollback() (ConfigRollback, bool) {
+	return f.rollout.PendingRollback()
+}
+
+// ConfigRollbackReported records that Ground Control was told about the
+// rollback.
+func (f *FetchAndReplicateStateProcess) ConfigRollbackReported(rollback ConfigRollback) error {
+	f.rollout.MarkReported(rollback)
+	return f.rollout.Save()
+}
+
+// checkReachable dials the host of rawURL.
+func checkReachable(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", rawURL, err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// checkRegistryHealthy checks that the registry at host serves the /v2/
+// endpoint to auth. Without credentials, an authentication challenge counts
+// as healthy.
+func checkRegistryHealthy(ctx context.Context, host string, insecure bool, caFile string, auth authn.Authenticator) (retErr error) {
+	if host == "" {
+		return errors.New("local registry URL is empty")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s/v2/", registryScheme(insecure), host), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	base, err := localRegistryTransport(caFile)
+	if err != nil {
+		return err
+	}
+	transport, err := authenticatedTransport(ctx, host, insecure, auth, base)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { retErr = errors.Join(retErr, resp.Body.Close()) }()
+
+	if resp.StatusCode == http.StatusUnauthorized && auth == authn.Anonymous {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("/v2/ returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigRolloutStore_Probation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigRolloutFileName)
+	s, err := NewConfigRolloutStore(path)
+	require.NoError(t, err)
+	now := time.Now()
+	failed := errors.New("unhealthy")
+
+	s.Begin("sha256:b", "sha256:a", time.Minute, 2, now)
+	require.Equal(t, probationFailed, s.Check(failed, now))
+	require.Equal(t, probationPassed, s.Check(nil, now), "a passing check resets the failures")
+	require.Equal(t, probationFailed, s.Check(failed, now))
+
+	// A newer config keeps the last promoted one to roll back to
+	s.Begin("sha256:c", "sha256:b", time.Minute, 2, now)
+	probation, ok := s.Probation()
+	require.True(t, ok)
+	require.Equal(t, "sha256:a", probation.PreviousDigest)
+	require.Zero(t, probation.Failures)
+
+	require.NoError(t, s.Save())
+	loaded, err := NewConfigRolloutStore(path)
+	require.NoError(t, err)
+	require.True(t, loaded.OnProbation())
+
+	require.Equal(t, probationPassed, s.Check(nil, now.Add(30*time.Second)))
+	require.Equal(t, probationPromoted, s.Check(nil, now.Add(time.Minute)))
+	require.False(t, s.OnProbation())
+	_, pending := s.PendingRollback()
+	require.False(t, pending)
+}
+
+func readConfigFile(t *testing.T, path string) *config.Config {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	cfg := &config.Config{}
+	require.NoError(t, json.Unmarshal(data, cfg))
+	return cfg
+}
+
+func TestConfigProbation_RollsBackFailingConfig(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	f := newBundleTestProcess(t, srcAddr, dstAddr)
+	dir := filepath.Dir(f.stateFilePath)
+	log := zerolog.Nop()
+
+	healthy := true
+	f.healthChecks = []healthCheck{{name: "stub", check: func(context.Context) error {
+		if healthy {
+			return nil
+		}
+		return errors.New("registry down")
+	}}}
+
+	published := config.Config{AppConfig: config.AppConfig{LogLevel: "debug"}}
+	configURL := pushStateArtifact(t, srcAddr, "satellite/config-state/edge/state", published)
+	reconcile := func() ConfigFetcherResult {
+		return f.reconcileRemoteConfig(testContext(), configURL, "", "", true, &sync.Mutex{}, &log)
+	}
+
+	result := reconcile()
+	require.NoError(t, result.Error)
+	applied := result.ConfigDigest
+	require.Equal(t, "debug", readConfigFile(t, filepath.Join(dir, "config.json")).AppConfig.LogLevel)
+	probation, ok := f.rollout.Probation()
+	require.True(t, ok)
+	require.Equal(t, applied, probation.Digest)
+	require.Empty(t, probation.PreviousDigest)
+
+	healthy = false
+	for i := 0; i < config.DefaultConfigRollbackFailures; i++ {
+		f.checkConfigProbation(testContext(), nil, &log)
+	}
+	require.False(t, f.rollout.OnProbation())
+	require.Empty(t, f.ConfigDigest())
+	require.NotEqual(t, "debug", readConfigFile(t, filepath.Join(dir, "config.json")).AppConfig.LogLevel)
+
+	rollback, pending := f.PendingConfigRollback()
+	require.True(t, pending)
+	require.Equal(t, applied, rollback.Digest)
+	require.Contains(t, rollback.Reason, "registry down")
+
+	// The rolled back config is not applied again
+	require.NoError(t, reconcile().Error)
+	require.Empty(t, f.ConfigDigest())
+	require.NotEqual(t, "debug", readConfigFile(t, filepath.Join(dir, "config.json")).AppConfig.LogLevel)
+
+	require.NoError(t, f.ConfigRollbackReported(rollback))
+	_, pending = f.PendingConfigRollback()
+	require.False(t, pending)
+	loaded, err := NewConfigRolloutStore(configRolloutPath(f.stateFilePath))
+	require.NoError(t, err)
+	require.True(t, loaded.Rejected(applied))
+}
+
+func TestCheckRegistryHealthy(t *testing.T) {
+	_, addr := newTestRegistry(t)
+	require.NoError(t, checkRegistryHealthy(testContext(), addr, true, "", authn.Anonymous))
+	require.NoError(t, checkReachable(testContext(), "http://"+addr))
+	require.Error(t, checkRegistryHealthy(testContext(), "", true, "", authn.Anonymous))
+
+	authAddr := newAuthTestRegistry(t, "admin", "secret")
+	require.NoError(t, checkRegistryHealthy(testContext(), authAddr, true, "", &authn.Basic{Username: "admin", Password: "secret"}))
+	require.Error(t, checkRegistryHealthy(testContext(), authAddr, true, "", &authn.Basic{Username: "admin", Password: "wrong"}), "rejected credentials are unhealthy")
+}
@@ -0,0 +1,186 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeadLetterFileName is the dead-letter list persisted next to state.json.
+const DeadLetterFileName = "dead_letter.json"
+
+// DeadLetterEntry is an entity that exhausted its retries. It is skipped by
+// regular syncs until NextRetryAt so that it cannot hold up its group.
+type DeadLetterEntry struct {
+	Group         string    `json:"group"`
+	Entity        Entity    `json:"entity"`
+	Error         string    `json:"error"`
+	Failures      int       `json:"failures"`
+	FirstFailedAt time.Time `json:"first_failed_at"`
+	LastFailedAt  time.Time `json:"last_failed_at"`
+	NextRetryAt   time.Time `json:"next_retry_at"`
+}
+
+// DeadLetterStore tracks dead-lettered entities per group and persists them
+// to disk. A store with an empty path is kept in memory only.
+type DeadLetterStore struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*DeadLetterEntry
+}
+
+// NewDeadLetterStore loads the dead-letter list from path. A missing file
+// yields an empty store.
+func NewDeadLetterStore(path string) (*DeadLetterStore, error) {
+	s := &DeadLetterStore{
+		path:    path,
+		entries: make(map[string]*DeadLetterEntry),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return s, fmt.Errorf("read dead-letter file: %w", err)
+	}
+
+	var entries []DeadLetterEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return s, fmt.Errorf("unmarshal dead-letter file: %w", err)
+	}
+	for i := range entries {
+		s.entries[deadLetterKey(entries[i].Group, entries[i].Entity)] = &entries[i]
+	}
+	return s, nil
+}
+
+// deadLetterPath returns the dead-letter file that belongs to stateFilePath.
+func deadLetterPath(stateFilePath string) string {
+	if stateFilePath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(stateFilePath), DeadLetterFileName)
+}
+
+func deadLetterKey(group string, e Entity) string {
+	return group + "|" + entityKey(e)
+}
+
+// Partition splits entities into those that may be replicated now and those
+// still waiting for their dead-letter retry. An entity whose digest changed
+// since it was dead-lettered is retried immediately.
+func (s *DeadLetterStore) Partition(group string, entities []Entity, now time.Time) (due, deferred []Entity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range entities {
+		entry, ok := s.entries[deadLetterKey(group, e)]
+		if ok && entry.Entity.Digest == e.Digest && now.Before(entry.NextRetryAt) {
+			deferred = append(deferred, e)
+			continue
+		}
+		due = append(due, e)
+	}
+	return due, deferred
+}
+
+// Apply records the outcome of a Replicate call: failed entities are added or
+// bumped on the list, replicated and skipped ones are removed from it.
+func (s *DeadLetterStore) Apply(group string, summary ReplicationSummary, retryAfter time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range summary.Results {
+		key := deadLetterKey(group, r.Entity)
+		if r.Status != EntityFailed {
+			delete(s.entries, key)
+			continue
+		}
+
+		entry, ok := s.entries[key]
+		if !ok || entry.Entity.Digest != r.Entity.Digest {
+			entry = &DeadLetterEntry{Group: group, Entity: r.Entity, FirstFailedAt: now}
+			s.entries[key] = entry
+		}
+		entry.Failures++
+		entry.LastFailedAt = now
+		entry.NextRetryAt = now.Add(retryAfter)
+		if r.Err != nil {
+			entry.Error = r.Err.Error()
+		}
+	}
+}
+
+// Retain drops entries of group that are no longer part of its desired
+// entities.
+func (s *DeadLetterStore) Retain(group string, desired []Entity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keep := make(map[string]struct{}, len(desired))
+	for _, e := range desired {
+		keep[deadLetterKey(group, e)] = struct{}{}
+	}
+	for key, entry := range s.entries {
+		if entry.Group != group {
+			continue
+		}
+		if _, ok := keep[key]; !ok {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// RetainGroups drops entries of groups the satellite no longer belongs to.
+func (s *DeadLetterStore) RetainGroups(groups []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, entry := range s.entries {
+		if !contains(groups, entry.Group) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Entries returns a copy of the dead-letter list ordered by group and entity.
+func (s *DeadLetterStore) Entries() []DeadLetterEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entriesUnlocked()
+}
+
+func (s *DeadLetterStore) entriesUnlocked() []DeadLetterEntry {
+	entries := make([]DeadLetterEntry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return deadLetterKey(entries[i].Group, entries[i].Entity) < deadLetterKey(entries[j].Group, entries[j].Entity)
+	})
+	return entries
+}
+
+// Save persists the dead-letter list. It is a no-op for in-memory stores.
+func (s *DeadLetterStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.entriesUnlocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal dead-letter list: %w", err)
+	}
+	return writeFileAtomic(s.path, data, "dead-letter-*.json.tmp")
+}
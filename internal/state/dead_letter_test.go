@@ -0,0 +1,117 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterStore_ApplyAndPartition(t *testing.T) {
+	store, err := NewDeadLetterStore("")
+	require.NoError(t, err)
+
+	now := time.Now()
+	group := "registry/satellite/group-state/edge/state:latest"
+	bad := Entity{Name: "bad", Repository: "library", Tag: "v1", Digest: "sha256:aaa"}
+	good := Entity{Name: "good", Repository: "library", Tag: "v1", Digest: "sha256:bbb"}
+
+	store.Apply(group, ReplicationSummary{Results: []EntityResult{
+		{Entity: bad, Status: EntityFailed, Err: errors.New("manifest unknown")},
+		{Entity: good, Status: EntityReplicated},
+	}}, time.Hour, now)
+
+	entries := store.Entries()
+	require.Len(t, entries, 1)
+	require.Equal(t, bad, entries[0].Entity)
+	require.Equal(t, 1, entries[0].Failures)
+	require.Equal(t, "manifest unknown", entries[0].Error)
+
+	due, deferred := store.Partition(group, []Entity{bad, good}, now.Add(time.Minute))
+	require.Equal(t, []Entity{good}, due)
+	require.Equal(t, []Entity{bad}, deferred)
+
+	// Once the retry interval elapses the entity is attempted again
+	due, deferred = store.Partition(group, []Entity{bad}, now.Add(2*time.Hour))
+	require.Equal(t, []Entity{bad}, due)
+	require.Empty(t, deferred)
+
+	// A new digest for the same tag bypasses the dead-letter delay
+	moved := bad
+	moved.Digest = "sha256:ccc"
+	due, _ = store.Partition(group, []Entity{moved}, now.Add(time.Minute))
+	require.Equal(t, []Entity{moved}, due)
+
+	// A later success clears the entry
+	store.Apply(group, ReplicationSummary{Results: []EntityResult{{Entity: bad, Status: EntityReplicated}}}, time.Hour, now)
+	require.Empty(t, store.Entries())
+}
+
+func TestDeadLetterStore_RepeatedFailuresAccumulate(t *testing.T) {
+	store, err := NewDeadLetterStore("")
+	require.NoError(t, err)
+
+	first := time.Now()
+	bad := Entity{Name: "bad", Repository: "library", Tag: "v1", Digest: "sha256:aaa"}
+	failed := ReplicationSummary{Results: []EntityResult{{Entity: bad, Status: EntityFailed}}}
+
+	store.Apply("group", failed, time.Minute, first)
+	store.Apply("group", failed, time.Minute, first.Add(time.Hour))
+
+	entries := store.Entries()
+	require.Len(t, entries, 1)
+	require.Equal(t, 2, entries[0].Failures)
+	require.Equal(t, first, entries[0].FirstFailedAt)
+	require.Equal(t, first.Add(time.Hour+time.Minute), entries[0].NextRetryAt)
+}
+
+func TestDeadLetterStore_Retain(t *testing.T) {
+	store, err := NewDeadLetterStore("")
+	require.NoError(t, err)
+
+	a := Entity{Name: "a", Tag: "v1"}
+	b := Entity{Name: "b", Tag: "v1"}
+	store.Apply("g1", ReplicationSummary{Results: []EntityResult{
+		{Entity: a, Status: EntityFailed},
+		{Entity: b, Status: EntityFailed},
+	}}, time.Hour, time.Now())
+	store.Apply("g2", ReplicationSummary{Results: []EntityResult{{Entity: a, Status: EntityFailed}}}, time.Hour, time.Now())
+
+	store.Retain("g1", []Entity{a})
+	require.Len(t, store.Entries(), 2)
+
+	store.RetainGroups([]string{"g1"})
+	entries := store.Entries()
+	require.Len(t, entries, 1)
+	require.Equal(t, "g1", entries[0].Group)
+	require.Equal(t, a, entries[0].Entity)
+}
+
+func TestDeadLetterStore_PersistRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := deadLetterPath(filepath.Join(dir, "state.json"))
+	require.Equal(t, filepath.Join(dir, DeadLetterFileName), path)
+
+	store, err := NewDeadLetterStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Save())
+
+	bad := Entity{Name: "bad", Repository: "library", Tag: "v1", Digest: "sha256:aaa"}
+	store.Apply("group", ReplicationSummary{Results: []EntityResult{{Entity: bad, Status: EntityFailed}}}, time.Hour, time.Now())
+	require.NoError(t, store.Save())
+
+	loaded, err := NewDeadLetterStore(path)
+	require.NoError(t, err)
+	entries := loaded.Entries()
+	require.Len(t, entries, 1)
+	require.Equal(t, bad, entries[0].Entity)
+
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+	corrupted, err := NewDeadLetterStore(path)
+	require.Error(t, err)
+	require.NotNil(t, corrupted)
+	require.Empty(t, corrupted.Entries())
+}
@@ -0,0 +1,177 @@
+package state
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	runtime "github.com/container-registry/harbor-satellite/internal/container_runtime"
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+// runningImages lists the images of running containers. It is a variable so
+// that tests can stub the host's container runtimes.
+var runningImages = runtime.RunningImages
+
+// deletionRun is the deletion budget and the in-use snapshot shared by the
+// groups of one sync. The container runtimes are only queried if some
+// tombstone is due.
+type deletionRun struct {
+	ignoreInUse bool
+
+	mu        sync.Mutex
+	remaining int
+
+	once     sync.Once
+	inUse    []runtime.RunningImage
+	inUseErr error
+}
+
+func newDeletionRun(cfg config.DeletionConfig) *deletionRun {
+	limit := cfg.MaxDeletionsPerSync
+	if limit <= 0 {
+		limit = config.DefaultMaxDeletionsPerSync
+	}
+	return &deletionRun{ignoreInUse: cfg.IgnoreInUse, remaining: limit}
+}
+
+// take reserves one deletion from the budget of the sync.
+func (d *deletionRun) take() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.remaining <= 0 {
+		return false
+	}
+	d.remaining--
+	return true
+}
+
+// hold returns why e must not be deleted because of running containers, or
+// an empty string if it may be. If the runtimes cannot be queried nothing is
+// deleted.
+func (d *deletionRun) hold(ctx context.Context, e Entity) string {
+	if d.ignoreInUse {
+		return ""
+	}
+	d.once.Do(func() {
+		d.inUse, d.inUseErr = runningImages(ctx)
+	})
+	if d.inUseErr != nil {
+		return HoldInUseUnknown
+	}
+	for _, img := range d.inUse {
+		if imageMatches(img, e) {
+			return HoldInUse
+		}
+	}
+	return ""
+}
+
+// imageMatches reports whether a running image is the given entity, by
+// digest or, for entities without one, by repository and tag. Runtimes know
+// images by the upstream name they were pulled as, so only the path suffix
+// is compared.
+func imageMatches(img runtime.RunningImage, e Entity) bool {
+	if e.Digest != "" {
+		for _, rd := range img.RepoDigests {
+			if strings.HasSuffix(rd, "@"+e.Digest) {
+				return true
+			}
+		}
+	}
+	ref := e.Repository + "/" + e.Name + ":" + e.Tag
+	for _, tag := range img.RepoTags {
+		if tag == ref || strings.HasSuffix(tag, "/"+ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// entityReferences reports whether refs still want the tag of e, and whether
+// another tag in the same repository may point at the manifest of e. Without
+// digests on both sides sharing cannot be ruled out and is assumed.
+func entityReferences(e Entity, refs []Entity) (tagWanted, manifestShared bool) {
+	for _, r := range refs {
+		if r.Repository != e.Repository || r.Name != e.Name {
+			continue
+		}
+		if r.Tag == e.Tag {
+			return true, true
+		}
+		if e.Digest == "" || r.Digest == "" || r.Digest == e.Digest {
+			manifestShared = true
+		}
+	}
+	return false, manifestShared
+}
+
+// retireEntities tombstones the entities that left the group state and
+// deletes the tombstones whose grace period is over. Images used by running
+// containers, and deletions beyond the per-sync limit, are kept for a later
+// sync.
+//
+// Deletions are resolved against others, the entities the other groups want,
+// and against pending tombstones: a tag still wanted elsewhere is kept, and a
+// manifest still referenced by another tag is only untagged.
+func (f *FetchAndReplicateStateProcess) retireEntities(ctx context.Context, group string, removed, desired, others []Entity, replicator Replicator, run *deletionRun, log *zerolog.Logger) {
+	now := time.Now()
+	grace := parseDurationOr(f.cm.GetDeletionConfig().GracePeriod, config.DefaultDeletionGracePeriod)
+
+	f.tombstones.Revive(group, desired)
+	f.tombstones.Bury(group, removed, grace, now)
+	if len(removed) > 0 {
+		log.Info().Int("tombstoned", len(removed)).Dur("grace_period", grace).Msg("Tombstoned entities removed from group state")
+	}
+
+	refs := append(append([]Entity{}, desired...), others...)
+	for _, t := range f.tombstones.Pending(now) {
+		refs = append(refs, t.Entity)
+	}
+
+	limited := 0
+	for _, t := range f.tombstones.Due(group, now) {
+		if ctx.Err() != nil {
+			break
+		}
+		tagWanted, shared := entityReferences(t.Entity, refs)
+		if tagWanted {
+			log.Info().Msgf("Image %s:%s is still wanted by another group, keeping it", t.Entity.Name, t.Entity.Tag)
+			f.tombstones.Remove(group, t.Entity)
+			continue
+		}
+		if reason := run.hold(ctx, t.Entity); reason != "" {
+			if t.Hold != reason {
+				log.Warn().Str("reason", reason).Msgf("Keeping image %s:%s past its grace period", t.Entity.Name, t.Entity.Tag)
+			}
+			f.tombstones.SetHold(group, t.Entity, reason)
+			continue
+		}
+		if !run.take() {
+			f.tombstones.SetHold(group, t.Entity, HoldDeletionLimit)
+			limited++
+			continue
+		}
+		remove := replicator.DeleteReplicationEntity
+		if shared {
+			remove = replicator.UntagReplicationEntity
+		}
+		if err := remove(ctx, []Entity{t.Entity}); err != nil {
+			log.Error().Err(err).Msgf("Failed to delete image %s:%s", t.Entity.Name, t.Entity.Tag)
+			f.tombstones.SetHold(group, t.Entity, HoldDeleteFailed)
+			continue
+		}
+		metrics.ImagesDeleted.WithLabelValues(f.name, groupLabel(group)).Inc()
+		f.tombstones.Remove(group, t.Entity)
+	}
+	if limited > 0 {
+		log.Warn().Int("kept", limited).Msg("Deletion limit for this sync reached, remaining tombstones wait for the next sync")
+	}
+
+	if err := f.tombstones.Save(); err != nil {
+		log.Warn().Err(err).Msg("Failed to persist tombstones to disk")
+	}
+}
@@ -0,0 +1,253 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	runtime "github.com/container-registry/harbor-satellite/internal/container_runtime"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// deleteRecorder is a Replicator that records deletions.
+type deleteRecorder struct {
+	mu       sync.Mutex
+	deleted  []Entity
+	untagged []Entity
+	fail     map[string]bool
+}
+
+func (d *deleteRecorder) Replicate(context.Context, []Entity) (ReplicationSummary, error) {
+	return ReplicationSummary{}, nil
+}
+
+func (d *deleteRecorder) DeleteReplicationEntity(_ context.Context, entities []Entity) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range entities {
+		if d.fail[e.Name] {
+			return errors.New("registry unavailable")
+		}
+		d.deleted = append(d.deleted, e)
+	}
+	return nil
+}
+
+func (d *deleteRecorder) UntagReplicationEntity(_ context.Context, entities []Entity) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.untagged = append(d.untagged, entities...)
+	return nil
+}
+
+func stubRunningImages(t *testing.T, images []runtime.RunningImage, err error) {
+	t.Helper()
+	orig := runningImages
+	runningImages = func(context.Context) ([]runtime.RunningImage, error) { return images, err }
+	t.Cleanup(func() { runningImages = orig })
+}
+
+func newDeletionTestProcess(t *testing.T, deletion config.DeletionConfig) *FetchAndReplicateStateProcess {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		StateConfig: config.StateConfig{StateURL: "http://registry/satellite/satellite-state/test-sat/state:latest"},
+		AppConfig: config.AppConfig{
+			GroundControlURL: "http://127.0.0.1:8080",
+			UseUnsecure:      true,
+			Deletion:         deletion,
+		},
+		ZotConfigRaw: json.RawMessage(`{}`),
+	}
+	cm, err := config.NewConfigManager(filepath.Join(dir, "config.json"), filepath.Join(dir, "prev.json"), "token", "http://127.0.0.1:8080", false, cfg)
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	return NewFetchAndReplicateStateProcess(cm, filepath.Join(dir, "state.json"), &log)
+}
+
+func retire(f *FetchAndReplicateStateProcess, removed, desired []Entity, r Replicator, run *deletionRun) {
+	log := zerolog.Nop()
+	f.retireEntities(context.Background(), "group", removed, desired, nil, r, run, &log)
+}
+
+var (
+	appV1 = Entity{Name: "app", Repository: "library", Tag: "v1", Digest: "sha256:111"}
+	dbV1  = Entity{Name: "db", Repository: "library", Tag: "v1", Digest: "sha256:222"}
+)
+
+func TestRetireEntities_GracePeriod(t *testing.T) {
+	stubRunningImages(t, nil, nil)
+	f := newDeletionTestProcess(t, config.DeletionConfig{GracePeriod: "1h"})
+	r := &deleteRecorder{}
+
+	retire(f, []Entity{appV1}, nil, r, newDeletionRun(f.cm.GetDeletionConfig()))
+	require.Empty(t, r.deleted, "deleted within the grace period")
+	require.Len(t, f.tombstones.Entries(), 1)
+
+	// Once the grace period is over, the next sync deletes it.
+	f.tombstones.entries[deadLetterKey("group", appV1)].PurgeAfter = time.Now().Add(-time.Second)
+	retire(f, nil, nil, r, newDeletionRun(f.cm.GetDeletionConfig()))
+	require.Equal(t, []Entity{appV1}, r.deleted)
+	require.Empty(t, f.tombstones.Entries())
+}
+
+func TestRetireEntities_RevivedWithinGracePeriod(t *testing.T) {
+	stubRunningImages(t, nil, nil)
+	f := newDeletionTestProcess(t, config.DeletionConfig{GracePeriod: "1h"})
+	r := &deleteRecorder{}
+
+	retire(f, []Entity{appV1}, nil, r, newDeletionRun(f.cm.GetDeletionConfig()))
+	retire(f, nil, []Entity{appV1}, r, newDeletionRun(f.cm.GetDeletionConfig()))
+	require.Empty(t, f.tombstones.Entries())
+	require.Empty(t, r.deleted)
+}
+
+func TestRetireEntities_InUseProtection(t *testing.T) {
+	f := newDeletionTestProcess(t, config.DeletionConfig{GracePeriod: "0s"})
+	r := &deleteRecorder{}
+
+	stubRunningImages(t, []runtime.RunningImage{{ID: "sha256:cfg", RepoDigests: []string{"harbor.example.com/library/app@sha256:111"}}}, nil)
+	retire(f, []Entity{appV1, dbV1}, nil, r, newDeletionRun(f.cm.GetDeletionConfig()))
+	require.Equal(t, []Entity{dbV1}, r.deleted)
+	entries := f.tombstones.Entries()
+	require.Len(t, entries, 1)
+	require.Equal(t, HoldInUse, entries[0].Hold)
+
+	// A runtime that cannot be queried keeps everything.
+	stubRunningImages(t, nil, errors.New("crictl failed"))
+	retire(f, nil, nil, r, newDeletionRun(f.cm.GetDeletionConfig()))
+	require.Equal(t, HoldInUseUnknown, f.tombstones.Entries()[0].Hold)
+	require.Len(t, r.deleted, 1)
+
+	// The container stopped.
+	stubRunningImages(t, nil, nil)
+	retire(f, nil, nil, r, newDeletionRun(f.cm.GetDeletionConfig()))
+	require.Equal(t, []Entity{dbV1, appV1}, r.deleted)
+}
+
+func TestRetireEntities_IgnoreInUse(t *testing.T) {
+	stubRunningImages(t, nil, errors.New("must not be queried"))
+	f := newDeletionTestProcess(t, config.DeletionConfig{GracePeriod: "0s", IgnoreInUse: true})
+	r := &deleteRecorder{}
+
+	retire(f, []Entity{appV1}, nil, r, newDeletionRun(f.cm.GetDeletionConfig()))
+	require.Equal(t, []Entity{appV1}, r.deleted)
+}
+
+func TestRetireEntities_DeletionLimitIsSharedAcrossGroups(t *testing.T) {
+	stubRunningImages(t, nil, nil)
+	f := newDeletionTestProcess(t, config.DeletionConfig{GracePeriod: "0s", MaxDeletionsPerSync: 1})
+	r := &deleteRecorder{}
+	run := newDeletionRun(f.cm.GetDeletionConfig())
+	log := zerolog.Nop()
+
+	f.retireEntities(context.Background(), "group-a", []Entity{appV1}, nil, nil, r, run, &log)
+	f.retireEntities(context.Background(), "group-b", []Entity{dbV1}, nil, nil, r, run, &log)
+	require.Len(t, r.deleted, 1)
+	entries := f.tombstones.Entries()
+	require.Len(t, entries, 1)
+	require.Equal(t, HoldDeletionLimit, entries[0].Hold)
+
+	// The next sync has a fresh budget.
+	f.retireEntities(context.Background(), "group-b", nil, nil, nil, r, newDeletionRun(f.cm.GetDeletionConfig()), &log)
+	require.Len(t, r.deleted, 2)
+	require.Empty(t, f.tombstones.Entries())
+}
+
+func TestRetireEntities_FailedDeleteIsKept(t *testing.T) {
+	stubRunningImages(t, nil, nil)
+	f := newDeletionTestProcess(t, config.DeletionConfig{GracePeriod: "0s"})
+	r := &deleteRecorder{fail: map[string]bool{"app": true}}
+
+	retire(f, []Entity{appV1}, nil, r, newDeletionRun(f.cm.GetDeletionConfig()))
+	entries := f.tombstones.Entries()
+	require.Len(t, entries, 1)
+	require.Equal(t, HoldDeleteFailed, entries[0].Hold)
+}
+
+func TestRetireEntities_SharedAcrossGroups(t *testing.T) {
+	stubRunningImages(t, nil, nil)
+	f := newDeletionTestProcess(t, config.DeletionConfig{GracePeriod: "0s"})
+	r := &deleteRecorder{}
+	log := zerolog.Nop()
+	run := newDeletionRun(f.cm.GetDeletionConfig())
+	appLatest := Entity{Name: "app", Repository: "library", Tag: "latest", Digest: appV1.Digest}
+
+	// Another group still wants the same tag: nothing is touched.
+	f.retireEntities(context.Background(), "group-a", []Entity{appV1}, nil, []Entity{appV1}, r, run, &log)
+	require.Empty(t, r.deleted)
+	require.Empty(t, r.untagged)
+	require.Empty(t, f.tombstones.Entries())
+
+	// Another tag of the same manifest is wanted: only the tag goes.
+	f.retireEntities(context.Background(), "group-a", []Entity{appV1}, nil, []Entity{appLatest}, r, run, &log)
+	require.Empty(t, r.deleted)
+	require.Equal(t, []Entity{appV1}, r.untagged)
+
+	// A different manifest in the same repository does not keep it.
+	appV2 := Entity{Name: "app", Repository: "library", Tag: "v2", Digest: "sha256:333"}
+	f.retireEntities(context.Background(), "group-a", []Entity{appLatest}, []Entity{appV2}, nil, r, run, &log)
+	require.Equal(t, []Entity{appLatest}, r.deleted)
+}
+
+func TestEntitiesOfOtherGroups_UsesStatesFetchedThisRun(t *testing.T) {
+	f := newDeletionTestProcess(t, config.DeletionConfig{})
+	f.stateMap = NewStateMap([]string{"group-a", "group-b", "group-c"})
+	// On disk, group-a holds app and the others hold nothing yet.
+	f.stateMap[0].Entities = []Entity{appV1}
+	f.stateMap[2].Entities = []Entity{dbV1}
+
+	// This run moved app from group-a to group-b; group-c failed to fetch.
+	fetched := map[int][]Entity{0: nil, 1: {appV1}}
+
+	require.Equal(t, []Entity{appV1, dbV1}, f.entitiesOfOtherGroups(0, fetched),
+		"group-a must see that group-b now wants app before it deletes it")
+	require.Equal(t, []Entity{dbV1}, f.entitiesOfOtherGroups(1, fetched))
+}
+
+func TestRetireEntities_PendingTombstoneKeepsManifest(t *testing.T) {
+	stubRunningImages(t, nil, nil)
+	f := newDeletionTestProcess(t, config.DeletionConfig{GracePeriod: "0s"})
+	r := &deleteRecorder{}
+	log := zerolog.Nop()
+
+	// group-b removed the same manifest under another tag and is still
+	// within its grace period.
+	appLatest := Entity{Name: "app", Repository: "library", Tag: "latest", Digest: appV1.Digest}
+	f.tombstones.Bury("group-b", []Entity{appLatest}, time.Hour, time.Now())
+
+	f.retireEntities(context.Background(), "group-a", []Entity{appV1}, nil, nil, r, newDeletionRun(f.cm.GetDeletionConfig()), &log)
+	require.Empty(t, r.deleted)
+	require.Equal(t, []Entity{appV1}, r.untagged)
+}
+
+func TestEntityReferences(t *testing.T) {
+	noDigest := Entity{Name: "app", Repository: "library", Tag: "v2"}
+	other := Entity{Name: "app", Repository: "library", Tag: "v2", Digest: "sha256:999"}
+	otherRepo := Entity{Name: "app", Repository: "team", Tag: "v1", Digest: appV1.Digest}
+
+	tagWanted, shared := entityReferences(appV1, []Entity{appV1})
+	require.True(t, tagWanted)
+	require.True(t, shared)
+
+	tagWanted, shared = entityReferences(appV1, []Entity{other, otherRepo})
+	require.False(t, tagWanted)
+	require.False(t, shared)
+
+	_, shared = entityReferences(appV1, []Entity{noDigest})
+	require.True(t, shared, "an unknown digest may be the same manifest")
+}
+
+func TestImageMatches(t *testing.T) {
+	require.True(t, imageMatches(runtime.RunningImage{RepoDigests: []string{"reg/library/app@sha256:111"}}, appV1))
+	require.True(t, imageMatches(runtime.RunningImage{RepoTags: []string{"reg.example.com/library/app:v1"}}, appV1))
+	require.True(t, imageMatches(runtime.RunningImage{RepoTags: []string{"library/app:v1"}}, appV1))
+	require.False(t, imageMatches(runtime.RunningImage{RepoTags: []string{"reg/mylibrary/app:v1"}}, appV1))
+	require.False(t, imageMatches(runtime.RunningImage{RepoDigests: []string{"reg/library/app@sha256:999"}}, appV1))
+}
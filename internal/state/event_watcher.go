@@ -0,0 +1,224 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/scheduler"
+	"github.com/container-registry/harbor-satellite/internal/spiffe"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+const GroundControlEventsRoute = "satellites/events"
+
+const (
+	// eventWait is how long Ground Control holds a subscription open. It
+	// stays below the HTTP client timeout.
+	eventWait = 20 * time.Second
+	// defaultEventTriggerRetry is the delay before a sync requested by an
+	// event is retried because another sync was still running.
+	defaultEventTriggerRetry = 5 * time.Second
+)
+
+var errNotRegistered = errors.New("satellite is not registered with Ground Control yet")
+
+// GroundControlEvent is a state change notification sent by Ground Control.
+type GroundControlEvent struct {
+	Seq    uint64    `json:"seq"`
+	Type   string    `json:"type"`
+	Group  string    `json:"group,omitempty"`
+	Config string    `json:"config,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+type eventBatch struct {
+	Epoch  string               `json:"epoch"`
+	Cursor uint64               `json:"cursor"`
+	Events []GroundControlEvent `json:"events"`
+	Resync bool                 `json:"resync"`
+}
+
+// EventWatcher subscribes to Ground Control's state change notifications and
+// starts a sync as soon as one arrives. Scheduled syncs keep running
+// alongside it, so changes are still picked up while it is disconnected.
+type EventWatcher struct {
+	cm           *config.ConfigManager
+	trigger      func(ctx context.Context) error
+	spiffeClient *spiffe.Client
+	policy       RetryPolicy
+	triggerRetry time.Duration
+	epoch        string
+	cursor       uint64
+}
+
+// NewEventWatcher creates a watcher calling trigger for every batch of
+// events. trigger may return scheduler.ErrAlreadyRunning, in which case the
+// watcher retries until the running sync has finished.
+func NewEventWatcher(cm *config.ConfigManager, trigger func(ctx context.Context) error) *EventWatcher {
+	w := &EventWatcher{
+		cm:      cm,
+		trigger: trigger,
+		policy: RetryPolicy{
+			InitialBackoff: time.Second,
+			MaxBackoff:     2 * time.Minute,
+		},
+		triggerRetry: defaultEventTriggerRetry,
+	}
+
+	if cm.IsSPIFFEEnabled() {
+		spiffeCfg := cm.GetSPIFFEConfig()
+		client, err := spiffe.NewClient(spiffe.Config{
+			Enabled:          spiffeCfg.Enabled,
+			EndpointSocket:   spiffeCfg.EndpointSocket,
+			ExpectedServerID: spiffeCfg.ExpectedServerID,
+		})
+		if err == nil {
+			w.spiffeClient = client
+		}
+	}
+
+	return w
+}
+
+// Run subscribes until ctx is done, reconnecting with exponential backoff.
+func (w *EventWatcher) Run(ctx context.Context) {
+	log := logger.FromContext(ctx).With().Str("component", "event_watcher").Logger()
+
+	failures := 0
+	for ctx.Err() == nil {
+		batch, err := w.poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			failures++
+			delay := w.policy.backoff(failures)
+			if errors.Is(err, errNotRegistered) {
+				log.Debug().Err(err).Dur("retry_in", delay).Msg("Waiting for registration before subscribing to Ground Control events")
+			} else {
+				log.Warn().Err(err).Dur("retry_in", delay).Msg("Ground Control event subscription failed, relying on scheduled syncs")
+			}
+			sleepContext(ctx, delay)
+			continue
+		}
+
+		if failures > 0 {
+			log.Info().Msg("Subscribed to Ground Control events again")
+		}
+		failures = 0
+		w.handle(ctx, batch, &log)
+	}
+}
+
+// handle starts a sync for a batch carrying events or asking for a resync
+// and advances the cursor.
+func (w *EventWatcher) handle(ctx context.Context, batch eventBatch, log *zerolog.Logger) {
+	if batch.Resync || len(batch.Events) > 0 {
+		log.Info().
+			Int("events", len(batch.Events)).
+			Bool("resync", batch.Resync).
+			Msg("Ground Control reported state changes, starting sync")
+		if err := w.triggerSync(ctx); err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("Failed to start sync for Ground Control events")
+		}
+	}
+	w.epoch = batch.Epoch
+	w.cursor = batch.Cursor
+}
+
+// triggerSync starts a sync, waiting for a running one to finish first so
+// that it does not miss a change it had already fetched state for.
+func (w *EventWatcher) triggerSync(ctx context.Context) error {
+	for {
+		err := w.trigger(ctx)
+		if !errors.Is(err, scheduler.ErrAlreadyRunning) {
+			return err
+		}
+		if !sleepContext(ctx, w.triggerRetry) {
+			return ctx.Err()
+		}
+	}
+}
+
+// poll waits for the next batch of events from Ground Control.
+func (w *EventWatcher) poll(ctx context.Context) (eventBatch, error) {
+	stateURL := w.cm.GetStateURL()
+	if stateURL == "" || !w.cm.IsZTRDone() {
+		return eventBatch{}, errNotRegistered
+	}
+	satelliteName, err := extractSatelliteNameFromURL(stateURL)
+	if err != nil {
+		return eventBatch{}, err
+	}
+
+	query := url.Values{}
+	query.Set("satellite", satelliteName)
+	query.Set("wait", eventWait.String())
+	if w.epoch != "" {
+		query.Set("epoch", w.epoch)
+		query.Set("cursor", strconv.FormatUint(w.cursor, 10))
+	}
+	eventsURL := fmt.Sprintf("%s/%s?%s", w.cm.ResolveGroundControlURL(), GroundControlEventsRoute, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, eventsURL, nil)
+	if err != nil {
+		return eventBatch{}, fmt.Errorf("create request: %w", err)
+	}
+
+	var client *http.Client
+	if w.spiffeClient != nil {
+		if err := w.spiffeClient.Connect(ctx); err != nil {
+			return eventBatch{}, fmt.Errorf("connect to SPIRE agent: %w", err)
+		}
+		client, err = w.spiffeClient.CreateHTTPClient()
+		if err != nil {
+			return eventBatch{}, fmt.Errorf("create SPIFFE HTTP client: %w", err)
+		}
+	} else {
+		client, err = createHTTPClient(w.cm.GetTLSConfig(), w.cm.UseUnsecure())
+		if err != nil {
+			return eventBatch{}, fmt.Errorf("create HTTP client: %w", err)
+		}
+		req.SetBasicAuth(w.cm.GetSourceRegistryUsername(), w.cm.GetSourceRegistryPassword())
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return eventBatch{}, fmt.Errorf("send request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.FromContext(ctx).Warn().Err(err).Msg("error closing response body")
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return eventBatch{}, fmt.Errorf("event subscription failed: %s", resp.Status)
+	}
+
+	var batch eventBatch
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return eventBatch{}, fmt.Errorf("decode events: %w", err)
+	}
+	return batch, nil
+}
+
+// sleepContext waits for d and reports whether it did so before ctx was done.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,180 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/scheduler"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func newEventWatcherConfig(t *testing.T, groundControlURL string) *config.ConfigManager {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		StateConfig: config.StateConfig{
+			RegistryCredentials: config.RegistryCredentials{
+				Username: "robot$edge-01",
+				Password: "s3cret",
+			},
+			StateURL: "registry.example.com/satellite/satellite-state/edge-01/state:latest",
+		},
+		AppConfig: config.AppConfig{
+			GroundControlURL:    config.URL(groundControlURL),
+			GroundControlEvents: true,
+		},
+	}
+	cm, err := config.NewConfigManager(filepath.Join(dir, "config.json"), filepath.Join(dir, "prev.json"), "token", groundControlURL, false, cfg)
+	require.NoError(t, err)
+	return cm
+}
+
+func TestEventWatcher_TriggersSyncOnEvents(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []*http.Request
+	)
+	batches := []eventBatch{
+		{Epoch: "e1", Cursor: 3, Events: []GroundControlEvent{}},
+		{Epoch: "e1", Cursor: 5, Events: []GroundControlEvent{{Seq: 5, Type: "group_state", Group: "edge"}}},
+		{Epoch: "e1", Cursor: 5, Events: []GroundControlEvent{}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		n := len(requests)
+		requests = append(requests, r)
+		mu.Unlock()
+		if n >= len(batches) {
+			<-r.Context().Done()
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(batches[n]))
+	}))
+	defer srv.Close()
+
+	triggered := make(chan struct{}, 4)
+	w := NewEventWatcher(newEventWatcherConfig(t, srv.URL), func(context.Context) error {
+		triggered <- struct{}{}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-triggered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sync was not triggered")
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(requests) > len(batches)
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	require.Len(t, triggered, 0, "only the batch with events triggers a sync")
+
+	mu.Lock()
+	defer mu.Unlock()
+	user, pass, ok := requests[0].BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "robot$edge-01", user)
+	require.Equal(t, "s3cret", pass)
+	require.Equal(t, "/satellites/events", requests[0].URL.Path)
+	require.Equal(t, "edge-01", requests[0].URL.Query().Get("satellite"))
+	require.Empty(t, requests[0].URL.Query().Get("epoch"))
+
+	require.Equal(t, "e1", requests[1].URL.Query().Get("epoch"))
+	require.Equal(t, "3", requests[1].URL.Query().Get("cursor"))
+	require.Equal(t, "5", requests[2].URL.Query().Get("cursor"))
+}
+
+func TestEventWatcher_ResyncTriggersSync(t *testing.T) {
+	w := NewEventWatcher(newEventWatcherConfig(t, "http://127.0.0.1:1"), nil)
+	var calls int
+	w.trigger = func(context.Context) error {
+		calls++
+		return nil
+	}
+
+	log := zerolog.Nop()
+	w.handle(context.Background(), eventBatch{Epoch: "e2", Cursor: 9, Resync: true}, &log)
+	require.Equal(t, 1, calls)
+	require.Equal(t, "e2", w.epoch)
+	require.Equal(t, uint64(9), w.cursor)
+
+	w.handle(context.Background(), eventBatch{Epoch: "e2", Cursor: 9}, &log)
+	require.Equal(t, 1, calls)
+}
+
+func TestEventWatcher_RetriesWhileSyncRunning(t *testing.T) {
+	w := NewEventWatcher(newEventWatcherConfig(t, "http://127.0.0.1:1"), nil)
+	w.triggerRetry = time.Millisecond
+	var calls int
+	w.trigger = func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return scheduler.ErrAlreadyRunning
+		}
+		return nil
+	}
+
+	require.NoError(t, w.triggerSync(context.Background()))
+	require.Equal(t, 3, calls)
+}
+
+func TestEventWatcher_ReconnectsAfterFailure(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			require.NoError(t, json.NewEncoder(w).Encode(eventBatch{Epoch: "e1", Resync: true}))
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	triggered := make(chan struct{}, 1)
+	w := NewEventWatcher(newEventWatcherConfig(t, srv.URL), func(context.Context) error {
+		triggered <- struct{}{}
+		return nil
+	})
+	w.policy = RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	select {
+	case <-triggered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sync was not triggered after reconnecting")
+	}
+}
+
+func TestEventWatcher_WaitsForRegistration(t *testing.T) {
+	cm := newEventWatcherConfig(t, "http://127.0.0.1:1")
+	cm.With(config.SetStateURL(""))
+
+	w := NewEventWatcher(cm, nil)
+	_, err := w.poll(context.Background())
+	require.ErrorIs(t, err, errNotRegistered)
+}
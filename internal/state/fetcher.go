@@ -35,6 +35,7 @@ type URLStateFetcher struct {
 	insecure  bool
 	useHTTP   bool
 	tlsCfg    config.TLSConfig
+	verifier  *StateVerifier
 }
 
 func NewURLStateFetcher(stateURL, userName, password string, insecure bool) StateFetcher {
@@ -88,7 +89,7 @@ func (f *URLStateFetcher) fetchSatelliteState(ctx context.Context, state *Satell
 	if err != nil {
 		return err
 	}
-	return f.extractArtifactJSON(f.url, img, state, log)
+	return decodeStateArtifact(f.url, img, state, f.verifier, log)
 }
 
 func (f *URLStateFetcher) fetchGroupState(ctx context.Context, state *State, log *zerolog.Logger) error {
@@ -97,7 +98,7 @@ func (f *URLStateFetcher) fetchGroupState(ctx context.Context, state *State, log
 	if err != nil {
 		return err
 	}
-	return f.extractArtifactJSON(f.url, img, state, log)
+	return decodeStateArtifact(f.url, img, state, f.verifier, log)
 }
 
 func (f *URLStateFetcher) fetchConfigState(ctx context.Context, config *config.Config, log *zerolog.Logger) error {
@@ -106,7 +107,12 @@ func (f *URLStateFetcher) fetchConfigState(ctx context.Context, config *config.C
 	if err != nil {
 		return err
 	}
-	return f.extractArtifactJSON(f.url, img, config, log)
+	return decodeStateArtifact(f.url, img, config, f.verifier, log)
+}
+
+// SetVerifier makes the fetcher refuse state artifacts the verifier rejects.
+func (f *URLStateFetcher) SetVerifier(verifier *StateVerifier) {
+	f.verifier = verifier
 }
 
 func (f *URLStateFetcher) FetchDigest(ctx context.Context, log *zerolog.Logger) (string, error) {
@@ -190,13 +196,21 @@ func (f *URLStateFetcher) buildTLSTransport() (http.RoundTripper, error) {
 	}, nil
 }
 
-func (f *URLStateFetcher) extractArtifactJSON(url string, img v1.Image, out any, log *zerolog.Logger) error {
+func extractArtifactJSON(url string, img v1.Image, out any, log *zerolog.Logger) error {
+	data, err := readArtifactJSON(url, img, log)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func readArtifactJSON(url string, img v1.Image, log *zerolog.Logger) ([]byte, error) {
 	log.Debug().Msgf("Extracting artifacts.json from the state artifact: %s", url)
 
 	tarContent := new(bytes.Buffer)
 	if err := crane.Export(img, tarContent); err != nil {
 		log.Error().Msgf("Error exporting the fs contents of the state artifact: %s", url)
-		return fmt.Errorf("failed to export the state artifact: %v", err)
+		return nil, fmt.Errorf("failed to export the state artifact: %v", err)
 	}
 
 	tr := tar.NewReader(tarContent)
@@ -207,20 +221,20 @@ func (f *URLStateFetcher) extractArtifactJSON(url string, img v1.Image, out any,
 		}
 		if err != nil {
 			log.Error().Msgf("Failed to read the tar archive of the state artifact: %s", url)
-			return fmt.Errorf("failed to read the tar archive: %v", err)
+			return nil, fmt.Errorf("failed to read the tar archive: %v", err)
 		}
 
 		if hdr.Name == "artifacts.json" {
 			artifactsJSON, err := io.ReadAll(tr)
 			if err != nil {
 				log.Error().Msgf("Failed to read the artifacts.json of the state artifact: %s", url)
-				return fmt.Errorf("failed to read the artifacts.json file: %v", err)
+				return nil, fmt.Errorf("failed to read the artifacts.json file: %v", err)
 			}
-			return json.Unmarshal(artifactsJSON, out)
+			return artifactsJSON, nil
 		}
 	}
 	log.Error().Msgf("artifacts.json not present for the state artifact: %s", url)
-	return fmt.Errorf("artifacts.json not found in the state artifact")
+	return nil, fmt.Errorf("artifacts.json not found in the state artifact")
 }
 
 func FromJSON(data []byte, reg StateReader) (StateReader, error) {
@@ -0,0 +1,71 @@
+package state
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnknownGroup is returned by a run restricted to a group the satellite
+// does not belong to.
+var ErrUnknownGroup = errors.New("satellite is not a member of the group")
+
+type groupFilterKey struct{}
+
+// WithGroup restricts a run of the state replication process to one group,
+// given by name or by state URL. Such a run leaves the other groups and the
+// remote config alone.
+func WithGroup(ctx context.Context, group string) context.Context {
+	return context.WithValue(ctx, groupFilterKey{}, group)
+}
+
+func groupFilter(ctx context.Context) string {
+	group, _ := ctx.Value(groupFilterKey{}).(string)
+	return group
+}
+
+// groupMatches reports whether the group state URL is the group given by name
+// or URL.
+func groupMatches(stateURL, group string) bool {
+	return stateURL == group || groupNameFromStateURL(stateURL) == group
+}
+
+// Groups returns the names of the groups the satellite replicated on its last
+// run, or their state URLs where the URL does not carry a name.
+func (f *FetchAndReplicateStateProcess) Groups() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.groups...)
+}
+
+// HasGroup reports whether the satellite belongs to the group given by name or
+// state URL.
+func (f *FetchAndReplicateStateProcess) HasGroup(group string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, url := range f.groupURLs {
+		if groupMatches(url, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordGroups publishes the groups of the stateMap for Groups and HasGroup,
+// which may be called while a run is in progress.
+func (f *FetchAndReplicateStateProcess) recordGroups() {
+	urls := make([]string, 0, len(f.stateMap))
+	names := make([]string, 0, len(f.stateMap))
+	for _, sm := range f.stateMap {
+		urls = append(urls, sm.url)
+		name := groupNameFromStateURL(sm.url)
+		if name == "" {
+			name = sm.url
+		}
+		names = append(names, name)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.groupURLs = urls
+	f.groups = names
+}
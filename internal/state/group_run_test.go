@@ -0,0 +1,26 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupFilter(t *testing.T) {
+	require.Empty(t, groupFilter(context.Background()))
+	require.Equal(t, "edge", groupFilter(WithGroup(context.Background(), "edge")))
+}
+
+func TestHasGroup(t *testing.T) {
+	edge := "registry.example.com/satellite/group-state/edge/state:latest"
+	other := "registry.example.com/custom/state:latest"
+	f := &FetchAndReplicateStateProcess{stateMap: NewStateMap([]string{edge, other})}
+	f.recordGroups()
+
+	require.Equal(t, []string{"edge", other}, f.Groups())
+	require.True(t, f.HasGroup("edge"))
+	require.True(t, f.HasGroup(edge))
+	require.True(t, f.HasGroup(other))
+	require.False(t, f.HasGroup("core"))
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/container-registry/harbor-satellite/internal/utils"
 	"github.com/container-registry/harbor-satellite/pkg/config"
@@ -13,6 +14,10 @@ func getStateFetcherForInput(input, username, password string, useInsecure bool,
 }
 
 func getStateFetcherForInputWithTLS(input, username, password string, useInsecure bool, tlsCfg config.TLSConfig, log *zerolog.Logger) (StateFetcher, error) {
+	if IsLocalStateInput(input) {
+		log.Info().Msg("Input is a local state")
+		return newLocalStateFetcher(input)
+	}
 	if !utils.IsValidURL(input) {
 		log.Error().Msg("Input is not a valid URL")
 		return nil, fmt.Errorf("invalid state url provided: %s", input)
@@ -20,3 +25,16 @@ func getStateFetcherForInputWithTLS(input, username, password string, useInsecur
 	log.Info().Msg("Input is a valid URL")
 	return NewURLStateFetcherWithTLS(input, username, password, useInsecure, tlsCfg), nil
 }
+
+// groupNameFromStateURL returns the group name of a group state URL of the
+// form "<registry>/satellite/group-state/<group>/state:<tag>", or an empty
+// string if the URL does not follow that layout.
+func groupNameFromStateURL(stateURL string) string {
+	parts := strings.Split(stateURL, "/")
+	for i, part := range parts {
+		if part == "group-state" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
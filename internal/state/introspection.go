@@ -0,0 +1,233 @@
+package state
+
+import (
+	"slices"
+	"time"
+)
+
+// Entity states reported by Inventory.
+const (
+	// InventoryReplicated entities are present in the local registry.
+	InventoryReplicated = "replicated"
+	// InventoryPending entities are wanted but have not been replicated yet.
+	InventoryPending = "pending"
+	// InventoryFailed entities failed to replicate and wait for their retry.
+	InventoryFailed = "failed"
+	// InventoryRetiring entities left their group and wait to be deleted.
+	InventoryRetiring = "retiring"
+)
+
+// maxRecordedRuns is the number of runs whose group results are kept.
+const maxRecordedRuns = 20
+
+// EntityInventory is an entity of a group together with its replication
+// status on this satellite.
+type EntityInventory struct {
+	Entity
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	Failures    int       `json:"failures,omitempty"`
+	NextRetryAt time.Time `json:"next_retry_at,omitzero"`
+	PurgeAfter  time.Time `json:"purge_after,omitzero"`
+	Hold        string    `json:"hold,omitempty"`
+}
+
+// GroupInventory is a group the satellite belongs to and the status of each
+// of its entities. SyncedAt is zero until the group was synced by this
+// process; until then the entities are the ones recorded in state.json.
+type GroupInventory struct {
+	Name     string            `json:"name"`
+	URL      string            `json:"url"`
+	SyncedAt time.Time         `json:"synced_at,omitzero"`
+	Entities []EntityInventory `json:"entities"`
+}
+
+// GroupSyncResult is the outcome of syncing one group during a run.
+type GroupSyncResult struct {
+	Group      string    `json:"group"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Replicated int       `json:"replicated"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// groupSnapshot is the part of a group's stateMap entry that can be read
+// while a run is in progress.
+type groupSnapshot struct {
+	url        string
+	desired    []Entity
+	replicated []Entity
+	syncedAt   time.Time
+}
+
+type runGroups struct {
+	runID   string
+	results []GroupSyncResult
+}
+
+// Inventory returns the groups of the satellite with the status of every
+// entity they want, followed by the entities they are retiring.
+func (f *FetchAndReplicateStateProcess) Inventory() []GroupInventory {
+	f.mu.Lock()
+	snapshots := slices.Clone(f.snapshots)
+	f.mu.Unlock()
+
+	deadLetters := make(map[string]DeadLetterEntry)
+	for _, entry := range f.deadLetters.Entries() {
+		deadLetters[deadLetterKey(entry.Group, entry.Entity)] = entry
+	}
+	tombstones := f.tombstones.Entries()
+
+	groups := make([]GroupInventory, 0, len(snapshots))
+	for _, snap := range snapshots {
+		group := GroupInventory{
+			Name:     groupNameFromStateURL(snap.url),
+			URL:      snap.url,
+			SyncedAt: snap.syncedAt,
+			Entities: []EntityInventory{},
+		}
+		if group.Name == "" {
+			group.Name = snap.url
+		}
+
+		replicated := make(map[string]string, len(snap.replicated))
+		for _, e := range snap.replicated {
+			replicated[entityKey(e)] = e.Digest
+		}
+
+		desired := snap.desired
+		if desired == nil {
+			desired = snap.replicated
+		}
+		for _, e := range desired {
+			item := EntityInventory{Entity: e, Status: InventoryPending}
+			if entry, ok := deadLetters[deadLetterKey(snap.url, e)]; ok && entry.Entity.Digest == e.Digest {
+				item.Status = InventoryFailed
+				item.Error = entry.Error
+				item.Failures = entry.Failures
+				item.NextRetryAt = entry.NextRetryAt
+			} else if digest, ok := replicated[entityKey(e)]; ok && digest == e.Digest {
+				item.Status = InventoryReplicated
+			}
+			group.Entities = append(group.Entities, item)
+		}
+
+		for _, t := range tombstones {
+			if t.Group != snap.url {
+				continue
+			}
+			group.Entities = append(group.Entities, EntityInventory{
+				Entity:     t.Entity,
+				Status:     InventoryRetiring,
+				PurgeAfter: t.PurgeAfter,
+				Hold:       t.Hold,
+			})
+		}
+
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// ConfigDigest returns the digest of the config artifact the satellite last
+// applied.
+func (f *FetchAndReplicateStateProcess) ConfigDigest() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.configDigest
+}
+
+// RunGroups returns the group results of the run with the given ID, if it is
+// still remembered.
+func (f *FetchAndReplicateStateProcess) RunGroups(runID string) ([]GroupSyncResult, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.runGroups {
+		if r.runID == runID {
+			return slices.Clone(r.results), true
+		}
+	}
+	return nil, false
+}
+
+// recordSnapshots publishes the groups of the stateMap for Inventory. Groups
+// already known keep what their last sync recorded. The stateMap must not be
+// modified concurrently.
+func (f *FetchAndReplicateStateProcess) recordSnapshots() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	snapshots := make([]groupSnapshot, 0, len(f.stateMap))
+	for _, sm := range f.stateMap {
+		snap := groupSnapshot{url: sm.url, replicated: slices.Clone(sm.Entities)}
+		for _, prev := range f.snapshots {
+			if prev.url == sm.url {
+				snap = prev
+				break
+			}
+		}
+		snapshots = append(snapshots, snap)
+	}
+	f.snapshots = snapshots
+}
+
+// recordGroupSync publishes the desired and replicated entities of a group
+// after it was synced.
+func (f *FetchAndReplicateStateProcess) recordGroupSync(url string, desired, replicated []Entity, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.snapshots {
+		if f.snapshots[i].url == url {
+			f.snapshots[i].desired = slices.Clone(desired)
+			f.snapshots[i].replicated = slices.Clone(replicated)
+			f.snapshots[i].syncedAt = now
+			return
+		}
+	}
+}
+
+// recordConfigDigest publishes the digest of the applied config.
+func (f *FetchAndReplicateStateProcess) recordConfigDigest(digest string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.configDigest = digest
+}
+
+// recordRunGroup adds the outcome of a group to the results of its run. Runs
+// not started by a scheduler are not recorded.
+func (f *FetchAndReplicateStateProcess) recordRunGroup(runID string, result StateFetcherResult, started time.Time) {
+	if runID == "" {
+		return
+	}
+
+	group := groupNameFromStateURL(result.URL)
+	if group == "" {
+		group = result.URL
+	}
+	entry := GroupSyncResult{
+		Group:      group,
+		StartedAt:  started,
+		DurationMs: time.Since(started).Milliseconds(),
+		Replicated: result.Summary.Count(EntityReplicated),
+		Skipped:    result.Summary.Count(EntitySkipped),
+		Failed:     result.Summary.Count(EntityFailed),
+	}
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.runGroups {
+		if f.runGroups[i].runID == runID {
+			f.runGroups[i].results = append(f.runGroups[i].results, entry)
+			return
+		}
+	}
+	f.runGroups = append(f.runGroups, runGroups{runID: runID, results: []GroupSyncResult{entry}})
+	if len(f.runGroups) > maxRecordedRuns {
+		f.runGroups = f.runGroups[len(f.runGroups)-maxRecordedRuns:]
+	}
+}
@@ -0,0 +1,118 @@
+package state
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+const edgeGroupURL = "registry.example.com/satellite/group-state/edge/state:latest"
+
+func TestInventory_EntityStatus(t *testing.T) {
+	f := newDeletionTestProcess(t, config.DeletionConfig{})
+	now := time.Now()
+
+	replicated := Entity{Name: "app", Repository: "library", Tag: "v1", Digest: "sha256:111"}
+	stale := Entity{Name: "api", Repository: "library", Tag: "v1", Digest: "sha256:222"}
+	failed := Entity{Name: "db", Repository: "library", Tag: "v1", Digest: "sha256:333"}
+	retired := Entity{Name: "old", Repository: "library", Tag: "v1", Digest: "sha256:444"}
+
+	f.stateMap = NewStateMap([]string{edgeGroupURL})
+	f.recordSnapshots()
+
+	f.deadLetters.Apply(edgeGroupURL, ReplicationSummary{Results: []EntityResult{
+		{Entity: failed, Status: EntityFailed, Err: errors.New("manifest unknown")},
+	}}, time.Hour, now)
+	f.tombstones.Bury(edgeGroupURL, []Entity{retired}, time.Hour, now)
+
+	updated := stale
+	updated.Digest = "sha256:999"
+	f.recordGroupSync(edgeGroupURL, []Entity{replicated, updated, failed}, []Entity{replicated, stale}, now)
+
+	groups := f.Inventory()
+	require.Len(t, groups, 1)
+	require.Equal(t, "edge", groups[0].Name)
+	require.Equal(t, edgeGroupURL, groups[0].URL)
+	require.Equal(t, now, groups[0].SyncedAt)
+
+	byName := map[string]EntityInventory{}
+	for _, e := range groups[0].Entities {
+		byName[e.Name] = e
+	}
+	require.Len(t, byName, 4)
+	require.Equal(t, InventoryReplicated, byName["app"].Status)
+	require.Equal(t, InventoryPending, byName["api"].Status, "a changed digest is pending until replicated")
+	require.Equal(t, InventoryFailed, byName["db"].Status)
+	require.Equal(t, "manifest unknown", byName["db"].Error)
+	require.Equal(t, 1, byName["db"].Failures)
+	require.Equal(t, now.Add(time.Hour), byName["db"].NextRetryAt)
+	require.Equal(t, InventoryRetiring, byName["old"].Status)
+	require.Equal(t, now.Add(time.Hour), byName["old"].PurgeAfter)
+}
+
+func TestInventory_FromPersistedState(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	entity := Entity{Name: "app", Repository: "library", Tag: "v1", Digest: "sha256:111"}
+	require.NoError(t, SaveState(statePath, []StateMap{{url: edgeGroupURL, Entities: []Entity{entity}}}, "sha256:cfg"))
+
+	cm, err := config.NewConfigManager(filepath.Join(dir, "config.json"), filepath.Join(dir, "prev.json"), "token", "http://127.0.0.1:8080", false, &config.Config{})
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	f := NewFetchAndReplicateStateProcess(cm, statePath, &log)
+
+	require.Equal(t, "sha256:cfg", f.ConfigDigest())
+	groups := f.Inventory()
+	require.Len(t, groups, 1)
+	require.True(t, groups[0].SyncedAt.IsZero())
+	require.Len(t, groups[0].Entities, 1)
+	require.Equal(t, InventoryReplicated, groups[0].Entities[0].Status)
+
+	// A group added by the next sync starts out empty, known groups keep
+	// their entities.
+	f.updateStateMap([]string{edgeGroupURL, "registry.example.com/satellite/group-state/prod/state:latest"})
+	f.recordSnapshots()
+	groups = f.Inventory()
+	require.Len(t, groups, 2)
+	require.Len(t, groups[0].Entities, 1)
+	require.Equal(t, "prod", groups[1].Name)
+	require.Empty(t, groups[1].Entities)
+}
+
+func TestRecordRunGroup(t *testing.T) {
+	f := newDeletionTestProcess(t, config.DeletionConfig{})
+	started := time.Now().Add(-time.Second)
+
+	summary := ReplicationSummary{Results: []EntityResult{
+		{Status: EntityReplicated},
+		{Status: EntityReplicated},
+		{Status: EntitySkipped},
+		{Status: EntityFailed},
+	}}
+	f.recordRunGroup("run-1", StateFetcherResult{URL: edgeGroupURL, Summary: summary}, started)
+	f.recordRunGroup("run-1", StateFetcherResult{URL: "other", Error: errors.New("boom")}, started)
+	f.recordRunGroup("", StateFetcherResult{URL: edgeGroupURL}, started)
+
+	results, ok := f.RunGroups("run-1")
+	require.True(t, ok)
+	require.Len(t, results, 2)
+	require.Equal(t, "edge", results[0].Group)
+	require.Equal(t, 2, results[0].Replicated)
+	require.Equal(t, 1, results[0].Skipped)
+	require.Equal(t, 1, results[0].Failed)
+	require.GreaterOrEqual(t, results[0].DurationMs, int64(1000))
+	require.Equal(t, "other", results[1].Group)
+	require.Equal(t, "boom", results[1].Error)
+
+	for i := range maxRecordedRuns {
+		f.recordRunGroup(string(rune('a'+i)), StateFetcherResult{URL: edgeGroupURL}, started)
+	}
+	_, ok = f.RunGroups("run-1")
+	require.False(t, ok, "the oldest run is forgotten")
+	require.Len(t, f.runGroups, maxRecordedRuns)
+}
@@ -0,0 +1,116 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/rs/zerolog"
+)
+
+// Prefixes of the state inputs read from the local filesystem rather than a
+// registry. Absolute paths are read from the filesystem as well.
+const (
+	// FileStatePrefix marks a JSON file or a directory, see FileStateFetcher.
+	FileStatePrefix = "file://"
+	// OCILayoutStatePrefix marks an OCI image layout directory, optionally
+	// followed by "#<ref>" to pick one of its manifests by reference
+	// annotation.
+	OCILayoutStatePrefix = "oci://"
+)
+
+// artifactsFileName is the file of a state artifact that holds the state.
+const artifactsFileName = "artifacts.json"
+
+// IsLocalStateInput reports whether a state input is read from the local
+// filesystem.
+func IsLocalStateInput(input string) bool {
+	return strings.HasPrefix(input, FileStatePrefix) || strings.HasPrefix(input, OCILayoutStatePrefix) || filepath.IsAbs(input)
+}
+
+// newLocalStateFetcher returns the fetcher of a local state input. A
+// directory holding an OCI image layout is read as one; any other directory
+// must hold an artifacts.json.
+func newLocalStateFetcher(input string) (StateFetcher, error) {
+	if rest, ok := strings.CutPrefix(input, OCILayoutStatePrefix); ok {
+		dir, ref, _ := strings.Cut(rest, "#")
+		return newOCILayoutStateFetcher(dir, ref)
+	}
+
+	path := strings.TrimPrefix(input, FileStatePrefix)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("state input %s: %w", input, err)
+	}
+	if info.IsDir() {
+		if _, err := os.Stat(filepath.Join(path, "oci-layout")); err == nil {
+			return newOCILayoutStateFetcher(path, "")
+		}
+	}
+	return NewFileStateFetcher(path), nil
+}
+
+func newOCILayoutStateFetcher(dir, ref string) (StateFetcher, error) {
+	path, err := layout.FromPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open OCI layout %s: %w", dir, err)
+	}
+	return NewLayoutStateFetcher(path, ref), nil
+}
+
+// FileStateFetcher reads a state from a JSON file, or from the artifacts.json
+// of a directory, written in the format of the artifacts.json of a state
+// artifact. The digest of a state is that of the file, so editing the file
+// is picked up by the next sync like a new state artifact would be.
+type FileStateFetcher struct {
+	path string
+}
+
+func NewFileStateFetcher(path string) StateFetcher {
+	return &FileStateFetcher{path: path}
+}
+
+func (f *FileStateFetcher) FetchStateArtifact(ctx context.Context, state any, log *zerolog.Logger) error {
+	switch state.(type) {
+	case *SatelliteState, *State, *config.Config:
+	default:
+		return fmt.Errorf("unexpected state type: %T", state)
+	}
+	log.Info().Msgf("Reading state file: %s", f.path)
+	data, err := f.read()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return fmt.Errorf("decode state file %s: %w", f.path, err)
+	}
+	return nil
+}
+
+func (f *FileStateFetcher) FetchDigest(ctx context.Context, log *zerolog.Logger) (string, error) {
+	data, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+func (f *FileStateFetcher) read() ([]byte, error) {
+	path := f.path
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, artifactsFileName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	return data, nil
+}
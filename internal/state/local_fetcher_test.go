@@ -0,0 +1,121 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func writeStateFile(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+// writeStateLayout writes an OCI image layout holding one state artifact per
+// reference.
+func writeStateLayout(t *testing.T, dir string, states map[string]any) {
+	t.Helper()
+	path, err := layout.Write(dir, empty.Index)
+	require.NoError(t, err)
+	for ref, v := range states {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		img, err := crane.Image(map[string][]byte{artifactsFileName: data})
+		require.NoError(t, err)
+		require.NoError(t, path.AppendImage(img, layout.WithAnnotations(map[string]string{BundleRefAnnotation: ref})))
+	}
+}
+
+func TestGetStateFetcherForInput_Local(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "state.json")
+	writeStateFile(t, file, SatelliteState{States: []string{"file:///groups/edge.json"}})
+	stateDir := filepath.Join(dir, "group")
+	require.NoError(t, os.Mkdir(stateDir, 0o755))
+	writeStateFile(t, filepath.Join(stateDir, artifactsFileName), SatelliteState{States: []string{"dir"}})
+	layoutDir := filepath.Join(dir, "layout")
+	writeStateLayout(t, layoutDir, map[string]any{"latest": SatelliteState{States: []string{"layout"}}})
+
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: file, want: []string{"file:///groups/edge.json"}},
+		{input: "file://" + file, want: []string{"file:///groups/edge.json"}},
+		{input: "file://" + stateDir, want: []string{"dir"}},
+		{input: "oci://" + layoutDir, want: []string{"layout"}},
+		{input: "oci://" + layoutDir + "#latest", want: []string{"layout"}},
+		{input: layoutDir, want: []string{"layout"}},
+	}
+	log := zerolog.Nop()
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			fetcher, err := getStateFetcherForInput(tt.input, "", "", false, &log)
+			require.NoError(t, err)
+			state := &SatelliteState{}
+			require.NoError(t, fetcher.FetchStateArtifact(testContext(), state, &log))
+			require.Equal(t, tt.want, state.States)
+		})
+	}
+
+	_, err := getStateFetcherForInput("file://"+filepath.Join(dir, "missing.json"), "", "", false, &log)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	fetcher, err := getStateFetcherForInput("http://registry/satellite/state:latest", "", "", false, &log)
+	require.NoError(t, err)
+	require.IsType(t, &URLStateFetcher{}, fetcher)
+}
+
+func TestFileStateFetcher_DigestFollowsContent(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "group.json")
+	writeStateFile(t, file, State{Registry: "registry", Artifacts: []Artifact{{Repository: "library/app", Tags: []string{"v1"}}}})
+	fetcher := NewFileStateFetcher(file)
+	log := zerolog.Nop()
+
+	first, err := fetcher.FetchDigest(testContext(), &log)
+	require.NoError(t, err)
+	again, err := fetcher.FetchDigest(testContext(), &log)
+	require.NoError(t, err)
+	require.Equal(t, first, again)
+
+	writeStateFile(t, file, State{Registry: "registry", Artifacts: []Artifact{{Repository: "library/app", Tags: []string{"v2"}}}})
+	second, err := fetcher.FetchDigest(testContext(), &log)
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+
+	state := NewState()
+	require.NoError(t, fetcher.FetchStateArtifact(testContext(), state, &log))
+	require.Equal(t, []string{"v2"}, state.GetArtifacts()[0].GetTags())
+}
+
+func TestLayoutStateFetcher_PicksByReference(t *testing.T) {
+	dir := t.TempDir()
+	writeStateLayout(t, dir, map[string]any{
+		"satellite/group-state/edge/state:latest": State{Registry: "edge"},
+		"satellite/group-state/core/state:latest": State{Registry: "core"},
+	})
+	log := zerolog.Nop()
+
+	fetcher, err := getStateFetcherForInput("oci://"+dir+"#http://harbor:8080/satellite/group-state/core/state:latest", "", "", false, &log)
+	require.NoError(t, err)
+	state := &State{}
+	require.NoError(t, fetcher.FetchStateArtifact(testContext(), state, &log))
+	require.Equal(t, "core", state.Registry)
+	digest, err := fetcher.FetchDigest(testContext(), &log)
+	require.NoError(t, err)
+	require.Contains(t, digest, "sha256:")
+
+	// Without a reference a layout must hold a single manifest
+	fetcher, err = getStateFetcherForInput("oci://"+dir, "", "", false, &log)
+	require.NoError(t, err)
+	require.ErrorContains(t, fetcher.FetchStateArtifact(testContext(), &State{}, &log), "holds 2 manifests")
+}
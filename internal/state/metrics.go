@@ -0,0 +1,55 @@
+package state
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// groupLabel is the group name used to label metrics, falling back to the
+// state URL for URLs that do not name a group.
+func groupLabel(stateURL string) string {
+	if group := groupNameFromStateURL(stateURL); group != "" {
+		return group
+	}
+	return stateURL
+}
+
+// countTransport returns a transport that adds the bytes of every blob
+// response body read through it to total and to counter.
+func countTransport(base http.RoundTripper, total *atomic.Int64, counter prometheus.Counter) http.RoundTripper {
+	return &countingTransport{base: base, total: total, counter: counter}
+}
+
+type countingTransport struct {
+	base    http.RoundTripper
+	total   *atomic.Int64
+	counter prometheus.Counter
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.Body == nil || !strings.Contains(req.URL.Path, "/blobs/") {
+		return resp, err
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, total: t.total, counter: t.counter}
+	return resp, nil
+}
+
+type countingBody struct {
+	io.ReadCloser
+	total   *atomic.Int64
+	counter prometheus.Counter
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.total.Add(int64(n))
+		b.counter.Add(float64(n))
+	}
+	return n, err
+}
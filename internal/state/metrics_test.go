@@ -0,0 +1,85 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupLabel(t *testing.T) {
+	require.Equal(t, "edge", groupLabel("registry.example.com/satellite/group-state/edge/state:latest"))
+	require.Equal(t, "registry.example.com/other", groupLabel("registry.example.com/other"))
+}
+
+func TestReplicate_RecordsMetrics(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	img := pushImage(t, srcAddr, "library", "app", "v1", 1)
+
+	const process, group = "metrics_test", "edge"
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithMetricLabels(process, group))
+	entity := Entity{Name: "app", Repository: "library", Tag: "v1"}
+
+	summary, err := r.Replicate(testContext(), []Entity{entity})
+	require.NoError(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.LayerCacheMisses.WithLabelValues(process, group)))
+	require.Zero(t, testutil.ToFloat64(metrics.LayerCacheHits.WithLabelValues(process, group)))
+	pulled := testutil.ToFloat64(metrics.BytesReplicated.WithLabelValues(process, group))
+	require.Positive(t, pulled)
+	require.Equal(t, pulled, float64(summary.BytesTransferred))
+
+	// A new version sharing the first layer only pulls the added one.
+	layer, err := random.Layer(1024, "application/vnd.docker.image.rootfs.diff.tar.gzip")
+	require.NoError(t, err)
+	updated, err := mutate.AppendLayers(img, layer)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(srcAddr+"/library/app:v1", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, updated))
+
+	_, err = r.Replicate(testContext(), []Entity{entity})
+	require.NoError(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.LayerCacheHits.WithLabelValues(process, group)))
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.LayerCacheMisses.WithLabelValues(process, group)))
+	require.Greater(t, testutil.ToFloat64(metrics.BytesReplicated.WithLabelValues(process, group)), pulled)
+
+	require.NoError(t, r.DeleteReplicationEntity(testContext(), []Entity{entity}))
+	require.Greater(t, testutil.ToFloat64(metrics.BytesDeleted.WithLabelValues(process, group)), 2048.0)
+}
+
+func TestRetireEntities_CountsDeletedImages(t *testing.T) {
+	stubRunningImages(t, nil, nil)
+	f := newDeletionTestProcess(t, config.DeletionConfig{GracePeriod: "1h"})
+	deleted := metrics.ImagesDeleted.WithLabelValues(f.name, "group")
+	before := testutil.ToFloat64(deleted)
+
+	r := &deleteRecorder{fail: map[string]bool{"db": true}}
+	retire(f, []Entity{appV1, dbV1}, nil, r, newDeletionRun(f.cm.GetDeletionConfig()))
+	for _, e := range []Entity{appV1, dbV1} {
+		f.tombstones.entries[deadLetterKey("group", e)].PurgeAfter = time.Now().Add(-time.Second)
+	}
+	retire(f, nil, nil, r, newDeletionRun(f.cm.GetDeletionConfig()))
+
+	require.Len(t, r.deleted, 1)
+	require.Equal(t, before+1, testutil.ToFloat64(deleted), "failed deletions are not counted")
+}
+
+func TestReconcileRemoteConfig_CountsFailures(t *testing.T) {
+	f := newDeletionTestProcess(t, config.DeletionConfig{})
+	failed := metrics.ConfigReconciliations.WithLabelValues(f.name, metrics.ConfigFailed)
+	before := testutil.ToFloat64(failed)
+
+	log := zerolog.Nop()
+	result := f.reconcileRemoteConfig(testContext(), "", "", "", true, nil, &log)
+	require.Error(t, result.Error)
+	require.Equal(t, before+1, testutil.ToFloat64(failed))
+}
@@ -0,0 +1,216 @@
+package state
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// peerDialTimeout bounds connecting to a peer, so that an unreachable peer
+// delays a layer by at most this long before the source is used.
+const peerDialTimeout = 2 * time.Second
+
+// Sources counted by the peer layers metric.
+const (
+	layerFromPeer     = "peer"
+	layerFromUpstream = "upstream"
+)
+
+// WithPeers fetches layers from the local registries of the given peer
+// satellites first and from the source only when no peer has them. Peers
+// are "scheme://host" URLs as assigned by Ground Control. They are read
+// with the satellite's local registry credentials, which satellites sharing
+// a config have in common.
+func WithPeers(peers []string) ReplicatorOption {
+	return func(r *BasicReplicator) {
+		r.peers = peers
+	}
+}
+
+// replicatorWithPeers applies the peers assigned to the satellite. Without
+// peers the replicator is returned as is.
+func replicatorWithPeers(replicator Replicator, peers []string) Replicator {
+	basic, ok := replicator.(*BasicReplicator)
+	if !ok || len(peers) == 0 {
+		return replicator
+	}
+	return basic.withOptions(WithPeers(peers))
+}
+
+// peerSource fetches the layers of one entity from peer satellites. A nil
+// source leaves images untouched.
+type peerSource struct {
+	ctx     context.Context
+	repos   []name.Repository
+	opts    []remote.Option
+	process string
+	group   string
+
+	mu   sync.Mutex
+	down map[string]bool
+}
+
+// newPeerSource returns the source of entity's layers at the peers, skipping
+// peers that are invalid or the satellite's own registry.
+func (r *BasicReplicator) newPeerSource(ctx context.Context, entity Entity) *peerSource {
+	if len(r.peers) == 0 {
+		return nil
+	}
+	log := logger.FromContext(ctx)
+
+	var repos []name.Repository
+	for _, peer := range r.peers {
+		u, err := url.Parse(peer)
+		if err != nil || u.Host == "" {
+			log.Warn().Str("peer", peer).Msg("Ignoring invalid peer URL")
+			continue
+		}
+		if u.Host == r.remoteRegistryURL {
+			continue
+		}
+		var nameOpts []name.Option
+		if u.Scheme == "http" {
+			nameOpts = append(nameOpts, name.Insecure)
+		}
+		repo, err := name.NewRepository(u.Host+"/"+entity.GetRepository()+"/"+entity.GetName(), nameOpts...)
+		if err != nil {
+			log.Warn().Err(err).Str("peer", peer).Msg("Ignoring invalid peer URL")
+			continue
+		}
+		repos = append(repos, repo)
+	}
+	if len(repos) == 0 {
+		return nil
+	}
+	transport, err := r.peerTransport()
+	if err != nil {
+		log.Warn().Err(err).Msg("Fetching layers from the source only")
+		return nil
+	}
+
+	return &peerSource{
+		ctx:   ctx,
+		repos: repos,
+		opts: []remote.Option{
+			remote.WithAuth(authn.FromConfig(authn.AuthConfig{Username: r.remoteUsername, Password: r.remotePassword})),
+			remote.WithContext(ctx),
+			remote.WithTransport(r.limits.wrapTransport(transport)),
+		},
+		process: r.process,
+		group:   r.group,
+		down:    make(map[string]bool),
+	}
+}
+
+// peerTransport connects to peers with a short timeout. Peers run with the
+// same TLS settings as the satellite's own registry, so their certificates
+// are verified with its CA.
+func (r *BasicReplicator) peerTransport() (http.RoundTripper, error) {
+	t, err := localRegistryTransport(r.localCAFile)
+	if err != nil {
+		return nil, err
+	}
+	t.DialContext = (&net.Dialer{Timeout: peerDialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	if r.useUnsecure {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec // matches the satellite's insecure mode
+	}
+	return t, nil
+}
+
+// image swaps the layers of img for layers fetched from the peers first.
+// Manifest and config are untouched, so the image keeps its digest.
+func (p *peerSource) image(img v1.Image) v1.Image {
+	if p == nil {
+		return img
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return img
+	}
+	wrapped := make([]v1.Layer, len(layers))
+	for i, l := range layers {
+		wrapped[i] = &peerLayer{Layer: l, peers: p}
+	}
+	return &wrappedImage{Image: img, layers: wrapped}
+}
+
+// index applies image to every image of idx, recursing into nested indexes.
+func (p *peerSource) index(idx v1.ImageIndex) v1.ImageIndex {
+	if p == nil {
+		return idx
+	}
+	return &wrappedIndex{idx: idx, wrapper: p}
+}
+
+// open returns the compressed content of the layer from the first peer that
+// has it. A peer that cannot be reached is not asked again for this entity.
+func (p *peerSource) open(digest v1.Hash) (io.ReadCloser, bool) {
+	log := logger.FromContext(p.ctx)
+	for _, repo := range p.repos {
+		host := repo.RegistryStr()
+		p.mu.Lock()
+		down := p.down[host]
+		p.mu.Unlock()
+		if down || p.ctx.Err() != nil {
+			continue
+		}
+
+		layer, err := remote.Layer(repo.Digest(digest.String()), p.opts...)
+		if err == nil {
+			var rc io.ReadCloser
+			if rc, err = layer.Compressed(); err == nil {
+				return rc, true
+			}
+		}
+		if isPeerUnreachable(err) {
+			log.Warn().Err(err).Str("peer", host).Msg("Peer unreachable, fetching its layers from the source")
+			p.mu.Lock()
+			p.down[host] = true
+			p.mu.Unlock()
+			continue
+		}
+		log.Debug().Err(err).Str("peer", host).Str("digest", digest.String()).Msg("Layer not available at peer")
+	}
+	return nil, false
+}
+
+// isPeerUnreachable tells connection failures apart from registry errors
+// such as a missing blob, which leave the peer usable for other layers.
+func isPeerUnreachable(err error) bool {
+	var terr *transport.Error
+	return err != nil && !errors.As(err, &terr)
+}
+
+// peerLayer fetches its compressed content from a peer and falls back to
+// the wrapped layer, i.e. the spool or the source. The digest is verified
+// while the content is read, whichever serves it.
+type peerLayer struct {
+	v1.Layer
+	peers *peerSource
+}
+
+func (l *peerLayer) Compressed() (io.ReadCloser, error) {
+	digest, err := l.Digest()
+	if err == nil {
+		if rc, ok := l.peers.open(digest); ok {
+			metrics.PeerLayers.WithLabelValues(l.peers.process, l.peers.group, layerFromPeer).Inc()
+			return rc, nil
+		}
+	}
+	metrics.PeerLayers.WithLabelValues(l.peers.process, l.peers.group, layerFromUpstream).Inc()
+	return l.Layer.Compressed()
+}
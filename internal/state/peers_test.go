@@ -0,0 +1,81 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicate_FetchesLayersFromPeers(t *testing.T) {
+	tests := []struct {
+		name string
+		// peerHasImage pushes the image to the peer before replicating
+		peerHasImage bool
+		// peer overrides the URL of the peer registry
+		peer string
+		// sourceBlobs is the number of blobs pulled from the source: the
+		// config and every layer no peer served
+		sourceBlobs int
+	}{
+		{name: "peer has the layers", peerHasImage: true, sourceBlobs: 1},
+		{name: "peer lacks the layers", sourceBlobs: 3},
+		{name: "peer unreachable", peer: "http://127.0.0.1:1", sourceBlobs: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := &rangeRecorder{}
+			srcAddr := rr.serve(t)
+			_, peerAddr := newTestRegistry(t)
+			_, dstAddr := newTestRegistry(t)
+
+			img := pushImage(t, srcAddr, "library", "app", "v1", 2)
+			if tt.peerHasImage {
+				ref, err := name.ParseReference(peerAddr+"/library/app:v1", name.Insecure)
+				require.NoError(t, err)
+				require.NoError(t, remote.Write(ref, img))
+			}
+			peer := "http://" + peerAddr
+			if tt.peer != "" {
+				peer = tt.peer
+			}
+
+			r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithPeers([]string{peer}))
+			_, err := r.Replicate(testContext(), []Entity{{Name: "app", Repository: "library", Tag: "v1"}})
+			require.NoError(t, err)
+			require.Len(t, rr.recorded(), tt.sourceBlobs)
+
+			dstRef, err := name.ParseReference(dstAddr+"/library/app:v1", name.Insecure)
+			require.NoError(t, err)
+			got, err := remote.Image(dstRef)
+			require.NoError(t, err)
+			layers, err := got.Layers()
+			require.NoError(t, err)
+			for _, l := range layers {
+				rc, err := l.Compressed()
+				require.NoError(t, err)
+				_ = rc.Close()
+			}
+		})
+	}
+}
+
+func TestNewPeerSource_SkipsOwnRegistry(t *testing.T) {
+	r := NewBasicReplicator("", "", "harbor.example.com", "127.0.0.1:8585", "", "", true,
+		WithPeers([]string{"http://127.0.0.1:8585", "not a url", "https://edge-1.example.com"})).(*BasicReplicator)
+
+	peers := r.newPeerSource(testContext(), Entity{Name: "app", Repository: "library", Tag: "v1"})
+	require.NotNil(t, peers)
+	require.Len(t, peers.repos, 1)
+	require.Equal(t, "edge-1.example.com/library/app", peers.repos[0].String())
+
+	plain := NewBasicReplicator("", "", "harbor.example.com", "127.0.0.1:8585", "", "", true)
+	require.Same(t, plain, replicatorWithPeers(plain, nil))
+	require.Nil(t, plain.(*BasicReplicator).newPeerSource(testContext(), Entity{Name: "app", Repository: "library", Tag: "v1"}))
+
+	withPeers := replicatorWithPeers(plain, []string{"https://edge-1.example.com"}).(*BasicReplicator)
+	require.Equal(t, []string{"https://edge-1.example.com"}, withPeers.peers)
+	require.Empty(t, plain.(*BasicReplicator).peers, "the shared replicator must not get peers")
+}
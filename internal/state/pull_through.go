@@ -0,0 +1,147 @@
+package state
+
+import (
+	"context"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+// Events counted by the pull-through metric.
+const (
+	pullThroughCached  = "cached"
+	pullThroughExpired = "expired"
+)
+
+// pullThrough keeps the books of the pull-through mode. The registry itself
+// fetches an allowed image from Harbor the moment a pull misses, through its
+// sync extension; the satellite records each tag of an allowed repository no
+// group state lists as an ad-hoc image the first time a sync sees it, and
+// deletes the ad-hoc images not pulled within their retention.
+func (f *FetchAndReplicateStateProcess) pullThrough(ctx context.Context, replicator Replicator, deletions *deletionRun, log *zerolog.Logger) {
+	cfg := f.cm.GetPullThroughConfig()
+	if !cfg.Enabled || f.cm.GetOwnRegistry() || bundleFromContext(ctx) != nil {
+		return
+	}
+
+	if path := f.registryLogPath(); path != "" {
+		if err := f.access.Scan(path); err != nil {
+			log.Warn().Err(err).Msg("Failed to read the registry access log")
+		}
+	}
+	defer func() {
+		if err := f.access.Save(); err != nil {
+			log.Warn().Err(err).Msg("Failed to persist the last pulls of local images")
+		}
+		if err := f.adhoc.Save(); err != nil {
+			log.Warn().Err(err).Msg("Failed to persist ad-hoc images")
+		}
+	}()
+
+	var desired []Entity
+	for _, sm := range f.stateMap {
+		desired = append(desired, sm.Entities...)
+	}
+
+	f.recordAdHoc(ctx, cfg, desired, log)
+	f.expireAdHoc(ctx, parseDurationOr(cfg.Retention, config.DefaultPullThroughRetention), desired, replicator, deletions, log)
+}
+
+// recordAdHoc records the images the registry fetched on demand since the
+// last sync: the tags of allowed repositories that neither a group state nor
+// a pending deletion accounts for.
+func (f *FetchAndReplicateStateProcess) recordAdHoc(ctx context.Context, cfg config.PullThroughConfig, desired []Entity, log *zerolog.Logger) {
+	registry, opts, err := f.localRegistry(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid local registry URL")
+		return
+	}
+	images, err := listLocalImages(ctx, registry, opts)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list the images of the local registry")
+		return
+	}
+
+	accounted := append([]Entity{}, desired...)
+	for _, t := range f.tombstones.Pending(time.Now()) {
+		accounted = append(accounted, t.Entity)
+	}
+
+	now := time.Now().UTC()
+	for _, img := range images {
+		if !cfg.Allows(img.repo) {
+			continue
+		}
+		entity, ok := img.entity()
+		if !ok {
+			continue
+		}
+		if tagWanted, _ := entityReferences(entity, accounted); tagWanted {
+			continue
+		}
+		if f.adhoc.Add(entity, now) {
+			metrics.PullThroughImages.WithLabelValues(f.name, pullThroughCached).Inc()
+			log.Info().Str("image", entity.reference()).Msg("Recorded image cached on demand as ad-hoc image")
+		}
+	}
+}
+
+// expireAdHoc deletes the ad-hoc images not pulled within retention. Images
+// a group state lists by now are no longer ad-hoc and are left to the group.
+func (f *FetchAndReplicateStateProcess) expireAdHoc(ctx context.Context, retention time.Duration, desired []Entity, replicator Replicator, run *deletionRun, log *zerolog.Logger) {
+	now := time.Now()
+	images := f.adhoc.Entries()
+
+	for _, img := range images {
+		if ctx.Err() != nil {
+			return
+		}
+		e := img.Entity
+		if tagWanted, _ := entityReferences(e, desired); tagWanted {
+			log.Info().Str("image", e.reference()).Msg("Ad-hoc image is listed by a group state now")
+			f.adhoc.Remove(e)
+			continue
+		}
+		lastUsed := img.CachedAt
+		if pulled := f.access.LastPull(e.Repository+"/"+e.Name, e.Tag, e.Digest); pulled.After(lastUsed) {
+			lastUsed = pulled
+		}
+		if now.Sub(lastUsed) < retention {
+			continue
+		}
+
+		refs := append([]Entity{}, desired...)
+		for _, other := range images {
+			if other.Entity.reference() != e.reference() {
+				refs = append(refs, other.Entity)
+			}
+		}
+		for _, t := range f.tombstones.Pending(now) {
+			refs = append(refs, t.Entity)
+		}
+		_, shared := entityReferences(e, refs)
+
+		if reason := run.hold(ctx, e); reason != "" {
+			log.Info().Str("image", e.reference()).Str("hold", reason).Msg("Keeping ad-hoc image past its retention")
+			continue
+		}
+		if !run.take() {
+			log.Warn().Msg("Deletion limit for this sync reached, remaining ad-hoc images wait for the next sync")
+			return
+		}
+		remove := replicator.DeleteReplicationEntity
+		if shared {
+			remove = replicator.UntagReplicationEntity
+		}
+		if err := remove(ctx, []Entity{e}); err != nil {
+			log.Error().Err(err).Str("image", e.reference()).Msg("Failed to delete ad-hoc image")
+			continue
+		}
+		f.adhoc.Remove(e)
+		f.access.Forget(e.Repository+"/"+e.Name, e.Tag, e.Digest)
+		metrics.PullThroughImages.WithLabelValues(f.name, pullThroughExpired).Inc()
+		log.Info().Str("image", e.reference()).Time("last_pulled_at", lastUsed).Msg("Deleted ad-hoc image past its retention")
+	}
+}
@@ -0,0 +1,90 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// enablePullThrough turns on the pull-through mode of f for allow and
+// returns the access log the registry would write.
+func enablePullThrough(t *testing.T, f *FetchAndReplicateStateProcess, retention string, allow ...string) string {
+	t.Helper()
+	logPath := filepath.Join(t.TempDir(), "zot-access.log")
+	f.cm.With(func(c *config.Config) {
+		c.AppConfig.PullThrough = config.PullThroughConfig{Enabled: true, Allow: allow, Retention: retention}
+		c.ZotConfigRaw = []byte(`{"log":{"output":"` + logPath + `"}}`)
+	})
+	return logPath
+}
+
+func TestPullThrough_RecordsImagesCachedOnDemand(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	f := newBundleTestProcess(t, srcAddr, dstAddr)
+	log := zerolog.Nop()
+
+	// What the registry's sync extension fetched, and a replicated image
+	tool := pushImage(t, dstAddr, "library", "tool", "v1", 1)
+	pushImage(t, dstAddr, "private", "app", "v1", 1)
+	pushImage(t, dstAddr, "library", "wanted", "v1", 1)
+	f.stateMap = []StateMap{{url: "edge", Entities: []Entity{{Repository: "library", Name: "wanted", Tag: "v1"}}}}
+	toolDigest, err := tool.Digest()
+	require.NoError(t, err)
+
+	enablePullThrough(t, f, "1h", "library/*")
+	replicator := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	f.pullThrough(testContext(), replicator, newDeletionRun(config.DeletionConfig{IgnoreInUse: true}), &log)
+
+	loaded, err := NewAdHocStore(adHocPath(f.stateFilePath))
+	require.NoError(t, err)
+	entries := loaded.Entries()
+	require.Len(t, entries, 1, "only allowed images no group lists are ad-hoc")
+	require.Equal(t, Entity{Repository: "library", Name: "tool", Tag: "v1", Digest: toolDigest.String()}, entries[0].Entity)
+
+	// A later sync keeps when the image was first seen
+	cachedAt := entries[0].CachedAt
+	f.pullThrough(testContext(), replicator, newDeletionRun(config.DeletionConfig{IgnoreInUse: true}), &log)
+	require.Equal(t, cachedAt, f.adhoc.Entries()[0].CachedAt)
+}
+
+func TestPullThrough_ExpiresAdHocImages(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	f := newBundleTestProcess(t, srcAddr, dstAddr)
+	log := zerolog.Nop()
+
+	stale := pushImage(t, dstAddr, "library", "stale", "v1", 1)
+	pushImage(t, dstAddr, "library", "pulled", "v1", 1)
+	pushImage(t, dstAddr, "library", "adopted", "v1", 1)
+	staleDigest, err := stale.Digest()
+	require.NoError(t, err)
+
+	longAgo := time.Now().Add(-2 * time.Hour)
+	f.adhoc.Add(Entity{Repository: "library", Name: "stale", Tag: "v1", Digest: staleDigest.String()}, longAgo)
+	f.adhoc.Add(Entity{Repository: "library", Name: "pulled", Tag: "v1"}, longAgo)
+	f.adhoc.Add(Entity{Repository: "library", Name: "adopted", Tag: "v1"}, longAgo)
+	f.stateMap = []StateMap{{url: "edge", Entities: []Entity{{Repository: "library", Name: "adopted", Tag: "v1"}}}}
+
+	logPath := enablePullThrough(t, f, "1h", "library/*")
+	appendFile(t, logPath, zotAccessLine(time.Now().UTC(), "GET", "/v2/library/pulled/manifests/v1", 200, "containerd/v1.7.0"))
+
+	replicator := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	f.pullThrough(testContext(), replicator, newDeletionRun(config.DeletionConfig{IgnoreInUse: true}), &log)
+
+	_, err = crane.Head(dstAddr+"/library/stale:v1", crane.Insecure)
+	require.True(t, isNotFound(err), "an ad-hoc image not pulled within its retention is deleted")
+	for _, kept := range []string{"library/pulled:v1", "library/adopted:v1"} {
+		_, err = crane.Head(dstAddr+"/"+kept, crane.Insecure)
+		require.NoError(t, err, kept)
+	}
+
+	entries := f.adhoc.Entries()
+	require.Len(t, entries, 1, "the adopted image belongs to its group now")
+	require.Equal(t, "pulled", entries[0].Entity.Name)
+}
@@ -0,0 +1,159 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// maxReferrerDepth bounds how far referrers of referrers are followed, e.g. a
+// signature attached to an SBOM that is attached to the image.
+const maxReferrerDepth = 2
+
+// cosignTagSuffixes are the tags cosign uses for signatures, attestations and
+// SBOMs on registries without the referrers API: sha256-<hex>.<suffix>.
+var cosignTagSuffixes = []string{".sig", ".att", ".sbom"}
+
+// cosignTag returns the cosign tag of the given kind for digest.
+func cosignTag(digest v1.Hash, suffix string) string {
+	return strings.Replace(digest.String(), ":", "-", 1) + suffix
+}
+
+// copyReferrers replicates the artifacts that refer to digest in srcRepo:
+// OCI 1.1 referrers (the registry client falls back to the referrers tag
+// schema on its own) and cosign's tag-based signatures, attestations and
+// SBOMs. Artifacts already present at the destination are not pushed again.
+// It returns the number of artifacts copied.
+func copyReferrers(srcRepo, dstRepo name.Repository, digest v1.Hash, opts *replicationOptions) (int, error) {
+	return copyReferrersOf(srcRepo, dstRepo, digest, opts, maxReferrerDepth)
+}
+
+func copyReferrersOf(srcRepo, dstRepo name.Repository, digest v1.Hash, opts *replicationOptions, depth int) (int, error) {
+	copied := 0
+
+	idx, err := remote.Referrers(srcRepo.Digest(digest.String()), opts.pullOpts...)
+	if err != nil {
+		return copied, fmt.Errorf("list referrers of %s: %w", digest, err)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return copied, fmt.Errorf("read referrers of %s: %w", digest, err)
+	}
+
+	for _, m := range manifest.Manifests {
+		ok, err := copyManifest(srcRepo.Digest(m.Digest.String()), dstRepo.Digest(m.Digest.String()), opts)
+		if err != nil {
+			return copied, fmt.Errorf("copy referrer %s: %w", m.Digest, err)
+		}
+		if ok {
+			copied++
+		}
+		if depth > 1 {
+			n, err := copyReferrersOf(srcRepo, dstRepo, m.Digest, opts, depth-1)
+			copied += n
+			if err != nil {
+				return copied, err
+			}
+		}
+	}
+
+	for _, suffix := range cosignTagSuffixes {
+		tag := cosignTag(digest, suffix)
+		ok, err := copyManifest(srcRepo.Tag(tag), dstRepo.Tag(tag), opts)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return copied, fmt.Errorf("copy %s: %w", tag, err)
+		}
+		if ok {
+			copied++
+		}
+	}
+
+	return copied, nil
+}
+
+// copyManifest copies the image or index at src to dst unchanged. It reports
+// false without copying if dst already has the same digest.
+func copyManifest(src, dst name.Reference, opts *replicationOptions) (bool, error) {
+	desc, err := remote.Get(src, opts.pullOpts...)
+	if err != nil {
+		return false, err
+	}
+
+	if dstDesc, err := remote.Head(dst, opts.pushOpts...); err == nil && dstDesc.Digest == desc.Digest {
+		return false, nil
+	}
+
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return false, err
+		}
+		return true, remote.WriteIndex(dst, idx, opts.pushOpts...)
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return false, err
+	}
+	return true, remote.Write(dst, img, opts.pushOpts...)
+}
+
+// deleteReferrersOf removes the artifacts referring to digest in repo from
+// the local registry, so they do not outlive their subject, following
+// nested referrers up to depth levels.
+func deleteReferrersOf(ctx context.Context, repo name.Repository, digest v1.Hash, opts []remote.Option, depth int) error {
+	log := logger.FromContext(ctx)
+
+	idx, err := remote.Referrers(repo.Digest(digest.String()), opts...)
+	if err != nil {
+		return fmt.Errorf("list referrers of %s: %w", digest, err)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("read referrers of %s: %w", digest, err)
+	}
+
+	var errs []error
+	for _, m := range manifest.Manifests {
+		if depth > 1 {
+			if err := deleteReferrersOf(ctx, repo, m.Digest, opts, depth-1); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := remote.Delete(repo.Digest(m.Digest.String()), opts...); err != nil && !isNotFound(err) {
+			errs = append(errs, fmt.Errorf("delete referrer %s: %w", m.Digest, err))
+			continue
+		}
+		log.Debug().Str("referrer", m.Digest.String()).Str("subject", digest.String()).Msg("Deleted referrer")
+	}
+
+	// Registries without the referrers API keep the list in a fallback tag.
+	tags := []string{strings.Replace(digest.String(), ":", "-", 1)}
+	for _, suffix := range cosignTagSuffixes {
+		tags = append(tags, cosignTag(digest, suffix))
+	}
+	for _, tag := range tags {
+		if err := remote.Delete(repo.Tag(tag), opts...); err != nil && !isNotFound(err) {
+			errs = append(errs, fmt.Errorf("delete %s: %w", tag, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isNotFound reports whether err is a registry 404.
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,267 @@
+package state
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+)
+
+// newReferrersRegistry starts an in-memory registry with the OCI 1.1
+// referrers API enabled.
+func newReferrersRegistry(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(registry.New(registry.WithReferrersSupport(true)))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// pushOCIImage pushes a random OCI image and returns its digest.
+func pushOCIImage(t *testing.T, ref string) v1.Hash {
+	t.Helper()
+	img, err := random.Image(512, 1)
+	require.NoError(t, err)
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, types.OCIConfigJSON)
+
+	r, err := name.ParseReference(ref, name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(r, img))
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	return digest
+}
+
+// pushDockerImage pushes a random Docker schema 2 image and returns its
+// digest.
+func pushDockerImage(t *testing.T, ref string) v1.Hash {
+	t.Helper()
+	img, err := random.Image(512, 1)
+	require.NoError(t, err)
+	mediaType, err := img.MediaType()
+	require.NoError(t, err)
+	require.Equal(t, types.DockerManifestSchema2, mediaType)
+
+	r, err := name.ParseReference(ref, name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(r, img))
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	return digest
+}
+
+// pushReferrer pushes an artifact whose subject is the manifest at subject
+// and returns its digest.
+func pushReferrer(t *testing.T, repo string, subject v1.Hash) v1.Hash {
+	t.Helper()
+	subjectRef, err := name.NewDigest(repo+"@"+subject.String(), name.Insecure)
+	require.NoError(t, err)
+	subjectDesc, err := remote.Head(subjectRef)
+	require.NoError(t, err)
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, "application/vnd.example.sbom")
+	artifact, ok := mutate.Subject(img, *subjectDesc).(v1.Image)
+	require.True(t, ok)
+
+	digest, err := artifact.Digest()
+	require.NoError(t, err)
+	ref, err := name.NewDigest(repo+"@"+digest.String(), name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, artifact))
+	return digest
+}
+
+func requireManifest(t *testing.T, ref string, exists bool) {
+	t.Helper()
+	r, err := name.ParseReference(ref, name.Insecure)
+	require.NoError(t, err)
+	_, err = remote.Head(r)
+	if exists {
+		require.NoError(t, err, "expected %s to exist", ref)
+	} else {
+		require.Error(t, err, "expected %s to be gone", ref)
+	}
+}
+
+func TestReplicate_CopiesReferrers(t *testing.T) {
+	srcAddr := newReferrersRegistry(t)
+	dstAddr := newReferrersRegistry(t)
+
+	subject := pushOCIImage(t, srcAddr+"/library/signed:v1")
+	sbom := pushReferrer(t, srcAddr+"/library/signed", subject)
+	sbomSignature := pushReferrer(t, srcAddr+"/library/signed", sbom)
+	sigTag := cosignTag(subject, ".sig")
+	pushOCIImage(t, srcAddr+"/library/signed:"+sigTag)
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	_, err := r.Replicate(testContext(), []Entity{{Name: "signed", Repository: "library", Tag: "v1"}})
+	require.NoError(t, err)
+
+	// The subject digest is preserved, so the copied referrers still point
+	// at it.
+	dstRef, err := name.ParseReference(dstAddr+"/library/signed:v1", name.Insecure)
+	require.NoError(t, err)
+	desc, err := remote.Head(dstRef)
+	require.NoError(t, err)
+	require.Equal(t, subject, desc.Digest)
+
+	requireManifest(t, dstAddr+"/library/signed@"+sbom.String(), true)
+	requireManifest(t, dstAddr+"/library/signed@"+sbomSignature.String(), true)
+	requireManifest(t, dstAddr+"/library/signed:"+sigTag, true)
+
+	referrers, err := remote.Referrers(dstRef.Context().Digest(subject.String()))
+	require.NoError(t, err)
+	manifest, err := referrers.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Manifests, 1)
+	require.Equal(t, sbom, manifest.Manifests[0].Digest)
+}
+
+func TestReplicate_CopiesReferrersOfDockerImages(t *testing.T) {
+	srcAddr := newReferrersRegistry(t)
+	dstAddr := newReferrersRegistry(t)
+
+	subject := pushDockerImage(t, srcAddr+"/library/signed:v1")
+	sbom := pushReferrer(t, srcAddr+"/library/signed", subject)
+	sigTag := cosignTag(subject, ".sig")
+	pushOCIImage(t, srcAddr+"/library/signed:"+sigTag)
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	entities := []Entity{{Name: "signed", Repository: "library", Tag: "v1"}}
+	_, err := r.Replicate(testContext(), entities)
+	require.NoError(t, err)
+
+	// The Docker manifest is stored as is, so the signature tag and the
+	// referrers name the digest the image has locally
+	dstRef, err := name.ParseReference(dstAddr+"/library/signed:v1", name.Insecure)
+	require.NoError(t, err)
+	desc, err := remote.Head(dstRef)
+	require.NoError(t, err)
+	require.Equal(t, subject, desc.Digest)
+	require.Equal(t, types.DockerManifestSchema2, desc.MediaType)
+
+	referrers, err := remote.Referrers(dstRef.Context().Digest(subject.String()))
+	require.NoError(t, err)
+	manifest, err := referrers.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Manifests, 1)
+	require.Equal(t, sbom, manifest.Manifests[0].Digest)
+	requireManifest(t, dstAddr+"/library/signed:"+sigTag, true)
+
+	// and they are deleted with it
+	local := NewBasicReplicator("", "", "", dstAddr, "", "", true)
+	require.NoError(t, local.DeleteReplicationEntity(testContext(), entities))
+	requireManifest(t, dstAddr+"/library/signed@"+sbom.String(), false)
+	requireManifest(t, dstAddr+"/library/signed:"+sigTag, false)
+}
+
+func TestReplicate_PicksUpReferrersOfSkippedImages(t *testing.T) {
+	srcAddr := newReferrersRegistry(t)
+	dstAddr := newReferrersRegistry(t)
+
+	subject := pushOCIImage(t, srcAddr+"/library/signed:v1")
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	entities := []Entity{{Name: "signed", Repository: "library", Tag: "v1"}}
+	_, err := r.Replicate(testContext(), entities)
+	require.NoError(t, err)
+
+	// The image is signed after it was first replicated
+	sigTag := cosignTag(subject, ".sig")
+	pushOCIImage(t, srcAddr+"/library/signed:"+sigTag)
+
+	summary, err := r.Replicate(testContext(), entities)
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.Count(EntitySkipped))
+	requireManifest(t, dstAddr+"/library/signed:"+sigTag, true)
+}
+
+func TestDeleteReplicationEntity_PrunesReferrers(t *testing.T) {
+	addr := newReferrersRegistry(t)
+
+	subject := pushOCIImage(t, addr+"/library/signed:v1")
+	sbom := pushReferrer(t, addr+"/library/signed", subject)
+	sigTag := cosignTag(subject, ".sig")
+	pushOCIImage(t, addr+"/library/signed:"+sigTag)
+
+	r := NewBasicReplicator("", "", "", addr, "", "", true)
+	require.NoError(t, r.DeleteReplicationEntity(testContext(), []Entity{{Name: "signed", Repository: "library", Tag: "v1"}}))
+
+	requireManifest(t, addr+"/library/signed@"+sbom.String(), false)
+	requireManifest(t, addr+"/library/signed:"+sigTag, false)
+}
+
+func TestCosignTag(t *testing.T) {
+	digest, err := v1.NewHash("sha256:" + strings.Repeat("ab", 32))
+	require.NoError(t, err)
+	require.Equal(t, "sha256-"+strings.Repeat("ab", 32)+".sig", cosignTag(digest, ".sig"))
+}
+
+func TestUntagReplicationEntity_KeepsManifestAndReferrers(t *testing.T) {
+	addr := newReferrersRegistry(t)
+
+	subject := pushOCIImage(t, addr+"/library/signed:v1")
+	src, err := name.ParseReference(addr+"/library/signed:v1", name.Insecure)
+	require.NoError(t, err)
+	img, err := remote.Image(src)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(src.Context().Tag("latest"), img))
+	sigTag := cosignTag(subject, ".sig")
+	pushOCIImage(t, addr+"/library/signed:"+sigTag)
+
+	r := NewBasicReplicator("", "", "", addr, "", "", true)
+	require.NoError(t, r.UntagReplicationEntity(testContext(), []Entity{{Name: "signed", Repository: "library", Tag: "v1"}}))
+
+	requireManifest(t, addr+"/library/signed:v1", false)
+	requireManifest(t, addr+"/library/signed:latest", true)
+	requireManifest(t, addr+"/library/signed@"+subject.String(), true)
+	requireManifest(t, addr+"/library/signed:"+sigTag, true)
+}
+
+func TestReplicate_SkipsReferrersOfTrimmedIndexes(t *testing.T) {
+	srcAddr := newReferrersRegistry(t)
+	dstAddr := newReferrersRegistry(t)
+
+	idx := pushIndex(t, srcAddr, "library", "multi", "v1", "linux/amd64", "linux/arm64")
+	subject, err := idx.Digest()
+	require.NoError(t, err)
+	sbom := pushReferrer(t, srcAddr+"/library/multi", subject)
+	sigTag := cosignTag(subject, ".sig")
+	pushOCIImage(t, srcAddr+"/library/multi:"+sigTag)
+
+	platforms, err := ParsePlatforms([]string{"linux/arm64"})
+	require.NoError(t, err)
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithPlatforms(platforms))
+	entities := []Entity{{Name: "multi", Repository: "library", Tag: "v1"}}
+	summary, err := r.Replicate(testContext(), entities)
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.Count(EntityReplicated))
+
+	// The trimmed index has a digest of its own, which the source referrers
+	// do not name
+	dstRef, err := name.ParseReference(dstAddr+"/library/multi:v1", name.Insecure)
+	require.NoError(t, err)
+	desc, err := remote.Head(dstRef)
+	require.NoError(t, err)
+	require.NotEqual(t, subject, desc.Digest)
+
+	requireManifest(t, dstAddr+"/library/multi@"+sbom.String(), false)
+	requireManifest(t, dstAddr+"/library/multi:"+sigTag, false)
+
+	summary, err = r.Replicate(testContext(), entities)
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.Count(EntitySkipped))
+	requireManifest(t, dstAddr+"/library/multi:"+sigTag, false)
+}
@@ -0,0 +1,104 @@
+package state
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+// ReplicationLimits holds the concurrency and bandwidth budget of the state
+// replicator. The image and blob semaphores and the bandwidth limiter are
+// global: every replicator built with the same limits draws from them, so
+// groups replicating in parallel cannot exceed the configured totals.
+type ReplicationLimits struct {
+	imagesPerGroup int
+	images         chan struct{}
+	blobs          chan struct{}
+	bandwidth      *BandwidthLimiter
+}
+
+// NewReplicationLimits creates limits from the replication config, falling
+// back to the defaults for unset values.
+func NewReplicationLimits(cfg config.ReplicationConfig) *ReplicationLimits {
+	images := cfg.MaxConcurrentImages
+	if images <= 0 {
+		images = config.DefaultMaxConcurrentImages
+	}
+	perGroup := cfg.MaxConcurrentImagesPerGroup
+	if perGroup <= 0 {
+		perGroup = config.DefaultMaxConcurrentImagesPerGroup
+	}
+	if perGroup > images {
+		perGroup = images
+	}
+	blobs := cfg.MaxConcurrentBlobs
+	if blobs <= 0 {
+		blobs = config.DefaultMaxConcurrentBlobs
+	}
+
+	return &ReplicationLimits{
+		imagesPerGroup: perGroup,
+		images:         make(chan struct{}, images),
+		blobs:          make(chan struct{}, blobs),
+		bandwidth:      NewBandwidthLimiter(cfg.MaxBytesPerSecond),
+	}
+}
+
+// acquireImage blocks until an image slot is free or ctx is done. The
+// returned func releases the slot.
+func (l *ReplicationLimits) acquireImage(ctx context.Context) (func(), error) {
+	select {
+	case l.images <- struct{}{}:
+		return func() { <-l.images }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// wrapTransport returns a transport that holds a blob slot for every blob
+// request until its response body is closed.
+func (l *ReplicationLimits) wrapTransport(base http.RoundTripper) http.RoundTripper {
+	return &blobLimitTransport{base: base, sem: l.blobs}
+}
+
+type blobLimitTransport struct {
+	base http.RoundTripper
+	sem  chan struct{}
+}
+
+func (t *blobLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.Contains(req.URL.Path, "/blobs/") {
+		return t.base.RoundTrip(req)
+	}
+
+	select {
+	case t.sem <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		<-t.sem
+		return resp, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-t.sem }}
+	return resp, nil
+}
+
+// releasingBody frees its blob slot exactly once when closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,111 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/signature"
+)
+
+// EntityStatus is the outcome of replicating a single entity.
+type EntityStatus string
+
+const (
+	EntityReplicated EntityStatus = "replicated"
+	EntitySkipped    EntityStatus = "skipped"
+	EntityFailed     EntityStatus = "failed"
+)
+
+// EntityResult records what happened to one entity during a Replicate call.
+// Signature is nil unless signature verification is enabled.
+type EntityResult struct {
+	Entity    Entity
+	Status    EntityStatus
+	Err       error
+	Signature *signature.Result
+	Attempts  int
+	Duration  time.Duration
+}
+
+// ReplicationSummary collects the per-entity results of a Replicate call and
+// the blob bytes it downloaded from the source.
+type ReplicationSummary struct {
+	Results          []EntityResult
+	BytesTransferred int64
+}
+
+// Count returns the number of results with the given status.
+func (s ReplicationSummary) Count(status EntityStatus) int {
+	n := 0
+	for _, r := range s.Results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the results of entities that could not be replicated.
+func (s ReplicationSummary) Failed() []EntityResult {
+	var failed []EntityResult
+	for _, r := range s.Results {
+		if r.Status == EntityFailed {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Err aggregates the failures into a single error, or returns nil if every
+// entity was replicated or skipped.
+func (s ReplicationSummary) Err() error {
+	failed := s.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(failed))
+	for _, r := range failed {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", r.Entity.reference(), r.Err))
+	}
+	return fmt.Errorf("%d of %d entities failed to replicate: %s", len(failed), len(s.Results), strings.Join(msgs, "; "))
+}
+
+// reference returns the repository/name:tag form used in logs and errors.
+func (e Entity) reference() string {
+	return fmt.Sprintf("%s/%s:%s", e.Repository, e.Name, e.Tag)
+}
+
+// entityKey identifies an entity by name and tag, matching GetChanges.
+func entityKey(e Entity) string {
+	return e.Name + "|" + e.Tag
+}
+
+// FailedEntities returns the entities that could not be replicated.
+func (s ReplicationSummary) FailedEntities() []Entity {
+	var entities []Entity
+	for _, r := range s.Failed() {
+		entities = append(entities, r.Entity)
+	}
+	return entities
+}
+
+// withoutEntities drops the excluded entities, matched by name and tag. It is
+// used to leave failed or deferred entities out of the recorded group state so
+// that the next sync sees them as new and schedules them again.
+func withoutEntities(entities []Entity, exclude []Entity) []Entity {
+	if len(exclude) == 0 {
+		return entities
+	}
+	skip := make(map[string]struct{}, len(exclude))
+	for _, e := range exclude {
+		skip[entityKey(e)] = struct{}{}
+	}
+	var kept []Entity
+	for _, e := range entities {
+		if _, ok := skip[entityKey(e)]; !ok {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -1,28 +1,40 @@
 package state
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"github.com/container-registry/harbor-satellite/internal/signature"
 	satTLS "github.com/container-registry/harbor-satellite/internal/tls"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
-	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 type Replicator interface {
 	// Replicate copies images from the source registry to the local registry.
-	Replicate(ctx context.Context, replicationEntities []Entity) error
-	// DeleteReplicationEntity deletes the image from the local registry.
+	// Every entity is attempted; the summary records the outcome of each one and
+	// the returned error is non-nil if any entity failed or ctx was cancelled.
+	Replicate(ctx context.Context, replicationEntities []Entity) (ReplicationSummary, error)
+	// DeleteReplicationEntity deletes the image manifest, every tag pointing
+	// at it and its referrers from the local registry.
 	DeleteReplicationEntity(ctx context.Context, replicationEntity []Entity) error
+	// UntagReplicationEntity removes only the tag from the local registry,
+	// for manifests that other entities still reference.
+	UntagReplicationEntity(ctx context.Context, replicationEntity []Entity) error
 }
 
 type BasicReplicator struct {
@@ -34,14 +46,100 @@ type BasicReplicator struct {
 	remoteUsername    string
 	remotePassword    string
 	tlsCfg            config.TLSConfig
+	limits            *ReplicationLimits
+	retry             RetryPolicy
+	platforms         []v1.Platform
+	verifier          *signature.Verifier
+	bandwidth         *BandwidthLimiter
+	spool             *BlobSpool
+	spoolMinSize      int64
+	peers             []string
+	localCAFile       string
+	process           string
+	group             string
 }
 
-func NewBasicReplicator(sourceUsername, sourcePassword, sourceRegistry, remoteURL, remoteUsername, remotePassword string, useUnsecure bool) Replicator {
-	return NewBasicReplicatorWithTLS(sourceUsername, sourcePassword, sourceRegistry, remoteURL, remoteUsername, remotePassword, useUnsecure, config.TLSConfig{})
+// ErrDigestMismatch is returned when the source serves different content than
+// the digest recorded in the group state.
+var ErrDigestMismatch = errors.New("pulled content does not match declared digest")
+
+// ErrSignatureRejected is returned for entities refused by the signature
+// verification policy.
+var ErrSignatureRejected = errors.New("image refused by signature policy")
+
+// ReplicatorOption customises a BasicReplicator at construction time.
+type ReplicatorOption func(*BasicReplicator)
+
+// WithReplicationLimits bounds the images and blobs the replicator keeps in
+// flight. Replicators sharing the same limits share the global budget.
+func WithReplicationLimits(limits *ReplicationLimits) ReplicatorOption {
+	return func(r *BasicReplicator) {
+		r.limits = limits
+	}
+}
+
+// WithRetryPolicy sets how transient errors are retried per entity.
+func WithRetryPolicy(policy RetryPolicy) ReplicatorOption {
+	return func(r *BasicReplicator) {
+		r.retry = policy
+	}
+}
+
+// WithPlatforms trims replicated image indexes to the given platforms. Without
+// it, or with an empty list, indexes are replicated intact.
+func WithPlatforms(platforms []v1.Platform) ReplicatorOption {
+	return func(r *BasicReplicator) {
+		r.platforms = platforms
+	}
+}
+
+// WithBandwidthLimit caps this replicator's blob downloads at the given
+// bytes per second, on top of the global limit in its ReplicationLimits.
+func WithBandwidthLimit(bytesPerSecond int64) ReplicatorOption {
+	return func(r *BasicReplicator) {
+		r.bandwidth = NewBandwidthLimiter(bytesPerSecond)
+	}
+}
+
+// WithBlobSpool downloads layers of at least minSize bytes through the spool
+// so that interrupted transfers resume instead of starting over.
+func WithBlobSpool(spool *BlobSpool, minSize int64) ReplicatorOption {
+	return func(r *BasicReplicator) {
+		r.spool = spool
+		r.spoolMinSize = minSize
+	}
+}
+
+// WithMetricLabels sets the process and group the replicator's metrics are
+// labelled with.
+func WithMetricLabels(process, group string) ReplicatorOption {
+	return func(r *BasicReplicator) {
+		r.process = process
+		r.group = group
+	}
 }
 
-func NewBasicReplicatorWithTLS(sourceUsername, sourcePassword, sourceRegistry, remoteURL, remoteUsername, remotePassword string, useUnsecure bool, tlsCfg config.TLSConfig) Replicator {
-	return &BasicReplicator{
+// WithSignatureVerifier gates replication on the verifier's trust policy.
+func WithSignatureVerifier(v *signature.Verifier) ReplicatorOption {
+	return func(r *BasicReplicator) {
+		r.verifier = v
+	}
+}
+
+// WithLocalRegistryCA verifies the local registry, and peers, with the CA in
+// caFile when they are served over HTTPS.
+func WithLocalRegistryCA(caFile string) ReplicatorOption {
+	return func(r *BasicReplicator) {
+		r.localCAFile = caFile
+	}
+}
+
+func NewBasicReplicator(sourceUsername, sourcePassword, sourceRegistry, remoteURL, remoteUsername, remotePassword string, useUnsecure bool, opts ...ReplicatorOption) Replicator {
+	return NewBasicReplicatorWithTLS(sourceUsername, sourcePassword, sourceRegistry, remoteURL, remoteUsername, remotePassword, useUnsecure, config.TLSConfig{}, opts...)
+}
+
+func NewBasicReplicatorWithTLS(sourceUsername, sourcePassword, sourceRegistry, remoteURL, remoteUsername, remotePassword string, useUnsecure bool, tlsCfg config.TLSConfig, opts ...ReplicatorOption) Replicator {
+	r := &BasicReplicator{
 		sourceUsername:    sourceUsername,
 		sourcePassword:    sourcePassword,
 		useUnsecure:       useUnsecure,
@@ -50,7 +148,25 @@ func NewBasicReplicatorWithTLS(sourceUsername, sourcePassword, sourceRegistry, r
 		remoteUsername:    remoteUsername,
 		remotePassword:    remotePassword,
 		tlsCfg:            tlsCfg,
+		retry:             NewRetryPolicy(config.ReplicationConfig{}),
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.limits == nil {
+		r.limits = NewReplicationLimits(config.ReplicationConfig{})
+	}
+	return r
+}
+
+// withOptions returns a copy of the replicator with opts applied on top of
+// its current settings, leaving the original untouched.
+func (r *BasicReplicator) withOptions(opts ...ReplicatorOption) *BasicReplicator {
+	scoped := *r
+	for _, opt := range opts {
+		opt(&scoped)
+	}
+	return &scoped
 }
 
 // Entity represents an image or artifact which needs to be handled by the replicator
@@ -73,11 +189,22 @@ func (e Entity) GetTag() string {
 	return e.Tag
 }
 
-// Replicate replicates images from the source registry to the local registry.
-// Before pulling, it checks which blobs already exist at the destination and
-// only downloads missing layers from source, saving bandwidth on crash recovery.
-func (r *BasicReplicator) Replicate(ctx context.Context, replicationEntities []Entity) error {
-	log := logger.FromContext(ctx)
+// replicationOptions holds the registry options shared by every entity of a
+// single Replicate call.
+type replicationOptions struct {
+	nameOpts []name.Option
+	pullOpts []remote.Option
+	pushOpts []remote.Option
+
+	// pullAuth and pullTransport back the spool's own range requests.
+	pullAuth      authn.Authenticator
+	pullTransport http.RoundTripper
+
+	// transferred counts the blob bytes downloaded from the source.
+	transferred atomic.Int64
+}
+
+func (r *BasicReplicator) buildReplicationOptions(ctx context.Context) (*replicationOptions, error) {
 	pullAuth := authn.FromConfig(authn.AuthConfig{
 		Username: r.sourceUsername,
 		Password: r.sourcePassword,
@@ -87,92 +214,401 @@ func (r *BasicReplicator) Replicate(ctx context.Context, replicationEntities []E
 		Password: r.remotePassword,
 	})
 
-	var nameOpts []name.Option
-	pullOpts := []remote.Option{remote.WithAuth(pullAuth), remote.WithContext(ctx)}
-	pushOpts := []remote.Option{remote.WithAuth(pushAuth), remote.WithContext(ctx)}
+	opts := &replicationOptions{
+		pullOpts: []remote.Option{remote.WithAuth(pullAuth), remote.WithContext(ctx)},
+		pushOpts: []remote.Option{remote.WithAuth(pushAuth), remote.WithContext(ctx)},
+		pullAuth: pullAuth,
+	}
 
+	var transport http.RoundTripper = remote.DefaultTransport
 	if r.useUnsecure {
-		nameOpts = append(nameOpts, name.Insecure)
+		opts.nameOpts = append(opts.nameOpts, name.Insecure)
 	} else {
-		transport, err := r.buildTLSTransport()
+		tlsTransport, err := r.buildTLSTransport()
 		if err != nil {
-			return fmt.Errorf("build TLS transport: %w", err)
+			return nil, fmt.Errorf("build TLS transport: %w", err)
 		}
-		if transport != nil {
-			pullOpts = append(pullOpts, remote.WithTransport(transport))
-			pushOpts = append(pushOpts, remote.WithTransport(transport))
+		if tlsTransport != nil {
+			transport = tlsTransport
+			opts.pushOpts = append(opts.pushOpts, remote.WithTransport(tlsTransport))
 		}
 	}
+	if r.localCAFile != "" {
+		localTransport, err := localRegistryTransport(r.localCAFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.pushOpts = append(opts.pushOpts, remote.WithTransport(localTransport))
+	}
 
-	for _, entity := range replicationEntities {
-		// Check context cancellation before processing each image
+	// Only source downloads count against the blob budget. Uploads to the
+	// local registry are fed by those downloads, so limiting both would let a
+	// download hold the last slot its own upload needs. The same goes for
+	// bandwidth: only the link to the source is metered.
+	opts.pullTransport = throttleTransport(r.limits.wrapTransport(transport), r.limits.bandwidth, r.bandwidth)
+	opts.pullTransport = countTransport(opts.pullTransport, &opts.transferred, metrics.BytesReplicated.WithLabelValues(r.process, r.group))
+	opts.pullOpts = append(opts.pullOpts, remote.WithTransport(opts.pullTransport))
+
+	return opts, nil
+}
+
+// Replicate replicates images from the source registry to the local registry.
+// Entities are handed to a bounded worker pool; a failure of one entity does
+// not stop the others. Before pulling, it checks which blobs already exist at
+// the destination and only downloads missing layers from source, saving
+// bandwidth on crash recovery.
+func (r *BasicReplicator) Replicate(ctx context.Context, replicationEntities []Entity) (ReplicationSummary, error) {
+	log := logger.FromContext(ctx)
+	summary := ReplicationSummary{}
+
+	if len(replicationEntities) == 0 {
+		return summary, nil
+	}
+
+	opts, err := r.buildReplicationOptions(ctx)
+	if err != nil {
+		return summary, err
+	}
+
+	results := make([]EntityResult, len(replicationEntities))
+	dispatched := make([]bool, len(replicationEntities))
+
+	workers := r.limits.imagesPerGroup
+	if workers > len(replicationEntities) {
+		workers = len(replicationEntities)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = r.replicateEntity(ctx, replicationEntities[i], opts)
+			}
+		}()
+	}
+
+dispatch:
+	for i := range replicationEntities {
 		select {
 		case <-ctx.Done():
 			log.Warn().Err(ctx.Err()).Msg("Context cancelled, stopping replication")
-			return ctx.Err()
-		default:
+			break dispatch
+		case jobs <- i:
+			dispatched[i] = true
 		}
+	}
+	close(jobs)
+	wg.Wait()
 
-		srcRef := fmt.Sprintf("%s/%s/%s:%s", r.sourceRegistry, entity.GetRepository(), entity.GetName(), entity.GetTag())
-		dstRef := fmt.Sprintf("%s/%s/%s:%s", r.remoteRegistryURL, entity.GetRepository(), entity.GetName(), entity.GetTag())
-
-		src, err := name.ParseReference(srcRef, nameOpts...)
-		if err != nil {
-			return fmt.Errorf("parse source ref %s: %w", srcRef, err)
+	for i, ok := range dispatched {
+		if !ok {
+			results[i] = EntityResult{Entity: replicationEntities[i], Status: EntityFailed, Err: ctx.Err()}
 		}
+	}
+	summary.Results = results
+	summary.BytesTransferred = opts.transferred.Load()
 
-		dst, err := name.ParseReference(dstRef, nameOpts...)
-		if err != nil {
-			return fmt.Errorf("parse dest ref %s: %w", dstRef, err)
-		}
+	if ctx.Err() != nil {
+		return summary, ctx.Err()
+	}
+	return summary, summary.Err()
+}
 
-		// Lazy fetch: only the manifest is downloaded, no layer data yet
-		desc, err := remote.Get(src, pullOpts...)
-		if err != nil {
-			log.Error().Msgf("Failed to fetch image descriptor: %v", err)
-			return err
+// replicateEntity copies a single image, retrying transient registry errors
+// with jittered exponential backoff.
+func (r *BasicReplicator) replicateEntity(ctx context.Context, entity Entity, opts *replicationOptions) EntityResult {
+	log := logger.FromContext(ctx)
+	start := time.Now()
+
+	var status EntityStatus
+	var verification *signature.Result
+	attempts, err := retryTransient(ctx, r.retry, func() error {
+		var copyErr error
+		status, verification, copyErr = r.copyEntity(ctx, entity, opts)
+		if copyErr != nil && isTransientError(copyErr) {
+			log.Warn().Err(copyErr).Str("entity", entity.reference()).Msg("Transient error replicating image, retrying")
 		}
+		return copyErr
+	})
 
-		img, err := desc.Image()
-		if err != nil {
-			log.Error().Msgf("Failed to resolve image: %v", err)
-			return err
-		}
+	result := EntityResult{Entity: entity, Status: status, Signature: verification, Attempts: attempts, Duration: time.Since(start)}
+	if err != nil {
+		log.Error().Err(err).Str("entity", entity.reference()).Int("attempts", attempts).Msg("Failed to replicate image")
+		result.Status = EntityFailed
+		result.Err = err
+	}
+	return result
+}
+
+// copyEntity performs one replication attempt, holding a slot of the global
+// image budget for the duration of the transfer. If a signature verifier is
+// set, the image is checked against the trust policy before anything is
+// written locally and the verdict is returned alongside the status.
+func (r *BasicReplicator) copyEntity(ctx context.Context, entity Entity, opts *replicationOptions) (EntityStatus, *signature.Result, error) {
+	log := logger.FromContext(ctx)
+
+	release, err := r.limits.acquireImage(ctx)
+	if err != nil {
+		return EntityFailed, nil, err
+	}
+	defer release()
+
+	// The source is pulled by the digest the group state declares, so a tag
+	// moved after the state was published cannot change what lands here.
+	// Only entities without a digest fall back to the tag.
+	srcRef := fmt.Sprintf("%s/%s/%s:%s", r.sourceRegistry, entity.GetRepository(), entity.GetName(), entity.GetTag())
+	if entity.Digest != "" {
+		srcRef = fmt.Sprintf("%s/%s/%s@%s", r.sourceRegistry, entity.GetRepository(), entity.GetName(), entity.Digest)
+	}
+	dstRef := fmt.Sprintf("%s/%s/%s:%s", r.remoteRegistryURL, entity.GetRepository(), entity.GetName(), entity.GetTag())
+
+	src, err := name.ParseReference(srcRef, opts.nameOpts...)
+	if err != nil {
+		return EntityFailed, nil, fmt.Errorf("parse source ref %s: %w", srcRef, err)
+	}
+
+	dst, err := name.ParseReference(dstRef, opts.nameOpts...)
+	if err != nil {
+		return EntityFailed, nil, fmt.Errorf("parse dest ref %s: %w", dstRef, err)
+	}
 
-		// Lazy OCI conversion, no data materialized
-		ociImage := mutate.MediaType(img, types.OCIManifestSchema1)
+	// Lazy fetch: only the manifest is downloaded, no layer data yet
+	desc, err := remote.Get(src, opts.pullOpts...)
+	if err != nil {
+		return EntityFailed, nil, fmt.Errorf("fetch image descriptor: %w", err)
+	}
+	if entity.Digest != "" && desc.Digest.String() != entity.Digest {
+		return EntityFailed, nil, fmt.Errorf("%w: state declares %s, source served %s", ErrDigestMismatch, entity.Digest, desc.Digest)
+	}
 
-		// Check if image already exists at destination with same digest
-		srcDigest, err := ociImage.Digest()
+	var verification *signature.Result
+	if r.verifier != nil {
+		result, err := r.verifier.Verify(ctx, src.Context().Digest(desc.Digest.String()), opts.pullOpts...)
 		if err != nil {
-			return fmt.Errorf("compute source digest: %w", err)
+			return EntityFailed, nil, fmt.Errorf("verify signature: %w", err)
 		}
+		verification = &result
+		if !r.verifier.Admits(result) {
+			return EntityFailed, verification, fmt.Errorf("%w: %s %s", ErrSignatureRejected, result.Verdict, result.Reason)
+		}
+		log.Info().Str("verdict", string(result.Verdict)).Str("signer", result.Signer).Msgf("Signature check passed for image %s", entity.GetName())
+	}
+
+	spooler := r.newLayerSpooler(ctx, src.Context(), opts)
+	peers := r.newPeerSource(ctx, entity)
+	subject := desc.Digest
+	var status EntityStatus
+	if desc.MediaType.IsIndex() {
+		status, subject, err = r.copyIndex(ctx, entity, desc, dst, spooler, peers, opts)
+	} else {
+		status, err = r.copyImage(ctx, entity, desc, dst, spooler, peers, opts)
+	}
+	if err != nil {
+		return EntityFailed, verification, err
+	}
+
+	// An index trimmed by the platform filter has a digest of its own. The
+	// referrers of the source index name, and sign, the source digest, so
+	// they would refer to a manifest that does not exist locally.
+	if subject != desc.Digest {
+		log.Info().Str("source_digest", desc.Digest.String()).Str("local_digest", subject.String()).
+			Msgf("Image index %s was trimmed to the platform filter, its referrers are not replicated", entity.GetName())
+		return status, verification, nil
+	}
+
+	// Signatures, SBOMs and attestations are checked on every pass, skipped
+	// images included, since they are often attached after the image is
+	// pushed.
+	copied, err := copyReferrers(src.Context(), dst.Context(), subject, opts)
+	if err != nil {
+		return EntityFailed, verification, fmt.Errorf("replicate referrers: %w", err)
+	}
+	if copied > 0 {
+		log.Info().Int("referrers", copied).Msgf("Replicated referrers of image %s", entity.GetName())
+	}
+
+	return status, verification, nil
+}
+
+// copyImage replicates a single-platform image. Docker and OCI manifests
+// alike are pushed unchanged, so that the image keeps the digest the group
+// state pins and its signatures and other referrers refer to.
+func (r *BasicReplicator) copyImage(ctx context.Context, entity Entity, desc *remote.Descriptor, dst name.Reference, spooler *layerSpooler, peers *peerSource, opts *replicationOptions) (EntityStatus, error) {
+	log := logger.FromContext(ctx)
+
+	img, err := desc.Image()
+	if err != nil {
+		return EntityFailed, fmt.Errorf("resolve image: %w", err)
+	}
+
+	// Check if image already exists at destination with same digest
+	srcDigest, err := img.Digest()
+	if err != nil {
+		return EntityFailed, fmt.Errorf("compute source digest: %w", err)
+	}
+
+	dstDesc, dstErr := remote.Head(dst, opts.pushOpts...)
+	if dstErr == nil && dstDesc.Digest == srcDigest {
+		log.Info().Msgf("Image %s already up-to-date at destination, skipping", entity.GetName())
+		return EntitySkipped, nil
+	}
+
+	// Log which layers need pulling vs already present
+	srcLayers, err := img.Layers()
+	if err != nil {
+		return EntityFailed, fmt.Errorf("get source layers: %w", err)
+	}
+
+	missing := r.countMissingLayers(dst, srcLayers, opts.pushOpts)
+	metrics.LayerCacheHits.WithLabelValues(r.process, r.group).Add(float64(len(srcLayers) - missing))
+	metrics.LayerCacheMisses.WithLabelValues(r.process, r.group).Add(float64(missing))
+	log.Info().Msgf("Replicating image %s: %d/%d layers to pull", entity.GetName(), missing, len(srcLayers))
+
+	// remote.Write streams layers one-by-one. For each layer it HEAD-checks
+	// the destination first; only missing blobs are pulled, from a peer if
+	// one has them and from source otherwise.
+	// Manifest is pushed last.
+	if err := remote.Write(dst, peers.image(spooler.image(img)), opts.pushOpts...); err != nil {
+		return EntityFailed, fmt.Errorf("write image: %w", err)
+	}
+	spooler.release()
+	log.Info().Msgf("Image %s replicated successfully", entity.GetName())
+
+	return EntityReplicated, nil
+}
+
+// copyIndex replicates a manifest list or OCI index without converting it, so
+// every platform keeps its own image. If the replicator has a platform filter,
+// the index is trimmed to the matching platforms first. It returns the digest
+// of the index at the destination, which differs from the source one if the
+// index was trimmed.
+func (r *BasicReplicator) copyIndex(ctx context.Context, entity Entity, desc *remote.Descriptor, dst name.Reference, spooler *layerSpooler, peers *peerSource, opts *replicationOptions) (EntityStatus, v1.Hash, error) {
+	log := logger.FromContext(ctx)
+
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return EntityFailed, v1.Hash{}, fmt.Errorf("resolve image index: %w", err)
+	}
+
+	idx, kept, total, err := filterIndex(idx, r.platforms)
+	if err != nil {
+		return EntityFailed, v1.Hash{}, err
+	}
 
-		dstDesc, dstErr := remote.Head(dst, pushOpts...)
-		if dstErr == nil && dstDesc.Digest == srcDigest {
-			log.Info().Msgf("Image %s already up-to-date at destination, skipping", entity.GetName())
+	srcDigest, err := idx.Digest()
+	if err != nil {
+		return EntityFailed, v1.Hash{}, fmt.Errorf("compute source index digest: %w", err)
+	}
+
+	dstDesc, dstErr := remote.Head(dst, opts.pushOpts...)
+	if dstErr == nil && dstDesc.Digest == srcDigest {
+		log.Info().Msgf("Image index %s already up-to-date at destination, skipping", entity.GetName())
+		return EntitySkipped, srcDigest, nil
+	}
+
+	log.Info().Msgf("Replicating image index %s: %d/%d manifests", entity.GetName(), kept, total)
+
+	// WriteIndex pushes every child manifest and its blobs before the index
+	// itself, skipping blobs that already exist at the destination.
+	if err := remote.WriteIndex(dst, peers.index(spooler.index(idx)), opts.pushOpts...); err != nil {
+		return EntityFailed, v1.Hash{}, fmt.Errorf("write image index: %w", err)
+	}
+	spooler.release()
+	log.Info().Msgf("Image index %s replicated successfully", entity.GetName())
+
+	return EntityReplicated, srcDigest, nil
+}
+
+// Annotations buildkit sets on attestation manifests, which carry the
+// unknown/unknown platform and point at the image they describe.
+const (
+	referenceTypeAnnotation   = "vnd.docker.reference.type"
+	referenceDigestAnnotation = "vnd.docker.reference.digest"
+	attestationManifestType   = "attestation-manifest"
+)
+
+// filterIndex removes the manifests of idx whose platform matches none of
+// platforms. Attestation manifests are kept together with the image they
+// refer to. It returns the filtered index with the number of manifests kept
+// and the number in the original index. An empty platforms list keeps the
+// index unchanged.
+func filterIndex(idx v1.ImageIndex, platforms []v1.Platform) (v1.ImageIndex, int, int, error) {
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("read index manifest: %w", err)
+	}
+	total := len(manifest.Manifests)
+	if len(platforms) == 0 {
+		return idx, total, total, nil
+	}
+
+	keep := make(map[v1.Hash]struct{}, total)
+	for _, m := range manifest.Manifests {
+		if m.Platform != nil && matchesPlatform(*m.Platform, platforms) {
+			keep[m.Digest] = struct{}{}
+		}
+	}
+	if len(keep) == 0 {
+		return nil, 0, total, fmt.Errorf("image index has no manifest for platforms %s", formatPlatforms(platforms))
+	}
+	for _, m := range manifest.Manifests {
+		if m.Annotations[referenceTypeAnnotation] != attestationManifestType {
 			continue
 		}
-
-		// Log which layers need pulling vs already present
-		srcLayers, err := ociImage.Layers()
+		subject, err := v1.NewHash(m.Annotations[referenceDigestAnnotation])
 		if err != nil {
-			return fmt.Errorf("get source layers: %w", err)
+			continue
 		}
+		if _, ok := keep[subject]; ok {
+			keep[m.Digest] = struct{}{}
+		}
+	}
 
-		missing := r.countMissingLayers(dst, srcLayers, pushOpts)
-		log.Info().Msgf("Replicating image %s: %d/%d layers to pull", entity.GetName(), missing, len(srcLayers))
+	if len(keep) == total {
+		return idx, total, total, nil
+	}
+	filtered := mutate.RemoveManifests(idx, func(desc v1.Descriptor) bool {
+		_, ok := keep[desc.Digest]
+		return !ok
+	})
+	return filtered, len(keep), total, nil
+}
 
-		// remote.Write streams layers one-by-one. For each layer it HEAD-checks
-		// the destination first; only missing blobs are pulled from source.
-		// Manifest is pushed last.
-		if err := remote.Write(dst, ociImage, pushOpts...); err != nil {
-			log.Error().Msgf("Failed to replicate image: %v", err)
-			return err
+// matchesPlatform reports whether p satisfies any of the wanted platforms. A
+// wanted platform without a variant matches every variant of its
+// architecture.
+func matchesPlatform(p v1.Platform, wanted []v1.Platform) bool {
+	for _, w := range wanted {
+		if p.Satisfies(w) {
+			return true
 		}
-		log.Info().Msgf("Image %s replicated successfully", entity.GetName())
 	}
-	return nil
+	return false
+}
+
+func formatPlatforms(platforms []v1.Platform) string {
+	names := make([]string, 0, len(platforms))
+	for _, p := range platforms {
+		names = append(names, p.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParsePlatforms converts platform strings such as "linux/arm/v7" into
+// platforms usable with WithPlatforms.
+func ParsePlatforms(values []string) ([]v1.Platform, error) {
+	platforms := make([]v1.Platform, 0, len(values))
+	for _, v := range values {
+		p, err := v1.ParsePlatform(v)
+		if err != nil {
+			return nil, fmt.Errorf("parse platform %q: %w", v, err)
+		}
+		platforms = append(platforms, *p)
+	}
+	return platforms, nil
 }
 
 // countMissingLayers checks which source layers are absent from the destination
@@ -214,14 +650,9 @@ func (r *BasicReplicator) countMissingLayers(dst name.Reference, srcLayers []v1.
 
 func (r *BasicReplicator) DeleteReplicationEntity(ctx context.Context, replicationEntity []Entity) error {
 	log := logger.FromContext(ctx)
-	auth := authn.FromConfig(authn.AuthConfig{
-		Username: r.remoteUsername,
-		Password: r.remotePassword,
-	})
-
-	options := []crane.Option{crane.WithAuth(auth), crane.WithContext(ctx)}
-	if r.useUnsecure {
-		options = append(options, crane.Insecure)
+	opts, nameOpts, err := r.deleteOptions(ctx)
+	if err != nil {
+		return err
 	}
 
 	for _, entity := range replicationEntity {
@@ -235,17 +666,113 @@ func (r *BasicReplicator) DeleteReplicationEntity(ctx context.Context, replicati
 
 		log.Info().Msgf("Deleting image %s from repository %s at registry %s with tag %s", entity.GetName(), entity.GetRepository(), r.remoteRegistryURL, entity.GetTag())
 
-		err := crane.Delete(fmt.Sprintf("%s/%s/%s:%s", r.remoteRegistryURL, entity.GetRepository(), entity.GetName(), entity.GetTag()), options...)
+		imageRef := fmt.Sprintf("%s/%s/%s:%s", r.remoteRegistryURL, entity.GetRepository(), entity.GetName(), entity.GetTag())
+		ref, err := name.ParseReference(imageRef, nameOpts...)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", imageRef, err)
+		}
+
+		desc, err := remote.Get(ref, opts...)
+		if isNotFound(err) {
+			log.Info().Msgf("Image %s is already gone", entity.GetName())
+			continue
+		}
 		if err != nil {
 			log.Error().Msgf("Failed to delete image: %v", err)
-			return err
+			return fmt.Errorf("resolve %s: %w", imageRef, err)
+		}
+
+		// Referrers are pruned first: once the manifest is gone there is no
+		// way to find what was attached to it.
+		if err := deleteReferrersOf(ctx, ref.Context(), desc.Digest, opts, maxReferrerDepth); err != nil {
+			log.Warn().Err(err).Msgf("Failed to prune referrers of image %s", entity.GetName())
 		}
+
+		// Registries differ in whether deleting a tag or a digest removes the
+		// other, so both are deleted.
+		for _, target := range []name.Reference{ref, ref.Context().Digest(desc.Digest.String())} {
+			if err := remote.Delete(target, opts...); err != nil && !isNotFound(err) {
+				log.Error().Msgf("Failed to delete image: %v", err)
+				return err
+			}
+		}
+		metrics.BytesDeleted.WithLabelValues(r.process, r.group).Add(float64(referencedSize(desc)))
 		log.Info().Msgf("Image %s deleted successfully", entity.GetName())
 	}
 
 	return nil
 }
 
+// referencedSize returns the size of the manifest and of the blobs or child
+// manifests it references.
+func referencedSize(desc *remote.Descriptor) int64 {
+	size := desc.Size
+	if desc.MediaType.IsIndex() {
+		if idx, err := v1.ParseIndexManifest(bytes.NewReader(desc.Manifest)); err == nil {
+			for _, m := range idx.Manifests {
+				size += m.Size
+			}
+		}
+		return size
+	}
+	if m, err := v1.ParseManifest(bytes.NewReader(desc.Manifest)); err == nil {
+		size += m.Config.Size
+		for _, l := range m.Layers {
+			size += l.Size
+		}
+	}
+	return size
+}
+
+func (r *BasicReplicator) UntagReplicationEntity(ctx context.Context, replicationEntity []Entity) error {
+	log := logger.FromContext(ctx)
+	opts, nameOpts, err := r.deleteOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, entity := range replicationEntity {
+		if err := ctx.Err(); err != nil {
+			log.Warn().Err(err).Msg("Context cancelled, stopping deletion")
+			return err
+		}
+
+		imageRef := fmt.Sprintf("%s/%s/%s:%s", r.remoteRegistryURL, entity.GetRepository(), entity.GetName(), entity.GetTag())
+		ref, err := name.NewTag(imageRef, nameOpts...)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", imageRef, err)
+		}
+		if err := remote.Delete(ref, opts...); err != nil && !isNotFound(err) {
+			log.Error().Msgf("Failed to untag image: %v", err)
+			return err
+		}
+		log.Info().Msgf("Tag %s of image %s removed, its manifest is still referenced", entity.GetTag(), entity.GetName())
+	}
+
+	return nil
+}
+
+// deleteOptions returns the options used to delete from the local registry.
+func (r *BasicReplicator) deleteOptions(ctx context.Context) ([]remote.Option, []name.Option, error) {
+	auth := authn.FromConfig(authn.AuthConfig{
+		Username: r.remoteUsername,
+		Password: r.remotePassword,
+	})
+	opts := []remote.Option{remote.WithAuth(auth), remote.WithContext(ctx)}
+	if r.localCAFile != "" {
+		transport, err := localRegistryTransport(r.localCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, remote.WithTransport(transport))
+	}
+	var nameOpts []name.Option
+	if r.useUnsecure {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	return opts, nameOpts, nil
+}
+
 func (r *BasicReplicator) buildTLSTransport() (http.RoundTripper, error) {
 	if r.tlsCfg.CertFile == "" && r.tlsCfg.CAFile == "" {
 		return nil, nil
@@ -268,3 +795,32 @@ func (r *BasicReplicator) buildTLSTransport() (http.RoundTripper, error) {
 		TLSClientConfig: tlsConfig,
 	}, nil
 }
+
+// localRegistryTransport returns a transport that verifies the local registry
+// with the CA in caFile, or the system's trusted CAs if caFile is empty.
+func localRegistryTransport(caFile string) (*http.Transport, error) {
+	base, ok := remote.DefaultTransport.(*http.Transport)
+	if !ok {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	t := base.Clone()
+	if caFile == "" {
+		return t, nil
+	}
+	tlsConfig, err := satTLS.LoadClientTLSConfig(&satTLS.Config{CAFile: caFile, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		return nil, fmt.Errorf("load local registry CA: %w", err)
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// localRegistryAuth returns the credentials the satellite uses with its local
+// registry, or anonymous access if it has none.
+func localRegistryAuth(cm *config.ConfigManager) authn.Authenticator {
+	creds := cm.GetRemoteRegistryCredentials()
+	if creds.Username == "" {
+		return authn.Anonymous
+	}
+	return authn.FromConfig(authn.AuthConfig{Username: creds.Username, Password: creds.Password})
+}
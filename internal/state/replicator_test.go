@@ -2,17 +2,26 @@ package state
 
 import (
 	"context"
+	"encoding/pem"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
-	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/stretchr/testify/require"
 )
 
@@ -47,7 +56,7 @@ func TestReplicate_NewImage(t *testing.T) {
 	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
 	ctx := testContext()
 
-	err := r.Replicate(ctx, []Entity{
+	_, err := r.Replicate(ctx, []Entity{
 		{Name: "alpine", Repository: "library", Tag: "latest"},
 	})
 	require.NoError(t, err)
@@ -74,7 +83,7 @@ func TestReplicate_SkipsExistingImage(t *testing.T) {
 	ctx := testContext()
 
 	// Should succeed without error and skip the image
-	err = r.Replicate(ctx, []Entity{
+	_, err = r.Replicate(ctx, []Entity{
 		{Name: "nginx", Repository: "library", Tag: "1.25"},
 	})
 	require.NoError(t, err)
@@ -93,7 +102,7 @@ func TestReplicate_UpdatesChangedImage(t *testing.T) {
 	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
 	ctx := testContext()
 
-	err := r.Replicate(ctx, []Entity{
+	_, err := r.Replicate(ctx, []Entity{
 		{Name: "redis", Repository: "library", Tag: "7"},
 	})
 	require.NoError(t, err)
@@ -108,10 +117,8 @@ func TestReplicate_UpdatesChangedImage(t *testing.T) {
 	srcDigest, err := srcImg.Digest()
 	require.NoError(t, err)
 
-	// Digests won't match exactly due to OCI media type conversion,
-	// but the image should exist at destination
-	require.NotEmpty(t, dstDesc.Digest)
-	_ = srcDigest
+	// The manifest is pushed unchanged, so the digests match
+	require.Equal(t, srcDigest, dstDesc.Digest)
 }
 
 func TestReplicate_MultipleEntities(t *testing.T) {
@@ -124,7 +131,7 @@ func TestReplicate_MultipleEntities(t *testing.T) {
 	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
 	ctx := testContext()
 
-	err := r.Replicate(ctx, []Entity{
+	_, err := r.Replicate(ctx, []Entity{
 		{Name: "alpine", Repository: "library", Tag: "latest"},
 		{Name: "nginx", Repository: "library", Tag: "1.25"},
 	})
@@ -150,10 +157,374 @@ func TestReplicate_SourceNotFound(t *testing.T) {
 	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
 	ctx := testContext()
 
-	err := r.Replicate(ctx, []Entity{
+	_, err := r.Replicate(ctx, []Entity{
+		{Name: "missing", Repository: "library", Tag: "latest"},
+	})
+	require.Error(t, err)
+}
+
+func TestReplicate_ContinuesAfterFailure(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+
+	pushImage(t, srcAddr, "library", "alpine", "latest", 1)
+	pushImage(t, srcAddr, "library", "nginx", "1.25", 1)
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	ctx := testContext()
+
+	summary, err := r.Replicate(ctx, []Entity{
+		{Name: "alpine", Repository: "library", Tag: "latest"},
+		{Name: "missing", Repository: "library", Tag: "latest"},
+		{Name: "nginx", Repository: "library", Tag: "1.25"},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "1 of 3 entities failed")
+
+	require.Len(t, summary.Results, 3)
+	require.Equal(t, 2, summary.Count(EntityReplicated))
+	failed := summary.Failed()
+	require.Len(t, failed, 1)
+	require.Equal(t, "missing", failed[0].Entity.Name)
+
+	// The failure must not have prevented the later entity from replicating
+	parsed, err := name.ParseReference(dstAddr+"/library/nginx:1.25", name.Insecure)
+	require.NoError(t, err)
+	_, err = remote.Head(parsed)
+	require.NoError(t, err)
+}
+
+func TestReplicate_RetriesTransientErrors(t *testing.T) {
+	src := registry.New()
+	var armed atomic.Bool
+	var failures atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// 501 is outside the status codes the registry client retries on its
+		// own, so only the per-entity retry can recover from it.
+		if armed.Load() && strings.Contains(req.URL.Path, "/manifests/") && failures.Add(1) <= 2 {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		src.ServeHTTP(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	srcAddr := strings.TrimPrefix(srv.URL, "http://")
+	_, dstAddr := newTestRegistry(t)
+
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(srcAddr+"/library/flaky:v1", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	armed.Store(true)
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithRetryPolicy(fastRetryPolicy(4)))
+
+	summary, err := r.Replicate(testContext(), []Entity{
+		{Name: "flaky", Repository: "library", Tag: "v1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.Count(EntityReplicated))
+	require.Equal(t, 3, summary.Results[0].Attempts)
+}
+
+func TestReplicate_DoesNotRetryPermanentErrors(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithRetryPolicy(fastRetryPolicy(4)))
+
+	summary, err := r.Replicate(testContext(), []Entity{
 		{Name: "missing", Repository: "library", Tag: "latest"},
 	})
 	require.Error(t, err)
+	require.Equal(t, 1, summary.Results[0].Attempts)
+}
+
+func TestReplicate_SummaryReportsSkipped(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+
+	img := pushImage(t, srcAddr, "library", "nginx", "1.25", 1)
+	dstRef, err := name.ParseReference(dstAddr+"/library/nginx:1.25", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(dstRef, img))
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+
+	summary, err := r.Replicate(testContext(), []Entity{
+		{Name: "nginx", Repository: "library", Tag: "1.25"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.Count(EntitySkipped))
+}
+
+func TestReplicate_RespectsGlobalImageLimit(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	src := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.URL.Path, "/manifests/") && req.Method == http.MethodGet {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		src.ServeHTTP(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	srcAddr := strings.TrimPrefix(srv.URL, "http://")
+	_, dstAddr := newTestRegistry(t)
+
+	var entities []Entity
+	for _, n := range []string{"a", "b", "c", "d", "e", "f"} {
+		pushImage(t, srcAddr, "library", n, "v1", 1)
+		entities = append(entities, Entity{Name: n, Repository: "library", Tag: "v1"})
+	}
+
+	// Two groups sharing the same global budget of two images
+	limits := NewReplicationLimits(config.ReplicationConfig{
+		MaxConcurrentImages:         2,
+		MaxConcurrentImagesPerGroup: 2,
+	})
+	r1 := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithReplicationLimits(limits))
+	r2 := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithReplicationLimits(limits))
+
+	var wg sync.WaitGroup
+	for _, r := range []Replicator{r1, r2} {
+		wg.Add(1)
+		go func(r Replicator) {
+			defer wg.Done()
+			_, err := r.Replicate(testContext(), entities)
+			require.NoError(t, err)
+		}(r)
+	}
+	wg.Wait()
+
+	require.LessOrEqual(t, peak.Load(), int32(2))
+}
+
+func TestNewReplicationLimits_Defaults(t *testing.T) {
+	limits := NewReplicationLimits(config.ReplicationConfig{})
+	require.Equal(t, config.DefaultMaxConcurrentImagesPerGroup, limits.imagesPerGroup)
+	require.Equal(t, config.DefaultMaxConcurrentImages, cap(limits.images))
+	require.Equal(t, config.DefaultMaxConcurrentBlobs, cap(limits.blobs))
+
+	clamped := NewReplicationLimits(config.ReplicationConfig{MaxConcurrentImages: 1, MaxConcurrentImagesPerGroup: 5})
+	require.Equal(t, 1, clamped.imagesPerGroup)
+}
+
+// pushIndex pushes a multi-platform OCI index with one random image per
+// platform and returns it.
+func pushIndex(t *testing.T, addr, repo, imgName, tag string, platforms ...string) v1.ImageIndex {
+	t.Helper()
+	idx := mutate.IndexMediaType(empty.Index, types.OCIImageIndex)
+	for _, p := range platforms {
+		img, err := random.Image(512, 1)
+		require.NoError(t, err)
+		platform, err := v1.ParsePlatform(p)
+		require.NoError(t, err)
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add:        mutate.MediaType(img, types.OCIManifestSchema1),
+			Descriptor: v1.Descriptor{Platform: platform},
+		})
+	}
+
+	ref, err := name.ParseReference(addr+"/"+repo+"/"+imgName+":"+tag, name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, idx))
+	return idx
+}
+
+func pulledPlatforms(t *testing.T, addr, ref string) []string {
+	t.Helper()
+	r, err := name.ParseReference(addr+"/"+ref, name.Insecure)
+	require.NoError(t, err)
+	idx, err := remote.Index(r)
+	require.NoError(t, err)
+	manifest, err := idx.IndexManifest()
+	require.NoError(t, err)
+
+	var platforms []string
+	for _, m := range manifest.Manifests {
+		platforms = append(platforms, m.Platform.String())
+	}
+	return platforms
+}
+
+func TestReplicate_ImageIndexIntact(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+
+	src := pushIndex(t, srcAddr, "library", "multi", "v1", "linux/amd64", "linux/arm64", "linux/arm/v7")
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	_, err := r.Replicate(testContext(), []Entity{{Name: "multi", Repository: "library", Tag: "v1"}})
+	require.NoError(t, err)
+
+	dstRef, err := name.ParseReference(dstAddr+"/library/multi:v1", name.Insecure)
+	require.NoError(t, err)
+	desc, err := remote.Head(dstRef)
+	require.NoError(t, err)
+	srcDigest, err := src.Digest()
+	require.NoError(t, err)
+	require.Equal(t, srcDigest, desc.Digest)
+	require.Equal(t, types.OCIImageIndex, desc.MediaType)
+
+	summary, err := r.Replicate(testContext(), []Entity{{Name: "multi", Repository: "library", Tag: "v1"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.Count(EntitySkipped))
+}
+
+func TestReplicate_ImageIndexPlatformFilter(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+
+	pushIndex(t, srcAddr, "library", "multi", "v1", "linux/amd64", "linux/arm64", "linux/arm/v7", "linux/arm/v6")
+
+	platforms, err := ParsePlatforms([]string{"linux/arm64", "linux/arm/v7"})
+	require.NoError(t, err)
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithPlatforms(platforms))
+
+	_, err = r.Replicate(testContext(), []Entity{{Name: "multi", Repository: "library", Tag: "v1"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"linux/arm64", "linux/arm/v7"}, pulledPlatforms(t, dstAddr, "library/multi:v1"))
+}
+
+func TestReplicate_ImageIndexNoMatchingPlatform(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+
+	pushIndex(t, srcAddr, "library", "multi", "v1", "linux/amd64")
+
+	platforms, err := ParsePlatforms([]string{"linux/arm64"})
+	require.NoError(t, err)
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithPlatforms(platforms))
+
+	summary, err := r.Replicate(testContext(), []Entity{{Name: "multi", Repository: "library", Tag: "v1"}})
+	require.Error(t, err)
+	require.Contains(t, summary.Results[0].Err.Error(), "linux/arm64")
+	require.Equal(t, 1, summary.Results[0].Attempts)
+}
+
+func TestFilterIndex_KeepsAttestationsOfKeptImages(t *testing.T) {
+	amd, err := random.Image(256, 1)
+	require.NoError(t, err)
+	arm, err := random.Image(256, 1)
+	require.NoError(t, err)
+	armDigest, err := arm.Digest()
+	require.NoError(t, err)
+	amdDigest, err := amd.Digest()
+	require.NoError(t, err)
+	armAttestation, err := random.Image(64, 1)
+	require.NoError(t, err)
+	amdAttestation, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	unknown := &v1.Platform{OS: "unknown", Architecture: "unknown"}
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+		mutate.IndexAddendum{Add: amdAttestation, Descriptor: v1.Descriptor{Platform: unknown, Annotations: map[string]string{
+			referenceTypeAnnotation: attestationManifestType, referenceDigestAnnotation: amdDigest.String(),
+		}}},
+		mutate.IndexAddendum{Add: armAttestation, Descriptor: v1.Descriptor{Platform: unknown, Annotations: map[string]string{
+			referenceTypeAnnotation: attestationManifestType, referenceDigestAnnotation: armDigest.String(),
+		}}},
+	)
+
+	filtered, kept, total, err := filterIndex(idx, []v1.Platform{{OS: "linux", Architecture: "arm64"}})
+	require.NoError(t, err)
+	require.Equal(t, 2, kept)
+	require.Equal(t, 4, total)
+
+	manifest, err := filtered.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Manifests, 2)
+	require.Equal(t, armDigest, manifest.Manifests[0].Digest)
+}
+
+func TestGroupNameFromStateURL(t *testing.T) {
+	require.Equal(t, "edge", groupNameFromStateURL("registry.example.com/satellite/group-state/edge/state:latest"))
+	require.Equal(t, "edge", groupNameFromStateURL("https://registry.example.com/satellite/group-state/edge/state:latest"))
+	require.Equal(t, "", groupNameFromStateURL("registry.example.com/satellite/satellite-state/sat-1/state:latest"))
+}
+
+func TestReplicate_PullsDeclaredDigest(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+
+	declared := pushImage(t, srcAddr, "library", "app", "v1", 1)
+	declaredDigest, err := declared.Digest()
+	require.NoError(t, err)
+
+	// The tag moves after the group state was published
+	pushImage(t, srcAddr, "library", "app", "v1", 1)
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	_, err = r.Replicate(testContext(), []Entity{
+		{Name: "app", Repository: "library", Tag: "v1", Digest: declaredDigest.String()},
+	})
+	require.NoError(t, err)
+
+	// The local tag holds the declared Docker manifest itself, not the
+	// moved tag nor a conversion of it
+	dstRef, err := name.ParseReference(dstAddr+"/library/app:v1", name.Insecure)
+	require.NoError(t, err)
+	desc, err := remote.Head(dstRef)
+	require.NoError(t, err)
+	require.Equal(t, declaredDigest, desc.Digest, "local tag should hold the declared image, not the moved tag")
+	require.Equal(t, types.DockerManifestSchema2, desc.MediaType)
+}
+
+func TestReplicate_DigestMismatchFails(t *testing.T) {
+	src := registry.New()
+	var served atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Serve the tagged manifest whatever digest is asked for
+		if tag, ok := served.Load().(string); ok && strings.Contains(req.URL.Path, "/manifests/sha256:") {
+			req.URL.Path = req.URL.Path[:strings.Index(req.URL.Path, "/manifests/")] + "/manifests/" + tag
+		}
+		src.ServeHTTP(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	srcAddr := strings.TrimPrefix(srv.URL, "http://")
+	_, dstAddr := newTestRegistry(t)
+
+	declared := pushImage(t, srcAddr, "library", "app", "v1", 1)
+	declaredDigest, err := declared.Digest()
+	require.NoError(t, err)
+	pushImage(t, srcAddr, "library", "app", "v2", 1)
+	served.Store("v2")
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	summary, err := r.Replicate(testContext(), []Entity{
+		{Name: "app", Repository: "library", Tag: "v1", Digest: declaredDigest.String()},
+	})
+	require.Error(t, err)
+	require.Equal(t, EntityFailed, summary.Results[0].Status)
+	require.Equal(t, 1, summary.Results[0].Attempts)
+
+	dstRef, err := name.ParseReference(dstAddr+"/library/app:v1", name.Insecure)
+	require.NoError(t, err)
+	_, err = remote.Head(dstRef)
+	require.Error(t, err, "mismatched content must not be stored")
+}
+
+func TestReplicate_UnknownDeclaredDigestFails(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	pushImage(t, srcAddr, "library", "app", "v1", 1)
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	_, err := r.Replicate(testContext(), []Entity{
+		{Name: "app", Repository: "library", Tag: "v1", Digest: "sha256:" + strings.Repeat("0", 64)},
+	})
+	require.Error(t, err)
 }
 
 func TestReplicate_EmptyEntities(t *testing.T) {
@@ -163,7 +534,7 @@ func TestReplicate_EmptyEntities(t *testing.T) {
 	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
 	ctx := testContext()
 
-	err := r.Replicate(ctx, []Entity{})
+	_, err := r.Replicate(ctx, []Entity{})
 	require.NoError(t, err)
 }
 
@@ -293,7 +664,7 @@ func TestReplicate_LayerResume(t *testing.T) {
 	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
 	ctx := testContext()
 
-	err = r.Replicate(ctx, []Entity{
+	_, err = r.Replicate(ctx, []Entity{
 		{Name: "app", Repository: "library", Tag: "extended"},
 	})
 	require.NoError(t, err, "replication should succeed with layer-level resume")
@@ -331,7 +702,7 @@ func TestReplicate_CancelledContextStopsProcessing(t *testing.T) {
 	ctx, cancel := context.WithCancel(testContext())
 	cancel() // cancel immediately
 
-	err := r.Replicate(ctx, []Entity{
+	_, err := r.Replicate(ctx, []Entity{
 		{Name: "img1", Repository: "library", Tag: "v1"},
 		{Name: "img2", Repository: "library", Tag: "v1"},
 		{Name: "img3", Repository: "library", Tag: "v1"},
@@ -358,3 +729,32 @@ func TestDeleteReplicationEntity_CancelledContextStopsProcessing(t *testing.T) {
 
 	require.ErrorIs(t, err, context.Canceled)
 }
+
+func TestReplicate_LocalRegistryOverTLS(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	pushImage(t, srcAddr, "library", "alpine", "latest", 1)
+
+	dst := httptest.NewTLSServer(registry.New())
+	t.Cleanup(dst.Close)
+	dstAddr := strings.TrimPrefix(dst.URL, "https://")
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: dst.Certificate().Raw}), 0600))
+	entities := []Entity{{Name: "alpine", Repository: "library", Tag: "latest"}}
+
+	// The registry's certificate is not trusted without its CA
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	_, err := r.Replicate(testContext(), entities)
+	require.Error(t, err)
+
+	r = NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true, WithLocalRegistryCA(caFile))
+	_, err = r.Replicate(testContext(), entities)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(dstAddr + "/library/alpine:latest")
+	require.NoError(t, err)
+	_, err = remote.Head(ref, remote.WithTransport(dst.Client().Transport))
+	require.NoError(t, err)
+
+	require.NoError(t, r.DeleteReplicationEntity(testContext(), entities))
+	_, err = remote.Head(ref, remote.WithTransport(dst.Client().Transport))
+	require.Error(t, err)
+}
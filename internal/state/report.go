@@ -8,28 +8,52 @@ import (
 	"time"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/registry"
+	"github.com/container-registry/harbor-satellite/internal/scheduler"
 	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/mem"
 )
 
 type StatusReportParams struct {
-	Name                string        `json:"name"`
-	Activity            string        `json:"activity"`
-	StateReportInterval string        `json:"state_report_interval"`
-	LatestStateDigest   string        `json:"latest_state_digest"`
-	LatestConfigDigest  string        `json:"latest_config_digest"`
-	MemoryUsedBytes     uint64        `json:"memory_used_bytes"`
-	StorageUsedBytes    uint64        `json:"storage_used_bytes"`
-	CPUPercent          float64       `json:"cpu_percent"`
-	RequestCreatedTime  time.Time     `json:"request_created_time"`
-	LastSyncDurationMs  int64         `json:"last_sync_duration_ms"`
-	ImageCount          int           `json:"image_count"`
-	CachedImages        []CachedImage `json:"cached_images,omitempty"`
+	Name                     string            `json:"name"`
+	Activity                 string            `json:"activity"`
+	StateReportInterval      string            `json:"state_report_interval"`
+	LatestStateDigest        string            `json:"latest_state_digest"`
+	LatestConfigDigest       string            `json:"latest_config_digest"`
+	GroupStateDigests        map[string]string `json:"group_state_digests,omitempty"`
+	MemoryUsedBytes          uint64            `json:"memory_used_bytes"`
+	StorageUsedBytes         uint64            `json:"storage_used_bytes"`
+	CPUPercent               float64           `json:"cpu_percent"`
+	RequestCreatedTime       time.Time         `json:"request_created_time"`
+	LastSyncAt               time.Time         `json:"last_sync_at,omitzero"`
+	LastSyncDurationMs       int64             `json:"last_sync_duration_ms"`
+	LastSyncErrors           int               `json:"last_sync_errors"`
+	LastSyncBytesTransferred int64             `json:"last_sync_bytes_transferred"`
+	ImageCount               int               `json:"image_count"`
+	CachedImages             []CachedImage     `json:"cached_images,omitempty"`
+	DeadLetter               []DeadLetterEntry `json:"dead_letter,omitempty"`
+	Signatures               []SignatureRecord `json:"signatures,omitempty"`
+	Tombstones               []Tombstone       `json:"tombstones,omitempty"`
+	ConfigRollback           *ConfigRollback   `json:"config_rollback,omitempty"`
+	Evictions                []Eviction        `json:"evictions,omitempty"`
+	RegistryRestart          *registry.Restart `json:"registry_restart,omitempty"`
 }
 
-func collectStatusReportParams(ctx context.Context, heartbeatInterval time.Duration, req *StatusReportParams, cfg config.MetricsConfig, registryURL string, insecure bool) {
+// applySyncReport fills in the outcome of the last complete sync.
+func (req *StatusReportParams) applySyncReport(report SyncReport) {
+	req.LatestStateDigest = report.StateDigest
+	req.LatestConfigDigest = report.ConfigDigest
+	req.GroupStateDigests = report.GroupDigests
+	req.LastSyncAt = report.FinishedAt
+	req.LastSyncDurationMs = report.DurationMs
+	req.LastSyncErrors = report.Errors
+	req.LastSyncBytesTransferred = report.BytesTransferred
+}
+
+func collectStatusReportParams(ctx context.Context, heartbeatInterval time.Duration, req *StatusReportParams, cfg config.MetricsConfig, registryURL string, insecure bool, caFile string, auth authn.Authenticator) {
 	log := logger.FromContext(ctx)
 
 	if cfg.CollectCPU {
@@ -43,7 +67,7 @@ func collectStatusReportParams(ctx context.Context, heartbeatInterval time.Durat
 	}
 
 	if registryURL != "" {
-		cached, err := collectCachedImages(ctx, registryURL, insecure)
+		cached, err := collectCachedImages(ctx, registryURL, insecure, caFile, auth)
 		if err != nil {
 			log.Warn().Err(err).Msg("Failed to collect cached images")
 		} else {
@@ -117,13 +141,20 @@ func extractSatelliteNameFromURL(stateURL string) (string, error) {
 	return "", fmt.Errorf("could not extract satellite name from URL path: %s", parsed.Path)
 }
 
-func parseEveryExpr(expr string) (time.Duration, error) {
-	const prefix = "@every "
-	if expr == "" {
-		return 0, fmt.Errorf("empty expression provided")
-	}
-	if !strings.HasPrefix(expr, prefix) {
-		return 0, fmt.Errorf("unsupported format: must start with %q", prefix)
+// heartbeatInterval returns the time between two heartbeats on expr, an
+// "@every" interval or a cron schedule. A cron schedule is measured between
+// its next two activations after now.
+func heartbeatInterval(expr string, now time.Time) (time.Duration, error) {
+	sched, err := scheduler.ParseSchedule(expr)
+	if err != nil {
+		return 0, err
 	}
-	return time.ParseDuration(strings.TrimPrefix(expr, prefix))
+	next := sched.Next(now)
+	return sched.Next(next).Sub(next), nil
+}
+
+// formatHeartbeatInterval renders d the way Ground Control expects the
+// heartbeat interval, as "@every" whole seconds.
+func formatHeartbeatInterval(d time.Duration) string {
+	return "@every " + max(d.Truncate(time.Second), time.Second).String()
 }
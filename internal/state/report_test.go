@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/stretchr/testify/require"
 )
 
@@ -13,7 +14,7 @@ func TestCollectStatusReportParams_EmptyRegistryURL(t *testing.T) {
 	req := &StatusReportParams{}
 	cfg := config.MetricsConfig{}
 
-	collectStatusReportParams(ctx, 30*time.Second, req, cfg, "", false)
+	collectStatusReportParams(ctx, 30*time.Second, req, cfg, "", false, "", authn.Anonymous)
 
 	require.Nil(t, req.CachedImages)
 	require.Equal(t, 0, req.ImageCount)
@@ -24,7 +25,7 @@ func TestCollectStatusReportParams_UnreachableRegistry(t *testing.T) {
 	req := &StatusReportParams{}
 	cfg := config.MetricsConfig{}
 
-	collectStatusReportParams(ctx, 30*time.Second, req, cfg, "127.0.0.1:1", true)
+	collectStatusReportParams(ctx, 30*time.Second, req, cfg, "127.0.0.1:1", true, "", authn.Anonymous)
 
 	// Should gracefully handle the error - no cached images, image count stays 0
 	require.Nil(t, req.CachedImages)
@@ -73,7 +74,8 @@ func TestExtractSatelliteNameFromURL(t *testing.T) {
 	}
 }
 
-func TestParseEveryExpr(t *testing.T) {
+func TestHeartbeatInterval(t *testing.T) {
+	now := time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)
 	tests := []struct {
 		name    string
 		expr    string
@@ -90,6 +92,16 @@ func TestParseEveryExpr(t *testing.T) {
 			expr:    "@every 00h01m30s",
 			wantDur: 90 * time.Second,
 		},
+		{
+			name:    "cron",
+			expr:    "*/15 * * * *",
+			wantDur: 15 * time.Minute,
+		},
+		{
+			name:    "cron with seconds and time zone",
+			expr:    "CRON_TZ=Europe/Berlin */20 * * * * *",
+			wantDur: 20 * time.Second,
+		},
 		{
 			name:    "empty",
 			expr:    "",
@@ -104,7 +116,7 @@ func TestParseEveryExpr(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			d, err := parseEveryExpr(tt.expr)
+			d, err := heartbeatInterval(tt.expr, now)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -114,3 +126,9 @@ func TestParseEveryExpr(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatHeartbeatInterval(t *testing.T) {
+	require.Equal(t, "@every 15m0s", formatHeartbeatInterval(15*time.Minute))
+	require.Equal(t, "@every 1s", formatHeartbeatInterval(250*time.Millisecond))
+	require.Equal(t, "@every 1m30s", formatHeartbeatInterval(90*time.Second+400*time.Millisecond))
+}
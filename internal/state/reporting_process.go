@@ -12,6 +12,8 @@ import (
 
 	runtime "github.com/container-registry/harbor-satellite/internal/container_runtime"
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"github.com/container-registry/harbor-satellite/internal/registry"
 	"github.com/container-registry/harbor-satellite/internal/spiffe"
 	"github.com/container-registry/harbor-satellite/internal/utils"
 	"github.com/container-registry/harbor-satellite/pkg/config"
@@ -27,6 +29,32 @@ type StatusReportingProcess struct {
 	spiffeClient     *spiffe.Client
 	pendingCRI      []runtime.CRIConfigResult
 	criReported     bool
+	deadLetters     *DeadLetterStore
+	signatures      *SignatureStore
+	tombstones      *TombstoneStore
+	syncReporter    SyncReporter
+	rollbacks       ConfigRollbackReporter
+	evictions       *EvictionLog
+	restarts        RegistryRestartReporter
+}
+
+// SyncReporter provides the outcome of the last complete state sync.
+type SyncReporter interface {
+	LastSync() (SyncReport, bool)
+}
+
+// ConfigRollbackReporter provides config rollbacks Ground Control has not
+// been told about yet.
+type ConfigRollbackReporter interface {
+	PendingConfigRollback() (ConfigRollback, bool)
+	ConfigRollbackReported(ConfigRollback) error
+}
+
+// RegistryRestartReporter provides the outcome of the last restart of the
+// embedded registry until Ground Control has been told about it.
+type RegistryRestartReporter interface {
+	PendingRestart() (registry.Restart, bool)
+	RestartReported(registry.Restart)
 }
 
 func NewStatusReportingProcess(cm *config.ConfigManager) *StatusReportingProcess {
@@ -58,6 +86,58 @@ func (s *StatusReportingProcess) SetPendingCRIResults(results []runtime.CRIConfi
 	s.pendingCRI = results
 }
 
+// SetDeadLetterStore sets the dead-letter list reported with every heartbeat.
+func (s *StatusReportingProcess) SetDeadLetterStore(store *DeadLetterStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadLetters = store
+}
+
+// SetTombstoneStore sets the pending deletions reported with every heartbeat.
+func (s *StatusReportingProcess) SetTombstoneStore(store *TombstoneStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tombstones = store
+}
+
+// SetEvictionLog sets the evictions reported until a heartbeat succeeds.
+func (s *StatusReportingProcess) SetEvictionLog(evictions *EvictionLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictions = evictions
+}
+
+// SetSignatureStore sets the signature verdicts reported with every heartbeat.
+func (s *StatusReportingProcess) SetSignatureStore(store *SignatureStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signatures = store
+}
+
+// SetSyncReporter sets where the sync outcome reported with every heartbeat
+// comes from.
+func (s *StatusReportingProcess) SetSyncReporter(reporter SyncReporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncReporter = reporter
+}
+
+// SetConfigRollbackReporter sets where the config rollbacks reported to
+// Ground Control come from.
+func (s *StatusReportingProcess) SetConfigRollbackReporter(reporter ConfigRollbackReporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollbacks = reporter
+}
+
+// SetRegistryRestartReporter sets where the registry restarts reported to
+// Ground Control come from.
+func (s *StatusReportingProcess) SetRegistryRestartReporter(reporter RegistryRestartReporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restarts = reporter
+}
+
 func (s *StatusReportingProcess) Execute(ctx context.Context) error {
 	s.start()
 	defer s.stop()
@@ -77,7 +157,7 @@ func (s *StatusReportingProcess) Execute(ctx context.Context) error {
 	}
 
 	heartbeatExpr := s.cm.GetHeartbeatInterval()
-	heartbeatDuration, err := parseEveryExpr(heartbeatExpr)
+	heartbeatDuration, err := heartbeatInterval(heartbeatExpr, time.Now())
 	if err != nil {
 		log.Warn().Err(err).Msgf("Failed to parse heartbeat interval %q, using 30s", heartbeatExpr)
 		heartbeatDuration = 30 * time.Second
@@ -85,9 +165,11 @@ func (s *StatusReportingProcess) Execute(ctx context.Context) error {
 
 	metricsCfg := s.cm.GetMetricsConfig()
 
+	// Ground Control tracks the heartbeat as an interval, cron schedules
+	// are sent as the interval they currently run at
 	req := &StatusReportParams{
 		Name:                satelliteName,
-		StateReportInterval: heartbeatExpr,
+		StateReportInterval: formatHeartbeatInterval(heartbeatDuration),
 		RequestCreatedTime:  time.Now().UTC(),
 	}
 
@@ -98,17 +180,70 @@ func (s *StatusReportingProcess) Execute(ctx context.Context) error {
 		req.Activity = formatCRIActivity(s.pendingCRI)
 		log.Info().Str("activity", req.Activity).Msg("Reporting CRI config results")
 	}
+	deadLetters := s.deadLetters
+	signatures := s.signatures
+	tombstones := s.tombstones
+	syncReporter := s.syncReporter
+	rollbacks := s.rollbacks
+	evictions := s.evictions
+	restarts := s.restarts
 	s.mu.Unlock()
 
+	// Include a config rollback until successfully sent
+	var rollback ConfigRollback
+	hasRollback := false
+	if rollbacks != nil {
+		rollback, hasRollback = rollbacks.PendingConfigRollback()
+	}
+	if hasRollback {
+		req.ConfigRollback = &rollback
+		req.Activity = joinActivity(req.Activity, formatConfigRollbackActivity(rollback))
+		log.Info().Str("digest", rollback.Digest).Msg("Reporting config rollback")
+	}
+
+	// Include the last registry restart until successfully sent
+	var restart registry.Restart
+	hasRestart := false
+	if restarts != nil {
+		restart, hasRestart = restarts.PendingRestart()
+	}
+	if hasRestart {
+		req.RegistryRestart = &restart
+		req.Activity = joinActivity(req.Activity, formatRegistryRestartActivity(restart))
+		log.Info().Str("outcome", restart.Outcome).Msg("Reporting registry restart")
+	}
+
+	if syncReporter != nil {
+		if report, ok := syncReporter.LastSync(); ok {
+			req.applySyncReport(report)
+		}
+	}
+
+	if deadLetters != nil {
+		req.DeadLetter = deadLetters.Entries()
+	}
+	if tombstones != nil {
+		req.Tombstones = tombstones.Entries()
+	}
+	if signatures != nil {
+		req.Signatures = signatures.Records()
+	}
+	if evictions != nil {
+		req.Evictions = evictions.Pending()
+	}
+
 	registryURL := utils.FormatRegistryURL(s.cm.GetLocalRegistryURL())
-	insecure := s.cm.UseUnsecure()
-	collectStatusReportParams(ctx, heartbeatDuration, req, metricsCfg, registryURL, insecure)
+	// A registry served over TLS never answers plain HTTP
+	insecure := s.cm.UseUnsecure() && !s.cm.IsLocalRegistryTLS()
+	collectStatusReportParams(ctx, heartbeatDuration, req, metricsCfg, registryURL, insecure, s.cm.GetLocalRegistryCAFile(), localRegistryAuth(s.cm))
 
 	groundControlURL := s.cm.ResolveGroundControlURL()
 	if err := s.sendStatusReport(ctx, groundControlURL, req); err != nil {
+		metrics.Heartbeats.WithLabelValues(s.name, metrics.HeartbeatFailed).Inc()
 		log.Error().Err(err).Msg("Failed to send status report")
 		return err
 	}
+	metrics.Heartbeats.WithLabelValues(s.name, metrics.HeartbeatSucceeded).Inc()
 
 	// Clear CRI results only after successful send
 	if hasPendingCRI {
@@ -117,6 +252,18 @@ func (s *StatusReportingProcess) Execute(ctx context.Context) error {
 		s.pendingCRI = nil
 		s.mu.Unlock()
 	}
+	if len(req.Evictions) > 0 {
+		evictions.Reported(req.Evictions)
+	}
+	if hasRollback {
+		if err := rollbacks.ConfigRollbackReported(rollback); err != nil {
+			log.Warn().Err(err).Msg("Failed to persist the reported config rollback")
+		}
+	}
+
+	if hasRestart {
+		restarts.RestartReported(restart)
+	}
 
 	log.Info().Str("satellite", satelliteName).Msg("Status report sent successfully")
 	return nil
@@ -140,6 +287,32 @@ func formatCRIActivity(results []runtime.CRIConfigResult) string {
 	return "cri_fallback_configured: " + strings.Join(parts, ", ")
 }
 
+// formatConfigRollbackActivity formats a config rollback for the Activity field.
+func formatConfigRollbackActivity(rollback ConfigRollback) string {
+	restored := rollback.RestoredDigest
+	if restored == "" {
+		restored = "local"
+	}
+	return fmt.Sprintf("config_rolled_back: %s -> %s (%s)", rollback.Digest, restored, rollback.Reason)
+}
+
+// formatRegistryRestartActivity formats a registry restart for the Activity
+// field.
+func formatRegistryRestartActivity(restart registry.Restart) string {
+	entry := "registry_" + restart.Outcome
+	if restart.Reason != "" {
+		entry += " (" + restart.Reason + ")"
+	}
+	return entry
+}
+
+func joinActivity(activity, entry string) string {
+	if activity == "" {
+		return entry
+	}
+	return activity + "; " + entry
+}
+
 func (s *StatusReportingProcess) sendStatusReport(ctx context.Context, groundControlURL string, req *StatusReportParams) error {
 	body, err := json.Marshal(req)
 	if err != nil {
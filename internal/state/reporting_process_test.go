@@ -7,8 +7,10 @@ import (
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	runtime "github.com/container-registry/harbor-satellite/internal/container_runtime"
+	"github.com/container-registry/harbor-satellite/internal/registry"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/stretchr/testify/require"
 )
@@ -255,3 +257,149 @@ func TestExecute_CRIReporting(t *testing.T) {
 		p.mu.Unlock()
 	})
 }
+
+type staticSyncReporter SyncReport
+
+func (r staticSyncReporter) LastSync() (SyncReport, bool) { return SyncReport(r), true }
+
+func TestExecute_ReportsLastSync(t *testing.T) {
+	var received StatusReportParams
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	finished := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	p := &StatusReportingProcess{name: "test", mu: &sync.Mutex{}, cm: newReportingTestCM(t, srv.URL)}
+	p.SetSyncReporter(staticSyncReporter{
+		GroupDigests:     map[string]string{"edge": "sha256:edge"},
+		StateDigest:      "sha256:combined",
+		ConfigDigest:     "sha256:cfg",
+		FinishedAt:       finished,
+		DurationMs:       4200,
+		Errors:           2,
+		BytesTransferred: 1 << 20,
+	})
+	require.NoError(t, p.Execute(testContext()))
+
+	require.Equal(t, "sha256:combined", received.LatestStateDigest)
+	require.Equal(t, "sha256:cfg", received.LatestConfigDigest)
+	require.Equal(t, map[string]string{"edge": "sha256:edge"}, received.GroupStateDigests)
+	require.True(t, finished.Equal(received.LastSyncAt))
+	require.Equal(t, int64(4200), received.LastSyncDurationMs)
+	require.Equal(t, 2, received.LastSyncErrors)
+	require.Equal(t, int64(1<<20), received.LastSyncBytesTransferred)
+}
+
+// flakyReportServer is a Ground Control stand-in that keeps the last status
+// report it received and rejects every report while fail is set.
+type flakyReportServer struct {
+	*httptest.Server
+	fail     bool
+	received StatusReportParams
+}
+
+func newFlakyReportServer(t *testing.T) *flakyReportServer {
+	t.Helper()
+	s := &flakyReportServer{fail: true}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.received = StatusReportParams{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&s.received))
+		if s.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+type storeRollbackReporter struct{ *ConfigRolloutStore }
+
+func (r storeRollbackReporter) PendingConfigRollback() (ConfigRollback, bool) {
+	return r.PendingRollback()
+}
+
+func (r storeRollbackReporter) ConfigRollbackReported(rollback ConfigRollback) error {
+	r.MarkReported(rollback)
+	return nil
+}
+
+func TestExecute_ReportsConfigRollbackUntilSent(t *testing.T) {
+	srv := newFlakyReportServer(t)
+
+	store, err := NewConfigRolloutStore("")
+	require.NoError(t, err)
+	store.Begin("sha256:new", "sha256:old", time.Minute, 1, time.Now())
+	store.RollBack("local registry healthy: connection refused", time.Now())
+
+	p := &StatusReportingProcess{name: "test", mu: &sync.Mutex{}, cm: newReportingTestCM(t, srv.URL)}
+	p.SetConfigRollbackReporter(storeRollbackReporter{store})
+
+	require.Error(t, p.Execute(testContext()))
+	require.NotNil(t, srv.received.ConfigRollback)
+
+	srv.fail = false
+	require.NoError(t, p.Execute(testContext()))
+	require.Equal(t, "sha256:new", srv.received.ConfigRollback.Digest)
+	require.Equal(t, "config_rolled_back: sha256:new -> sha256:old (local registry healthy: connection refused)", srv.received.Activity)
+
+	require.NoError(t, p.Execute(testContext()))
+	require.Nil(t, srv.received.ConfigRollback)
+	require.Empty(t, srv.received.Activity)
+}
+
+func TestExecute_ReportsEvictionsUntilSent(t *testing.T) {
+	srv := newFlakyReportServer(t)
+
+	evictions := &EvictionLog{}
+	evictions.add(Eviction{Reference: "library/app:v1", Digest: "sha256:abc", FreedBytes: 2048, EvictedAt: time.Now().UTC()})
+
+	p := &StatusReportingProcess{name: "test", mu: &sync.Mutex{}, cm: newReportingTestCM(t, srv.URL)}
+	p.SetEvictionLog(evictions)
+
+	require.Error(t, p.Execute(testContext()))
+	require.Len(t, srv.received.Evictions, 1)
+
+	srv.fail = false
+	require.NoError(t, p.Execute(testContext()))
+	require.Equal(t, "library/app:v1", srv.received.Evictions[0].Reference)
+	require.Equal(t, int64(2048), srv.received.Evictions[0].FreedBytes)
+
+	require.NoError(t, p.Execute(testContext()))
+	require.Empty(t, srv.received.Evictions)
+}
+
+type stubRestartReporter struct {
+	pending *registry.Restart
+}
+
+func (s *stubRestartReporter) PendingRestart() (registry.Restart, bool) {
+	if s.pending == nil {
+		return registry.Restart{}, false
+	}
+	return *s.pending, true
+}
+
+func (s *stubRestartReporter) RestartReported(registry.Restart) { s.pending = nil }
+
+func TestExecute_ReportsRegistryRestartUntilSent(t *testing.T) {
+	srv := newFlakyReportServer(t)
+
+	restarts := &stubRestartReporter{pending: &registry.Restart{At: time.Now().UTC(), Outcome: registry.RestartRolledBack, Reason: "registry not healthy"}}
+	p := &StatusReportingProcess{name: "test", mu: &sync.Mutex{}, cm: newReportingTestCM(t, srv.URL)}
+	p.SetRegistryRestartReporter(restarts)
+
+	require.Error(t, p.Execute(testContext()))
+	require.NotNil(t, srv.received.RegistryRestart)
+
+	srv.fail = false
+	require.NoError(t, p.Execute(testContext()))
+	require.Equal(t, registry.RestartRolledBack, srv.received.RegistryRestart.Outcome)
+	require.Contains(t, srv.received.Activity, "registry_rolled_back (registry not healthy)")
+
+	require.NoError(t, p.Execute(testContext()))
+	require.Nil(t, srv.received.RegistryRestart)
+}
@@ -0,0 +1,112 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// RetryPolicy controls how often a single entity is retried after a
+// transient registry error before it is given up on for the current sync.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// NewRetryPolicy builds a policy from the replication config, falling back to
+// the defaults for unset or unparsable values.
+func NewRetryPolicy(cfg config.ReplicationConfig) RetryPolicy {
+	p := RetryPolicy{
+		MaxAttempts:    cfg.MaxAttempts,
+		InitialBackoff: parseDurationOr(cfg.RetryInitialBackoff, config.DefaultRetryInitialBackoff),
+		MaxBackoff:     parseDurationOr(cfg.RetryMaxBackoff, config.DefaultRetryMaxBackoff),
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = config.DefaultMaxAttempts
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = p.InitialBackoff
+	}
+	return p
+}
+
+// backoff returns the delay before the given retry (1-based) using
+// exponential growth with full jitter.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	ceiling := p.InitialBackoff
+	for i := 1; i < retry && ceiling < p.MaxBackoff; i++ {
+		ceiling *= 2
+	}
+	if ceiling > p.MaxBackoff {
+		ceiling = p.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// retryTransient calls fn until it succeeds, returns a non-transient error,
+// the attempts are exhausted or ctx is done. It returns the number of
+// attempts made together with the last error.
+func retryTransient(ctx context.Context, policy RetryPolicy, fn func() error) (int, error) {
+	var err error
+	attempt := 0
+	for attempt < policy.MaxAttempts {
+		attempt++
+		err = fn()
+		if err == nil || !isTransientError(err) || attempt == policy.MaxAttempts {
+			return attempt, err
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return attempt, err
+}
+
+// isTransientError reports whether err is worth retrying: registry 5xx and
+// 429 responses, connection resets and refusals, and network timeouts.
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.StatusCode == http.StatusTooManyRequests || terr.StatusCode >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func parseDurationOr(value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fallback
+	}
+	return d
+}
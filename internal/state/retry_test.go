@@ -0,0 +1,101 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/require"
+)
+
+func fastRetryPolicy(attempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: attempts, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"server error", &transport.Error{StatusCode: http.StatusBadGateway}, true},
+		{"too many requests", &transport.Error{StatusCode: http.StatusTooManyRequests}, true},
+		{"not found", &transport.Error{StatusCode: http.StatusNotFound}, false},
+		{"unauthorized", &transport.Error{StatusCode: http.StatusUnauthorized}, false},
+		{"wrapped server error", fmt.Errorf("write image: %w", &transport.Error{StatusCode: http.StatusServiceUnavailable}), true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"context cancelled", context.Canceled, false},
+		{"plain error", errors.New("manifest invalid"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, isTransientError(tt.err))
+		})
+	}
+}
+
+func TestRetryTransient_RetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	attempts, err := retryTransient(context.Background(), fastRetryPolicy(5), func() error {
+		calls++
+		if calls < 3 {
+			return &transport.Error{StatusCode: http.StatusServiceUnavailable}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+}
+
+func TestRetryTransient_StopsOnPermanentError(t *testing.T) {
+	attempts, err := retryTransient(context.Background(), fastRetryPolicy(5), func() error {
+		return &transport.Error{StatusCode: http.StatusNotFound}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+}
+
+func TestRetryTransient_ExhaustsAttempts(t *testing.T) {
+	attempts, err := retryTransient(context.Background(), fastRetryPolicy(3), func() error {
+		return syscall.ECONNRESET
+	})
+	require.ErrorIs(t, err, syscall.ECONNRESET)
+	require.Equal(t, 3, attempts)
+}
+
+func TestRetryTransient_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+
+	go cancel()
+	_, err := retryTransient(ctx, policy, func() error {
+		return syscall.ECONNRESET
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRetryPolicy_BackoffIsBounded(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond}
+	for retry := 1; retry <= 8; retry++ {
+		d := p.backoff(retry)
+		require.Greater(t, d, time.Duration(0))
+		require.LessOrEqual(t, d, p.MaxBackoff)
+	}
+}
+
+func TestNewRetryPolicy_Defaults(t *testing.T) {
+	p := NewRetryPolicy(config.ReplicationConfig{RetryInitialBackoff: "bogus"})
+	require.Equal(t, config.DefaultMaxAttempts, p.MaxAttempts)
+	require.Equal(t, config.DefaultRetryInitialBackoff, p.InitialBackoff)
+	require.Equal(t, config.DefaultRetryMaxBackoff, p.MaxBackoff)
+}
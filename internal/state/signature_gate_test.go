@@ -0,0 +1,128 @@
+package state
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/signature"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+)
+
+// signWithCosign attaches a key-based cosign signature for the image at
+// repo@digest using the sha256-<hex>.sig tag scheme.
+func signWithCosign(t *testing.T, key *ecdsa.PrivateKey, repo, digest string) {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, repo, digest))
+	sum := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	require.NoError(t, err)
+
+	img, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{
+		Layer:       static.NewLayer(payload, "application/vnd.dev.cosign.simplesigning.v1+json"),
+		Annotations: map[string]string{"dev.cosignproject.cosign/signature": base64.StdEncoding.EncodeToString(sig)},
+	})
+	require.NoError(t, err)
+	ref, err := name.ParseReference(repo+":"+strings.Replace(digest, ":", "-", 1)+".sig", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+}
+
+func newTestVerifier(t *testing.T, key *ecdsa.PrivateKey, cfg config.SignatureVerificationConfig) *signature.Verifier {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	cfg.PublicKeys = []string{string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))}
+	v, err := signature.NewVerifier(cfg)
+	require.NoError(t, err)
+	return v
+}
+
+func TestReplicate_SignatureGate(t *testing.T) {
+	srcAddr := newReferrersRegistry(t)
+	dstAddr := newReferrersRegistry(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signed := pushOCIImage(t, srcAddr+"/library/signed:v1")
+	signWithCosign(t, key, srcAddr+"/library/signed", signed.String())
+	pushOCIImage(t, srcAddr+"/library/unsigned:v1")
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true,
+		WithSignatureVerifier(newTestVerifier(t, key, config.SignatureVerificationConfig{})))
+
+	summary, err := r.Replicate(testContext(), []Entity{
+		{Name: "signed", Repository: "library", Tag: "v1"},
+		{Name: "unsigned", Repository: "library", Tag: "v1"},
+	})
+	require.Error(t, err)
+
+	require.Equal(t, EntityReplicated, summary.Results[0].Status)
+	require.Equal(t, signature.Verified, summary.Results[0].Signature.Verdict)
+
+	require.Equal(t, EntityFailed, summary.Results[1].Status)
+	require.ErrorIs(t, summary.Results[1].Err, ErrSignatureRejected)
+	require.Equal(t, signature.Unsigned, summary.Results[1].Signature.Verdict)
+	require.Equal(t, 1, summary.Results[1].Attempts)
+
+	requireManifest(t, dstAddr+"/library/signed:v1", true)
+	requireManifest(t, dstAddr+"/library/unsigned:v1", false)
+}
+
+func TestReplicate_SignatureGateAuditMode(t *testing.T) {
+	srcAddr := newReferrersRegistry(t)
+	dstAddr := newReferrersRegistry(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pushOCIImage(t, srcAddr+"/library/unsigned:v1")
+
+	r := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true,
+		WithSignatureVerifier(newTestVerifier(t, key, config.SignatureVerificationConfig{Mode: config.SignatureModeAudit})))
+
+	summary, err := r.Replicate(testContext(), []Entity{{Name: "unsigned", Repository: "library", Tag: "v1"}})
+	require.NoError(t, err)
+	require.Equal(t, signature.Unsigned, summary.Results[0].Signature.Verdict)
+	requireManifest(t, dstAddr+"/library/unsigned:v1", true)
+}
+
+func TestSignatureStore(t *testing.T) {
+	store := NewSignatureStore()
+	now := time.Now()
+	a := Entity{Name: "a", Tag: "v1"}
+	b := Entity{Name: "b", Tag: "v1"}
+
+	store.Apply("g1", ReplicationSummary{Results: []EntityResult{
+		{Entity: a, Status: EntityReplicated, Signature: &signature.Result{Verdict: signature.Verified, Signer: "ci"}},
+		{Entity: b, Status: EntityFailed, Signature: &signature.Result{Verdict: signature.Rejected, Reason: "bad"}},
+	}}, now)
+	store.Apply("g2", ReplicationSummary{Results: []EntityResult{
+		{Entity: a, Status: EntityFailed},
+	}}, now)
+
+	records := store.Records()
+	require.Len(t, records, 2)
+	require.Equal(t, signature.Verified, records[0].Verdict)
+	require.Equal(t, signature.Rejected, records[1].Verdict)
+
+	store.Retain("g1", []Entity{a})
+	require.Len(t, store.Group("g1"), 1)
+
+	store.RetainGroups(nil)
+	require.Empty(t, store.Records())
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/signature"
+)
+
+// SignatureRecord is the latest signature verdict for an entity of a group.
+type SignatureRecord struct {
+	Group     string            `json:"group"`
+	Entity    Entity            `json:"entity"`
+	Verdict   signature.Verdict `json:"verdict"`
+	Signer    string            `json:"signer,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+// SignatureStore keeps the latest verdict per group and entity so that it can
+// be persisted with the group state and reported in heartbeats.
+type SignatureStore struct {
+	mu      sync.Mutex
+	records map[string]SignatureRecord
+}
+
+func NewSignatureStore() *SignatureStore {
+	return &SignatureStore{records: make(map[string]SignatureRecord)}
+}
+
+// Load adds previously persisted records.
+func (s *SignatureStore) Load(records []SignatureRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range records {
+		s.records[deadLetterKey(r.Group, r.Entity)] = r
+	}
+}
+
+// Apply records the verdicts of a Replicate call. Entities that were not
+// verified, e.g. because they failed before the check, keep their previous
+// verdict.
+func (s *SignatureStore) Apply(group string, summary ReplicationSummary, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range summary.Results {
+		if r.Signature == nil {
+			continue
+		}
+		s.records[deadLetterKey(group, r.Entity)] = SignatureRecord{
+			Group:     group,
+			Entity:    r.Entity,
+			Verdict:   r.Signature.Verdict,
+			Signer:    r.Signature.Signer,
+			Reason:    r.Signature.Reason,
+			CheckedAt: now,
+		}
+	}
+}
+
+// Retain drops records of group that are no longer part of its desired
+// entities.
+func (s *SignatureStore) Retain(group string, desired []Entity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keep := make(map[string]struct{}, len(desired))
+	for _, e := range desired {
+		keep[deadLetterKey(group, e)] = struct{}{}
+	}
+	for key, r := range s.records {
+		if r.Group != group {
+			continue
+		}
+		if _, ok := keep[key]; !ok {
+			delete(s.records, key)
+		}
+	}
+}
+
+// RetainGroups drops records of groups the satellite no longer belongs to.
+func (s *SignatureStore) RetainGroups(groups []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, r := range s.records {
+		if !contains(groups, r.Group) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// Group returns the records of one group ordered by entity.
+func (s *SignatureStore) Group(group string) []SignatureRecord {
+	var records []SignatureRecord
+	for _, r := range s.Records() {
+		if r.Group == group {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// Records returns every record ordered by group and entity.
+func (s *SignatureStore) Records() []SignatureRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]SignatureRecord, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return deadLetterKey(records[i].Group, records[i].Entity) < deadLetterKey(records[j].Group, records[j].Entity)
+	})
+	return records
+}
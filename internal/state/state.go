@@ -27,6 +27,9 @@ type State struct {
 type SatelliteState struct {
 	States []string `json:"states,omitempty"`
 	Config string   `json:"config,omitempty"`
+	// Peers are the local registries of other satellites layers are fetched
+	// from before the source.
+	Peers []string `json:"peers,omitempty"`
 }
 
 func NewState() StateReader {
@@ -8,10 +8,12 @@ import (
 	"path/filepath"
 )
 
-// PersistedGroupState is the serializable form of a group's replicated entities.
+// PersistedGroupState is the serializable form of a group's replicated
+// entities and their latest signature verdicts.
 type PersistedGroupState struct {
-	URL      string   `json:"url"`
-	Entities []Entity `json:"entities"`
+	URL        string            `json:"url"`
+	Entities   []Entity          `json:"entities"`
+	Signatures []SignatureRecord `json:"signatures,omitempty"`
 }
 
 // PersistedState is the top-level struct written to state.json.
@@ -28,8 +30,9 @@ func SaveState(path string, stateMap []StateMap, configDigest string) error {
 	}
 	for _, sm := range stateMap {
 		persisted.Groups = append(persisted.Groups, PersistedGroupState{
-			URL:      sm.url,
-			Entities: sm.Entities,
+			URL:        sm.url,
+			Entities:   sm.Entities,
+			Signatures: sm.Signatures,
 		})
 	}
 
@@ -38,15 +41,21 @@ func SaveState(path string, stateMap []StateMap, configDigest string) error {
 		return fmt.Errorf("marshal state: %w", err)
 	}
 
+	return writeFileAtomic(path, data, "state-*.json.tmp")
+}
+
+// writeFileAtomic writes data to a temp file in the target directory and
+// renames it over path, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte, tmpPattern string) (err error) {
 	dir := filepath.Dir(path)
-	tmp, err := os.CreateTemp(dir, "state-*.json.tmp")
+	tmp, err := os.CreateTemp(dir, tmpPattern)
 	if err != nil {
 		return fmt.Errorf("create temp file: %w", err)
 	}
 	tmpName := tmp.Name()
 
 	cleanup := func() {
-		if closeErr := tmp.Close(); closeErr != nil && err == nil {
+		if closeErr := tmp.Close(); closeErr != nil && err == nil && !errors.Is(closeErr, os.ErrClosed) {
 			err = fmt.Errorf("close temp file: %w", closeErr)
 		}
 		if err != nil {
@@ -67,7 +76,7 @@ func SaveState(path string, stateMap []StateMap, configDigest string) error {
 		return fmt.Errorf("close temp file: %w", err)
 	}
 	if err = os.Rename(tmpName, path); err != nil {
-		return fmt.Errorf("rename temp to state file: %w", err)
+		return fmt.Errorf("rename temp to %s: %w", filepath.Base(path), err)
 	}
 
 	return nil
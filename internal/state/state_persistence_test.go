@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/container-registry/harbor-satellite/internal/signature"
 )
 
 func TestSaveAndLoadRoundTrip(t *testing.T) {
@@ -101,3 +103,32 @@ func TestSaveEmptyState(t *testing.T) {
 		t.Errorf("state file should exist: %v", err)
 	}
 }
+
+func TestSaveAndLoadSignatureRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	group := "http://registry.example.com/group1"
+	entity := Entity{Name: "alpine", Repository: "library", Tag: "latest", Digest: "sha256:abc123"}
+
+	stateMap := []StateMap{{
+		url:      group,
+		Entities: []Entity{entity},
+		Signatures: []SignatureRecord{
+			{Group: group, Entity: entity, Verdict: signature.Verified, Signer: "release@example.com"},
+		},
+	}}
+	if err := SaveState(path, stateMap, ""); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if len(loaded.Groups) != 1 || len(loaded.Groups[0].Signatures) != 1 {
+		t.Fatalf("signature records not persisted: %+v", loaded.Groups)
+	}
+	got := loaded.Groups[0].Signatures[0]
+	if got.Verdict != signature.Verified || got.Signer != "release@example.com" || got.Entity != entity {
+		t.Errorf("Signatures[0] = %+v, want verified by release@example.com", got)
+	}
+}
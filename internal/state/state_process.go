@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"github.com/container-registry/harbor-satellite/internal/scheduler"
+	"github.com/container-registry/harbor-satellite/internal/signature"
+	"github.com/container-registry/harbor-satellite/internal/spiffe"
 	"github.com/container-registry/harbor-satellite/internal/utils"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/rs/zerolog"
@@ -20,20 +25,41 @@ type FetchAndReplicateStateProcess struct {
 	cm                  *config.ConfigManager
 	mu                  sync.Mutex
 	stateFilePath       string
+	deadLetters         *DeadLetterStore
+	signatures          *SignatureStore
+	spool               *BlobSpool
+	tombstones          *TombstoneStore
+	rollout             *ConfigRolloutStore
+	access              *AccessTracker
+	adhoc               *AdHocStore
+	evictions           *EvictionLog
+	healthChecks        []healthCheck
+	groups              []string
+	groupURLs           []string
+	configDigest        string
+	snapshots           []groupSnapshot
+	runGroups           []runGroups
+	lastSync            *SyncReport
+	verifier            *StateVerifier
+	verifierKey         string
+	spiffeClient        *spiffe.Client
 }
 
 // Define result types for channels
 type StateFetcherResult struct {
-	Index     int
-	URL       string
-	Error     error
-	Cancelled bool
+	Index       int
+	URL         string
+	StateDigest string
+	Error       error
+	Cancelled   bool
+	Summary     ReplicationSummary
 }
 
 type ConfigFetcherResult struct {
 	ConfigDigest string
 	Error        error
 	Cancelled    bool
+	Skipped      bool
 }
 
 func NewFetchAndReplicateStateProcess(cm *config.ConfigManager, stateFilePath string, log *zerolog.Logger) *FetchAndReplicateStateProcess {
@@ -41,6 +67,46 @@ func NewFetchAndReplicateStateProcess(cm *config.ConfigManager, stateFilePath st
 		name:          config.ReplicateStateJobName,
 		cm:            cm,
 		stateFilePath: stateFilePath,
+		signatures:    NewSignatureStore(),
+		evictions:     &EvictionLog{},
+	}
+
+	deadLetters, err := NewDeadLetterStore(deadLetterPath(stateFilePath))
+	if err != nil {
+		log.Warn().Err(err).Msg("Corrupted dead-letter file, starting with an empty list")
+	}
+	p.deadLetters = deadLetters
+
+	tombstones, err := NewTombstoneStore(tombstonePath(stateFilePath))
+	if err != nil {
+		log.Warn().Err(err).Msg("Corrupted tombstone file, starting with an empty list")
+	}
+	p.tombstones = tombstones
+
+	rollout, err := NewConfigRolloutStore(configRolloutPath(stateFilePath))
+	if err != nil {
+		log.Warn().Err(err).Msg("Corrupted config rollout file, no config is on probation")
+	}
+	p.rollout = rollout
+
+	access, err := NewAccessTracker(accessPath(stateFilePath))
+	if err != nil {
+		log.Warn().Err(err).Msg("Corrupted access file, local images are treated as never pulled")
+	}
+	p.access = access
+
+	adhoc, err := NewAdHocStore(adHocPath(stateFilePath))
+	if err != nil {
+		log.Warn().Err(err).Msg("Corrupted ad-hoc file, previously cached ad-hoc images are no longer tracked")
+	}
+	p.adhoc = adhoc
+
+	if dir := spoolDir(stateFilePath); dir != "" {
+		spool, err := NewBlobSpool(dir)
+		if err != nil {
+			log.Warn().Err(err).Msg("Blob spool unavailable, interrupted downloads will restart from zero")
+		}
+		p.spool = spool
 	}
 
 	if stateFilePath != "" {
@@ -51,10 +117,27 @@ func NewFetchAndReplicateStateProcess(cm *config.ConfigManager, stateFilePath st
 			p.currentConfigDigest = persisted.ConfigDigest
 			for _, g := range persisted.Groups {
 				p.stateMap = append(p.stateMap, StateMap{
-					url:      g.URL,
-					Entities: g.Entities,
+					url:        g.URL,
+					Entities:   g.Entities,
+					Signatures: g.Signatures,
 				})
+				p.signatures.Load(g.Signatures)
 			}
+			p.recordGroups()
+			p.recordSnapshots()
+			p.recordConfigDigest(persisted.ConfigDigest)
+		}
+	}
+
+	if cm.IsSPIFFEEnabled() {
+		spiffeCfg := cm.GetSPIFFEConfig()
+		client, err := spiffe.NewClient(spiffe.Config{
+			Enabled:          spiffeCfg.Enabled,
+			EndpointSocket:   spiffeCfg.EndpointSocket,
+			ExpectedServerID: spiffeCfg.ExpectedServerID,
+		})
+		if err == nil {
+			p.spiffeClient = client
 		}
 	}
 
@@ -62,9 +145,10 @@ func NewFetchAndReplicateStateProcess(cm *config.ConfigManager, stateFilePath st
 }
 
 type StateMap struct {
-	url      string
-	State    StateReader
-	Entities []Entity
+	url        string
+	State      StateReader
+	Entities   []Entity
+	Signatures []SignatureRecord
 }
 
 func NewStateMap(url []string) []StateMap {
@@ -78,6 +162,7 @@ func NewStateMap(url []string) []StateMap {
 func (f *FetchAndReplicateStateProcess) Execute(ctx context.Context) error {
 	f.start()
 	defer f.stop()
+	started := time.Now()
 
 	// Top level logger with process name
 	log := logger.FromContext(ctx).With().Str("process", f.name).Logger()
@@ -89,16 +174,42 @@ func (f *FetchAndReplicateStateProcess) Execute(ctx context.Context) error {
 	default:
 	}
 
-	replicator, sourceURL, srcUsername, srcPassword, remoteURL, useUnsecure, satelliteStateURL := f.setupReplication()
+	replicator, sourceURL, srcUsername, srcPassword, remoteURL, useUnsecure, satelliteStateURL := f.setupReplication(&log)
 
 	canExecute, reason := f.CanExecute(satelliteStateURL, remoteURL, sourceURL, srcUsername, srcPassword)
+
+	// A bundle brings its own states and images, only the local registry is
+	// needed to import it
+	if bundle := bundleFromContext(ctx); bundle != nil {
+		satelliteStateURL = bundle.stateRef
+		if basic, ok := replicator.(*BasicReplicator); ok {
+			replicator = NewLayoutReplicator(bundle.path, basic)
+		}
+		canExecute, reason = remoteURL != "", fmt.Sprintf("Process %s importing bundle %s", f.name, bundle.path)
+		if remoteURL == "" {
+			reason = "local registry URL is empty"
+		}
+	}
 	if !canExecute {
 		log.Warn().Msgf("Process %s cannot execute: %s", f.name, reason)
 		return nil
 	}
 	log.Info().Msg(reason)
 
+	if bundleFromContext(ctx) == nil {
+		f.ensureStatePublicKey(ctx, &log)
+	}
+
+	if f.spool != nil {
+		if err := f.spool.Prune(spoolMaxAge, time.Now()); err != nil {
+			log.Warn().Err(err).Msg("Failed to prune stale blobs from the spool")
+		}
+	}
+
 	satelliteState, err := f.fetchSatelliteRootState(ctx, satelliteStateURL, srcUsername, srcPassword, useUnsecure, &log)
+	if bundleFromContext(ctx) == nil {
+		f.checkConfigProbation(ctx, err, &log)
+	}
 	if err != nil {
 		return err
 	}
@@ -112,7 +223,18 @@ func (f *FetchAndReplicateStateProcess) Execute(ctx context.Context) error {
 		}
 	}
 
+	// Layers are fetched from the peers Ground Control assigned before Harbor
+	if bundleFromContext(ctx) == nil && len(satelliteState.Peers) > 0 {
+		log.Debug().Strs("peers", satelliteState.Peers).Msg("Fetching layers from peer satellites first")
+		replicator = replicatorWithPeers(replicator, satelliteState.Peers)
+	}
+
 	changed := f.updateStateMap(satelliteState.States)
+	f.recordGroups()
+	f.recordSnapshots()
+	f.deadLetters.RetainGroups(satelliteState.States)
+	f.signatures.RetainGroups(satelliteState.States)
+	f.tombstones.RetainGroups(satelliteState.States)
 
 	// Persist state if groups were added, removed, or swapped
 	if f.stateFilePath != "" && changed {
@@ -121,28 +243,110 @@ func (f *FetchAndReplicateStateProcess) Execute(ctx context.Context) error {
 		}
 	}
 
+	// A run triggered for one group leaves the others alone
+	only := groupFilter(ctx)
+	var indices []int
+	for i := range f.stateMap {
+		if only == "" || groupMatches(f.stateMap[i].url, only) {
+			indices = append(indices, i)
+		}
+	}
+	if only != "" && len(indices) == 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownGroup, only)
+	}
+
 	// Create channels for results
-	stateFetcherResults := make(chan StateFetcherResult, len(f.stateMap))
+	stateFetcherResults := make(chan StateFetcherResult, len(indices))
 	configFetcherResult := make(chan ConfigFetcherResult, 1)
 
 	// Mutex for concurrency safe access of the stateMap
 	mutex := &sync.Mutex{}
 
-	// Launch state fetcher goroutines
-	for i := range f.stateMap {
-		go func(index int) {
-			result := f.processGroupState(ctx, index, srcUsername, srcPassword, useUnsecure, replicator, mutex, &log)
-			stateFetcherResults <- result
-		}(i)
-	}
+	// Deletions of all groups share one budget per sync
+	deletions := newDeletionRun(f.cm.GetDeletionConfig())
 
 	// Launch config fetcher goroutine
-	go func() {
-		result := f.reconcileRemoteConfig(ctx, satelliteState.Config, srcUsername, srcPassword, useUnsecure, mutex, &log)
-		configFetcherResult <- result
-	}()
+	if only != "" {
+		configFetcherResult <- ConfigFetcherResult{Skipped: true}
+	} else {
+		go func() {
+			result := f.reconcileRemoteConfig(ctx, satelliteState.Config, srcUsername, srcPassword, useUnsecure, mutex, &log)
+			configFetcherResult <- result
+		}()
+	}
+
+	// Every group state is fetched before anything is deleted, so that an
+	// image moving between groups is never deleted by the group it left while
+	// the group it joined is still being fetched.
+	fetches := make([]*groupFetch, len(indices))
+	var fetching sync.WaitGroup
+	for n, i := range indices {
+		fetching.Add(1)
+		go func(n, index int) {
+			defer fetching.Done()
+			fetches[n] = f.fetchGroupState(ctx, index, srcUsername, srcPassword, useUnsecure, &log)
+		}(n, i)
+	}
+	fetching.Wait()
+
+	// Deletions of every group are resolved against the same union of wanted
+	// entities. A group whose fetch failed keeps what it wanted before.
+	fetched := make(map[int][]Entity, len(fetches))
+	for _, fetch := range fetches {
+		if fetch.result.Error == nil {
+			fetched[fetch.result.Index] = fetch.desired
+		}
+	}
+	var incoming []Entity
+	for _, fetch := range fetches {
+		incoming = append(incoming, fetch.added...)
+	}
+	mutex.Lock()
+	wanted := f.entitiesOfOtherGroups(-1, fetched)
+	mutex.Unlock()
+
+	// Images no group wants make room before new ones are replicated
+	f.enforceStorageQuota(ctx, replicator, wanted, incoming, deletions, &log)
+
+	// Images the registry cached on demand are recorded and expired
+	f.pullThrough(ctx, replicator, deletions, &log)
+
+	adhoc := f.adhoc.Entities()
+	others := make(map[int][]Entity, len(fetched))
+	mutex.Lock()
+	for index := range fetched {
+		others[index] = append(f.entitiesOfOtherGroups(index, fetched), adhoc...)
+	}
+	mutex.Unlock()
+
+	// Launch state applier goroutines
+	runID := scheduler.RunID(ctx)
+	for _, fetch := range fetches {
+		if fetch.result.Error != nil {
+			f.recordRunGroup(runID, fetch.result, fetch.started)
+			stateFetcherResults <- fetch.result
+			continue
+		}
+		go func(fetch *groupFetch) {
+			result := f.applyGroupState(ctx, fetch, others[fetch.result.Index], replicator, deletions, mutex)
+			f.recordRunGroup(runID, result, fetch.started)
+			stateFetcherResults <- result
+		}(fetch)
+	}
 
-	return f.collectResults(ctx, stateFetcherResults, configFetcherResult, len(f.stateMap), &log)
+	// Only runs covering every group describe the state of the satellite
+	var report *SyncReport
+	if only == "" {
+		report = &SyncReport{GroupDigests: make(map[string]string, len(indices))}
+	}
+	err = f.collectResults(ctx, stateFetcherResults, configFetcherResult, len(indices), report, &log)
+	if report != nil && ctx.Err() == nil && len(report.GroupDigests) == len(indices) {
+		report.ConfigDigest = f.ConfigDigest()
+		report.FinishedAt = time.Now()
+		report.DurationMs = report.FinishedAt.Sub(started).Milliseconds()
+		f.recordSyncReport(*report)
+	}
+	return err
 }
 
 func (f *FetchAndReplicateStateProcess) updateStateMap(states []string) bool {
@@ -240,6 +444,24 @@ func (f *FetchAndReplicateStateProcess) Name() string {
 	return f.name
 }
 
+// DeadLetters returns the process's dead-letter list so that it can be
+// included in status reports.
+func (f *FetchAndReplicateStateProcess) DeadLetters() *DeadLetterStore {
+	return f.deadLetters
+}
+
+// Signatures returns the latest signature verdicts so that they can be
+// included in status reports.
+func (f *FetchAndReplicateStateProcess) Signatures() *SignatureStore {
+	return f.signatures
+}
+
+// Tombstones returns the entities waiting to be deleted so that they can be
+// included in status reports.
+func (f *FetchAndReplicateStateProcess) Tombstones() *TombstoneStore {
+	return f.tombstones
+}
+
 // The state fetch process is prepetual, the only criteria for completion is
 // if the statellite is shut down.
 func (f *FetchAndReplicateStateProcess) IsComplete() bool {
@@ -277,6 +499,7 @@ func (f *FetchAndReplicateStateProcess) collectResults(
 	stateFetcherResults <-chan StateFetcherResult,
 	configFetcherResult <-chan ConfigFetcherResult,
 	expectedCount int,
+	report *SyncReport,
 	log *zerolog.Logger,
 ) error {
 	var allErrors []string
@@ -291,6 +514,9 @@ func (f *FetchAndReplicateStateProcess) collectResults(
 
 		case stateResult := <-stateFetcherResults:
 			receivedStateFetchers++
+			if report != nil && !stateResult.Cancelled {
+				report.add(stateResult)
+			}
 
 			switch {
 			case stateResult.Cancelled:
@@ -308,7 +534,12 @@ func (f *FetchAndReplicateStateProcess) collectResults(
 			switch {
 			case configResult.Cancelled:
 				log.Debug().Msg("Config fetcher cancelled")
+			case configResult.Skipped:
+				log.Debug().Msg("Config fetcher skipped for a single group run")
 			case configResult.Error != nil:
+				if report != nil {
+					report.Errors++
+				}
 				allErrors = append(allErrors, configResult.Error.Error())
 				log.Error().Err(configResult.Error).Msg("Config fetcher failed")
 			default:
@@ -341,13 +572,18 @@ func (f *FetchAndReplicateStateProcess) reconcileRemoteConfig(
 
 	result := ConfigFetcherResult{}
 
+	outcome := metrics.ConfigFailed
+	defer func() {
+		metrics.ConfigReconciliations.WithLabelValues(f.name, outcome).Inc()
+	}()
+
 	if override := f.cm.GetHarborRegistryURL(); override != "" {
 		if replaced, err := config.ReplaceURLHost(configURL, override); err == nil {
 			configURL = replaced
 		}
 	}
 
-	configStateFetcher, err := getStateFetcherForInput(configURL, srcUsername, srcPassword, useUnsecure, &configFetcherLog)
+	configStateFetcher, err := f.stateFetcher(ctx, configURL, srcUsername, srcPassword, useUnsecure, &configFetcherLog)
 	if err != nil {
 		configFetcherLog.Error().Err(err).Msg("Error processing satellite state")
 		result.Error = fmt.Errorf("failed to create config state fetcher: %w", err)
@@ -361,7 +597,13 @@ func (f *FetchAndReplicateStateProcess) reconcileRemoteConfig(
 		return result
 	}
 
-	if configDigest != f.currentConfigDigest {
+	outcome = metrics.ConfigUnchanged
+	if configDigest != f.currentConfigDigest && f.rollout.Rejected(configDigest) {
+		outcome = metrics.ConfigRejected
+		configFetcherLog.Warn().Str("Current Digest", f.currentConfigDigest).Str("Remote Digest", configDigest).
+			Msg("The upstream config was rolled back on this satellite, waiting for Ground Control to publish a new one")
+	} else if configDigest != f.currentConfigDigest {
+		outcome = metrics.ConfigFailed
 		configFetcherLog.Info().Str("Current Digest", f.currentConfigDigest).Str("Remote Digest", configDigest).Msgf("The upstream config has changes, reconciling the satellite accordingly")
 
 		remoteConfig := config.Config{}
@@ -385,11 +627,16 @@ func (f *FetchAndReplicateStateProcess) reconcileRemoteConfig(
 			utils.HandleNewConfigWarnings(&configFetcherLog, warnings)
 		}
 
-		if err := f.cm.WritePrevConfigToDisk(f.cm.GetConfig()); err != nil {
-			configFetcherLog.Error().Err(err).
-				Msgf("Error writing the prev config to disk while reconciling remote config, continuing execution with the same previous config with digest %s", f.currentConfigDigest)
-			result.Error = fmt.Errorf("failed to write previous config to disk: %w", err)
-			return result
+		// The previous config is the last promoted one, a config replacing
+		// one still on probation does not overwrite it
+		rollout := f.cm.GetConfigRolloutConfig()
+		if !f.rollout.OnProbation() || rollout.Disabled {
+			if err := f.cm.WritePrevConfigToDisk(f.cm.GetConfig()); err != nil {
+				configFetcherLog.Error().Err(err).
+					Msgf("Error writing the prev config to disk while reconciling remote config, continuing execution with the same previous config with digest %s", f.currentConfigDigest)
+				result.Error = fmt.Errorf("failed to write previous config to disk: %w", err)
+				return result
+			}
 		}
 
 		configFetcherLog.Debug().Str("Current Digest", f.currentConfigDigest).Str("Remote Digest", configDigest).Msgf("Writing new config to disk")
@@ -399,8 +646,27 @@ func (f *FetchAndReplicateStateProcess) reconcileRemoteConfig(
 			result.Error = fmt.Errorf("failed to write new config to disk: %w", err)
 			return result
 		}
+		outcome = metrics.ConfigApplied
 		mutex.Lock()
+		if rollout.Disabled {
+			f.rollout.Clear()
+		} else {
+			period, err := time.ParseDuration(rollout.ProbationPeriod)
+			if err != nil {
+				period = config.DefaultConfigProbationPeriod
+			}
+			threshold := rollout.FailureThreshold
+			if threshold <= 0 {
+				threshold = config.DefaultConfigRollbackFailures
+			}
+			f.rollout.Begin(configDigest, f.currentConfigDigest, period, threshold, time.Now())
+			configFetcherLog.Info().Str("digest", configDigest).Dur("probation", period).Msg("New config is on probation")
+		}
+		if err := f.rollout.Save(); err != nil {
+			configFetcherLog.Warn().Err(err).Msg("Failed to persist the config rollout")
+		}
 		f.currentConfigDigest = configDigest
+		f.recordConfigDigest(configDigest)
 		if f.stateFilePath != "" {
 			if err := SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest); err != nil {
 				configFetcherLog.Warn().Err(err).Msg("Failed to persist state to disk")
@@ -413,24 +679,41 @@ func (f *FetchAndReplicateStateProcess) reconcileRemoteConfig(
 	return result
 }
 
-func (f *FetchAndReplicateStateProcess) processGroupState(
+// groupFetch is the state of a group fetched in a sync, along with the
+// changes it brings, before any of them are applied.
+type groupFetch struct {
+	result   StateFetcherResult
+	started  time.Time
+	log      zerolog.Logger
+	removed  []Entity
+	added    []Entity
+	newState StateReader
+	desired  []Entity
+}
+
+// fetchGroupState fetches the state of the group at index and works out what
+// changed since the last sync. A fetch that failed carries the error in its
+// result and nothing to apply.
+func (f *FetchAndReplicateStateProcess) fetchGroupState(
 	ctx context.Context,
 	index int,
 	srcUsername, srcPassword string,
 	useUnsecure bool,
-	replicator Replicator,
-	mutex *sync.Mutex,
 	log *zerolog.Logger,
-) StateFetcherResult {
+) *groupFetch {
 	stateFetcherLog := log.With().
 		Str("sub-process", "state-fetcher").
 		Str("group", f.stateMap[index].url).
 		Int("goroutine-id", index).
 		Logger()
 
-	result := StateFetcherResult{
-		Index: index,
-		URL:   f.stateMap[index].url,
+	fetch := &groupFetch{
+		result: StateFetcherResult{
+			Index: index,
+			URL:   f.stateMap[index].url,
+		},
+		started: time.Now(),
+		log:     stateFetcherLog,
 	}
 
 	groupURL := f.stateMap[index].url
@@ -442,39 +725,99 @@ func (f *FetchAndReplicateStateProcess) processGroupState(
 
 	stateFetcherLog.Info().Msgf("Processing state for %s", groupURL)
 
-	groupStateFetcher, err := getStateFetcherForInput(groupURL, srcUsername, srcPassword, useUnsecure, &stateFetcherLog)
+	group := groupLabel(f.stateMap[index].url)
+	groupStateFetcher, err := f.stateFetcher(ctx, groupURL, srcUsername, srcPassword, useUnsecure, &stateFetcherLog)
 	if err != nil {
+		metrics.StateFetchErrors.WithLabelValues(f.name, group).Inc()
 		stateFetcherLog.Error().Err(err).Msg("Error processing input")
-		result.Error = fmt.Errorf("failed to create state fetcher for %s: %w", f.stateMap[index].url, err)
-		return result
+		fetch.result.Error = fmt.Errorf("failed to create state fetcher for %s: %w", f.stateMap[index].url, err)
+		return fetch
 	}
 
+	fetchStarted := time.Now()
 	newStateFetched, err := f.FetchAndProcessState(ctx, groupStateFetcher, &stateFetcherLog)
+	metrics.StateFetchDuration.WithLabelValues(f.name, group).Observe(time.Since(fetchStarted).Seconds())
 	if err != nil {
+		metrics.StateFetchErrors.WithLabelValues(f.name, group).Inc()
 		stateFetcherLog.Error().Err(err).Msg("Error fetching state")
-		result.Error = fmt.Errorf("failed to fetch state for %s: %w", f.stateMap[index].url, err)
-		return result
+		fetch.result.Error = fmt.Errorf("failed to fetch state for %s: %w", f.stateMap[index].url, err)
+		return fetch
 	}
 	stateFetcherLog.Info().Msgf("State fetched successfully for %s", f.stateMap[index].url)
 
-	deleteEntity, replicateEntity, newState := f.GetChanges(*newStateFetched, &stateFetcherLog, f.stateMap[index].Entities)
-	f.LogChanges(deleteEntity, replicateEntity, &stateFetcherLog)
+	if digest, err := groupStateFetcher.FetchDigest(ctx, &stateFetcherLog); err != nil {
+		stateFetcherLog.Warn().Err(err).Msg("Failed to resolve the digest of the group state")
+	} else {
+		fetch.result.StateDigest = digest
+	}
 
-	if err := replicator.DeleteReplicationEntity(ctx, deleteEntity); err != nil {
-		stateFetcherLog.Error().Err(err).Msg("Error deleting entities")
-		result.Error = fmt.Errorf("failed to delete entities for %s: %w", f.stateMap[index].url, err)
-		return result
+	fetch.removed, fetch.added, fetch.newState = f.GetChanges(*newStateFetched, &stateFetcherLog, f.stateMap[index].Entities)
+	f.LogChanges(fetch.removed, fetch.added, &stateFetcherLog)
+	fetch.desired = FetchEntitiesFromState(fetch.newState)
+
+	return fetch
+}
+
+// applyGroupState retires the entities a fetched group no longer wants and
+// replicates the ones it gained. others are the entities wanted by every
+// other group and by ad-hoc pulls, which must not be deleted.
+func (f *FetchAndReplicateStateProcess) applyGroupState(
+	ctx context.Context,
+	fetch *groupFetch,
+	others []Entity,
+	replicator Replicator,
+	deletions *deletionRun,
+	mutex *sync.Mutex,
+) StateFetcherResult {
+	index := fetch.result.Index
+	result := fetch.result
+	stateFetcherLog := fetch.log
+	group := groupLabel(f.stateMap[index].url)
+	deleteEntity, replicateEntity, newState, desired := fetch.removed, fetch.added, fetch.newState, fetch.desired
+
+	replicator = f.replicatorForGroup(replicator, f.stateMap[index].url, &stateFetcherLog)
+
+	// Removed entities are tombstoned rather than deleted right away, so a
+	// mistaken group edit can be reverted within the grace period.
+	f.retireEntities(ctx, f.stateMap[index].url, deleteEntity, desired, others, replicator, deletions, &stateFetcherLog)
+
+	// Dead-lettered entities wait for their slower retry cadence so that a
+	// poisoned tag is not hammered on every sync.
+	replicateEntity, deferred := f.deadLetters.Partition(f.stateMap[index].url, replicateEntity, time.Now())
+	if len(deferred) > 0 {
+		stateFetcherLog.Warn().Int("deferred", len(deferred)).Msg("Skipping dead-lettered entities until their next retry")
 	}
 
-	if err := replicator.Replicate(ctx, replicateEntity); err != nil {
-		stateFetcherLog.Error().Err(err).Msg("Error replicating state")
-		result.Error = fmt.Errorf("failed to replicate entities for %s: %w", f.stateMap[index].url, err)
+	summary, replicateErr := replicator.Replicate(ctx, replicateEntity)
+	result.Summary = summary
+	metrics.ImagesReplicated.WithLabelValues(f.name, group).Add(float64(summary.Count(EntityReplicated)))
+	if replicateErr != nil && ctx.Err() != nil {
+		stateFetcherLog.Warn().Err(replicateErr).Msg("Replication cancelled")
+		result.Cancelled = true
 		return result
 	}
+	stateFetcherLog.Info().
+		Int("replicated", summary.Count(EntityReplicated)).
+		Int("skipped", summary.Count(EntitySkipped)).
+		Int("failed", summary.Count(EntityFailed)).
+		Msg("Replication finished")
+
+	retryAfter := parseDurationOr(f.cm.GetReplicationConfig().DeadLetterRetryInterval, config.DefaultDeadLetterRetryInterval)
+	f.deadLetters.Apply(f.stateMap[index].url, summary, retryAfter, time.Now())
+	f.deadLetters.Retain(f.stateMap[index].url, desired)
+	if err := f.deadLetters.Save(); err != nil {
+		stateFetcherLog.Warn().Err(err).Msg("Failed to persist dead-letter list to disk")
+	}
+	f.signatures.Apply(f.stateMap[index].url, summary, time.Now())
+	f.signatures.Retain(f.stateMap[index].url, desired)
 
+	// Entities that failed or were deferred are left out of the recorded
+	// state so that the next sync schedules them again.
 	mutex.Lock()
 	f.stateMap[index].State = newState
-	f.stateMap[index].Entities = FetchEntitiesFromState(newState)
+	f.stateMap[index].Entities = withoutEntities(desired, append(summary.FailedEntities(), deferred...))
+	f.stateMap[index].Signatures = f.signatures.Group(f.stateMap[index].url)
+	f.recordGroupSync(f.stateMap[index].url, desired, f.stateMap[index].Entities, time.Now())
 	if f.stateFilePath != "" {
 		if err := SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest); err != nil {
 			stateFetcherLog.Warn().Err(err).Msg("Failed to persist state to disk")
@@ -482,16 +825,44 @@ func (f *FetchAndReplicateStateProcess) processGroupState(
 	}
 	mutex.Unlock()
 
+	if replicateErr != nil {
+		stateFetcherLog.Error().Err(replicateErr).Msg("Error replicating state")
+		result.Error = fmt.Errorf("failed to replicate entities for %s: %w", f.stateMap[index].url, replicateErr)
+	}
+
 	return result
 }
 
+// entitiesOfOtherGroups returns the entities wanted by every group but the one
+// at index. Groups fetched in this run are known by the state just fetched,
+// the others by their last fetched state or the entities recorded on disk.
+// The caller must hold the stateMap mutex.
+func (f *FetchAndReplicateStateProcess) entitiesOfOtherGroups(index int, fetched map[int][]Entity) []Entity {
+	var entities []Entity
+	for i, sm := range f.stateMap {
+		if i == index {
+			continue
+		}
+		if desired, ok := fetched[i]; ok {
+			entities = append(entities, desired...)
+			continue
+		}
+		if sm.State != nil {
+			entities = append(entities, FetchEntitiesFromState(sm.State)...)
+			continue
+		}
+		entities = append(entities, sm.Entities...)
+	}
+	return entities
+}
+
 func (f *FetchAndReplicateStateProcess) fetchSatelliteRootState(
 	ctx context.Context,
 	satelliteStateURL, srcUsername, srcPassword string,
 	useUnsecure bool,
 	log *zerolog.Logger,
 ) (*SatelliteState, error) {
-	satelliteStateFetcher, err := getStateFetcherForInput(satelliteStateURL, srcUsername, srcPassword, useUnsecure, log)
+	satelliteStateFetcher, err := f.stateFetcher(ctx, satelliteStateURL, srcUsername, srcPassword, useUnsecure, log)
 	if err != nil {
 		log.Error().Err(err).Msg("Error processing satellite state")
 		return nil, err
@@ -504,7 +875,29 @@ func (f *FetchAndReplicateStateProcess) fetchSatelliteRootState(
 	return satelliteState, nil
 }
 
-func (f *FetchAndReplicateStateProcess) setupReplication() (Replicator, string, string, string, string, bool, string) {
+// replicatorForGroup applies the group's platform filter, bandwidth limit and
+// metric labels to the replicator. Replicators other than BasicReplicator are returned
+// unchanged.
+func (f *FetchAndReplicateStateProcess) replicatorForGroup(replicator Replicator, groupURL string, log *zerolog.Logger) Replicator {
+	basic, ok := replicator.(*BasicReplicator)
+	if !ok {
+		return replicator
+	}
+	group := groupNameFromStateURL(groupURL)
+	opts := []ReplicatorOption{
+		WithMetricLabels(basic.process, groupLabel(groupURL)),
+		WithBandwidthLimit(f.cm.GetReplicationBandwidth(group)),
+	}
+	platforms, err := ParsePlatforms(f.cm.GetReplicationPlatforms(group))
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid platform filter, replicating every platform")
+	} else {
+		opts = append(opts, WithPlatforms(platforms))
+	}
+	return basic.withOptions(opts...)
+}
+
+func (f *FetchAndReplicateStateProcess) setupReplication(log *zerolog.Logger) (Replicator, string, string, string, string, bool, string) {
 	sourceURL := utils.FormatRegistryURL(f.cm.GetSourceRegistryURL())
 	remoteURL := utils.FormatRegistryURL(f.cm.GetLocalRegistryURL())
 	srcUsername := f.cm.GetSourceRegistryUsername()
@@ -524,7 +917,30 @@ func (f *FetchAndReplicateStateProcess) setupReplication() (Replicator, string,
 		}
 	}
 
-	replicator := NewBasicReplicator(srcUsername, srcPassword, sourceURL, remoteURL, remoteUsername, remotePassword, useUnsecure)
+	replicationCfg := f.cm.GetReplicationConfig()
+	opts := []ReplicatorOption{
+		WithReplicationLimits(NewReplicationLimits(replicationCfg)),
+		WithRetryPolicy(NewRetryPolicy(replicationCfg)),
+		WithMetricLabels(f.name, ""),
+	}
+	if f.spool != nil {
+		minSize := replicationCfg.ResumableBlobMinSize
+		if minSize <= 0 {
+			minSize = config.DefaultResumableBlobMinSize
+		}
+		opts = append(opts, WithBlobSpool(f.spool, minSize))
+	}
+	if sigCfg := f.cm.GetSignatureVerificationConfig(); sigCfg.Enabled {
+		verifier, err := signature.NewVerifier(sigCfg)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load signature trust policy, every image will be refused")
+		}
+		opts = append(opts, WithSignatureVerifier(verifier))
+	}
+	if caFile := f.cm.GetLocalRegistryCAFile(); caFile != "" {
+		opts = append(opts, WithLocalRegistryCA(caFile))
+	}
+	replicator := NewBasicReplicator(srcUsername, srcPassword, sourceURL, remoteURL, remoteUsername, remotePassword, useUnsecure, opts...)
 
 	return replicator, sourceURL, srcUsername, srcPassword, remoteURL, useUnsecure, satelliteStateURL
 }
@@ -1,8 +1,12 @@
 package state
 
 import (
+	"encoding/json"
+	"path/filepath"
 	"testing"
 
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
@@ -284,3 +288,34 @@ func TestRemoveNullTagArtifacts(t *testing.T) {
 		require.Len(t, result.GetArtifacts(), 1)
 	})
 }
+
+func TestReplicatorForGroup_AppliesGroupSettings(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		StateConfig: config.StateConfig{StateURL: "http://registry/satellite/satellite-state/test-sat/state:latest"},
+		AppConfig: config.AppConfig{
+			GroundControlURL: "http://127.0.0.1:8080",
+			Replication: config.ReplicationConfig{
+				GroupPlatforms:         map[string][]string{"edge": {"linux/arm64"}},
+				GroupMaxBytesPerSecond: map[string]int64{"edge": 1 << 20},
+			},
+		},
+		ZotConfigRaw: json.RawMessage(`{}`),
+	}
+	cm, err := config.NewConfigManager(filepath.Join(dir, "config.json"), filepath.Join(dir, "prev.json"), "token", "http://127.0.0.1:8080", false, cfg)
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	f := NewFetchAndReplicateStateProcess(cm, filepath.Join(dir, "state.json"), &log)
+
+	base := NewBasicReplicator("", "", "src", "dst", "", "", true, WithMetricLabels("replicate", "")).(*BasicReplicator)
+	scoped, ok := f.replicatorForGroup(base, "http://registry/satellite/group-state/edge/state:latest", &log).(*BasicReplicator)
+	require.True(t, ok)
+
+	require.Equal(t, []v1.Platform{{OS: "linux", Architecture: "arm64"}}, scoped.platforms)
+	require.Equal(t, "replicate", scoped.process)
+	require.Equal(t, "edge", scoped.group)
+	require.NotNil(t, scoped.bandwidth)
+	require.Empty(t, base.platforms, "the shared replicator must not be scoped")
+	require.Empty(t, base.group)
+	require.Nil(t, base.bandwidth)
+}
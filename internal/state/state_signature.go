@@ -0,0 +1,311 @@
+package state
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/rs/zerolog"
+)
+
+// Annotations Ground Control signs state artifacts with, see
+// ground-control/internal/utils/signing.go.
+const (
+	StatePayloadAnnotation   = "io.goharbor.satellite.state.payload"
+	StateSignatureAnnotation = "io.goharbor.satellite.state.signature"
+)
+
+// StatePublicKeyRoute serves the state public key to registered satellites.
+const StatePublicKeyRoute = "satellites/state-key"
+
+// StateIssuedFileName records when the last state artifact accepted from each
+// repository was issued, persisted next to state.json.
+const StateIssuedFileName = "state_issued.json"
+
+// stateIssuedPath returns the issue time file that belongs to stateFilePath.
+func stateIssuedPath(stateFilePath string) string {
+	if stateFilePath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(stateFilePath), StateIssuedFileName)
+}
+
+// ErrStateSignature is returned for state artifacts that are unsigned, not
+// signed by Ground Control, or signed for another repository or content.
+var ErrStateSignature = errors.New("state artifact signature rejected")
+
+// statePayload is what Ground Control signs for a state artifact.
+type statePayload struct {
+	Subject  string    `json:"subject"`
+	Digest   string    `json:"digest"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// StateVerifier checks state artifacts against the key Ground Control signs
+// them with, so that whoever can push to the satellite project in Harbor
+// cannot hand satellites a state of their own. The signed payload ties the
+// artifact to its repository and content, and artifacts issued before the
+// last one accepted for the same repository are refused, so that an older
+// state cannot be replayed either. That high-water mark is persisted to path,
+// so that it holds across restarts; a verifier with an empty path keeps it in
+// memory only.
+//
+// States read from the local filesystem are trusted as they are.
+type StateVerifier struct {
+	key    ed25519.PublicKey
+	path   string
+	mu     sync.Mutex
+	latest map[string]time.Time
+	dirty  bool
+}
+
+// NewStateVerifier returns a verifier for the PEM encoded ed25519 public key
+// that persists the issue time of accepted states to path. If the issue
+// times cannot be read, a verifier that starts without them is returned along
+// with the error.
+func NewStateVerifier(publicKeyPEM, path string) (*StateVerifier, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.New("state public key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse state public key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("state public key is a %T, not an ed25519 key", parsed)
+	}
+	v := &StateVerifier{key: key, path: path, latest: map[string]time.Time{}}
+	if path == "" {
+		return v, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return v, nil
+		}
+		return v, fmt.Errorf("read state issue times: %w", err)
+	}
+	var latest map[string]time.Time
+	if err := json.Unmarshal(data, &latest); err != nil {
+		return v, fmt.Errorf("unmarshal state issue times: %w", err)
+	}
+	for subject, issuedAt := range latest {
+		v.latest[subject] = issuedAt
+	}
+	return v, nil
+}
+
+// Save persists the issue time of the last state accepted from each
+// repository if it changed since the last save.
+func (v *StateVerifier) Save() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.path == "" || !v.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(v.latest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state issue times: %w", err)
+	}
+	if err := writeFileAtomic(v.path, data, "state-issued-*.json.tmp"); err != nil {
+		return err
+	}
+	v.dirty = false
+	return nil
+}
+
+// Verify checks that img, fetched from ref and holding data as its
+// artifacts.json, was signed by Ground Control for that repository and
+// content, and is not older than the last artifact accepted from it.
+func (v *StateVerifier) Verify(ref string, img v1.Image, data []byte) error {
+	parsed, err := name.ParseReference(strings.TrimPrefix(strings.TrimPrefix(ref, "https://"), "http://"))
+	if err != nil {
+		return fmt.Errorf("parse reference %s: %w", ref, err)
+	}
+	subject := parsed.Context().RepositoryStr()
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return fmt.Errorf("read state artifact manifest: %w", err)
+	}
+	encodedPayload, encodedSignature := manifest.Annotations[StatePayloadAnnotation], manifest.Annotations[StateSignatureAnnotation]
+	if encodedPayload == "" || encodedSignature == "" {
+		return fmt.Errorf("%w: %s is not signed", ErrStateSignature, subject)
+	}
+	payload, err := base64.StdEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return fmt.Errorf("%w: decode payload: %v", ErrStateSignature, err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return fmt.Errorf("%w: decode signature: %v", ErrStateSignature, err)
+	}
+	if len(v.key) != ed25519.PublicKeySize || !ed25519.Verify(v.key, payload, signature) {
+		return fmt.Errorf("%w: %s is not signed by Ground Control", ErrStateSignature, subject)
+	}
+
+	var signed statePayload
+	if err := json.Unmarshal(payload, &signed); err != nil {
+		return fmt.Errorf("%w: decode payload: %v", ErrStateSignature, err)
+	}
+	if signed.Subject != subject {
+		return fmt.Errorf("%w: %s carries the state of %s", ErrStateSignature, subject, signed.Subject)
+	}
+	sum := sha256.Sum256(data)
+	if digest := "sha256:" + hex.EncodeToString(sum[:]); signed.Digest != digest {
+		return fmt.Errorf("%w: content of %s is %s, signed %s", ErrStateSignature, subject, digest, signed.Digest)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	latest := v.latest[subject]
+	if signed.IssuedAt.Before(latest) {
+		return fmt.Errorf("%w: %s was issued at %s, before the state already applied from %s", ErrStateSignature, subject, signed.IssuedAt, latest)
+	}
+	if signed.IssuedAt.After(latest) {
+		v.latest[subject] = signed.IssuedAt
+		v.dirty = true
+	}
+	return nil
+}
+
+// decodeStateArtifact decodes the artifacts.json of the state artifact
+// fetched from ref into out, once verifier, if any, has accepted it.
+func decodeStateArtifact(ref string, img v1.Image, out any, verifier *StateVerifier, log *zerolog.Logger) error {
+	data, err := readArtifactJSON(ref, img, log)
+	if err != nil {
+		return err
+	}
+	if verifier != nil {
+		if err := verifier.Verify(ref, img, data); err != nil {
+			log.Error().Err(err).Msgf("Refusing state artifact: %s", ref)
+			return err
+		}
+		if err := verifier.Save(); err != nil {
+			log.Warn().Err(err).Msg("Failed to persist the issue time of the accepted state")
+		}
+	}
+	return json.Unmarshal(data, out)
+}
+
+// stateVerifier returns the verifier for the key Ground Control handed out,
+// or nil if it does not sign states. It is rebuilt when the key changes.
+func (f *FetchAndReplicateStateProcess) stateVerifier(log *zerolog.Logger) *StateVerifier {
+	key := f.cm.GetStatePublicKey()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if key == "" {
+		f.verifier, f.verifierKey = nil, ""
+		return nil
+	}
+	if key == f.verifierKey && f.verifier != nil {
+		return f.verifier
+	}
+	verifier, err := NewStateVerifier(key, stateIssuedPath(f.stateFilePath))
+	switch {
+	case verifier == nil:
+		// An unusable key must not turn verification off
+		log.Error().Err(err).Msg("Invalid state public key, every state artifact will be refused")
+		verifier = &StateVerifier{latest: map[string]time.Time{}}
+	case err != nil:
+		log.Warn().Err(err).Msg("Failed to read when the accepted states were issued, older states are refused again once a state is accepted")
+	}
+	f.verifier, f.verifierKey = verifier, key
+	return verifier
+}
+
+// ensureStatePublicKey asks Ground Control for the state public key if the
+// satellite has none, as satellites registered before Ground Control signed
+// states did not receive it. The key is kept in the config, so verification
+// stays on from then on.
+func (f *FetchAndReplicateStateProcess) ensureStatePublicKey(ctx context.Context, log *zerolog.Logger) {
+	if f.cm.GetStatePublicKey() != "" || !f.cm.IsZTRDone() {
+		return
+	}
+	key, err := f.fetchStatePublicKey(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to fetch the state public key from Ground Control")
+		return
+	}
+	if key == "" {
+		return
+	}
+	f.cm.With(config.SetStatePublicKey(key))
+	if err := f.cm.WriteConfig(); err != nil {
+		log.Warn().Err(err).Msg("Failed to persist the state public key")
+	}
+	log.Info().Msg("Received the state public key from Ground Control, state artifacts are verified from now on")
+}
+
+func (f *FetchAndReplicateStateProcess) fetchStatePublicKey(ctx context.Context) (string, error) {
+	satelliteName, err := extractSatelliteNameFromURL(f.cm.GetStateURL())
+	if err != nil {
+		return "", err
+	}
+	keyURL := fmt.Sprintf("%s/%s?satellite=%s", f.cm.ResolveGroundControlURL(), StatePublicKeyRoute, satelliteName)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keyURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+
+	var client *http.Client
+	if f.spiffeClient != nil {
+		if err := f.spiffeClient.Connect(ctx); err != nil {
+			return "", fmt.Errorf("connect to SPIRE agent: %w", err)
+		}
+		client, err = f.spiffeClient.CreateHTTPClient()
+		if err != nil {
+			return "", fmt.Errorf("create SPIFFE HTTP client: %w", err)
+		}
+	} else {
+		client, err = createHTTPClient(f.cm.GetTLSConfig(), f.cm.UseUnsecure())
+		if err != nil {
+			return "", fmt.Errorf("create HTTP client: %w", err)
+		}
+		req.SetBasicAuth(f.cm.GetSourceRegistryUsername(), f.cm.GetSourceRegistryPassword())
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("send request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.FromContext(ctx).Warn().Err(err).Msg("error closing response body")
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("state public key request failed: %s", resp.Status)
+	}
+
+	var body struct {
+		StatePublicKey string `json:"state_public_key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode state public key: %w", err)
+	}
+	return body.StatePublicKey, nil
+}
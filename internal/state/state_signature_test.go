@@ -0,0 +1,203 @@
+package state
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func newStateSigningKey(t *testing.T) (ed25519.PrivateKey, string) {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// signedStateImage returns a state artifact holding data, signed the way
+// Ground Control signs them.
+func signedStateImage(t *testing.T, key ed25519.PrivateKey, subject string, data []byte, issuedAt time.Time) v1.Image {
+	t.Helper()
+	img, err := crane.Image(map[string][]byte{artifactsFileName: data})
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	payload, err := json.Marshal(statePayload{Subject: subject, Digest: "sha256:" + hex.EncodeToString(sum[:]), IssuedAt: issuedAt})
+	require.NoError(t, err)
+	return mutate.Annotations(img, map[string]string{
+		StatePayloadAnnotation:   base64.StdEncoding.EncodeToString(payload),
+		StateSignatureAnnotation: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	}).(v1.Image)
+}
+
+func TestStateVerifier_Verify(t *testing.T) {
+	key, publicKey := newStateSigningKey(t)
+	otherKey, _ := newStateSigningKey(t)
+	const ref = "http://harbor:8080/satellite/satellite-state/sat/state:latest"
+	const subject = "satellite/satellite-state/sat/state"
+	data := []byte(`{"states":["edge"]}`)
+	now := time.Now().UTC()
+
+	unsigned, err := crane.Image(map[string][]byte{artifactsFileName: data})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		img     v1.Image
+		data    []byte
+		wantErr string
+	}{
+		{name: "signed", img: signedStateImage(t, key, subject, data, now), data: data},
+		{name: "unsigned", img: unsigned, data: data, wantErr: "is not signed"},
+		{name: "other key", img: signedStateImage(t, otherKey, subject, data, now), data: data, wantErr: "not signed by Ground Control"},
+		{name: "other repository", img: signedStateImage(t, key, "satellite/satellite-state/other/state", data, now), data: data, wantErr: "carries the state of"},
+		{name: "tampered content", img: signedStateImage(t, key, subject, data, now), data: []byte(`{"states":["evil"]}`), wantErr: "signed sha256:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, err := NewStateVerifier(publicKey, "")
+			require.NoError(t, err)
+			err = verifier.Verify(ref, tt.img, tt.data)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrStateSignature)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestStateVerifier_RefusesReplayedState(t *testing.T) {
+	key, publicKey := newStateSigningKey(t)
+	verifier, err := NewStateVerifier(publicKey, "")
+	require.NoError(t, err)
+	const ref = "harbor:8080/satellite/group-state/edge/state:latest"
+	const subject = "satellite/group-state/edge/state"
+	older, newer := []byte(`{"registry":"old"}`), []byte(`{"registry":"new"}`)
+	issued := time.Now().UTC()
+
+	require.NoError(t, verifier.Verify(ref, signedStateImage(t, key, subject, newer, issued), newer))
+	require.NoError(t, verifier.Verify(ref, signedStateImage(t, key, subject, newer, issued), newer), "the same state is fetched again")
+	err = verifier.Verify(ref, signedStateImage(t, key, subject, older, issued.Add(-time.Minute)), older)
+	require.ErrorIs(t, err, ErrStateSignature)
+}
+
+func TestStateVerifier_RefusesReplayedStateAfterRestart(t *testing.T) {
+	key, publicKey := newStateSigningKey(t)
+	path := filepath.Join(t.TempDir(), StateIssuedFileName)
+	const ref = "harbor:8080/satellite/group-state/edge/state:latest"
+	const subject = "satellite/group-state/edge/state"
+	older, newer := []byte(`{"registry":"old"}`), []byte(`{"registry":"new"}`)
+	issued := time.Now().UTC()
+
+	verifier, err := NewStateVerifier(publicKey, path)
+	require.NoError(t, err)
+	require.NoError(t, verifier.Verify(ref, signedStateImage(t, key, subject, newer, issued), newer))
+	require.NoError(t, verifier.Save())
+
+	// The satellite restarts
+	verifier, err = NewStateVerifier(publicKey, path)
+	require.NoError(t, err)
+	err = verifier.Verify(ref, signedStateImage(t, key, subject, older, issued.Add(-time.Minute)), older)
+	require.ErrorIs(t, err, ErrStateSignature)
+	require.NoError(t, verifier.Verify(ref, signedStateImage(t, key, subject, newer, issued), newer))
+}
+
+func TestURLStateFetcher_VerifiesState(t *testing.T) {
+	_, addr := newTestRegistry(t)
+	key, publicKey := newStateSigningKey(t)
+	log := zerolog.Nop()
+	data, err := json.Marshal(State{Registry: "edge"})
+	require.NoError(t, err)
+
+	signedRef := addr + "/satellite/group-state/edge/state:latest"
+	require.NoError(t, crane.Push(signedStateImage(t, key, "satellite/group-state/edge/state", data, time.Now().UTC()), signedRef, crane.Insecure))
+	unsignedURL := pushStateArtifact(t, addr, "satellite/group-state/core/state", State{Registry: "core"})
+
+	verifier, err := NewStateVerifier(publicKey, "")
+	require.NoError(t, err)
+
+	fetcher := NewURLStateFetcher("http://"+signedRef, "", "", true).(*URLStateFetcher)
+	fetcher.SetVerifier(verifier)
+	state := &State{}
+	require.NoError(t, fetcher.FetchStateArtifact(testContext(), state, &log))
+	require.Equal(t, "edge", state.Registry)
+
+	fetcher = NewURLStateFetcher(unsignedURL, "", "", true).(*URLStateFetcher)
+	fetcher.SetVerifier(verifier)
+	require.ErrorIs(t, fetcher.FetchStateArtifact(testContext(), &State{}, &log), ErrStateSignature)
+
+	// Without a key states are accepted as before
+	fetcher = NewURLStateFetcher(unsignedURL, "", "", true).(*URLStateFetcher)
+	require.NoError(t, fetcher.FetchStateArtifact(testContext(), &State{}, &log))
+}
+
+func TestEnsureStatePublicKey(t *testing.T) {
+	_, publicKey := newStateSigningKey(t)
+	var requests int
+	gc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "robot$test-sat" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		require.Equal(t, "/"+StatePublicKeyRoute, r.URL.Path)
+		require.Equal(t, "test-sat", r.URL.Query().Get("satellite"))
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]string{"state_public_key": publicKey}))
+	}))
+	t.Cleanup(gc.Close)
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		StateConfig: config.StateConfig{
+			StateURL: "http://harbor/satellite/satellite-state/test-sat/state:latest",
+			RegistryCredentials: config.RegistryCredentials{
+				URL:      "http://harbor",
+				Username: "robot$test-sat",
+				Password: "secret",
+			},
+		},
+		AppConfig: config.AppConfig{
+			GroundControlURL: config.URL(gc.URL),
+			UseUnsecure:      true,
+		},
+		ZotConfigRaw: json.RawMessage(`{}`),
+	}
+	configPath := filepath.Join(dir, "config.json")
+	cm, err := config.NewConfigManager(configPath, filepath.Join(dir, "prev.json"), "token", gc.URL, false, cfg)
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	p := NewFetchAndReplicateStateProcess(cm, filepath.Join(dir, "state.json"), &log)
+
+	p.ensureStatePublicKey(testContext(), &log)
+	require.Equal(t, publicKey, cm.GetStatePublicKey())
+	require.NotNil(t, p.stateVerifier(&log))
+
+	persisted, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	require.Contains(t, string(persisted), "state_public_key")
+
+	// Once known the key is not asked for again
+	p.ensureStatePublicKey(testContext(), &log)
+	require.Equal(t, 1, requests)
+}
@@ -0,0 +1,576 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/metrics"
+	"github.com/container-registry/harbor-satellite/internal/utils"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rs/zerolog"
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// maxPendingEvictions bounds the evictions kept until they are reported; the
+// oldest are dropped first.
+const maxPendingEvictions = 100
+
+// Eviction is an image removed from the local registry to keep it within
+// its storage quota. FreedBytes counts the blobs no other image references;
+// the registry reclaims them at its next garbage collection.
+type Eviction struct {
+	Reference    string    `json:"reference"`
+	Digest       string    `json:"digest"`
+	FreedBytes   int64     `json:"freed_bytes"`
+	LastPulledAt time.Time `json:"last_pulled_at,omitzero"`
+	EvictedAt    time.Time `json:"evicted_at"`
+}
+
+// EvictionLog holds the evictions Ground Control has not been told about yet.
+type EvictionLog struct {
+	mu      sync.Mutex
+	entries []Eviction
+}
+
+func (l *EvictionLog) add(evictions ...Eviction) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, evictions...)
+	if over := len(l.entries) - maxPendingEvictions; over > 0 {
+		l.entries = l.entries[over:]
+	}
+}
+
+// Pending returns the evictions not reported yet, oldest first.
+func (l *EvictionLog) Pending() []Eviction {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Eviction(nil), l.entries...)
+}
+
+// Reported drops the given evictions once they were reported.
+func (l *EvictionLog) Reported(reported []Eviction) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sent := make(map[string]bool, len(reported))
+	for _, e := range reported {
+		sent[e.Reference+"|"+e.EvictedAt.String()] = true
+	}
+	kept := l.entries[:0]
+	for _, e := range l.entries {
+		if !sent[e.Reference+"|"+e.EvictedAt.String()] {
+			kept = append(kept, e)
+		}
+	}
+	l.entries = kept
+}
+
+// localImage is a tag of the local registry and the blobs it references,
+// manifests included, keyed by digest.
+type localImage struct {
+	repo   string
+	tag    string
+	digest string
+	blobs  map[string]int64
+}
+
+func (i localImage) ref() string {
+	return i.repo + ":" + i.tag
+}
+
+// entity returns the image as the entity the replicator would have created
+// it from. Images outside of a project have none.
+func (i localImage) entity() (Entity, bool) {
+	dir, base := path.Split(i.repo)
+	if dir == "" {
+		return Entity{}, false
+	}
+	return Entity{Repository: strings.TrimSuffix(dir, "/"), Name: base, Tag: i.tag, Digest: i.digest}, true
+}
+
+// localRegistry returns the local registry and the options to reach it.
+func (f *FetchAndReplicateStateProcess) localRegistry(ctx context.Context) (name.Registry, []remote.Option, error) {
+	var nameOpts []name.Option
+	if f.cm.UseUnsecure() {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	registry, err := name.NewRegistry(utils.FormatRegistryURL(f.cm.GetLocalRegistryURL()), nameOpts...)
+	if err != nil {
+		return name.Registry{}, nil, err
+	}
+	transport, err := localRegistryTransport(f.cm.GetLocalRegistryCAFile())
+	if err != nil {
+		return name.Registry{}, nil, err
+	}
+	opts := []remote.Option{remote.WithContext(ctx), remote.WithTransport(transport), remote.WithAuth(localRegistryAuth(f.cm))}
+	return registry, opts, nil
+}
+
+// storageUsage returns the combined size of the blobs of images, counting
+// shared blobs once.
+func storageUsage(images []localImage) int64 {
+	seen := make(map[string]bool)
+	var total int64
+	for _, img := range images {
+		for digest, size := range img.blobs {
+			if !seen[digest] {
+				seen[digest] = true
+				total += size
+			}
+		}
+	}
+	return total
+}
+
+// isCosignTag reports whether tag holds a cosign signature, attestation or
+// SBOM. These are deleted along with the image they are attached to.
+func isCosignTag(tag string) bool {
+	if !strings.HasPrefix(tag, "sha256-") {
+		return false
+	}
+	for _, suffix := range cosignTagSuffixes {
+		if strings.HasSuffix(tag, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// listLocalImages lists every tag of the registry with the blobs it
+// references. Image indexes reference the blobs of their child manifests.
+func listLocalImages(ctx context.Context, registry name.Registry, opts []remote.Option) ([]localImage, error) {
+	repos, err := remote.Catalog(ctx, registry, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("list repositories: %w", err)
+	}
+
+	var images []localImage
+	for _, repoName := range repos {
+		repo := registry.Repo(repoName)
+		tags, err := remote.List(repo, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("list tags of %s: %w", repoName, err)
+		}
+		for _, tag := range tags {
+			desc, err := remote.Get(repo.Tag(tag), opts...)
+			if isNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("get %s:%s: %w", repoName, tag, err)
+			}
+			img := localImage{repo: repoName, tag: tag, digest: desc.Digest.String(), blobs: map[string]int64{}}
+			if err := addManifestBlobs(repo, desc, img.blobs, opts); err != nil {
+				return nil, fmt.Errorf("read %s:%s: %w", repoName, tag, err)
+			}
+			images = append(images, img)
+		}
+	}
+	return images, nil
+}
+
+func addManifestBlobs(repo name.Repository, desc *remote.Descriptor, blobs map[string]int64, opts []remote.Option) error {
+	blobs[desc.Digest.String()] = desc.Size
+	if desc.MediaType.IsIndex() {
+		idx, err := v1.ParseIndexManifest(bytes.NewReader(desc.Manifest))
+		if err != nil {
+			return err
+		}
+		for _, m := range idx.Manifests {
+			child, err := remote.Get(repo.Digest(m.Digest.String()), opts...)
+			if isNotFound(err) {
+				blobs[m.Digest.String()] = m.Size
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := addManifestBlobs(repo, child, blobs, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	m, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+	if err != nil {
+		return err
+	}
+	blobs[m.Config.Digest.String()] = m.Config.Size
+	for _, l := range m.Layers {
+		blobs[l.Digest.String()] = l.Size
+	}
+	return nil
+}
+
+// localZotConfig is the part of the embedded registry's config the storage
+// quota and the pull-through mode read.
+type localZotConfig struct {
+	Storage struct {
+		RootDirectory string `json:"rootDirectory"`
+	} `json:"storage"`
+	Log struct {
+		Output string `json:"output"`
+	} `json:"log"`
+}
+
+func parseLocalZotConfig(raw []byte) (localZotConfig, error) {
+	var zot localZotConfig
+	if err := json.Unmarshal(raw, &zot); err != nil {
+		return zot, fmt.Errorf("unmarshal zot config: %w", err)
+	}
+	return zot, nil
+}
+
+// FollowRegistryLog copies the log of the embedded registry to stdout, where
+// it would go if the satellite did not read pulls from it, and records the
+// pulls as they are logged, until ctx is done.
+func (f *FetchAndReplicateStateProcess) FollowRegistryLog(ctx context.Context) {
+	f.access.Follow(ctx, f.registryLogPath, os.Stdout)
+}
+
+// registryLogPath returns the file the embedded registry logs to, if any.
+func (f *FetchAndReplicateStateProcess) registryLogPath() string {
+	if f.cm.GetOwnRegistry() {
+		return ""
+	}
+	zot, err := parseLocalZotConfig(f.cm.GetRawZotConfig())
+	if err != nil {
+		return ""
+	}
+	return zot.Log.Output
+}
+
+// storageBudget returns the quota in bytes. A percentage is taken of the
+// volume root is stored on.
+func storageBudget(quota config.StorageQuotaConfig, root string) (int64, error) {
+	budget := quota.MaxBytes
+	if quota.MaxPercent > 0 {
+		usage, err := disk.Usage(root)
+		if err != nil {
+			return 0, fmt.Errorf("read usage of %s: %w", root, err)
+		}
+		byPercent := int64(float64(usage.Total) * quota.MaxPercent / 100)
+		if budget == 0 || byPercent < budget {
+			budget = byPercent
+		}
+	}
+	return budget, nil
+}
+
+// enforceStorageQuota evicts images that no group state lists, least
+// recently pulled first, until the local registry fits its quota with room
+// left for the images this run is about to replicate. wanted are the
+// entities of every group state, incoming the ones to replicate. Images
+// still within their deletion grace period are evicted only once no other
+// image is left to evict, so that a mistaken group edit can still be undone.
+// Images used by running containers are kept unless deletion.ignore_in_use is
+// set, and evictions count against the deletions allowed per sync.
+func (f *FetchAndReplicateStateProcess) enforceStorageQuota(ctx context.Context, replicator Replicator, wanted, incoming []Entity, deletions *deletionRun, log *zerolog.Logger) {
+	quota := f.cm.GetStorageQuotaConfig()
+	if !quota.Enabled() || f.cm.GetOwnRegistry() {
+		return
+	}
+
+	zot, err := parseLocalZotConfig(f.cm.GetRawZotConfig())
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read the registry config, storage quota not enforced")
+		return
+	}
+	budget, err := storageBudget(quota, zot.Storage.RootDirectory)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to compute the storage quota, storage quota not enforced")
+		return
+	}
+
+	if zot.Log.Output == "" {
+		log.Warn().Msg("The registry does not write an access log, images are evicted without knowing when they were last pulled")
+	} else if err := f.access.Scan(zot.Log.Output); err != nil {
+		log.Warn().Err(err).Msg("Failed to read the registry access log")
+	}
+	defer func() {
+		if err := f.access.Save(); err != nil {
+			log.Warn().Err(err).Msg("Failed to persist the last pulls of local images")
+		}
+	}()
+
+	registry, opts, err := f.localRegistry(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid local registry URL, storage quota not enforced")
+		return
+	}
+	images, err := listLocalImages(ctx, registry, opts)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list local images, storage quota not enforced")
+		return
+	}
+
+	var incomingBlobs map[string]int64
+	if sizer, ok := replicator.(incomingSizer); ok && len(incoming) > 0 {
+		incomingBlobs, err = sizer.incomingBlobs(ctx, incoming)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to size the images to replicate, no room is reserved for them")
+		}
+	}
+
+	used := storageUsage(images)
+	reserved := reservedBytes(incomingBlobs, images)
+	if used+reserved <= budget {
+		log.Debug().Int64("used_bytes", used).Int64("reserved_bytes", reserved).Int64("quota_bytes", budget).Msg("Local registry is within its storage quota")
+		return
+	}
+	log.Info().Int64("used_bytes", used).Int64("reserved_bytes", reserved).Int64("quota_bytes", budget).Msg("Local registry exceeds its storage quota, evicting images")
+
+	desired := make(map[string]bool)
+	for _, e := range wanted {
+		desired[e.Repository+"/"+e.Name+":"+e.Tag] = true
+	}
+	inGrace := make(map[string]bool)
+	for _, t := range f.tombstones.Pending(time.Now()) {
+		inGrace[t.Entity.Repository+"/"+t.Entity.Name+":"+t.Entity.Tag] = true
+	}
+	var candidates []localImage
+	lastPull := make(map[string]time.Time)
+	for _, img := range images {
+		if desired[img.ref()] || isCosignTag(img.tag) {
+			continue
+		}
+		candidates = append(candidates, img)
+		lastPull[img.ref()] = f.access.LastPull(img.repo, img.tag, img.digest)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ri, rj := candidates[i].ref(), candidates[j].ref()
+		if inGrace[ri] != inGrace[rj] {
+			return !inGrace[ri]
+		}
+		a, b := lastPull[ri], lastPull[rj]
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return ri < rj
+	})
+
+	var evicted []Eviction
+	for _, img := range candidates {
+		if used+reserved <= budget {
+			break
+		}
+		entity, ok := img.entity()
+		if !ok {
+			log.Debug().Str("image", img.ref()).Msg("Image outside of a project is not evicted")
+			continue
+		}
+		if hold := deletions.hold(ctx, entity); hold != "" {
+			log.Info().Str("image", img.ref()).Str("hold", hold).Msg("Image is kept despite the storage quota")
+			continue
+		}
+		if !deletions.take() {
+			log.Warn().Msg("Deletion limit for this sync reached, remaining images are evicted on the next sync")
+			break
+		}
+		if inGrace[img.ref()] {
+			log.Warn().Str("image", img.ref()).Msg("Evicting image within its deletion grace period, no other image is left to evict")
+		}
+
+		remaining, shared := withoutImage(images, img)
+		if shared {
+			err = replicator.UntagReplicationEntity(ctx, []Entity{entity})
+		} else {
+			err = replicator.DeleteReplicationEntity(ctx, []Entity{entity})
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("image", img.ref()).Msg("Failed to evict image")
+			continue
+		}
+
+		newUsed := storageUsage(remaining)
+		evicted = append(evicted, Eviction{
+			Reference:    img.ref(),
+			Digest:       img.digest,
+			FreedBytes:   used - newUsed,
+			LastPulledAt: lastPull[img.ref()],
+			EvictedAt:    time.Now().UTC(),
+		})
+		metrics.ImagesEvicted.WithLabelValues(f.name).Inc()
+		log.Info().Str("image", img.ref()).Int64("freed_bytes", used-newUsed).Time("last_pulled_at", lastPull[img.ref()]).Msg("Evicted image to stay within the storage quota")
+		f.access.Forget(img.repo, img.tag, img.digest)
+		f.forgetTombstone(entity, log)
+		f.adhoc.Remove(entity)
+		images, used = remaining, newUsed
+		reserved = reservedBytes(incomingBlobs, images)
+	}
+	f.evictions.add(evicted...)
+	if len(evicted) > 0 {
+		if err := f.adhoc.Save(); err != nil {
+			log.Warn().Err(err).Msg("Failed to persist ad-hoc images after an eviction")
+		}
+	}
+
+	if used+reserved > budget {
+		log.Warn().Int64("used_bytes", used).Int64("reserved_bytes", reserved).Int64("quota_bytes", budget).Msg("Local registry still exceeds its storage quota, the remaining images are wanted by group states, in use or over the deletion limit")
+	}
+}
+
+// reservedBytes returns the size of the incoming blobs that no local image
+// references yet.
+func reservedBytes(incoming map[string]int64, images []localImage) int64 {
+	local := make(map[string]bool)
+	for _, img := range images {
+		for digest := range img.blobs {
+			local[digest] = true
+		}
+	}
+	var total int64
+	for digest, size := range incoming {
+		if !local[digest] {
+			total += size
+		}
+	}
+	return total
+}
+
+// incomingSizer is implemented by the replicators that can list the blobs of
+// images before replicating them.
+type incomingSizer interface {
+	// incomingBlobs returns the blobs the entities reference, manifests
+	// included, keyed by digest. Every platform of an index is counted, so
+	// a platform filter makes the reservation generous.
+	incomingBlobs(ctx context.Context, entities []Entity) (map[string]int64, error)
+}
+
+func (r *BasicReplicator) incomingBlobs(ctx context.Context, entities []Entity) (map[string]int64, error) {
+	opts, err := r.buildReplicationOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blobs := make(map[string]int64)
+	for _, entity := range entities {
+		srcRef := fmt.Sprintf("%s/%s/%s:%s", r.sourceRegistry, entity.GetRepository(), entity.GetName(), entity.GetTag())
+		if entity.Digest != "" {
+			srcRef = fmt.Sprintf("%s/%s/%s@%s", r.sourceRegistry, entity.GetRepository(), entity.GetName(), entity.Digest)
+		}
+		src, err := name.ParseReference(srcRef, opts.nameOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("parse source ref %s: %w", srcRef, err)
+		}
+		desc, err := remote.Get(src, opts.pullOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("get %s: %w", srcRef, err)
+		}
+		if err := addManifestBlobs(src.Context(), desc, blobs, opts.pullOpts); err != nil {
+			return nil, fmt.Errorf("read %s: %w", srcRef, err)
+		}
+	}
+	return blobs, nil
+}
+
+func (r *LayoutReplicator) incomingBlobs(ctx context.Context, entities []Entity) (map[string]int64, error) {
+	index, err := r.path.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("read layout index: %w", err)
+	}
+	blobs := make(map[string]int64)
+	for _, entity := range entities {
+		key, err := entityBundleKey(entity)
+		if err != nil {
+			return nil, err
+		}
+		desc, err := findInLayout(r.path, key)
+		if err != nil {
+			return nil, err
+		}
+		if err := addLayoutBlobs(index, desc, blobs); err != nil {
+			return nil, fmt.Errorf("read %s: %w", key, err)
+		}
+	}
+	return blobs, nil
+}
+
+// addLayoutBlobs adds the blobs of desc, a manifest listed in index.
+func addLayoutBlobs(index v1.ImageIndex, desc v1.Descriptor, blobs map[string]int64) error {
+	blobs[desc.Digest.String()] = desc.Size
+	if desc.MediaType.IsIndex() {
+		child, err := index.ImageIndex(desc.Digest)
+		if err != nil {
+			return err
+		}
+		m, err := child.IndexManifest()
+		if err != nil {
+			return err
+		}
+		for _, d := range m.Manifests {
+			if err := addLayoutBlobs(child, d, blobs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	img, err := index.Image(desc.Digest)
+	if err != nil {
+		return err
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+	blobs[m.Config.Digest.String()] = m.Config.Size
+	for _, l := range m.Layers {
+		blobs[l.Digest.String()] = l.Size
+	}
+	return nil
+}
+
+// withoutImage returns images without img and whether another tag still
+// references its manifest. Unless one does, the cosign tags attached to the
+// manifest go with it.
+func withoutImage(images []localImage, img localImage) ([]localImage, bool) {
+	shared := false
+	for _, other := range images {
+		if other.ref() != img.ref() && other.repo == img.repo && other.digest == img.digest {
+			shared = true
+		}
+	}
+	attached := strings.Replace(img.digest, ":", "-", 1)
+	var remaining []localImage
+	for _, other := range images {
+		if other.ref() == img.ref() {
+			continue
+		}
+		if !shared && other.repo == img.repo && isCosignTag(other.tag) && strings.HasPrefix(other.tag, attached) {
+			continue
+		}
+		remaining = append(remaining, other)
+	}
+	return remaining, shared
+}
+
+// forgetTombstone drops the tombstones of an evicted entity.
+func (f *FetchAndReplicateStateProcess) forgetTombstone(entity Entity, log *zerolog.Logger) {
+	removed := false
+	for _, t := range f.tombstones.Entries() {
+		if t.Entity.Repository == entity.Repository && t.Entity.Name == entity.Name && t.Entity.Tag == entity.Tag {
+			f.tombstones.Remove(t.Group, t.Entity)
+			removed = true
+		}
+	}
+	if removed {
+		if err := f.tombstones.Save(); err != nil {
+			log.Warn().Err(err).Msg("Failed to persist tombstones after an eviction")
+		}
+	}
+}
+
+// Evictions returns the evictions not reported to Ground Control yet.
+func (f *FetchAndReplicateStateProcess) Evictions() *EvictionLog {
+	return f.evictions
+}
@@ -0,0 +1,192 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnforceStorageQuota_EvictsLeastRecentlyPulled(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	f := newBundleTestProcess(t, srcAddr, dstAddr)
+	log := zerolog.Nop()
+
+	wanted := pushImage(t, dstAddr, "library", "wanted", "v1", 2)
+	stale := pushImage(t, dstAddr, "library", "stale", "v1", 2)
+	pushImage(t, dstAddr, "library", "recent", "v1", 2)
+	staleDigest, err := stale.Digest()
+	require.NoError(t, err)
+	wantedDigest, err := wanted.Digest()
+	require.NoError(t, err)
+	wantedEntities := []Entity{{Repository: "library", Name: "wanted", Tag: "v1", Digest: wantedDigest.String()}}
+
+	registry, err := name.NewRegistry(dstAddr, name.Insecure)
+	require.NoError(t, err)
+	images, err := listLocalImages(testContext(), registry, nil)
+	require.NoError(t, err)
+	require.Len(t, images, 3)
+	used := storageUsage(images)
+
+	// The wanted image was never pulled, stale before recent
+	logPath := filepath.Join(t.TempDir(), "zot-access.log")
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	appendFile(t, logPath,
+		zotAccessLine(at, "GET", "/v2/library/stale/manifests/v1", 200, "containerd")+
+			zotAccessLine(at.Add(time.Hour), "GET", "/v2/library/recent/manifests/v1", 200, "containerd"))
+	f.cm.With(func(c *config.Config) {
+		c.AppConfig.StorageQuota = config.StorageQuotaConfig{MaxBytes: used - 1}
+		c.ZotConfigRaw = []byte(`{"storage":{"rootDirectory":"` + t.TempDir() + `"},"log":{"output":"` + logPath + `"}}`)
+	})
+
+	replicator := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	f.enforceStorageQuota(testContext(), replicator, wantedEntities, nil, newDeletionRun(config.DeletionConfig{IgnoreInUse: true}), &log)
+
+	_, err = crane.Head(dstAddr+"/library/stale:v1", crane.Insecure)
+	require.True(t, isNotFound(err), "the least recently pulled image is evicted")
+	for _, kept := range []string{"library/wanted:v1", "library/recent:v1"} {
+		_, err = crane.Head(dstAddr+"/"+kept, crane.Insecure)
+		require.NoError(t, err, kept)
+	}
+
+	evictions := f.Evictions().Pending()
+	require.Len(t, evictions, 1)
+	require.Equal(t, "library/stale:v1", evictions[0].Reference)
+	require.Equal(t, staleDigest.String(), evictions[0].Digest)
+	require.Equal(t, at, evictions[0].LastPulledAt)
+	require.Positive(t, evictions[0].FreedBytes)
+
+	f.Evictions().Reported(evictions)
+	require.Empty(t, f.Evictions().Pending())
+	_, err = os.Stat(accessPath(f.stateFilePath))
+	require.NoError(t, err, "the scanned pulls are persisted")
+}
+
+func TestEnforceStorageQuota_KeepsWantedImages(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	f := newBundleTestProcess(t, srcAddr, dstAddr)
+	log := zerolog.Nop()
+
+	img := pushImage(t, dstAddr, "library", "wanted", "v1", 2)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	wantedEntities := []Entity{{Repository: "library", Name: "wanted", Tag: "v1", Digest: digest.String()}}
+	f.cm.With(func(c *config.Config) {
+		c.AppConfig.StorageQuota = config.StorageQuotaConfig{MaxBytes: 1}
+		c.ZotConfigRaw = []byte(`{"storage":{"rootDirectory":"` + t.TempDir() + `"}}`)
+	})
+
+	replicator := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	f.enforceStorageQuota(testContext(), replicator, wantedEntities, nil, newDeletionRun(config.DeletionConfig{IgnoreInUse: true}), &log)
+
+	_, err = crane.Head(dstAddr+"/library/wanted:v1", crane.Insecure)
+	require.NoError(t, err)
+	require.Empty(t, f.Evictions().Pending())
+}
+
+func TestEnforceStorageQuota_ReservesRoomForIncomingImages(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	f := newBundleTestProcess(t, srcAddr, dstAddr)
+	log := zerolog.Nop()
+
+	pushImage(t, dstAddr, "library", "stale", "v1", 2)
+	incoming := pushImage(t, srcAddr, "library", "incoming", "v1", 2)
+	digest, err := incoming.Digest()
+	require.NoError(t, err)
+
+	registry, err := name.NewRegistry(dstAddr, name.Insecure)
+	require.NoError(t, err)
+	images, err := listLocalImages(testContext(), registry, nil)
+	require.NoError(t, err)
+	f.cm.With(func(c *config.Config) {
+		c.AppConfig.StorageQuota = config.StorageQuotaConfig{MaxBytes: storageUsage(images)}
+		c.ZotConfigRaw = []byte(`{"storage":{"rootDirectory":"` + t.TempDir() + `"}}`)
+	})
+
+	// The registry fits its quota, but not with the incoming image
+	entity := Entity{Repository: "library", Name: "incoming", Tag: "v1", Digest: digest.String()}
+	replicator := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	f.enforceStorageQuota(testContext(), replicator, []Entity{entity}, []Entity{entity}, newDeletionRun(config.DeletionConfig{IgnoreInUse: true}), &log)
+
+	_, err = crane.Head(dstAddr+"/library/stale:v1", crane.Insecure)
+	require.True(t, isNotFound(err), "room is made for the incoming image")
+	require.Len(t, f.Evictions().Pending(), 1)
+}
+
+func TestEnforceStorageQuota_EvictsImagesInGracePeriodLast(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	f := newBundleTestProcess(t, srcAddr, dstAddr)
+	log := zerolog.Nop()
+
+	retired := pushImage(t, dstAddr, "library", "retired", "v1", 2)
+	pushImage(t, dstAddr, "library", "stale", "v1", 2)
+	digest, err := retired.Digest()
+	require.NoError(t, err)
+	retiredEntity := Entity{Repository: "library", Name: "retired", Tag: "v1", Digest: digest.String()}
+	f.tombstones.Bury("edge", []Entity{retiredEntity}, time.Hour, time.Now())
+
+	registry, err := name.NewRegistry(dstAddr, name.Insecure)
+	require.NoError(t, err)
+	images, err := listLocalImages(testContext(), registry, nil)
+	require.NoError(t, err)
+
+	// The retired image was never pulled, stale was pulled since
+	logPath := filepath.Join(t.TempDir(), "zot-access.log")
+	appendFile(t, logPath, zotAccessLine(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), "GET", "/v2/library/stale/manifests/v1", 200, "containerd"))
+	f.cm.With(func(c *config.Config) {
+		c.AppConfig.StorageQuota = config.StorageQuotaConfig{MaxBytes: storageUsage(images) - 1}
+		c.ZotConfigRaw = []byte(`{"storage":{"rootDirectory":"` + t.TempDir() + `"},"log":{"output":"` + logPath + `"}}`)
+	})
+
+	replicator := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	f.enforceStorageQuota(testContext(), replicator, nil, nil, newDeletionRun(config.DeletionConfig{IgnoreInUse: true}), &log)
+
+	_, err = crane.Head(dstAddr+"/library/stale:v1", crane.Insecure)
+	require.True(t, isNotFound(err), "images outside of a grace period are evicted first")
+	_, err = crane.Head(dstAddr+"/library/retired:v1", crane.Insecure)
+	require.NoError(t, err, "the image within its grace period can still be restored")
+	require.Len(t, f.tombstones.Pending(time.Now()), 1, "its tombstone is kept")
+}
+
+func TestEnforceStorageQuota_CountsAgainstDeletionLimit(t *testing.T) {
+	_, srcAddr := newTestRegistry(t)
+	_, dstAddr := newTestRegistry(t)
+	f := newBundleTestProcess(t, srcAddr, dstAddr)
+	log := zerolog.Nop()
+
+	pushImage(t, dstAddr, "library", "first", "v1", 2)
+	pushImage(t, dstAddr, "library", "second", "v1", 2)
+	f.cm.With(func(c *config.Config) {
+		c.AppConfig.StorageQuota = config.StorageQuotaConfig{MaxBytes: 1}
+		c.ZotConfigRaw = []byte(`{"storage":{"rootDirectory":"` + t.TempDir() + `"}}`)
+	})
+
+	replicator := NewBasicReplicator("", "", srcAddr, dstAddr, "", "", true)
+	deletions := newDeletionRun(config.DeletionConfig{IgnoreInUse: true, MaxDeletionsPerSync: 1})
+	f.enforceStorageQuota(testContext(), replicator, nil, nil, deletions, &log)
+
+	require.Len(t, f.Evictions().Pending(), 1, "evictions stop at the deletion limit")
+	require.False(t, deletions.take(), "the eviction used up the deletions of the sync")
+}
+
+func TestStorageBudget(t *testing.T) {
+	dir := t.TempDir()
+	budget, err := storageBudget(config.StorageQuotaConfig{MaxBytes: 1024}, dir)
+	require.NoError(t, err)
+	require.Equal(t, int64(1024), budget)
+
+	budget, err = storageBudget(config.StorageQuotaConfig{MaxBytes: 1 << 62, MaxPercent: 50}, dir)
+	require.NoError(t, err)
+	require.Less(t, budget, int64(1<<62), "the smaller of both limits applies")
+	require.Positive(t, budget)
+}
@@ -0,0 +1,76 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"slices"
+	"time"
+)
+
+// SyncReport is the outcome of the last replicate-state run that fetched the
+// state of every group. Runs in which some entities failed still produce a
+// report, with Errors counting the failures; runs that could not fetch every
+// group leave the previous report in place.
+type SyncReport struct {
+	// GroupDigests maps each group name to the digest of the group state
+	// artifact that was applied.
+	GroupDigests map[string]string
+	// StateDigest combines GroupDigests, see CombinedStateDigest.
+	StateDigest      string
+	ConfigDigest     string
+	FinishedAt       time.Time
+	DurationMs       int64
+	Errors           int
+	BytesTransferred int64
+}
+
+// CombinedStateDigest returns a single digest for a set of group state
+// digests: the sha256 of the "<group>=<digest>\n" lines sorted by group.
+// Two satellites applying the same group states report the same combined
+// digest. It returns an empty string for an empty set.
+func CombinedStateDigest(groupDigests map[string]string) string {
+	if len(groupDigests) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, group := range slices.Sorted(maps.Keys(groupDigests)) {
+		h.Write([]byte(group + "=" + groupDigests[group] + "\n"))
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// LastSync returns the report of the last complete run, if there was one
+// since the process started.
+func (f *FetchAndReplicateStateProcess) LastSync() (SyncReport, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lastSync == nil {
+		return SyncReport{}, false
+	}
+	report := *f.lastSync
+	report.GroupDigests = maps.Clone(report.GroupDigests)
+	return report, true
+}
+
+// add accounts for the result of one group. Failed entities count one error
+// each; a group that failed without any failed entity counts one.
+func (r *SyncReport) add(result StateFetcherResult) {
+	if result.StateDigest != "" {
+		r.GroupDigests[groupLabel(result.URL)] = result.StateDigest
+	}
+	r.BytesTransferred += result.Summary.BytesTransferred
+	failed := result.Summary.Count(EntityFailed)
+	if failed == 0 && result.Error != nil {
+		failed = 1
+	}
+	r.Errors += failed
+}
+
+// recordSyncReport publishes the report of a run that fetched every group.
+func (f *FetchAndReplicateStateProcess) recordSyncReport(report SyncReport) {
+	report.StateDigest = CombinedStateDigest(report.GroupDigests)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastSync = &report
+}
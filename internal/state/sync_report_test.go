@@ -0,0 +1,67 @@
+package state
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCombinedStateDigest(t *testing.T) {
+	a := CombinedStateDigest(map[string]string{"edge": "sha256:aaa", "prod": "sha256:bbb"})
+	b := CombinedStateDigest(map[string]string{"prod": "sha256:bbb", "edge": "sha256:aaa"})
+	require.Equal(t, a, b)
+	require.Regexp(t, `^sha256:[0-9a-f]{64}$`, a)
+
+	require.NotEqual(t, a, CombinedStateDigest(map[string]string{"edge": "sha256:aaa", "prod": "sha256:ccc"}))
+	require.Empty(t, CombinedStateDigest(nil))
+}
+
+func TestCollectResults_BuildsSyncReport(t *testing.T) {
+	f := newDeletionTestProcess(t, config.DeletionConfig{})
+	states := make(chan StateFetcherResult, 3)
+	configs := make(chan ConfigFetcherResult, 1)
+
+	states <- StateFetcherResult{
+		URL:         edgeGroupURL,
+		StateDigest: "sha256:edge",
+		Summary: ReplicationSummary{
+			Results:          []EntityResult{{Status: EntityReplicated}, {Status: EntityFailed}},
+			BytesTransferred: 1024,
+		},
+		Error: errors.New("1 of 2 entities failed to replicate"),
+	}
+	states <- StateFetcherResult{URL: "registry.example.com/satellite/group-state/prod/state:latest", StateDigest: "sha256:prod"}
+	states <- StateFetcherResult{URL: "registry.example.com/satellite/group-state/lab/state:latest", Error: errors.New("unauthorized")}
+	configs <- ConfigFetcherResult{Error: errors.New("manifest unknown")}
+
+	report := &SyncReport{GroupDigests: map[string]string{}}
+	log := zerolog.Nop()
+	require.Error(t, f.collectResults(testContext(), states, configs, 3, report, &log))
+
+	require.Equal(t, map[string]string{"edge": "sha256:edge", "prod": "sha256:prod"}, report.GroupDigests)
+	require.Equal(t, 3, report.Errors, "one failed entity, one failed group and the config")
+	require.Equal(t, int64(1024), report.BytesTransferred)
+}
+
+func TestLastSync(t *testing.T) {
+	f := newDeletionTestProcess(t, config.DeletionConfig{})
+	_, ok := f.LastSync()
+	require.False(t, ok)
+
+	digests := map[string]string{"edge": "sha256:edge"}
+	f.recordSyncReport(SyncReport{GroupDigests: digests, ConfigDigest: "sha256:cfg", FinishedAt: time.Now(), DurationMs: 1500})
+
+	report, ok := f.LastSync()
+	require.True(t, ok)
+	require.Equal(t, CombinedStateDigest(digests), report.StateDigest)
+	require.Equal(t, "sha256:cfg", report.ConfigDigest)
+	require.Equal(t, int64(1500), report.DurationMs)
+
+	report.GroupDigests["edge"] = "changed"
+	again, _ := f.LastSync()
+	require.Equal(t, "sha256:edge", again.GroupDigests["edge"], "callers get a copy")
+}
@@ -0,0 +1,76 @@
+package state
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+// interfaceUp reports whether the named network interface exists and is up.
+// It is a variable so that tests can stub the host's interfaces.
+var interfaceUp = func(name string) bool {
+	iface, err := net.InterfaceByName(name)
+	return err == nil && iface.Flags&net.FlagUp != 0
+}
+
+// InWindow reports whether state replication may run at now according to the
+// configured sync windows. Without windows it may always run.
+func (f *FetchAndReplicateStateProcess) InWindow(now time.Time) (bool, string) {
+	return syncWindowsOpen(f.cm.GetSyncWindows(), now)
+}
+
+// syncWindowsOpen reports whether any of the windows is open at now and, if
+// none is, describes why.
+func syncWindowsOpen(windows []config.SyncWindow, now time.Time) (bool, string) {
+	if len(windows) == 0 {
+		return true, ""
+	}
+	reasons := make([]string, 0, len(windows))
+	for _, w := range windows {
+		open, reason := syncWindowOpen(w, now)
+		if open {
+			return true, ""
+		}
+		reasons = append(reasons, reason)
+	}
+	return false, "no sync window is open: " + strings.Join(reasons, "; ")
+}
+
+func syncWindowOpen(w config.SyncWindow, now time.Time) (bool, string) {
+	if w.Start != "" && w.End != "" {
+		start, err := config.ParseClock(w.Start)
+		if err != nil {
+			return false, err.Error()
+		}
+		end, err := config.ParseClock(w.End)
+		if err != nil {
+			return false, err.Error()
+		}
+		if !clockWithin(now, start, end) {
+			return false, fmt.Sprintf("outside %s-%s", w.Start, w.End)
+		}
+	}
+
+	if len(w.Interfaces) == 0 {
+		return true, ""
+	}
+	for _, name := range w.Interfaces {
+		if interfaceUp(name) {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("interface %s down", strings.Join(w.Interfaces, ", "))
+}
+
+// clockWithin reports whether the wall-clock time of now lies in [start, end),
+// wrapping past midnight when end is not after start.
+func clockWithin(now time.Time, start, end time.Duration) bool {
+	clock := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+	if start < end {
+		return clock >= start && clock < end
+	}
+	return clock >= start || clock < end
+}
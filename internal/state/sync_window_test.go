@@ -0,0 +1,87 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2025, 1, 1, hour, minute, 0, 0, time.Local)
+}
+
+func stubInterfaces(t *testing.T, up ...string) {
+	t.Helper()
+	orig := interfaceUp
+	interfaceUp = func(name string) bool { return contains(up, name) }
+	t.Cleanup(func() { interfaceUp = orig })
+}
+
+func TestSyncWindowsOpen_NoWindows(t *testing.T) {
+	open, _ := syncWindowsOpen(nil, at(12, 0))
+	require.True(t, open)
+}
+
+func TestSyncWindowsOpen_TimeRange(t *testing.T) {
+	windows := []config.SyncWindow{{Start: "01:00", End: "05:00"}}
+
+	for _, tc := range []struct {
+		now  time.Time
+		open bool
+	}{
+		{at(0, 59), false},
+		{at(1, 0), true},
+		{at(4, 59), true},
+		{at(5, 0), false},
+		{at(13, 0), false},
+	} {
+		open, reason := syncWindowsOpen(windows, tc.now)
+		require.Equal(t, tc.open, open, tc.now.Format("15:04"))
+		if !open {
+			require.Contains(t, reason, "outside 01:00-05:00")
+		}
+	}
+}
+
+func TestSyncWindowsOpen_SpansMidnight(t *testing.T) {
+	windows := []config.SyncWindow{{Start: "22:00", End: "02:00"}}
+
+	open, _ := syncWindowsOpen(windows, at(23, 30))
+	require.True(t, open)
+	open, _ = syncWindowsOpen(windows, at(1, 30))
+	require.True(t, open)
+	open, _ = syncWindowsOpen(windows, at(12, 0))
+	require.False(t, open)
+}
+
+func TestSyncWindowsOpen_Interfaces(t *testing.T) {
+	windows := []config.SyncWindow{{Interfaces: []string{"eth0", "wlan0"}}}
+
+	stubInterfaces(t, "wwan0")
+	open, reason := syncWindowsOpen(windows, at(12, 0))
+	require.False(t, open)
+	require.Contains(t, reason, "interface eth0, wlan0 down")
+
+	stubInterfaces(t, "wlan0")
+	open, _ = syncWindowsOpen(windows, at(12, 0))
+	require.True(t, open)
+}
+
+func TestSyncWindowsOpen_AnyWindow(t *testing.T) {
+	stubInterfaces(t)
+	windows := []config.SyncWindow{
+		{Start: "01:00", End: "05:00"},
+		{Interfaces: []string{"eth0"}},
+	}
+
+	open, _ := syncWindowsOpen(windows, at(12, 0))
+	require.False(t, open)
+	open, _ = syncWindowsOpen(windows, at(2, 0))
+	require.True(t, open)
+
+	stubInterfaces(t, "eth0")
+	open, _ = syncWindowsOpen(windows, at(12, 0))
+	require.True(t, open)
+}
@@ -0,0 +1,196 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TombstoneFileName is the tombstone list persisted next to state.json.
+const TombstoneFileName = "tombstones.json"
+
+// Reasons a due tombstone is kept instead of deleted.
+const (
+	HoldInUse         = "in_use"
+	HoldInUseUnknown  = "in_use_check_failed"
+	HoldDeletionLimit = "deletion_limit"
+	HoldDeleteFailed  = "delete_failed"
+)
+
+// Tombstone is an entity that left its group state. It stays in the local
+// registry until PurgeAfter and is revived if the group wants it back before
+// then. Hold records why a due tombstone was not deleted on the last sync.
+type Tombstone struct {
+	Group      string    `json:"group"`
+	Entity     Entity    `json:"entity"`
+	RemovedAt  time.Time `json:"removed_at"`
+	PurgeAfter time.Time `json:"purge_after"`
+	Hold       string    `json:"hold,omitempty"`
+}
+
+// TombstoneStore tracks tombstoned entities per group and persists them to
+// disk. A store with an empty path is kept in memory only.
+type TombstoneStore struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*Tombstone
+}
+
+// NewTombstoneStore loads the tombstone list from path. A missing file yields
+// an empty store.
+func NewTombstoneStore(path string) (*TombstoneStore, error) {
+	s := &TombstoneStore{
+		path:    path,
+		entries: make(map[string]*Tombstone),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return s, fmt.Errorf("read tombstone file: %w", err)
+	}
+
+	var entries []Tombstone
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return s, fmt.Errorf("unmarshal tombstone file: %w", err)
+	}
+	for i := range entries {
+		s.entries[deadLetterKey(entries[i].Group, entries[i].Entity)] = &entries[i]
+	}
+	return s, nil
+}
+
+// tombstonePath returns the tombstone file that belongs to stateFilePath.
+func tombstonePath(stateFilePath string) string {
+	if stateFilePath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(stateFilePath), TombstoneFileName)
+}
+
+// Bury tombstones entities removed from group. Entities that are already
+// tombstoned keep their original deadline.
+func (s *TombstoneStore) Bury(group string, entities []Entity, grace time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range entities {
+		key := deadLetterKey(group, e)
+		if _, ok := s.entries[key]; ok {
+			continue
+		}
+		s.entries[key] = &Tombstone{Group: group, Entity: e, RemovedAt: now, PurgeAfter: now.Add(grace)}
+	}
+}
+
+// Revive drops the tombstones of entities group wants again.
+func (s *TombstoneStore) Revive(group string, desired []Entity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range desired {
+		delete(s.entries, deadLetterKey(group, e))
+	}
+}
+
+// Due returns the tombstones of group whose grace period is over.
+func (s *TombstoneStore) Due(group string, now time.Time) []Tombstone {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Tombstone
+	for _, t := range s.entriesUnlocked() {
+		if t.Group == group && !now.Before(t.PurgeAfter) {
+			due = append(due, t)
+		}
+	}
+	return due
+}
+
+// Pending returns the tombstones of all groups that are still within their
+// grace period. Their images must stay pullable until then.
+func (s *TombstoneStore) Pending(now time.Time) []Tombstone {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []Tombstone
+	for _, t := range s.entriesUnlocked() {
+		if now.Before(t.PurgeAfter) {
+			pending = append(pending, t)
+		}
+	}
+	return pending
+}
+
+// SetHold records why a due tombstone was kept. An empty reason clears it.
+func (s *TombstoneStore) SetHold(group string, e Entity, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.entries[deadLetterKey(group, e)]; ok {
+		t.Hold = reason
+	}
+}
+
+// Remove drops the tombstone of a deleted entity.
+func (s *TombstoneStore) Remove(group string, e Entity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, deadLetterKey(group, e))
+}
+
+// RetainGroups drops tombstones of groups the satellite no longer belongs to.
+// Their images were never deleted on leaving a group, and are not now.
+func (s *TombstoneStore) RetainGroups(groups []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, t := range s.entries {
+		if !contains(groups, t.Group) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Entries returns a copy of the tombstone list ordered by group and entity.
+func (s *TombstoneStore) Entries() []Tombstone {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entriesUnlocked()
+}
+
+func (s *TombstoneStore) entriesUnlocked() []Tombstone {
+	entries := make([]Tombstone, 0, len(s.entries))
+	for _, t := range s.entries {
+		entries = append(entries, *t)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return deadLetterKey(entries[i].Group, entries[i].Entity) < deadLetterKey(entries[j].Group, entries[j].Entity)
+	})
+	return entries
+}
+
+// Save persists the tombstone list. It is a no-op for in-memory stores.
+func (s *TombstoneStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.entriesUnlocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal tombstone list: %w", err)
+	}
+	return writeFileAtomic(s.path, data, "tombstones-*.json.tmp")
+}
@@ -0,0 +1,55 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTombstoneStore_BuryKeepsOriginalDeadline(t *testing.T) {
+	s, err := NewTombstoneStore("")
+	require.NoError(t, err)
+	now := time.Now()
+
+	s.Bury("group", []Entity{appV1}, time.Hour, now)
+	s.Bury("group", []Entity{appV1}, time.Hour, now.Add(30*time.Minute))
+
+	require.Empty(t, s.Due("group", now.Add(59*time.Minute)))
+	require.Len(t, s.Due("group", now.Add(time.Hour)), 1)
+	require.Empty(t, s.Due("other", now.Add(time.Hour)))
+}
+
+func TestTombstoneStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), TombstoneFileName)
+	s, err := NewTombstoneStore(path)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	s.Bury("group", []Entity{appV1}, time.Hour, now)
+	s.SetHold("group", appV1, HoldInUse)
+	require.NoError(t, s.Save())
+
+	loaded, err := NewTombstoneStore(path)
+	require.NoError(t, err)
+	require.Equal(t, []Tombstone{{
+		Group:      "group",
+		Entity:     appV1,
+		RemovedAt:  now,
+		PurgeAfter: now.Add(time.Hour),
+		Hold:       HoldInUse,
+	}}, loaded.Entries())
+}
+
+func TestTombstoneStore_RetainGroups(t *testing.T) {
+	s, err := NewTombstoneStore("")
+	require.NoError(t, err)
+	s.Bury("a", []Entity{appV1}, 0, time.Now())
+	s.Bury("b", []Entity{dbV1}, 0, time.Now())
+
+	s.RetainGroups([]string{"b"})
+	entries := s.Entries()
+	require.Len(t, entries, 1)
+	require.Equal(t, "b", entries[0].Group)
+}
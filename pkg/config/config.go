@@ -2,6 +2,8 @@ package config
 
 import (
 	"encoding/json"
+	"path"
+	"strings"
 
 	"github.com/rs/zerolog"
 )
@@ -35,6 +37,201 @@ type MetricsConfig struct {
 	CollectStorage bool `json:"collect_storage,omitempty"`
 }
 
+// ReplicationConfig bounds how much work the state replicator does in parallel
+// and how it retries failed entities. Zero values fall back to the defaults in
+// constants.go; durations use Go duration syntax (e.g. "30s", "15m").
+//
+// Platforms trims replicated image indexes to the listed platforms
+// (e.g. "linux/arm64", "linux/arm/v7"). GroupPlatforms overrides it for
+// individual groups, keyed by group name. An empty list keeps every platform.
+// A trimmed index has a digest of its own, so the signatures and other
+// referrers of the source index are not replicated with it.
+//
+// MaxBytesPerSecond caps the combined download rate of blobs from the source
+// registry; GroupMaxBytesPerSecond additionally caps individual groups, keyed
+// by group name. Zero means unlimited.
+//
+// Layers of at least ResumableBlobMinSize bytes are staged in a spool
+// directory next to state.json and resumed with HTTP Range requests when a
+// transfer is interrupted.
+type ReplicationConfig struct {
+	MaxConcurrentImages         int                 `json:"max_concurrent_images,omitempty"`
+	MaxConcurrentImagesPerGroup int                 `json:"max_concurrent_images_per_group,omitempty"`
+	MaxConcurrentBlobs          int                 `json:"max_concurrent_blobs,omitempty"`
+	MaxAttempts                 int                 `json:"max_attempts,omitempty"`
+	RetryInitialBackoff         string              `json:"retry_initial_backoff,omitempty"`
+	RetryMaxBackoff             string              `json:"retry_max_backoff,omitempty"`
+	DeadLetterRetryInterval     string              `json:"dead_letter_retry_interval,omitempty"`
+	Platforms                   []string            `json:"platforms,omitempty"`
+	GroupPlatforms              map[string][]string `json:"group_platforms,omitempty"`
+	MaxBytesPerSecond           int64               `json:"max_bytes_per_second,omitempty"`
+	GroupMaxBytesPerSecond      map[string]int64    `json:"group_max_bytes_per_second,omitempty"`
+	ResumableBlobMinSize        int64               `json:"resumable_blob_min_size,omitempty"`
+}
+
+// SyncWindow is a period during which state replication may run. Start and
+// End are local wall-clock times in "15:04" form; a window whose End is not
+// after its Start spans midnight, and leaving both empty opens the window
+// all day. Interfaces, if set, additionally require one of the named network
+// interfaces to be up.
+type SyncWindow struct {
+	Start      string   `json:"start,omitempty"`
+	End        string   `json:"end,omitempty"`
+	Interfaces []string `json:"interfaces,omitempty"`
+}
+
+// DeletionConfig controls how images that leave a group state are removed.
+// They are first tombstoned and only deleted once GracePeriod has passed, so
+// a mistaken group edit can be undone. Images used by running containers are
+// kept unless IgnoreInUse is set, and at most MaxDeletionsPerSync images are
+// deleted per sync, evictions to stay within the storage quota included.
+type DeletionConfig struct {
+	GracePeriod         string `json:"grace_period,omitempty"`
+	MaxDeletionsPerSync int    `json:"max_deletions_per_sync,omitempty"`
+	IgnoreInUse         bool   `json:"ignore_in_use,omitempty"`
+}
+
+// LocalAPIConfig enables the satellite's local HTTP API. Every request must
+// carry Token as a bearer token; the API stays off without one.
+type LocalAPIConfig struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Address string `json:"address,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+// StorageQuotaConfig caps the storage of the embedded registry at MaxBytes,
+// or at MaxPercent of the volume its storage directory is on; if both are set
+// the smaller one applies. Before every sync, images that none of the group
+// states list are evicted, least recently pulled first, until the registry
+// fits its quota again. Images within their deletion grace period go last.
+// Pulls are read from the registry's access log.
+type StorageQuotaConfig struct {
+	MaxBytes   int64   `json:"max_bytes,omitempty"`
+	MaxPercent float64 `json:"max_percent,omitempty"`
+}
+
+// Enabled reports whether a quota is set.
+func (q StorageQuotaConfig) Enabled() bool {
+	return q.MaxBytes > 0 || q.MaxPercent > 0
+}
+
+// PullThroughConfig lets the satellite cache images no group state lists.
+// A pull that misses the embedded registry for a repository matching one of
+// the Allow patterns is served by the registry's sync extension, which
+// fetches the image from Harbor with the satellite's credentials while the
+// pull waits. The satellite keeps such images as ad-hoc images and deletes
+// those not pulled for Retention. Patterns are "project/repository" globs as
+// understood by path.Match, and a trailing "/**" matches every repository
+// below a path. Images fetched on demand are not checked against the
+// signature policy.
+type PullThroughConfig struct {
+	Enabled   bool     `json:"enabled,omitempty"`
+	Allow     []string `json:"allow,omitempty"`
+	Retention string   `json:"retention,omitempty"`
+}
+
+// Allows reports whether repository matches one of the allow patterns.
+func (p PullThroughConfig) Allows(repository string) bool {
+	for _, pattern := range p.Allow {
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if strings.HasPrefix(repository, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, repository); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigRolloutConfig controls how configs published by Ground Control are
+// rolled out. A new config is on probation for ProbationPeriod, during which
+// every state sync checks that Ground Control is reachable, that the local
+// registry is healthy and that the satellite state can be fetched. After
+// FailureThreshold consecutive failed checks the satellite reverts to the
+// previous config and reports the rollback to Ground Control; a config that
+// passes its checks once the period is over is promoted. The settings of the
+// config being replaced apply, so a config cannot turn off its own probation.
+type ConfigRolloutConfig struct {
+	Disabled         bool   `json:"disabled,omitempty"`
+	ProbationPeriod  string `json:"probation_period,omitempty"`
+	FailureThreshold int    `json:"failure_threshold,omitempty"`
+}
+
+// CertificateIdentity is a signer identity accepted from certificate-based
+// signatures. Subject is matched against the certificate's email and URI
+// SANs (cosign keyless) or its subject DN (notation), either exactly or via
+// SubjectRegExp. Issuer, if set, must equal the OIDC issuer recorded in a
+// Fulcio certificate.
+type CertificateIdentity struct {
+	Subject       string `json:"subject,omitempty"`
+	SubjectRegExp string `json:"subject_regexp,omitempty"`
+	Issuer        string `json:"issuer,omitempty"`
+}
+
+// SignatureVerificationConfig is the trust policy images must satisfy before
+// they are replicated. Keys and certificates are given either inline as PEM
+// or as paths to PEM files.
+//
+// In "enforce" mode images whose signatures fail verification are refused,
+// as are unsigned images unless AllowUnsigned is set. "audit" mode records
+// the verdicts but replicates every image.
+type SignatureVerificationConfig struct {
+	Enabled          bool                  `json:"enabled,omitempty"`
+	Mode             string                `json:"mode,omitempty"`
+	AllowUnsigned    bool                  `json:"allow_unsigned,omitempty"`
+	PublicKeys       []string              `json:"public_keys,omitempty"`
+	RootCertificates []string              `json:"root_certificates,omitempty"`
+	Identities       []CertificateIdentity `json:"certificate_identities,omitempty"`
+	RekorPublicKey   string                `json:"rekor_public_key,omitempty"`
+}
+
+// RegistryTLSConfig serves the embedded registry over HTTPS. Mode selects
+// its certificate: "self-signed" issues a CA and a server certificate into
+// the registry-tls directory next to config.json, "spiffe" serves the
+// satellite's X.509 SVID with the bundle of its trust domain as CA, and
+// "files" serves CertFile and KeyFile, issued by CAFile. Hosts adds names
+// and addresses to the self-signed certificate, which always covers
+// localhost, the host name and the address the registry listens on.
+// Issued certificates are renewed before they expire and the registry is
+// restarted to serve them.
+//
+// Auth additionally requires clients to authenticate: "htpasswd" accepts the
+// local_registry credentials, "bearer" accepts tokens issued by Realm for
+// Service and signed by the key of BearerCertFile. With htpasswd, pulls stay
+// anonymous so that nodes need no credentials, unless AuthenticatedPulls is
+// set; bearer tokens govern pulls as well. Container runtimes are given no
+// credentials, so they are not configured to pull through a registry whose
+// pulls require them.
+//
+// The CA is installed for every container runtime the satellite configures
+// to pull through the registry.
+type RegistryTLSConfig struct {
+	Mode               string   `json:"mode,omitempty"`
+	CertFile           string   `json:"cert_file,omitempty"`
+	KeyFile            string   `json:"key_file,omitempty"`
+	CAFile             string   `json:"ca_file,omitempty"`
+	Hosts              []string `json:"hosts,omitempty"`
+	Auth               string   `json:"auth,omitempty"`
+	Realm              string   `json:"realm,omitempty"`
+	Service            string   `json:"service,omitempty"`
+	BearerCertFile     string   `json:"bearer_cert_file,omitempty"`
+	AuthenticatedPulls bool     `json:"authenticated_pulls,omitempty"`
+}
+
+// Enabled reports whether the registry is served over TLS.
+func (r RegistryTLSConfig) Enabled() bool {
+	return r.Mode != ""
+}
+
+// PullsRequireAuth reports whether clients must authenticate to pull from the
+// registry.
+func (r RegistryTLSConfig) PullsRequireAuth() bool {
+	return r.Auth == RegistryAuthBearer || (r.Auth == RegistryAuthHtpasswd && r.AuthenticatedPulls)
+}
+
 type RegistryFallbackConfig struct {
 	Enabled    bool     `json:"enabled,omitempty"`
 	Registries []string `json:"registries,omitempty"`
@@ -42,25 +239,40 @@ type RegistryFallbackConfig struct {
 }
 
 type AppConfig struct {
-	GroundControlURL          URL                    `json:"ground_control_url,omitempty"`
-	LogLevel                  string                 `json:"log_level,omitempty"`
-	UseUnsecure               bool                   `json:"use_unsecure,omitempty"`
-	StateReplicationInterval  string                 `json:"state_replication_interval,omitempty"`
-	RegisterSatelliteInterval string                 `json:"register_satellite_interval,omitempty"`
-	HeartbeatInterval         string                 `json:"heartbeat_interval,omitempty"`
-	Metrics                   MetricsConfig          `json:"metrics,omitempty"`
-	BringOwnRegistry          bool                   `json:"bring_own_registry,omitempty"`
-	LocalRegistryCredentials  RegistryCredentials    `json:"local_registry,omitempty"`
-	TLS                       TLSConfig              `json:"tls,omitempty"`
-	SPIFFE                    SPIFFEConfig           `json:"spiffe,omitempty"`
-	EncryptConfig             bool                   `json:"encrypt_config,omitempty"`
-	RegistryFallback          RegistryFallbackConfig `json:"registry_fallback,omitempty"`
-	HarborRegistryURL        string                 `json:"harbor_registry_url,omitempty"`
+	GroundControlURL          URL                         `json:"ground_control_url,omitempty"`
+	LogLevel                  string                      `json:"log_level,omitempty"`
+	UseUnsecure               bool                        `json:"use_unsecure,omitempty"`
+	StateReplicationInterval  string                      `json:"state_replication_interval,omitempty"`
+	RegisterSatelliteInterval string                      `json:"register_satellite_interval,omitempty"`
+	HeartbeatInterval         string                      `json:"heartbeat_interval,omitempty"`
+	SchedulerJitter           map[string]string           `json:"scheduler_jitter,omitempty"`
+	Metrics                   MetricsConfig               `json:"metrics,omitempty"`
+	BringOwnRegistry          bool                        `json:"bring_own_registry,omitempty"`
+	LocalRegistryCredentials  RegistryCredentials         `json:"local_registry,omitempty"`
+	TLS                       TLSConfig                   `json:"tls,omitempty"`
+	SPIFFE                    SPIFFEConfig                `json:"spiffe,omitempty"`
+	EncryptConfig             bool                        `json:"encrypt_config,omitempty"`
+	RegistryFallback          RegistryFallbackConfig      `json:"registry_fallback,omitempty"`
+	HarborRegistryURL         string                      `json:"harbor_registry_url,omitempty"`
+	Replication               ReplicationConfig           `json:"replication,omitempty"`
+	SignatureVerification     SignatureVerificationConfig `json:"signature_verification,omitempty"`
+	SyncWindows               []SyncWindow                `json:"sync_windows,omitempty"`
+	Deletion                  DeletionConfig              `json:"deletion,omitempty"`
+	LocalAPI                  LocalAPIConfig              `json:"local_api,omitempty"`
+	GroundControlEvents       bool                        `json:"ground_control_events,omitempty"`
+	ConfigRollout             ConfigRolloutConfig         `json:"config_rollout,omitempty"`
+	StorageQuota              StorageQuotaConfig          `json:"storage_quota,omitempty"`
+	PullThrough               PullThroughConfig           `json:"pull_through,omitempty"`
+	RegistryTLS               RegistryTLSConfig           `json:"registry_tls,omitempty"`
 }
 
 type StateConfig struct {
 	RegistryCredentials RegistryCredentials `json:"auth,omitempty"`
 	StateURL            string              `json:"state,omitempty"`
+	// StatePublicKey is the PEM encoded ed25519 key Ground Control signs
+	// state artifacts with. Once set, unsigned or wrongly signed state
+	// artifacts are refused.
+	StatePublicKey string `json:"state_public_key,omitempty"`
 }
 
 type Config struct {
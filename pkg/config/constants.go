@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // Job names that the user is expected to provide in the config.json file
 const ReplicateStateJobName string = "replicate_state"
 const ZTRConfigJobName string = "register_satellite"
@@ -24,6 +26,57 @@ const DefaultHeartbeatCronExpr string = "@every 00h00m30s"
 
 const BringOwnRegistry bool = false
 
+// Default concurrency limits for state replication. Images are counted across
+// all groups, blobs are counted per in-flight layer download from the source.
+const DefaultMaxConcurrentImages int = 4
+const DefaultMaxConcurrentImagesPerGroup int = 2
+const DefaultMaxConcurrentBlobs int = 8
+
+// Default retry behaviour for transient registry errors during replication.
+// Entities that still fail are dead-lettered and retried at the slower
+// dead-letter interval instead of on every sync.
+const DefaultMaxAttempts int = 4
+const DefaultRetryInitialBackoff = 2 * time.Second
+const DefaultRetryMaxBackoff = time.Minute
+const DefaultDeadLetterRetryInterval = 30 * time.Minute
+
+// Layers at least this large are downloaded through the resumable blob spool.
+// Smaller blobs are cheap to fetch again and are streamed directly.
+const DefaultResumableBlobMinSize int64 = 16 << 20
+
+// Default safety net for deleting images that left a group state.
+const DefaultDeletionGracePeriod = 24 * time.Hour
+const DefaultMaxDeletionsPerSync int = 25
+
+// Default probation of a new config published by Ground Control, and the
+// consecutive failed health checks that roll it back.
+const DefaultConfigProbationPeriod = 5 * time.Minute
+const DefaultConfigRollbackFailures int = 3
+
+// Default time an ad-hoc image cached through the pull-through mode is kept
+// without being pulled.
+const DefaultPullThroughRetention = 7 * 24 * time.Hour
+
+// Default listen address of the local HTTP API.
+const DefaultLocalAPIAddress string = ":9090"
+
+// Bearer tokens shorter than this are accepted with a warning.
+const minLocalAPITokenLength = 16
+
+// Signature verification modes. Enforce refuses images that fail the trust
+// policy, audit only records the verdict.
+const SignatureModeEnforce string = "enforce"
+const SignatureModeAudit string = "audit"
+
+// Sources of the certificate the embedded registry is served with.
+const RegistryTLSSelfSigned string = "self-signed"
+const RegistryTLSSPIFFE string = "spiffe"
+const RegistryTLSFiles string = "files"
+
+// Authentication the embedded registry can require.
+const RegistryAuthHtpasswd string = "htpasswd"
+const RegistryAuthBearer string = "bearer"
+
 const DefaultZotConfigJSON = `{
   "distSpecVersion": "1.1.0",
   "storage": {
@@ -40,4 +93,3 @@ const DefaultZotConfigJSON = `{
 
 const DefaultRemoteRegistryURL = "http://127.0.0.1:8585"
 const DefaultGroundControlURL = "http://127.0.0.1:8080"
-
@@ -1,6 +1,12 @@
 package config
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/registry"
+)
 
 // Threadsafe getter functions to fetch config data.
 
@@ -65,6 +71,14 @@ func (cm *ConfigManager) GetStateURL() string {
 	return cm.config.StateConfig.StateURL
 }
 
+// GetStatePublicKey returns the PEM encoded key Ground Control signs state
+// artifacts with, empty if it does not sign them.
+func (cm *ConfigManager) GetStatePublicKey() string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.StateConfig.StatePublicKey
+}
+
 func (cm *ConfigManager) GetRemoteRegistryUsername() string {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
@@ -107,6 +121,14 @@ func (cm *ConfigManager) GetHeartbeatInterval() string {
 	return cm.config.AppConfig.HeartbeatInterval
 }
 
+// GetSchedulerJitter returns the random splay configured for a scheduled job.
+func (cm *ConfigManager) GetSchedulerJitter(jobName string) time.Duration {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	d, _ := time.ParseDuration(cm.config.AppConfig.SchedulerJitter[jobName])
+	return d
+}
+
 func (cm *ConfigManager) GetMetricsConfig() MetricsConfig {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
@@ -184,3 +206,113 @@ func (cm *ConfigManager) GetHarborRegistryURL() string {
 	defer cm.mu.RUnlock()
 	return cm.config.AppConfig.HarborRegistryURL
 }
+
+func (cm *ConfigManager) GetReplicationConfig() ReplicationConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.Replication
+}
+
+func (cm *ConfigManager) GetDeletionConfig() DeletionConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.Deletion
+}
+
+func (cm *ConfigManager) GetLocalAPIConfig() LocalAPIConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.LocalAPI
+}
+
+func (cm *ConfigManager) GetStorageQuotaConfig() StorageQuotaConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.StorageQuota
+}
+
+func (cm *ConfigManager) GetPullThroughConfig() PullThroughConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.PullThrough
+}
+
+func (cm *ConfigManager) GetConfigRolloutConfig() ConfigRolloutConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.ConfigRollout
+}
+
+// IsGroundControlEventsEnabled reports whether the satellite subscribes to
+// Ground Control's state change notifications.
+func (cm *ConfigManager) IsGroundControlEventsEnabled() bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.GroundControlEvents
+}
+
+func (cm *ConfigManager) GetSignatureVerificationConfig() SignatureVerificationConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.SignatureVerification
+}
+
+// GetReplicationPlatforms returns the platforms image indexes of the given
+// group are trimmed to. A group override takes precedence over the
+// satellite-wide list; nil means every platform is replicated.
+func (cm *ConfigManager) GetReplicationPlatforms(group string) []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	rc := cm.config.AppConfig.Replication
+	if platforms, ok := rc.GroupPlatforms[group]; ok {
+		return platforms
+	}
+	return rc.Platforms
+}
+
+// GetReplicationBandwidth returns the download rate limit in bytes per second
+// for the given group, or 0 if the group is not limited on its own.
+func (cm *ConfigManager) GetReplicationBandwidth(group string) int64 {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.Replication.GroupMaxBytesPerSecond[group]
+}
+
+// GetSyncWindows returns the windows state replication is restricted to. An
+// empty list means replication may run at any time.
+func (cm *ConfigManager) GetSyncWindows() []SyncWindow {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.SyncWindows
+}
+
+func (cm *ConfigManager) GetRegistryTLSConfig() RegistryTLSConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config.AppConfig.RegistryTLS
+}
+
+// IsLocalRegistryTLS reports whether the embedded registry is served over
+// TLS. It is always false with bring_own_registry.
+func (cm *ConfigManager) IsLocalRegistryTLS() bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return !cm.config.AppConfig.BringOwnRegistry && cm.config.AppConfig.RegistryTLS.Enabled()
+}
+
+// GetLocalRegistryCAFile returns the CA file the embedded registry's
+// certificate is issued by. It is empty if the registry is not served over
+// TLS, or is served with provided certificates issued by a public CA.
+func (cm *ConfigManager) GetLocalRegistryCAFile() string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	rt := cm.config.AppConfig.RegistryTLS
+	switch {
+	case cm.config.AppConfig.BringOwnRegistry || !rt.Enabled():
+		return ""
+	case rt.Mode == RegistryTLSFiles:
+		return rt.CAFile
+	default:
+		return filepath.Join(filepath.Dir(cm.configPath), RegistryTLSDirName, registry.TLSCAFile)
+	}
+}
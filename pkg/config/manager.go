@@ -31,6 +31,11 @@ type ConfigChange struct {
 
 type ConfigChangeCallback func(change ConfigChange) error
 
+// ZotConfigOverlay adds the settings of the embedded registry the satellite
+// manages itself, such as its storage directory and its certificate, to the
+// Zot config of cfg.
+type ZotConfigOverlay func(zotConfigJSON string, cfg *Config) (string, error)
+
 type ConfigManager struct {
 	config                  *Config
 	Token                   string
@@ -41,6 +46,7 @@ type ConfigManager struct {
 	mu                      sync.RWMutex
 	encryptor               *secure.ConfigEncryptor
 	encryptEnabled          bool
+	zotOverlay              ZotConfigOverlay
 }
 
 func NewConfigManager(configPath, prevConfigPath, token, defaultGroundControlURL string, jsonLog bool, config *Config) (*ConfigManager, error) {
@@ -60,6 +66,15 @@ func NewConfigManager(configPath, prevConfigPath, token, defaultGroundControlURL
 	}, nil
 }
 
+// SetZotConfigOverlay sets the overlay applied to the Zot config of every
+// reloaded config, so that a config from Ground Control or a rollback keeps
+// the settings the satellite set up for the embedded registry.
+func (cm *ConfigManager) SetZotConfigOverlay(overlay ZotConfigOverlay) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.zotOverlay = overlay
+}
+
 func (cm *ConfigManager) With(mutators ...func(*Config)) *ConfigManager {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
@@ -112,6 +127,15 @@ func (cm *ConfigManager) WritePrevConfigToDisk(config *Config) error {
 	return cm.writeConfigUnlocked(config, cm.prevConfigPath)
 }
 
+// ReadPrevConfig reads the config last written with WritePrevConfigToDisk.
+func (cm *ConfigManager) ReadPrevConfig() (*Config, error) {
+	cm.mu.RLock()
+	path := cm.prevConfigPath
+	cm.mu.RUnlock()
+
+	return readAndReturnConfig(path)
+}
+
 func (cm *ConfigManager) detectChanges(oldConfig *Config, newConfig *Config) []ConfigChange {
 	var changes []ConfigChange
 
@@ -157,6 +181,14 @@ func (cm *ConfigManager) ReloadConfig() ([]ConfigChange, []string, error) {
 		return nil, warnings, fmt.Errorf("failed to validate reloaded config: %w", err)
 	}
 
+	if cm.zotOverlay != nil {
+		zotConfigJSON, err := cm.zotOverlay(string(validatedConfig.ZotConfigRaw), validatedConfig)
+		if err != nil {
+			return nil, warnings, fmt.Errorf("failed to apply local zot settings: %w", err)
+		}
+		validatedConfig.ZotConfigRaw = json.RawMessage(zotConfigJSON)
+	}
+
 	changes := cm.detectChanges(oldConfig, validatedConfig)
 
 	cm.config = validatedConfig
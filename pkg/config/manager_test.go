@@ -85,3 +85,60 @@ func TestConfigManager_WriteConfig(t *testing.T) {
 		require.Equal(t, "warn", saved.AppConfig.LogLevel)
 	})
 }
+
+func TestConfigManager_ReloadConfigKeepsZotOverlay(t *testing.T) {
+	overlay := func(zotConfigJSON string, _ *Config) (string, error) {
+		zotConfigJSON, err := SetZotStoragePath(zotConfigJSON, "/var/lib/satellite/zot")
+		if err != nil {
+			return "", err
+		}
+		return SetZotTLS(zotConfigJSON, "/tls/tls.crt", "/tls/tls.key")
+	}
+	local, err := overlay(DefaultZotConfigJSON, nil)
+	require.NoError(t, err)
+
+	cfg, _, err := ValidateAndEnforceDefaults(&Config{
+		AppConfig:    AppConfig{GroundControlURL: "http://groundcontrol", LogLevel: "info"},
+		ZotConfigRaw: json.RawMessage(local),
+	}, "http://groundcontrol")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "config.json")
+	cm, err := NewConfigManager(path, "", "", "http://groundcontrol", false, cfg)
+	require.NoError(t, err)
+	cm.SetZotConfigOverlay(overlay)
+
+	// A remote config carries Ground Control's Zot config, without the
+	// local storage directory and TLS
+	remote := &Config{
+		AppConfig:    AppConfig{GroundControlURL: "http://groundcontrol", LogLevel: "info"},
+		ZotConfigRaw: json.RawMessage(DefaultZotConfigJSON),
+	}
+	require.NoError(t, cm.WriteConfigToDisk(remote))
+
+	changes, _, err := cm.ReloadConfig()
+	require.NoError(t, err)
+	require.Empty(t, changes, "the same registry settings are no change")
+
+	// A remote config that changes the registry keeps them too
+	remote.ZotConfigRaw = json.RawMessage(`{"distSpecVersion": "1.1.0", "storage": {"rootDirectory": "./zot", "gc": false}, "http": {"address": "0.0.0.0", "port": "8585"}}`)
+	require.NoError(t, cm.WriteConfigToDisk(remote))
+
+	changes, _, err = cm.ReloadConfig()
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, ZotConfigChanged, changes[0].Type)
+
+	var zot struct {
+		Storage struct {
+			RootDirectory string `json:"rootDirectory"`
+			GC            *bool  `json:"gc"`
+		} `json:"storage"`
+		HTTP struct {
+			TLS map[string]string `json:"tls"`
+		} `json:"http"`
+	}
+	require.NoError(t, json.Unmarshal(cm.GetRawZotConfig(), &zot))
+	require.Equal(t, "/var/lib/satellite/zot", zot.Storage.RootDirectory)
+	require.NotNil(t, zot.Storage.GC)
+	require.Equal(t, map[string]string{"cert": "/tls/tls.crt", "key": "/tls/tls.key"}, zot.HTTP.TLS)
+}
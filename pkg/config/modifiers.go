@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"path/filepath"
+	"strings"
 )
 
 // Threadsafe setter functions to modify config data.
@@ -28,6 +30,12 @@ func SetStateConfig(sc StateConfig) func(*Config) {
 	}
 }
 
+func SetStatePublicKey(key string) func(*Config) {
+	return func(cfg *Config) {
+		cfg.StateConfig.StatePublicKey = key
+	}
+}
+
 func SetGroundControlURL(url string) func(*Config) {
 	return func(cfg *Config) {
 		cfg.AppConfig.GroundControlURL = URL(url)
@@ -119,11 +127,16 @@ func SetHarborRegistryURL(url string) func(*Config) {
 }
 
 // ReplaceURLHost replaces the scheme and host:port in raw with those from override.
+// States read from the local filesystem (file:// and oci:// inputs and absolute
+// paths) are returned unchanged.
 func ReplaceURLHost(raw, override string) (string, error) {
 	overrideParsed, err := url.Parse(override)
 	if err != nil {
 		return "", fmt.Errorf("parse override URL: %w", err)
 	}
+	if strings.HasPrefix(raw, "file://") || strings.HasPrefix(raw, "oci://") || filepath.IsAbs(raw) {
+		return raw, nil
+	}
 	parsed, err := url.Parse(raw)
 	if err != nil {
 		return "", fmt.Errorf("parse URL %q: %w", raw, err)
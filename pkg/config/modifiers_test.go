@@ -135,3 +135,17 @@ func TestGetRegistryFallbackConfigEmpty(t *testing.T) {
 	require.Nil(t, got.Registries)
 	require.Nil(t, got.Runtimes)
 }
+
+func TestReplaceURLHost(t *testing.T) {
+	tests := map[string]string{
+		"http://harbor.internal:8080/satellite/state:latest": "https://10.0.0.1:443/satellite/state:latest",
+		"file:///etc/satellite/state.json":                   "file:///etc/satellite/state.json",
+		"oci:///var/lib/layout#state:latest":                 "oci:///var/lib/layout#state:latest",
+		"/etc/satellite/state.json":                          "/etc/satellite/state.json",
+	}
+	for raw, want := range tests {
+		got, err := ReplaceURLHost(raw, "https://10.0.0.1:443")
+		require.NoError(t, err)
+		require.Equal(t, want, got, raw)
+	}
+}
@@ -8,6 +8,10 @@ import (
 	"strings"
 )
 
+// RegistryTLSDirName is the directory in the config directory that holds the
+// certificates and the htpasswd file the embedded registry is served with.
+const RegistryTLSDirName = "registry-tls"
+
 // PathConfig holds all resolved file paths for satellite storage.
 type PathConfig struct {
 	ConfigDir      string
@@ -15,7 +19,10 @@ type PathConfig struct {
 	PrevConfigFile string
 	ZotTempConfig  string
 	ZotStorageDir  string
+	ZotAccessLog   string
+	ZotSyncCreds   string
 	StateFile      string
+	RegistryTLSDir string
 }
 
 // expandPath expands ~ and ~/ to the user's home directory in paths.
@@ -89,16 +96,25 @@ func ResolvePathConfig(configDir string) (*PathConfig, error) {
 		PrevConfigFile: filepath.Join(expanded, "prev_config.json"),
 		ZotTempConfig:  filepath.Join(expanded, "zot-hot.json"),
 		ZotStorageDir:  filepath.Join(expanded, "zot"),
+		ZotAccessLog:   filepath.Join(expanded, "zot-access.log"),
+		ZotSyncCreds:   filepath.Join(expanded, "zot-sync-credentials.json"),
 		StateFile:      filepath.Join(expanded, "state.json"),
+		RegistryTLSDir: filepath.Join(expanded, RegistryTLSDirName),
 	}, nil
 }
 
 // BuildZotConfigWithStoragePath updates the Zot configuration JSON to use
 // the specified storage directory path.
 func BuildZotConfigWithStoragePath(storageDir string) (string, error) {
+	return SetZotStoragePath(DefaultZotConfigJSON, storageDir)
+}
+
+// SetZotStoragePath updates the Zot configuration JSON to store images in
+// storageDir.
+func SetZotStoragePath(zotConfigJSON, storageDir string) (string, error) {
 	var zotConfig map[string]any
-	if err := json.Unmarshal([]byte(DefaultZotConfigJSON), &zotConfig); err != nil {
-		return "", fmt.Errorf("unmarshal default Zot config: %w", err)
+	if err := json.Unmarshal([]byte(zotConfigJSON), &zotConfig); err != nil {
+		return "", fmt.Errorf("unmarshal Zot config: %w", err)
 	}
 
 	storage, ok := zotConfig["storage"].(map[string]any)
@@ -115,3 +131,140 @@ func BuildZotConfigWithStoragePath(storageDir string) (string, error) {
 
 	return string(updatedJSON), nil
 }
+
+// SetZotLogOutput updates the Zot configuration JSON to write its log, which
+// includes the access log, to the given file.
+func SetZotLogOutput(zotConfigJSON, logFile string) (string, error) {
+	var zotConfig map[string]any
+	if err := json.Unmarshal([]byte(zotConfigJSON), &zotConfig); err != nil {
+		return "", fmt.Errorf("unmarshal Zot config: %w", err)
+	}
+
+	logSection, ok := zotConfig["log"].(map[string]any)
+	if !ok {
+		logSection = map[string]any{"level": "info"}
+		zotConfig["log"] = logSection
+	}
+	logSection["output"] = logFile
+
+	updatedJSON, err := json.MarshalIndent(zotConfig, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal updated Zot config: %w", err)
+	}
+
+	return string(updatedJSON), nil
+}
+
+// SetZotSync updates the Zot configuration JSON so that a pull missing a
+// repository that matches one of the allow patterns is fetched on demand from
+// upstream, with the credentials in credentialsFile. Without allow patterns
+// the sync extension is removed: the satellite owns it, and replicates every
+// other image itself.
+func SetZotSync(zotConfigJSON, upstream string, allow []string, tlsVerify bool, credentialsFile string) (string, error) {
+	var zotConfig map[string]any
+	if err := json.Unmarshal([]byte(zotConfigJSON), &zotConfig); err != nil {
+		return "", fmt.Errorf("unmarshal Zot config: %w", err)
+	}
+
+	extensions, _ := zotConfig["extensions"].(map[string]any)
+	if len(allow) == 0 {
+		delete(extensions, "sync")
+		if extensions != nil && len(extensions) == 0 {
+			delete(zotConfig, "extensions")
+		}
+	} else {
+		if extensions == nil {
+			extensions = map[string]any{}
+			zotConfig["extensions"] = extensions
+		}
+		content := make([]map[string]any, 0, len(allow))
+		for _, pattern := range allow {
+			content = append(content, map[string]any{"prefix": pattern})
+		}
+		extensions["sync"] = map[string]any{
+			"enable":          true,
+			"credentialsFile": credentialsFile,
+			"registries": []map[string]any{{
+				"urls":      []string{upstream},
+				"onDemand":  true,
+				"tlsVerify": tlsVerify,
+				"content":   content,
+			}},
+		}
+	}
+
+	updatedJSON, err := json.MarshalIndent(zotConfig, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal updated Zot config: %w", err)
+	}
+
+	return string(updatedJSON), nil
+}
+
+// SetZotTLS updates the Zot configuration JSON to serve HTTPS with the given
+// certificate and key. Clients are not asked for certificates.
+func SetZotTLS(zotConfigJSON, certFile, keyFile string) (string, error) {
+	var zotConfig map[string]any
+	if err := json.Unmarshal([]byte(zotConfigJSON), &zotConfig); err != nil {
+		return "", fmt.Errorf("unmarshal Zot config: %w", err)
+	}
+
+	httpSection, ok := zotConfig["http"].(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("invalid Zot config: http section not found")
+	}
+	httpSection["tls"] = map[string]any{"cert": certFile, "key": keyFile}
+
+	updatedJSON, err := json.MarshalIndent(zotConfig, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal updated Zot config: %w", err)
+	}
+
+	return string(updatedJSON), nil
+}
+
+// SetZotAuth updates the Zot configuration JSON to require the
+// authentication of registryTLS. With htpasswd, users are read from
+// htpasswdFile and anonymous clients may still pull unless authenticated
+// pulls are required.
+func SetZotAuth(zotConfigJSON string, registryTLS RegistryTLSConfig, htpasswdFile string) (string, error) {
+	var zotConfig map[string]any
+	if err := json.Unmarshal([]byte(zotConfigJSON), &zotConfig); err != nil {
+		return "", fmt.Errorf("unmarshal Zot config: %w", err)
+	}
+
+	httpSection, ok := zotConfig["http"].(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("invalid Zot config: http section not found")
+	}
+
+	switch registryTLS.Auth {
+	case RegistryAuthHtpasswd:
+		httpSection["auth"] = map[string]any{"htpasswd": map[string]any{"path": htpasswdFile}}
+		if !registryTLS.AuthenticatedPulls {
+			httpSection["accessControl"] = map[string]any{
+				"repositories": map[string]any{
+					"**": map[string]any{
+						"anonymousPolicy": []string{"read"},
+						"defaultPolicy":   []string{"read", "create", "update", "delete"},
+					},
+				},
+			}
+		}
+	case RegistryAuthBearer:
+		httpSection["auth"] = map[string]any{"bearer": map[string]any{
+			"realm":   registryTLS.Realm,
+			"service": registryTLS.Service,
+			"cert":    registryTLS.BearerCertFile,
+		}}
+	default:
+		return zotConfigJSON, nil
+	}
+
+	updatedJSON, err := json.MarshalIndent(zotConfig, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal updated Zot config: %w", err)
+	}
+
+	return string(updatedJSON), nil
+}
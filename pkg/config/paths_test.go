@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/container-registry/harbor-satellite/internal/registry"
 	"github.com/stretchr/testify/require"
 )
 
@@ -156,6 +157,8 @@ func TestResolvePathConfig(t *testing.T) {
 			require.Equal(t, filepath.Join(pathConfig.ConfigDir, "prev_config.json"), pathConfig.PrevConfigFile)
 			require.Equal(t, filepath.Join(pathConfig.ConfigDir, "zot-hot.json"), pathConfig.ZotTempConfig)
 			require.Equal(t, filepath.Join(pathConfig.ConfigDir, "zot"), pathConfig.ZotStorageDir)
+			require.Equal(t, filepath.Join(pathConfig.ConfigDir, "zot-access.log"), pathConfig.ZotAccessLog)
+			require.Equal(t, filepath.Join(pathConfig.ConfigDir, "zot-sync-credentials.json"), pathConfig.ZotSyncCreds)
 		})
 	}
 }
@@ -174,3 +177,91 @@ func TestBuildZotConfigWithStoragePath(t *testing.T) {
 	require.True(t, ok, "storage section should exist")
 	require.Equal(t, storagePath, storage["rootDirectory"])
 }
+
+func TestSetZotLogOutput(t *testing.T) {
+	zotConfig, err := BuildZotConfigWithStoragePath("/custom/zot/storage")
+	require.NoError(t, err)
+
+	result, err := SetZotLogOutput(zotConfig, "/custom/zot-access.log")
+	require.NoError(t, err)
+
+	var parsed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	logSection, ok := parsed["log"].(map[string]any)
+	require.True(t, ok, "log section should exist")
+	require.Equal(t, "/custom/zot-access.log", logSection["output"])
+	require.Equal(t, "info", logSection["level"], "the log level is kept")
+}
+
+func TestSetZotSync(t *testing.T) {
+	zotConfig, err := BuildZotConfigWithStoragePath("/custom/zot/storage")
+	require.NoError(t, err)
+
+	withSync, err := SetZotSync(zotConfig, "https://harbor.example.com", []string{"library/**", "tools/*"}, true, "/creds.json")
+	require.NoError(t, err)
+
+	var parsed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(withSync), &parsed))
+	sync := parsed["extensions"].(map[string]any)["sync"].(map[string]any)
+	require.Equal(t, true, sync["enable"])
+	require.Equal(t, "/creds.json", sync["credentialsFile"])
+	registries := sync["registries"].([]any)
+	require.Len(t, registries, 1)
+	upstream := registries[0].(map[string]any)
+	require.Equal(t, []any{"https://harbor.example.com"}, upstream["urls"])
+	require.Equal(t, true, upstream["onDemand"], "images are only fetched when a pull misses")
+	require.Nil(t, upstream["pollInterval"])
+	require.Equal(t, []any{map[string]any{"prefix": "library/**"}, map[string]any{"prefix": "tools/*"}}, upstream["content"])
+
+	withoutSync, err := SetZotSync(withSync, "", nil, false, "")
+	require.NoError(t, err)
+	parsed = nil
+	require.NoError(t, json.Unmarshal([]byte(withoutSync), &parsed))
+	require.NotContains(t, parsed, "extensions", "disabling pull-through removes the extension")
+	require.Equal(t, "/custom/zot/storage", parsed["storage"].(map[string]any)["rootDirectory"])
+}
+
+func TestSetZotTLSAndAuth(t *testing.T) {
+	zotConfig, err := BuildZotConfigWithStoragePath("/custom/zot/storage")
+	require.NoError(t, err)
+
+	withTLS, err := SetZotTLS(zotConfig, "/tls/tls.crt", "/tls/tls.key")
+	require.NoError(t, err)
+
+	var parsed registry.ZotConfig
+	require.NoError(t, json.Unmarshal([]byte(withTLS), &parsed))
+	require.Equal(t, &registry.ZotTLSConfig{Cert: "/tls/tls.crt", Key: "/tls/tls.key"}, parsed.HTTP.TLS)
+	require.Equal(t, "https://0.0.0.0:8585", parsed.GetRegistryURL())
+
+	tests := []struct {
+		name          string
+		registryTLS   RegistryTLSConfig
+		auth          string
+		accessControl bool
+	}{
+		{name: "no auth", registryTLS: RegistryTLSConfig{Mode: RegistryTLSSelfSigned}},
+		{name: "htpasswd with anonymous pulls", registryTLS: RegistryTLSConfig{Mode: RegistryTLSSelfSigned, Auth: RegistryAuthHtpasswd}, auth: "htpasswd", accessControl: true},
+		{name: "htpasswd with authenticated pulls", registryTLS: RegistryTLSConfig{Mode: RegistryTLSSelfSigned, Auth: RegistryAuthHtpasswd, AuthenticatedPulls: true}, auth: "htpasswd"},
+		{name: "bearer", registryTLS: RegistryTLSConfig{Mode: RegistryTLSSelfSigned, Auth: RegistryAuthBearer, Realm: "https://auth.example.com/token", Service: "satellite", BearerCertFile: "/auth.crt"}, auth: "bearer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SetZotAuth(withTLS, tt.registryTLS, "/tls/htpasswd")
+			require.NoError(t, err)
+
+			var parsed map[string]any
+			require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+			httpSection := parsed["http"].(map[string]any)
+			require.NotNil(t, httpSection["tls"], "TLS is kept")
+
+			auth, _ := httpSection["auth"].(map[string]any)
+			if tt.auth == "" {
+				require.Nil(t, auth)
+			} else {
+				require.Contains(t, auth, tt.auth)
+			}
+			_, hasAccessControl := httpSection["accessControl"]
+			require.Equal(t, tt.accessControl, hasAccessControl)
+		})
+	}
+}
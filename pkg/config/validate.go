@@ -3,12 +3,17 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
+	"path"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/container-registry/harbor-satellite/internal/registry"
-	"github.com/robfig/cron/v3"
+	"github.com/container-registry/harbor-satellite/internal/scheduler"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/rs/zerolog"
 )
 
@@ -71,12 +76,40 @@ func ValidateAndEnforceDefaults(config *Config, defaultGroundControlURL string)
 
 	warnings = append(warnings, validateRegistryFallbackConfig(config)...)
 
+	warnings = append(warnings, validateAndEnforceReplicationConfig(config)...)
+
+	warnings = append(warnings, validateAndEnforceDeletionConfig(config)...)
+
+	warnings = append(warnings, validateAndEnforceLocalAPIConfig(config)...)
+
+	warnings = append(warnings, validateAndEnforceConfigRolloutConfig(config)...)
+
+	warnings = append(warnings, validateStorageQuotaConfig(config)...)
+	warnings = append(warnings, validatePullThroughConfig(config)...)
+
+	registryTLSWarnings, registryTLSErr := validateRegistryTLSConfig(config)
+	warnings = append(warnings, registryTLSWarnings...)
+	if registryTLSErr != nil {
+		return nil, warnings, registryTLSErr
+	}
+
+	sigWarnings, sigErr := validateSignatureVerificationConfig(&config.AppConfig.SignatureVerification)
+	warnings = append(warnings, sigWarnings...)
+	if sigErr != nil {
+		return nil, warnings, sigErr
+	}
+
+	if err := validateSyncWindows(config.AppConfig.SyncWindows); err != nil {
+		return nil, warnings, err
+	}
+
 	return config, warnings, nil
 }
 
-// isValidCronExpression checks the validity of a cron expression.
+// isValidCronExpression checks the validity of an @every interval or a 5/6
+// field cron expression, optionally prefixed with CRON_TZ=<zone>.
 func isValidCronExpression(cronExpression string) bool {
-	if _, err := cron.ParseStandard(cronExpression); err != nil {
+	if _, err := scheduler.ParseSchedule(cronExpression); err != nil {
 		return false
 	}
 	return true
@@ -140,9 +173,29 @@ func validateAndEnforceCronSchedules(config *Config) []string {
 		warnings = append(warnings, fmt.Sprintf("invalid schedule provided for heartbeat_interval, using default schedule %s", DefaultHeartbeatCronExpr))
 	}
 
+	for job, jitter := range config.AppConfig.SchedulerJitter {
+		if !isSchedulerJob(job) {
+			warnings = append(warnings, fmt.Sprintf("scheduler_jitter for unknown job '%s' is ignored", job))
+			delete(config.AppConfig.SchedulerJitter, job)
+			continue
+		}
+		if d, err := time.ParseDuration(jitter); err != nil || d < 0 {
+			warnings = append(warnings, fmt.Sprintf("invalid scheduler_jitter '%s' for %s, running without jitter", jitter, job))
+			delete(config.AppConfig.SchedulerJitter, job)
+		}
+	}
+
 	return warnings
 }
 
+func isSchedulerJob(job string) bool {
+	switch job {
+	case ReplicateStateJobName, ZTRConfigJobName, StatusReportJobName, SPIFFEZTRConfigJobName:
+		return true
+	}
+	return false
+}
+
 // validateAndEnforceZotConfig validates and defaults zot registry configuration.
 func validateAndEnforceZotConfig(config *Config, bringOwnRegistry bool) ([]string, error) {
 	var warnings []string
@@ -220,6 +273,389 @@ func validateRegistryFallbackConfig(config *Config) []string {
 	return warnings
 }
 
+// validateAndEnforceReplicationConfig defaults unset concurrency limits and
+// retry settings, and clamps the per-group image limit to the global one.
+func validateAndEnforceReplicationConfig(config *Config) []string {
+	var warnings []string
+	rc := &config.AppConfig.Replication
+
+	limits := []struct {
+		name  string
+		value *int
+		def   int
+	}{
+		{"max_concurrent_images", &rc.MaxConcurrentImages, DefaultMaxConcurrentImages},
+		{"max_concurrent_images_per_group", &rc.MaxConcurrentImagesPerGroup, DefaultMaxConcurrentImagesPerGroup},
+		{"max_concurrent_blobs", &rc.MaxConcurrentBlobs, DefaultMaxConcurrentBlobs},
+		{"max_attempts", &rc.MaxAttempts, DefaultMaxAttempts},
+	}
+	for _, l := range limits {
+		if *l.value < 0 {
+			warnings = append(warnings, fmt.Sprintf("invalid replication.%s %d, using default %d", l.name, *l.value, l.def))
+		}
+		if *l.value <= 0 {
+			*l.value = l.def
+		}
+	}
+
+	durations := []struct {
+		name  string
+		value *string
+		def   time.Duration
+	}{
+		{"retry_initial_backoff", &rc.RetryInitialBackoff, DefaultRetryInitialBackoff},
+		{"retry_max_backoff", &rc.RetryMaxBackoff, DefaultRetryMaxBackoff},
+		{"dead_letter_retry_interval", &rc.DeadLetterRetryInterval, DefaultDeadLetterRetryInterval},
+	}
+	for _, d := range durations {
+		if *d.value == "" {
+			*d.value = d.def.String()
+			continue
+		}
+		if parsed, err := time.ParseDuration(*d.value); err != nil || parsed <= 0 {
+			warnings = append(warnings, fmt.Sprintf("invalid replication.%s %q, using default %s", d.name, *d.value, d.def))
+			*d.value = d.def.String()
+		}
+	}
+
+	if rc.MaxConcurrentImagesPerGroup > rc.MaxConcurrentImages {
+		warnings = append(warnings, fmt.Sprintf(
+			"replication.max_concurrent_images_per_group (%d) exceeds max_concurrent_images (%d), clamping",
+			rc.MaxConcurrentImagesPerGroup, rc.MaxConcurrentImages,
+		))
+		rc.MaxConcurrentImagesPerGroup = rc.MaxConcurrentImages
+	}
+
+	var platformWarnings []string
+	rc.Platforms, platformWarnings = validPlatforms("replication.platforms", rc.Platforms)
+	warnings = append(warnings, platformWarnings...)
+	for group, platforms := range rc.GroupPlatforms {
+		rc.GroupPlatforms[group], platformWarnings = validPlatforms(fmt.Sprintf("replication.group_platforms[%q]", group), platforms)
+		warnings = append(warnings, platformWarnings...)
+	}
+
+	if rc.ResumableBlobMinSize < 0 {
+		warnings = append(warnings, fmt.Sprintf("invalid replication.resumable_blob_min_size %d, using default %d", rc.ResumableBlobMinSize, DefaultResumableBlobMinSize))
+	}
+	if rc.ResumableBlobMinSize <= 0 {
+		rc.ResumableBlobMinSize = DefaultResumableBlobMinSize
+	}
+
+	if rc.MaxBytesPerSecond < 0 {
+		warnings = append(warnings, fmt.Sprintf("invalid replication.max_bytes_per_second %d, replicating without a limit", rc.MaxBytesPerSecond))
+		rc.MaxBytesPerSecond = 0
+	}
+	for group, limit := range rc.GroupMaxBytesPerSecond {
+		if limit < 0 {
+			warnings = append(warnings, fmt.Sprintf("invalid replication.group_max_bytes_per_second[%q] %d, replicating the group without a limit", group, limit))
+			rc.GroupMaxBytesPerSecond[group] = 0
+		}
+	}
+
+	return warnings
+}
+
+// validateAndEnforceDeletionConfig applies the default grace period and
+// deletion limit. A grace period of zero is allowed and deletes images on the
+// next sync.
+func validateAndEnforceDeletionConfig(config *Config) []string {
+	var warnings []string
+	dc := &config.AppConfig.Deletion
+
+	if dc.GracePeriod == "" {
+		dc.GracePeriod = DefaultDeletionGracePeriod.String()
+	} else if parsed, err := time.ParseDuration(dc.GracePeriod); err != nil || parsed < 0 {
+		warnings = append(warnings, fmt.Sprintf("invalid deletion.grace_period %q, using default %s", dc.GracePeriod, DefaultDeletionGracePeriod))
+		dc.GracePeriod = DefaultDeletionGracePeriod.String()
+	}
+
+	if dc.MaxDeletionsPerSync < 0 {
+		warnings = append(warnings, fmt.Sprintf("invalid deletion.max_deletions_per_sync %d, using default %d", dc.MaxDeletionsPerSync, DefaultMaxDeletionsPerSync))
+	}
+	if dc.MaxDeletionsPerSync <= 0 {
+		dc.MaxDeletionsPerSync = DefaultMaxDeletionsPerSync
+	}
+
+	if dc.IgnoreInUse {
+		warnings = append(warnings, "deletion.ignore_in_use is set, images used by running containers may be deleted")
+	}
+	return warnings
+}
+
+// validateAndEnforceLocalAPIConfig defaults the listen address and keeps the
+// API off when no token is configured.
+func validateAndEnforceLocalAPIConfig(config *Config) []string {
+	var warnings []string
+	api := &config.AppConfig.LocalAPI
+
+	if api.Address == "" {
+		api.Address = DefaultLocalAPIAddress
+	} else if _, _, err := net.SplitHostPort(api.Address); err != nil {
+		warnings = append(warnings, fmt.Sprintf("invalid local_api.address %q, using default %s", api.Address, DefaultLocalAPIAddress))
+		api.Address = DefaultLocalAPIAddress
+	}
+
+	if !api.Enabled {
+		return warnings
+	}
+	if api.Token == "" {
+		warnings = append(warnings, "local_api is enabled without a token, disabling it")
+		api.Enabled = false
+	} else if len(api.Token) < minLocalAPITokenLength {
+		warnings = append(warnings, fmt.Sprintf("local_api.token is shorter than %d characters", minLocalAPITokenLength))
+	}
+	return warnings
+}
+
+// validateAndEnforceConfigRolloutConfig applies the default probation period
+// and failure threshold.
+func validateAndEnforceConfigRolloutConfig(config *Config) []string {
+	var warnings []string
+	rc := &config.AppConfig.ConfigRollout
+
+	if rc.ProbationPeriod == "" {
+		rc.ProbationPeriod = DefaultConfigProbationPeriod.String()
+	} else if parsed, err := time.ParseDuration(rc.ProbationPeriod); err != nil || parsed <= 0 {
+		warnings = append(warnings, fmt.Sprintf("invalid config_rollout.probation_period %q, using default %s", rc.ProbationPeriod, DefaultConfigProbationPeriod))
+		rc.ProbationPeriod = DefaultConfigProbationPeriod.String()
+	}
+
+	if rc.FailureThreshold < 0 {
+		warnings = append(warnings, fmt.Sprintf("invalid config_rollout.failure_threshold %d, using default %d", rc.FailureThreshold, DefaultConfigRollbackFailures))
+	}
+	if rc.FailureThreshold <= 0 {
+		rc.FailureThreshold = DefaultConfigRollbackFailures
+	}
+
+	if rc.Disabled {
+		warnings = append(warnings, "config_rollout.disabled is set, new configs are applied without probation")
+	}
+	return warnings
+}
+
+// validateStorageQuotaConfig drops invalid quota values. The quota only
+// applies to the embedded registry.
+func validateStorageQuotaConfig(config *Config) []string {
+	var warnings []string
+	q := &config.AppConfig.StorageQuota
+
+	if q.MaxBytes < 0 {
+		warnings = append(warnings, fmt.Sprintf("invalid storage_quota.max_bytes %d, ignoring it", q.MaxBytes))
+		q.MaxBytes = 0
+	}
+	if q.MaxPercent < 0 || q.MaxPercent > 100 {
+		warnings = append(warnings, fmt.Sprintf("invalid storage_quota.max_percent %g, ignoring it", q.MaxPercent))
+		q.MaxPercent = 0
+	}
+	if q.Enabled() && config.AppConfig.BringOwnRegistry {
+		warnings = append(warnings, "storage_quota is ignored with bring_own_registry")
+	}
+	return warnings
+}
+
+// validatePullThroughConfig drops invalid allow patterns and applies the
+// default retention. Like the storage quota, the pull-through mode only
+// applies to the embedded registry.
+func validatePullThroughConfig(config *Config) []string {
+	var warnings []string
+	pt := &config.AppConfig.PullThrough
+	if !pt.Enabled {
+		return warnings
+	}
+
+	var allow []string
+	for _, pattern := range pt.Allow {
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil || pattern == "" {
+			warnings = append(warnings, fmt.Sprintf("pull_through.allow contains invalid pattern %q, ignoring it", pattern))
+			continue
+		}
+		allow = append(allow, pattern)
+	}
+	pt.Allow = allow
+	if len(pt.Allow) == 0 {
+		warnings = append(warnings, "pull_through is enabled without allow patterns, no image is cached on demand")
+	}
+
+	if pt.Retention == "" {
+		pt.Retention = DefaultPullThroughRetention.String()
+	} else if parsed, err := time.ParseDuration(pt.Retention); err != nil || parsed <= 0 {
+		warnings = append(warnings, fmt.Sprintf("invalid pull_through.retention %q, using default %s", pt.Retention, DefaultPullThroughRetention))
+		pt.Retention = DefaultPullThroughRetention.String()
+	}
+
+	if config.AppConfig.BringOwnRegistry {
+		warnings = append(warnings, "pull_through is ignored with bring_own_registry")
+	}
+	return warnings
+}
+
+// validateRegistryTLSConfig checks the certificate source and the
+// authentication of the embedded registry. Misconfigurations are fatal so
+// that a registry meant to require authentication is never served without
+// it.
+func validateRegistryTLSConfig(config *Config) ([]string, error) {
+	var warnings []string
+	rt := &config.AppConfig.RegistryTLS
+
+	switch rt.Mode {
+	case "":
+		if rt.Auth != "" {
+			return warnings, fmt.Errorf("registry_tls.auth requires registry_tls.mode")
+		}
+		return warnings, nil
+	case RegistryTLSSelfSigned, RegistryTLSSPIFFE:
+	case RegistryTLSFiles:
+		if rt.CertFile == "" || rt.KeyFile == "" {
+			return warnings, fmt.Errorf("registry_tls.cert_file and registry_tls.key_file are required in %s mode", RegistryTLSFiles)
+		}
+		for _, file := range []string{rt.CertFile, rt.KeyFile, rt.CAFile} {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				return warnings, fmt.Errorf("registry_tls: %w", err)
+			}
+		}
+	default:
+		return warnings, fmt.Errorf("invalid registry_tls.mode %q, valid values: %s, %s, %s",
+			rt.Mode, RegistryTLSSelfSigned, RegistryTLSSPIFFE, RegistryTLSFiles)
+	}
+
+	switch rt.Auth {
+	case "":
+	case RegistryAuthHtpasswd:
+		creds := config.AppConfig.LocalRegistryCredentials
+		if creds.Username == "" || creds.Password == "" {
+			return warnings, fmt.Errorf("registry_tls.auth %s requires the local_registry username and password", RegistryAuthHtpasswd)
+		}
+	case RegistryAuthBearer:
+		if rt.Realm == "" || rt.Service == "" || rt.BearerCertFile == "" {
+			return warnings, fmt.Errorf("registry_tls.auth %s requires realm, service and bearer_cert_file", RegistryAuthBearer)
+		}
+		if _, err := os.Stat(rt.BearerCertFile); err != nil {
+			return warnings, fmt.Errorf("registry_tls: %w", err)
+		}
+	default:
+		return warnings, fmt.Errorf("invalid registry_tls.auth %q, valid values: %s, %s",
+			rt.Auth, RegistryAuthHtpasswd, RegistryAuthBearer)
+	}
+
+	if rt.AuthenticatedPulls && rt.Auth != RegistryAuthHtpasswd {
+		warnings = append(warnings, "registry_tls.authenticated_pulls only applies to htpasswd authentication")
+	}
+	if config.AppConfig.BringOwnRegistry {
+		warnings = append(warnings, "registry_tls is ignored with bring_own_registry")
+	} else if rt.PullsRequireAuth() && config.AppConfig.RegistryFallback.Enabled {
+		warnings = append(warnings, "registry_fallback is not applied: pulls from the registry require credentials, which container runtimes are not given")
+	}
+	return warnings, nil
+}
+
+// validPlatforms drops platform entries that are not of the form
+// os/arch[/variant].
+func validPlatforms(field string, platforms []string) ([]string, []string) {
+	var warnings []string
+	var valid []string
+	for _, p := range platforms {
+		parsed, err := v1.ParsePlatform(p)
+		if err != nil || parsed.OS == "" || parsed.Architecture == "" {
+			warnings = append(warnings, fmt.Sprintf("%s contains invalid platform %q, ignoring it", field, p))
+			continue
+		}
+		valid = append(valid, p)
+	}
+	return valid, warnings
+}
+
+// validateSignatureVerificationConfig checks that the trust material of an
+// enabled signature policy can be loaded. Unreadable keys or certificates are
+// fatal so that a broken policy never silently admits images.
+func validateSignatureVerificationConfig(sv *SignatureVerificationConfig) ([]string, error) {
+	var warnings []string
+	if !sv.Enabled {
+		return warnings, nil
+	}
+
+	switch sv.Mode {
+	case "":
+		sv.Mode = SignatureModeEnforce
+	case SignatureModeEnforce, SignatureModeAudit:
+	default:
+		warnings = append(warnings, fmt.Sprintf(
+			"invalid signature_verification.mode %q, valid values: enforce, audit. Defaulting to enforce", sv.Mode,
+		))
+		sv.Mode = SignatureModeEnforce
+	}
+
+	for _, key := range sv.PublicKeys {
+		if _, err := ParsePublicKeyPEM(key); err != nil {
+			return warnings, fmt.Errorf("signature_verification.public_keys: %w", err)
+		}
+	}
+	if sv.RekorPublicKey != "" {
+		if _, err := ParsePublicKeyPEM(sv.RekorPublicKey); err != nil {
+			return warnings, fmt.Errorf("signature_verification.rekor_public_key: %w", err)
+		}
+	}
+	for _, cert := range sv.RootCertificates {
+		if _, err := ParseCertificatesPEM(cert); err != nil {
+			return warnings, fmt.Errorf("signature_verification.root_certificates: %w", err)
+		}
+	}
+	for _, id := range sv.Identities {
+		if id.Subject == "" && id.SubjectRegExp == "" {
+			return warnings, fmt.Errorf("signature_verification.certificate_identities: subject or subject_regexp is required")
+		}
+		if id.SubjectRegExp != "" {
+			if _, err := regexp.Compile(id.SubjectRegExp); err != nil {
+				return warnings, fmt.Errorf("signature_verification.certificate_identities: invalid subject_regexp %q: %w", id.SubjectRegExp, err)
+			}
+		}
+	}
+
+	if len(sv.PublicKeys) == 0 && len(sv.RootCertificates) == 0 {
+		warnings = append(warnings, "signature_verification is enabled without public_keys or root_certificates, no signature can be verified")
+	}
+	if len(sv.RootCertificates) > 0 && len(sv.Identities) == 0 {
+		warnings = append(warnings, "signature_verification has root_certificates but no certificate_identities, certificate-based signatures will be rejected")
+	}
+
+	return warnings, nil
+}
+
+// validateSyncWindows rejects windows with malformed times. Dropping a bad
+// window instead could lift the restriction entirely, so it is fatal.
+func validateSyncWindows(windows []SyncWindow) error {
+	for i, w := range windows {
+		if (w.Start == "") != (w.End == "") {
+			return fmt.Errorf("sync_windows[%d] must set both start and end, or neither", i)
+		}
+		for _, t := range []string{w.Start, w.End} {
+			if t == "" {
+				continue
+			}
+			if _, err := ParseClock(t); err != nil {
+				return fmt.Errorf("sync_windows[%d]: %w", i, err)
+			}
+		}
+		for _, iface := range w.Interfaces {
+			if strings.TrimSpace(iface) == "" {
+				return fmt.Errorf("sync_windows[%d] contains an empty interface name", i)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseClock parses a "15:04" wall-clock time into its offset from midnight.
+func ParseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
 // validateTLSConfig validates TLS configuration.
 func validateTLSConfig(tls *TLSConfig) ([]string, error) {
 	var warnings []string
@@ -1,6 +1,11 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"os"
 	"path/filepath"
 	"testing"
@@ -280,6 +285,26 @@ func TestValidateAndEnforceDefaults(t *testing.T) {
 	}
 }
 
+func TestValidateAndEnforceCronSchedules(t *testing.T) {
+	cfg := &Config{AppConfig: AppConfig{
+		StateReplicationInterval:  "CRON_TZ=Europe/Berlin 0 2 * * 1-5",
+		RegisterSatelliteInterval: "*/10 * * * * *",
+		HeartbeatInterval:         "@every 500ms",
+		SchedulerJitter: map[string]string{
+			ReplicateStateJobName: "30s",
+			StatusReportJobName:   "soon",
+			"unknown_job":         "5s",
+		},
+	}}
+
+	warnings := validateAndEnforceCronSchedules(cfg)
+	require.Len(t, warnings, 2)
+	require.Equal(t, "CRON_TZ=Europe/Berlin 0 2 * * 1-5", cfg.AppConfig.StateReplicationInterval)
+	require.Equal(t, "*/10 * * * * *", cfg.AppConfig.RegisterSatelliteInterval)
+	require.Equal(t, "@every 500ms", cfg.AppConfig.HeartbeatInterval)
+	require.Equal(t, map[string]string{ReplicateStateJobName: "30s"}, cfg.AppConfig.SchedulerJitter)
+}
+
 func TestValidateTLSConfig(t *testing.T) {
 	t.Run("valid TLS with cert and key files", func(t *testing.T) {
 		tmpDir := t.TempDir()
@@ -536,3 +561,288 @@ func TestUseUnsecureEnvVar(t *testing.T) {
 		require.False(t, result.AppConfig.UseUnsecure)
 	})
 }
+
+func TestValidateAndEnforceReplicationConfig(t *testing.T) {
+	t.Run("unset limits default silently", func(t *testing.T) {
+		cfg := &Config{}
+		warnings := validateAndEnforceReplicationConfig(cfg)
+		require.Empty(t, warnings)
+		require.Equal(t, ReplicationConfig{
+			MaxConcurrentImages:         DefaultMaxConcurrentImages,
+			MaxConcurrentImagesPerGroup: DefaultMaxConcurrentImagesPerGroup,
+			MaxConcurrentBlobs:          DefaultMaxConcurrentBlobs,
+			MaxAttempts:                 DefaultMaxAttempts,
+			RetryInitialBackoff:         DefaultRetryInitialBackoff.String(),
+			RetryMaxBackoff:             DefaultRetryMaxBackoff.String(),
+			DeadLetterRetryInterval:     DefaultDeadLetterRetryInterval.String(),
+			ResumableBlobMinSize:        DefaultResumableBlobMinSize,
+		}, cfg.AppConfig.Replication)
+	})
+
+	t.Run("invalid duration warns and defaults", func(t *testing.T) {
+		cfg := &Config{AppConfig: AppConfig{Replication: ReplicationConfig{DeadLetterRetryInterval: "soon"}}}
+		warnings := validateAndEnforceReplicationConfig(cfg)
+		require.Len(t, warnings, 1)
+		require.Contains(t, warnings[0], "dead_letter_retry_interval")
+		require.Equal(t, DefaultDeadLetterRetryInterval.String(), cfg.AppConfig.Replication.DeadLetterRetryInterval)
+	})
+
+	t.Run("negative limit warns and defaults", func(t *testing.T) {
+		cfg := &Config{AppConfig: AppConfig{Replication: ReplicationConfig{MaxConcurrentBlobs: -1}}}
+		warnings := validateAndEnforceReplicationConfig(cfg)
+		require.Len(t, warnings, 1)
+		require.Contains(t, warnings[0], "max_concurrent_blobs")
+		require.Equal(t, DefaultMaxConcurrentBlobs, cfg.AppConfig.Replication.MaxConcurrentBlobs)
+	})
+
+	t.Run("per-group limit clamped to global", func(t *testing.T) {
+		cfg := &Config{AppConfig: AppConfig{Replication: ReplicationConfig{
+			MaxConcurrentImages:         2,
+			MaxConcurrentImagesPerGroup: 8,
+		}}}
+		warnings := validateAndEnforceReplicationConfig(cfg)
+		require.Len(t, warnings, 1)
+		require.Equal(t, 2, cfg.AppConfig.Replication.MaxConcurrentImagesPerGroup)
+	})
+
+	t.Run("invalid platforms are dropped", func(t *testing.T) {
+		cfg := &Config{AppConfig: AppConfig{Replication: ReplicationConfig{
+			Platforms: []string{"linux/arm64", "arm64", "linux/arm/v7"},
+			GroupPlatforms: map[string][]string{
+				"edge": {"linux/amd64", "a/b/c/d"},
+			},
+		}}}
+		warnings := validateAndEnforceReplicationConfig(cfg)
+		require.Len(t, warnings, 2)
+		require.Equal(t, []string{"linux/arm64", "linux/arm/v7"}, cfg.AppConfig.Replication.Platforms)
+		require.Equal(t, []string{"linux/amd64"}, cfg.AppConfig.Replication.GroupPlatforms["edge"])
+	})
+
+	t.Run("negative bandwidth warns and unlimits", func(t *testing.T) {
+		cfg := &Config{AppConfig: AppConfig{Replication: ReplicationConfig{
+			MaxBytesPerSecond:      -1,
+			GroupMaxBytesPerSecond: map[string]int64{"edge": -5, "core": 1 << 20},
+		}}}
+		warnings := validateAndEnforceReplicationConfig(cfg)
+		require.Len(t, warnings, 2)
+		require.Zero(t, cfg.AppConfig.Replication.MaxBytesPerSecond)
+		require.Zero(t, cfg.AppConfig.Replication.GroupMaxBytesPerSecond["edge"])
+		require.Equal(t, int64(1<<20), cfg.AppConfig.Replication.GroupMaxBytesPerSecond["core"])
+	})
+}
+
+func TestValidateAndEnforceDeletionConfig(t *testing.T) {
+	t.Run("unset values default silently", func(t *testing.T) {
+		cfg := &Config{}
+		require.Empty(t, validateAndEnforceDeletionConfig(cfg))
+		require.Equal(t, DeletionConfig{
+			GracePeriod:         DefaultDeletionGracePeriod.String(),
+			MaxDeletionsPerSync: DefaultMaxDeletionsPerSync,
+		}, cfg.AppConfig.Deletion)
+	})
+
+	t.Run("zero grace period is kept", func(t *testing.T) {
+		cfg := &Config{AppConfig: AppConfig{Deletion: DeletionConfig{GracePeriod: "0s"}}}
+		require.Empty(t, validateAndEnforceDeletionConfig(cfg))
+		require.Equal(t, "0s", cfg.AppConfig.Deletion.GracePeriod)
+	})
+
+	t.Run("invalid values warn and default", func(t *testing.T) {
+		cfg := &Config{AppConfig: AppConfig{Deletion: DeletionConfig{GracePeriod: "-1h", MaxDeletionsPerSync: -3}}}
+		warnings := validateAndEnforceDeletionConfig(cfg)
+		require.Len(t, warnings, 2)
+		require.Equal(t, DefaultDeletionGracePeriod.String(), cfg.AppConfig.Deletion.GracePeriod)
+		require.Equal(t, DefaultMaxDeletionsPerSync, cfg.AppConfig.Deletion.MaxDeletionsPerSync)
+	})
+}
+
+func TestValidateAndEnforceConfigRolloutConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg := &Config{}
+		require.Empty(t, validateAndEnforceConfigRolloutConfig(cfg))
+		require.Equal(t, ConfigRolloutConfig{
+			ProbationPeriod:  DefaultConfigProbationPeriod.String(),
+			FailureThreshold: DefaultConfigRollbackFailures,
+		}, cfg.AppConfig.ConfigRollout)
+	})
+
+	t.Run("invalid values warn and default", func(t *testing.T) {
+		cfg := &Config{AppConfig: AppConfig{ConfigRollout: ConfigRolloutConfig{ProbationPeriod: "0s", FailureThreshold: -1}}}
+		require.Len(t, validateAndEnforceConfigRolloutConfig(cfg), 2)
+		require.Equal(t, DefaultConfigProbationPeriod.String(), cfg.AppConfig.ConfigRollout.ProbationPeriod)
+		require.Equal(t, DefaultConfigRollbackFailures, cfg.AppConfig.ConfigRollout.FailureThreshold)
+	})
+}
+
+func TestValidatePullThroughConfig(t *testing.T) {
+	cfg := &Config{AppConfig: AppConfig{PullThrough: PullThroughConfig{
+		Enabled: true,
+		Allow:   []string{"library/*", "[", "tools/**"},
+	}}}
+	require.Len(t, validatePullThroughConfig(cfg), 1)
+	pt := cfg.AppConfig.PullThrough
+	require.Equal(t, []string{"library/*", "tools/**"}, pt.Allow)
+	require.Equal(t, DefaultPullThroughRetention.String(), pt.Retention)
+
+	require.True(t, pt.Allows("library/nginx"))
+	require.False(t, pt.Allows("library/team/nginx"), "a single star stays within one path segment")
+	require.True(t, pt.Allows("tools/team/builder"))
+	require.False(t, pt.Allows("tools"))
+	require.False(t, pt.Allows("private/app"))
+}
+
+func TestValidateAndEnforceLocalAPIConfig(t *testing.T) {
+	t.Run("disabled API gets the default address", func(t *testing.T) {
+		cfg := &Config{}
+		require.Empty(t, validateAndEnforceLocalAPIConfig(cfg))
+		require.Equal(t, DefaultLocalAPIAddress, cfg.AppConfig.LocalAPI.Address)
+	})
+
+	t.Run("missing token disables the API", func(t *testing.T) {
+		cfg := &Config{AppConfig: AppConfig{LocalAPI: LocalAPIConfig{Enabled: true, Address: "127.0.0.1:9191"}}}
+		require.Len(t, validateAndEnforceLocalAPIConfig(cfg), 1)
+		require.False(t, cfg.AppConfig.LocalAPI.Enabled)
+		require.Equal(t, "127.0.0.1:9191", cfg.AppConfig.LocalAPI.Address)
+	})
+
+	t.Run("short token and bad address warn", func(t *testing.T) {
+		cfg := &Config{AppConfig: AppConfig{LocalAPI: LocalAPIConfig{Enabled: true, Address: "9191", Token: "secret"}}}
+		require.Len(t, validateAndEnforceLocalAPIConfig(cfg), 2)
+		require.True(t, cfg.AppConfig.LocalAPI.Enabled)
+		require.Equal(t, DefaultLocalAPIAddress, cfg.AppConfig.LocalAPI.Address)
+	})
+}
+
+func TestValidateSyncWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []SyncWindow
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "time range", windows: []SyncWindow{{Start: "01:00", End: "05:00"}}},
+		{name: "interface only", windows: []SyncWindow{{Interfaces: []string{"eth0"}}}},
+		{name: "missing end", windows: []SyncWindow{{Start: "01:00"}}, wantErr: true},
+		{name: "malformed time", windows: []SyncWindow{{Start: "1am", End: "05:00"}}, wantErr: true},
+		{name: "out of range", windows: []SyncWindow{{Start: "01:00", End: "25:00"}}, wantErr: true},
+		{name: "empty interface", windows: []SyncWindow{{Interfaces: []string{" "}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSyncWindows(tt.windows)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateSignatureVerificationConfig(t *testing.T) {
+	t.Run("disabled policy is not checked", func(t *testing.T) {
+		sv := &SignatureVerificationConfig{PublicKeys: []string{"/does/not/exist.pub"}}
+		warnings, err := validateSignatureVerificationConfig(sv)
+		require.NoError(t, err)
+		require.Empty(t, warnings)
+	})
+
+	t.Run("mode defaults to enforce", func(t *testing.T) {
+		sv := &SignatureVerificationConfig{Enabled: true}
+		warnings, err := validateSignatureVerificationConfig(sv)
+		require.NoError(t, err)
+		require.Len(t, warnings, 1)
+		require.Equal(t, SignatureModeEnforce, sv.Mode)
+	})
+
+	t.Run("invalid mode warns", func(t *testing.T) {
+		sv := &SignatureVerificationConfig{Enabled: true, Mode: "permissive"}
+		warnings, err := validateSignatureVerificationConfig(sv)
+		require.NoError(t, err)
+		require.Contains(t, warnings[0], "permissive")
+		require.Equal(t, SignatureModeEnforce, sv.Mode)
+	})
+
+	t.Run("unreadable key is fatal", func(t *testing.T) {
+		sv := &SignatureVerificationConfig{Enabled: true, PublicKeys: []string{"/does/not/exist.pub"}}
+		_, err := validateSignatureVerificationConfig(sv)
+		require.Error(t, err)
+	})
+
+	t.Run("identity without subject is fatal", func(t *testing.T) {
+		sv := &SignatureVerificationConfig{Enabled: true, Identities: []CertificateIdentity{{Issuer: "https://accounts.example.com"}}}
+		_, err := validateSignatureVerificationConfig(sv)
+		require.Error(t, err)
+	})
+
+	t.Run("valid key file", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "cosign.pub")
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+		sv := &SignatureVerificationConfig{Enabled: true, Mode: SignatureModeAudit, PublicKeys: []string{path}}
+		warnings, err := validateSignatureVerificationConfig(sv)
+		require.NoError(t, err)
+		require.Empty(t, warnings)
+	})
+}
+
+func TestValidateRegistryTLSConfig(t *testing.T) {
+	certFile := filepath.Join(t.TempDir(), "tls.crt")
+	require.NoError(t, os.WriteFile(certFile, []byte("cert"), 0600))
+
+	tests := []struct {
+		name        string
+		registryTLS RegistryTLSConfig
+		credentials RegistryCredentials
+		fallback    bool
+		wantErr     string
+		warnings    int
+	}{
+		{name: "disabled", registryTLS: RegistryTLSConfig{}},
+		{name: "self-signed", registryTLS: RegistryTLSConfig{Mode: RegistryTLSSelfSigned}},
+		{name: "auth without TLS", registryTLS: RegistryTLSConfig{Auth: RegistryAuthHtpasswd}, wantErr: "requires registry_tls.mode"},
+		{name: "unknown mode", registryTLS: RegistryTLSConfig{Mode: "acme"}, wantErr: "invalid registry_tls.mode"},
+		{name: "files without key", registryTLS: RegistryTLSConfig{Mode: RegistryTLSFiles, CertFile: certFile}, wantErr: "key_file are required"},
+		{name: "files with missing CA", registryTLS: RegistryTLSConfig{Mode: RegistryTLSFiles, CertFile: certFile, KeyFile: certFile, CAFile: "/missing/ca.crt"}, wantErr: "no such file"},
+		{name: "files", registryTLS: RegistryTLSConfig{Mode: RegistryTLSFiles, CertFile: certFile, KeyFile: certFile}},
+		{name: "htpasswd without credentials", registryTLS: RegistryTLSConfig{Mode: RegistryTLSSelfSigned, Auth: RegistryAuthHtpasswd}, wantErr: "username and password"},
+		{
+			name:        "htpasswd",
+			registryTLS: RegistryTLSConfig{Mode: RegistryTLSSelfSigned, Auth: RegistryAuthHtpasswd},
+			credentials: RegistryCredentials{Username: "admin", Password: "secret"},
+		},
+		{name: "bearer without realm", registryTLS: RegistryTLSConfig{Mode: RegistryTLSSPIFFE, Auth: RegistryAuthBearer, Service: "satellite", BearerCertFile: certFile}, wantErr: "requires realm"},
+		{
+			name:        "bearer with authenticated pulls",
+			registryTLS: RegistryTLSConfig{Mode: RegistryTLSSPIFFE, Auth: RegistryAuthBearer, Realm: "https://auth.example.com/token", Service: "satellite", BearerCertFile: certFile, AuthenticatedPulls: true},
+			warnings:    1,
+		},
+		{
+			name:        "authenticated pulls with registry fallback",
+			registryTLS: RegistryTLSConfig{Mode: RegistryTLSSelfSigned, Auth: RegistryAuthHtpasswd, AuthenticatedPulls: true},
+			credentials: RegistryCredentials{Username: "admin", Password: "secret"},
+			fallback:    true,
+			warnings:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{AppConfig: AppConfig{
+				RegistryTLS:              tt.registryTLS,
+				LocalRegistryCredentials: tt.credentials,
+				RegistryFallback:         RegistryFallbackConfig{Enabled: tt.fallback},
+			}}
+			warnings, err := validateRegistryTLSConfig(cfg)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, warnings, tt.warnings)
+		})
+	}
+}